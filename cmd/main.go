@@ -4,15 +4,18 @@ import (
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
+	"time"
 
 	"github.com/STreeChin/contactapi/internal/controller"
 	"github.com/STreeChin/contactapi/internal/service"
 	"github.com/STreeChin/contactapi/pkg/cache"
 	"github.com/STreeChin/contactapi/pkg/config"
+	"github.com/STreeChin/contactapi/pkg/entities"
 	"github.com/STreeChin/contactapi/pkg/log"
 	"github.com/STreeChin/contactapi/pkg/repository"
 	"github.com/STreeChin/contactapi/pkg/route"
 	"github.com/STreeChin/contactapi/pkg/route/middleware"
+	"github.com/pkg/errors"
 )
 
 func main() {
@@ -22,12 +25,37 @@ func main() {
 	}
 	logger := log.NewLogger(cfg)
 	rep := repository.NewRepository(logger, cfg)
+	go func() {
+		if err := rep.Reencrypt(); err != nil {
+			logger.Errorf("re-encryption stopped, it resumes at the next start: %+v", err)
+		}
+	}()
 	cacher := cache.NewCache(logger, cfg)
 	srv := service.NewContactService(logger, cfg, cacher, rep)
 	ctrl := controller.NewContactController(logger, srv)
-	rtr := route.NewRouter(ctrl)
+	//the middlewares skip redis too while the breaker of the service is open
+	breaker := srv.CacheBreaker()
+	limiter := middleware.NewRateLimit(logger, cfg, middleware.GuardBuckets(cacher, breaker))
+	meter := middleware.NewMeter(logger, cfg, middleware.GuardUsages(cacher, breaker))
+	rtr := route.NewRouter(ctrl, limiter, meter)
+	go func() {
+		for range time.Tick(service.UsageFlushInterval) {
+			if err := srv.FlushUsage(); err != nil {
+				logger.Errorf("usage flush, retried at the next one: %+v", err)
+			}
+		}
+	}()
+	go func() {
+		for range time.Tick(service.InvalidationRetryInterval) {
+			//nothing to log while the breaker is open, it logged redis is down
+			if err := srv.RetryInvalidations(); err != nil && errors.Cause(err) != entities.ErrCacheUnavailable {
+				logger.Warnf("cache invalidations, retried at the next one: %+v", err)
+			}
+		}
+	}()
 
-	authMdw := middleware.NewAuth(logger, rep)
+	authMdw := middleware.NewAuth(logger, rep, middleware.GuardPrincipals(cacher, breaker))
+	go authMdw.WatchRevocations()
 	rtr.Use(authMdw.Middleware)
 
 	port := cfg.Host.Port
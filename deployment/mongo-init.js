@@ -17,6 +17,7 @@ db.createCollection("contactInfo");
 
 db.createCollection("apiKey"); 
 
+// the first api key, hashed at the first start of the api: create the others by POST /v1/admin/apikeys
 db.apiKey.insertOne( 
     { 
         contactid: BinData(0,"0D60XbKwTnK7OOsG2pMR43LobAnUjJ3tN9E+ARIfpHCCm3yGjY0kTpZLHFvjt2Hu"), 
@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/pkg/errors"
+)
+
+//GetReencryptionCtrl: get the progress of the re-encryption of the stored values by the current key
+func (cc *contactController) GetReencryptionCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	progress, err := cc.contactService.GetReencryption()
+	if err != nil {
+		if errors.Cause(err) == entities.ErrReencryptionNotRun {
+			cc.handleError(w, http.StatusNotFound, "Re-encryption has not run.")
+			return
+		}
+
+		cc.log.Errorf("GetReencryptionCtrl: %+v", err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cc.buildResponse(w, progress)
+}
@@ -0,0 +1,60 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/STreeChin/contactapi/internal/controller"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestGetReencryptionCtrl Use GoConvey test framework
+func TestGetReencryptionCtrl(t *testing.T) {
+	Convey("GetReencryptionCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		act := "GET"
+		reencryptionURL := "/v1/admin/reencryption"
+
+		Convey("UT Normal Case1: 200, the progress", func() {
+			req, w := formHTTTest(act, reencryptionURL, nil)
+			progress := &entities.Reencryption{KeyID: "2020-06", Collection: "apiKey", Scanned: 1200, Rewritten: 1100, Failed: 1}
+			mockSrv.EXPECT().GetReencryption().Return(progress, nil)
+
+			cCtrl.GetReencryptionCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			result := new(entities.Reencryption)
+			_ = json.NewDecoder(w.Body).Decode(result)
+			So(result, ShouldResemble, progress)
+		})
+
+		Convey("UT AbNormal Case1: 404, the job has never run", func() {
+			req, w := formHTTTest(act, reencryptionURL, nil)
+			mockSrv.EXPECT().GetReencryption().Return(nil, errors.Wrap(entities.ErrReencryptionNotRun, "service GetReencryption"))
+
+			cCtrl.GetReencryptionCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("UT AbNormal Case2: 500, the db fails", func() {
+			req, w := formHTTTest(act, reencryptionURL, nil)
+			mockSrv.EXPECT().GetReencryption().Return(nil, errors.New("db down"))
+
+			cCtrl.GetReencryptionCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+		})
+	})
+}
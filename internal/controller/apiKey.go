@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/route/middleware"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+//GetAPIKeysCtrl: get the api keys of the account, without their secrets
+func (cc *contactController) GetAPIKeysCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	apiKeys, err := cc.contactService.GetAPIKeys(middleware.AccountID(r.Context()))
+	if err != nil {
+		cc.log.Errorf("GetAPIKeysCtrl: %+v", err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	body := map[string][]*entities.APIKey{"api_keys": apiKeys}
+	cc.buildResponse(w, body)
+}
+
+//CreateAPIKeyCtrl: create an api key with the name, the scopes, the rate limits, the quotas and the expiry of the request, the key is only shown in this response
+func (cc *contactController) CreateAPIKeyCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	dst := new(entities.ReqAPIKey)
+	err := json.NewDecoder(r.Body).Decode(dst)
+	if err != nil || strings.TrimSpace(dst.Name) == "" {
+		cc.log.Infof("CreateAPIKeyCtrl: %+v", err)
+		cc.handleError(w, http.StatusBadRequest, "No api key name provided.")
+		return
+	}
+	if len(dst.Scopes) == 0 {
+		cc.log.Infoln("CreateAPIKeyCtrl: no scopes")
+		cc.handleError(w, http.StatusBadRequest, "No api key scopes provided.")
+		return
+	}
+	for _, scope := range dst.Scopes {
+		if !entities.ValidScope(scope) {
+			cc.log.Infof("CreateAPIKeyCtrl: invalid scope %q", scope)
+			cc.handleError(w, http.StatusBadRequest, "Invalid scope provided: "+scope+".")
+			return
+		}
+	}
+	for class, limit := range dst.RateLimits {
+		if !entities.ValidRateClass(class) || limit <= 0 {
+			cc.log.Infof("CreateAPIKeyCtrl: invalid rate limit %q: %d", class, limit)
+			cc.handleError(w, http.StatusBadRequest, "Invalid rate limit provided: "+class+".")
+			return
+		}
+	}
+	for counter, quota := range dst.Quotas {
+		if !entities.ValidUsageCounter(counter) || quota <= 0 {
+			cc.log.Infof("CreateAPIKeyCtrl: invalid quota %q: %d", counter, quota)
+			cc.handleError(w, http.StatusBadRequest, "Invalid quota provided: "+counter+".")
+			return
+		}
+	}
+	var expiresAt time.Time
+	if dst.ExpiresAt != nil {
+		if !dst.ExpiresAt.After(time.Now()) {
+			cc.log.Infoln("CreateAPIKeyCtrl: expires_at is not in the future")
+			cc.handleError(w, http.StatusBadRequest, "Invalid expires_at provided.")
+			return
+		}
+		expiresAt = *dst.ExpiresAt
+	}
+
+	apiKey, err := cc.contactService.CreateAPIKey(middleware.AccountID(r.Context()), strings.TrimSpace(dst.Name), dst.Scopes, dst.RateLimits, dst.Quotas, expiresAt)
+	if err != nil {
+		cc.log.Errorf("CreateAPIKeyCtrl: %+v", err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cc.buildResponse(w, apiKey)
+}
+
+//RotateAPIKeyCtrl: replace the api key by a new one, the key is only shown in this response
+func (cc *contactController) RotateAPIKeyCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	apiKey, err := cc.contactService.RotateAPIKey(middleware.AccountID(r.Context()), mux.Vars(r)["key_id"])
+	if err != nil {
+		cc.handleAPIKeyError(w, "RotateAPIKeyCtrl", err)
+		return
+	}
+
+	cc.buildResponse(w, apiKey)
+}
+
+//RevokeAPIKeyCtrl: revoke the api key at once
+func (cc *contactController) RevokeAPIKeyCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	err := cc.contactService.RevokeAPIKey(middleware.AccountID(r.Context()), mux.Vars(r)["key_id"])
+	if err != nil {
+		cc.handleAPIKeyError(w, "RevokeAPIKeyCtrl", err)
+		return
+	}
+
+	cc.buildResponse(w, map[string]string{})
+}
+
+func (cc *contactController) handleAPIKeyError(w http.ResponseWriter, name string, err error) {
+	switch errors.Cause(err) {
+	case entities.ErrAPIKeyNotFound:
+		cc.log.Infof("%s: %+v", name, err)
+		cc.handleError(w, http.StatusNotFound, "API key could not be found.")
+	case entities.ErrAPIKeyRevoked:
+		cc.log.Infof("%s: %+v", name, err)
+		cc.handleError(w, http.StatusConflict, "API key has been revoked.")
+	default:
+		cc.log.Errorf("%s: %+v", name, err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+	}
+}
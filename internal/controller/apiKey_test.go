@@ -0,0 +1,124 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"github.com/STreeChin/contactapi/internal/controller"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestAPIKeysCtrl Use GoConvey test framework
+func TestAPIKeysCtrl(t *testing.T) {
+	Convey("APIKeysCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		keysURL := "/v1/admin/apikeys"
+		created := time.Date(2020, 5, 1, 8, 0, 0, 0, time.UTC)
+		apiKey := &entities.APIKey{KeyID: "ak_0123456789abcdef", Name: "zapier", Scopes: []string{entities.ScopeContactsRead}, Key: "ak_0123456789abcdef.secret", CreatTime: created, ExpiresAt: created.AddDate(1, 0, 0)}
+
+		Convey("UT Normal Case1: 200, create, the key is in the response", func() {
+			req, w := formHTTTest("POST", keysURL, []byte(`{"name": " zapier ", "scopes": ["contacts:read"], "rate_limits": {"read": 600}, "quotas": {"contacts": 1000}}`))
+			mockSrv.EXPECT().CreateAPIKey("", "zapier", []string{entities.ScopeContactsRead}, map[string]int{entities.RateClassRead: 600}, map[string]int64{entities.UsageContacts: 1000}, time.Time{}).Return(apiKey, nil)
+
+			cCtrl.CreateAPIKeyCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			result := new(entities.APIKey)
+			_ = json.NewDecoder(w.Body).Decode(result)
+			So(result, ShouldResemble, apiKey)
+		})
+
+		Convey("UT Normal Case2: 200, list, no secret", func() {
+			req, w := formHTTTest("GET", keysURL, nil)
+			listed := *apiKey
+			listed.Key = ""
+			mockSrv.EXPECT().GetAPIKeys("").Return([]*entities.APIKey{&listed}, nil)
+
+			cCtrl.GetAPIKeysCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			result := map[string][]map[string]interface{}{}
+			_ = json.NewDecoder(w.Body).Decode(&result)
+			So(len(result["api_keys"]), ShouldEqual, 1)
+			So(result["api_keys"][0], ShouldNotContainKey, "key")
+		})
+
+		Convey("UT AbNormal Case1: 400, no name, no or unknown scopes, an unknown or a non positive rate limit or quota or an expiry in the past", func() {
+			for _, body := range []string{
+				`{}`, `{"name": " ", "scopes": ["admin"]}`, `not json`,
+				`{"name": "zapier"}`, `{"name": "zapier", "scopes": ["contacts:read", "everything"]}`,
+				`{"name": "zapier", "scopes": ["admin"], "rate_limits": {"search": 10}}`,
+				`{"name": "zapier", "scopes": ["admin"], "rate_limits": {"bulk": 0}}`,
+				`{"name": "zapier", "scopes": ["admin"], "quotas": {"requests": 10}}`,
+				`{"name": "zapier", "scopes": ["admin"], "quotas": {"writes": -1}}`,
+				`{"name": "zapier", "scopes": ["admin"], "expires_at": "2015-01-01T00:00:00Z"}`,
+			} {
+				req, w := formHTTTest("POST", keysURL, []byte(body))
+
+				cCtrl.CreateAPIKeyCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			}
+		})
+
+		Convey("rotate and revoke", func() {
+			defer monkey.UnpatchAll()
+			monkey.Patch(mux.Vars, func(r *http.Request) map[string]string {
+				return map[string]string{"key_id": apiKey.KeyID}
+			})
+
+			Convey("UT Normal Case3: 200, rotate, the new key is in the response", func() {
+				req, w := formHTTTest("POST", "/v1/admin/apikey/ak_0123456789abcdef/rotate", nil)
+				mockSrv.EXPECT().RotateAPIKey("", apiKey.KeyID).Return(apiKey, nil)
+
+				cCtrl.RotateAPIKeyCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("UT Normal Case4: 200, revoke", func() {
+				req, w := formHTTTest("DELETE", "/v1/admin/apikey/ak_0123456789abcdef", nil)
+				mockSrv.EXPECT().RevokeAPIKey("", apiKey.KeyID).Return(nil)
+
+				cCtrl.RevokeAPIKeyCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("UT AbNormal Case2: 404, the key is not in the account", func() {
+				req, w := formHTTTest("DELETE", "/v1/admin/apikey/ak_0123456789abcdef", nil)
+				mockSrv.EXPECT().RevokeAPIKey("", apiKey.KeyID).Return(errors.Wrap(entities.ErrAPIKeyNotFound, "service RevokeAPIKey"))
+
+				cCtrl.RevokeAPIKeyCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+
+			Convey("UT AbNormal Case3: 409, rotate a revoked key", func() {
+				req, w := formHTTTest("POST", "/v1/admin/apikey/ak_0123456789abcdef/rotate", nil)
+				mockSrv.EXPECT().RotateAPIKey("", apiKey.KeyID).Return(nil, errors.Wrap(entities.ErrAPIKeyRevoked, "service RotateAPIKey"))
+
+				cCtrl.RotateAPIKeyCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusConflict)
+				result := map[string]string{}
+				_ = json.NewDecoder(w.Body).Decode(&result)
+				So(result["error"], ShouldEqual, "Conflict")
+			})
+		})
+	})
+}
@@ -2,12 +2,15 @@ package controller
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
-	"strconv"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/STreeChin/contactapi/pkg/customfield"
 	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/route/middleware"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -18,10 +21,45 @@ import (
 
 //ContactService interface
 type ContactService interface {
-	AddOrUpdateContact(contact *entities.Contact) (string, error)
-	GetOneContact(key, value string) (*entities.Contact, error)
+	AddOrUpdateContact(account string, contact *entities.Contact) (string, bool, error)
+	GetOneContact(account, key, value string) (*entities.Contact, error)
+	GetAllContacts(account, bookmark string) (*entities.ContactPage, error)
+	CheckBulkLimit(count int) error
+	AddBulkContacts(account string, contacts []*entities.Contact) ([]entities.BulkContactResult, error)
+	DeleteContact(account, key, value string) error
+	PatchContact(account, key, value string, patch map[string]interface{}, customTypes map[string]string) (*entities.Contact, error)
+	UnsubscribeContact(account, key, value string, unsub *entities.Unsubscription) error
+	ResubscribeContact(account, key, value string) error
+	GetCustomFields(account string) ([]*entities.CustomField, error)
+	GetLists(account string) ([]*entities.List, error)
+	AddList(account, title string) (*entities.List, error)
+	GetListContacts(account, listID, bookmark string) (*entities.ContactPage, error)
+	AddContactToList(account, listID, key, value string) error
+	RemoveContactFromList(account, listID, key, value string) error
+	CheckContactInList(account, listID, key, value string) error
+	GetSegments(account string) ([]*entities.Segment, error)
+	AddSegment(account, name, expression string) (*entities.Segment, error)
+	CountSegmentContacts(account, segmentID string) (int64, error)
+	GetSegmentContacts(account, segmentID, bookmark string) (*entities.ContactPage, error)
+	GetReencryption() (*entities.Reencryption, error)
+	EraseContact(account, key, value string) (*entities.Erasure, error)
+	GetAPIKeys(account string) ([]*entities.APIKey, error)
+	CreateAPIKey(account, name string, scopes []string, rateLimits map[string]int, quotas map[string]int64, expiresAt time.Time) (*entities.APIKey, error)
+	RotateAPIKey(account, keyID string) (*entities.APIKey, error)
+	RevokeAPIKey(account, keyID string) error
+	GetUsageReport(account, keyID string, from, to time.Time) (*entities.UsageReport, error)
+	GetHealth() *entities.Health
 }
 
+//the unsubscription details if the request does not tell
+const (
+	defaultUnsubscribeBy     = "api"
+	defaultUnsubscribeSource = "api"
+)
+
+//customPlain the value of the "custom" query flag to get the custom fields decoded, not in the "type--Name" format
+const customPlain = "plain"
+
 type contactController struct {
 	log            *logrus.Logger
 	contactService ContactService
@@ -48,7 +86,7 @@ func (cc *contactController) GetOneContactCtrl(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	respContact, err := cc.contactService.GetOneContact(key, contactIDOrEmail)
+	respContact, err := cc.contactService.GetOneContact(middleware.AccountID(r.Context()), key, contactIDOrEmail)
 	if err != nil {
 		if errors.Cause(err) == mongo.ErrNoDocuments {
 			cc.log.Infof("GetOneContactCtrl: %+v", err)
@@ -61,7 +99,7 @@ func (cc *contactController) GetOneContactCtrl(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	cc.buildResponse(w, respContact)
+	cc.buildResponse(w, cc.wireContact(r, respContact))
 }
 
 //AddOrUpdateContactCtrl: add or update contact
@@ -72,6 +110,11 @@ func (cc *contactController) AddOrUpdateContactCtrl(w http.ResponseWriter, r *ht
 	}
 
 	contact, err := cc.parseContactFromReq(r)
+	if fieldErrs, ok := errors.Cause(err).(customfield.Errors); ok {
+		cc.log.Infof("AddOrUpdateContactCtrl: %+v", err)
+		cc.handleFieldErrors(w, fieldErrs)
+		return
+	}
 	if err != nil {
 		cc.log.Errorf("AddOrUpdateContactCtrl: %+v", err)
 		cc.handleError(w, http.StatusInternalServerError, "Internal Error")
@@ -84,17 +127,329 @@ func (cc *contactController) AddOrUpdateContactCtrl(w http.ResponseWriter, r *ht
 		return
 	}
 
-	contactID, err := cc.contactService.AddOrUpdateContact(contact)
+	contactID, created, err := cc.contactService.AddOrUpdateContact(middleware.AccountID(r.Context()), contact)
 	if err != nil {
+		if roErr, ok := errors.Cause(err).(*entities.ReadOnlyFieldError); ok {
+			cc.log.Infof("AddOrUpdateContactCtrl: %+v", err)
+			cc.handleError(w, http.StatusBadRequest, "The field "+roErr.Field+" is read-only.")
+			return
+		}
+		if cfErr, ok := errors.Cause(err).(*entities.CustomFieldTypeError); ok {
+			cc.log.Infof("AddOrUpdateContactCtrl: %+v", err)
+			cc.handleError(w, http.StatusBadRequest, "The custom field "+cfErr.Name+" is of type "+cfErr.FieldType+".")
+			return
+		}
 		cc.log.Errorf("AddOrUpdateContactCtrl: %+v", err)
 		cc.handleError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	body := map[string]string{"contact_id": contactID}
+	status := entities.ContactUpdated
+	if created {
+		status = entities.ContactCreated
+		middleware.AddUsage(r.Context(), 0, 1)
+	}
+	body := map[string]string{"contact_id": contactID, "status": status}
 	cc.buildResponse(w, body)
 }
 
+//DeleteContactCtrl: delete one contact by contact id or email
+func (cc *contactController) DeleteContactCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	contactIDOrEmail := mux.Vars(r)["contact_id_or_email"]
+
+	key, check := cc.checkContactIDOrEmail(contactIDOrEmail)
+	if !check {
+		cc.log.Infoln("Invalid contact_id_or_email value provided")
+		cc.handleError(w, http.StatusBadRequest, "Invalid contact_id_or_email value provided.")
+		return
+	}
+
+	err := cc.contactService.DeleteContact(middleware.AccountID(r.Context()), key, contactIDOrEmail)
+	if err != nil {
+		if errors.Cause(err) == mongo.ErrNoDocuments {
+			cc.log.Infof("DeleteContactCtrl: %+v", err)
+			cc.handleError(w, http.StatusNotFound, "Contact could not be found.")
+			return
+		}
+
+		cc.log.Errorf("DeleteContactCtrl: %+v", err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cc.buildResponse(w, map[string]string{})
+}
+
+//PatchContactCtrl: partial update of one contact with the json merge patch (RFC 7386)
+func (cc *contactController) PatchContactCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	contactIDOrEmail := mux.Vars(r)["contact_id_or_email"]
+
+	key, check := cc.checkContactIDOrEmail(contactIDOrEmail)
+	if !check {
+		cc.log.Infoln("Invalid contact_id_or_email value provided")
+		cc.handleError(w, http.StatusBadRequest, "Invalid contact_id_or_email value provided.")
+		return
+	}
+
+	patch := map[string]interface{}{}
+	err := json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		cc.log.Infof("PatchContactCtrl: %+v", err)
+		cc.handleError(w, http.StatusBadRequest, "Invalid merge patch provided.")
+		return
+	}
+	var customTypes map[string]string
+	if custom, ok := patch["custom"].(map[string]interface{}); ok {
+		patch["custom"], customTypes, err = cc.parseCustomPatch(custom)
+		if err != nil {
+			cc.log.Infof("PatchContactCtrl: %+v", err)
+			cc.handleFieldErrors(w, err.(customfield.Errors))
+			return
+		}
+	}
+
+	contact, err := cc.contactService.PatchContact(middleware.AccountID(r.Context()), key, contactIDOrEmail, patch, customTypes)
+	if err != nil {
+		if roErr, ok := errors.Cause(err).(*entities.ReadOnlyFieldError); ok {
+			cc.log.Infof("PatchContactCtrl: %+v", err)
+			cc.handleError(w, http.StatusBadRequest, "The field "+roErr.Field+" is read-only.")
+			return
+		}
+		if cfErr, ok := errors.Cause(err).(*entities.CustomFieldTypeError); ok {
+			cc.log.Infof("PatchContactCtrl: %+v", err)
+			cc.handleError(w, http.StatusBadRequest, "The custom field "+cfErr.Name+" is of type "+cfErr.FieldType+".")
+			return
+		}
+
+		switch errors.Cause(err) {
+		case mongo.ErrNoDocuments:
+			cc.log.Infof("PatchContactCtrl: %+v", err)
+			cc.handleError(w, http.StatusNotFound, "Contact could not be found.")
+		case entities.ErrInvalidPatch:
+			cc.log.Infof("PatchContactCtrl: %+v", err)
+			cc.handleError(w, http.StatusBadRequest, "Invalid merge patch provided.")
+		default:
+			cc.log.Errorf("PatchContactCtrl: %+v", err)
+			cc.handleError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	cc.buildResponse(w, cc.wireContact(r, contact))
+}
+
+//UnsubscribeContactCtrl: unsubscribe one contact, the body may tell who unsubscribed and from which source
+func (cc *contactController) UnsubscribeContactCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	contactIDOrEmail := mux.Vars(r)["contact_id_or_email"]
+
+	key, check := cc.checkContactIDOrEmail(contactIDOrEmail)
+	if !check {
+		cc.log.Infoln("Invalid contact_id_or_email value provided")
+		cc.handleError(w, http.StatusBadRequest, "Invalid contact_id_or_email value provided.")
+		return
+	}
+
+	unsub := new(entities.Unsubscription)
+	err := json.NewDecoder(r.Body).Decode(unsub)
+	if err != nil && err != io.EOF {
+		cc.log.Infof("UnsubscribeContactCtrl: %+v", err)
+		cc.handleError(w, http.StatusBadRequest, "Invalid unsubscribe details provided.")
+		return
+	}
+	if unsub.By == "" {
+		unsub.By = defaultUnsubscribeBy
+	}
+	if unsub.Source == "" {
+		unsub.Source = defaultUnsubscribeSource
+	}
+
+	err = cc.contactService.UnsubscribeContact(middleware.AccountID(r.Context()), key, contactIDOrEmail, unsub)
+	cc.handleSubscriptionResult(w, "UnsubscribeContactCtrl", err)
+}
+
+//ResubscribeContactCtrl: subscribe one contact again
+func (cc *contactController) ResubscribeContactCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	contactIDOrEmail := mux.Vars(r)["contact_id_or_email"]
+
+	key, check := cc.checkContactIDOrEmail(contactIDOrEmail)
+	if !check {
+		cc.log.Infoln("Invalid contact_id_or_email value provided")
+		cc.handleError(w, http.StatusBadRequest, "Invalid contact_id_or_email value provided.")
+		return
+	}
+
+	err := cc.contactService.ResubscribeContact(middleware.AccountID(r.Context()), key, contactIDOrEmail)
+	cc.handleSubscriptionResult(w, "ResubscribeContactCtrl", err)
+}
+
+func (cc *contactController) handleSubscriptionResult(w http.ResponseWriter, name string, err error) {
+	if err != nil {
+		if errors.Cause(err) == mongo.ErrNoDocuments {
+			cc.log.Infof("%s: %+v", name, err)
+			cc.handleError(w, http.StatusNotFound, "Contact could not be found.")
+			return
+		}
+
+		cc.log.Errorf("%s: %+v", name, err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cc.buildResponse(w, map[string]string{})
+}
+
+//GetAllContactsCtrl: get the first page of contacts
+func (cc *contactController) GetAllContactsCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	cc.getContactsPage(w, r, "")
+}
+
+//GetAllContactsBookmarkCtrl: get the page of contacts after the bookmark
+func (cc *contactController) GetAllContactsBookmarkCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	bookmark := mux.Vars(r)["bookmark"]
+	if bookmark == "" {
+		cc.log.Infoln("GetAllContactsBookmarkCtrl: bookmark is nil")
+		cc.handleError(w, http.StatusBadRequest, "Invalid bookmark provided.")
+		return
+	}
+
+	cc.getContactsPage(w, r, bookmark)
+}
+
+func (cc *contactController) getContactsPage(w http.ResponseWriter, r *http.Request, bookmark string) {
+	page, err := cc.contactService.GetAllContacts(middleware.AccountID(r.Context()), bookmark)
+	if err != nil {
+		if errors.Cause(err) == entities.ErrInvalidBookmark {
+			cc.log.Infof("getContactsPage: %+v", err)
+			cc.handleError(w, http.StatusBadRequest, "Invalid bookmark provided.")
+			return
+		}
+
+		cc.log.Errorf("getContactsPage: %+v", err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cc.buildResponse(w, cc.wirePage(r, page))
+}
+
+//AddBulkContactsCtrl: add or update the contacts in one request
+func (cc *contactController) AddBulkContactsCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	dst := new(entities.ReqContacts)
+	err := json.NewDecoder(r.Body).Decode(dst)
+	if err != nil {
+		cc.log.Infof("AddBulkContactsCtrl: %+v", err)
+		cc.handleError(w, http.StatusBadRequest, "Invalid contacts provided.")
+		return
+	}
+	if len(dst.Contacts) == 0 {
+		cc.log.Infoln("AddBulkContactsCtrl: request contacts is nil")
+		cc.handleError(w, http.StatusBadRequest, "No contact details provided.")
+		return
+	}
+	//the limit is on the contacts of the request, the ones failing to parse too
+	if err := cc.contactService.CheckBulkLimit(len(dst.Contacts)); err != nil {
+		cc.log.Infof("AddBulkContactsCtrl: %+v", err)
+		cc.handleError(w, http.StatusBadRequest, "Too many contacts provided.")
+		return
+	}
+	//each contact may be a new one, the quotas must take them all before the write
+	if !middleware.ReserveUsage(w, r, int64(len(dst.Contacts)), int64(len(dst.Contacts))) {
+		cc.log.Infof("AddBulkContactsCtrl: %d contacts over the quota", len(dst.Contacts))
+		return
+	}
+
+	//the contacts failed to parse are reported in their place, the others go to the service
+	results := make([]entities.BulkContactResult, len(dst.Contacts))
+	parsed := make([]*entities.Contact, 0, len(dst.Contacts))
+	parsedIndex := make([]int, 0, len(dst.Contacts))
+	for i := range dst.Contacts {
+		contact := &dst.Contacts[i]
+		if err := cc.parseCustom(contact); err != nil {
+			results[i] = entities.BulkContactResult{Email: contact.Email, Status: entities.ContactFailed, Error: err.Error()}
+			continue
+		}
+		parsed = append(parsed, contact)
+		parsedIndex = append(parsedIndex, i)
+	}
+
+	written, err := cc.contactService.AddBulkContacts(middleware.AccountID(r.Context()), parsed)
+	if err != nil {
+		if errors.Cause(err) == entities.ErrTooManyContacts {
+			cc.log.Infof("AddBulkContactsCtrl: %+v", err)
+			cc.handleError(w, http.StatusBadRequest, "Too many contacts provided.")
+			return
+		}
+
+		cc.log.Errorf("AddBulkContactsCtrl: %+v", err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var created int64
+	for j, result := range written {
+		results[parsedIndex[j]] = result
+		if result.Status == entities.ContactCreated {
+			created++
+		}
+	}
+	middleware.AddUsage(r.Context(), int64(len(dst.Contacts)), created)
+
+	body := map[string][]entities.BulkContactResult{"contacts": results}
+	cc.buildResponse(w, body)
+}
+
+//GetCustomFieldsCtrl: get the custom fields registered in the account
+func (cc *contactController) GetCustomFieldsCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	fields, err := cc.contactService.GetCustomFields(middleware.AccountID(r.Context()))
+	if err != nil {
+		cc.log.Errorf("GetCustomFieldsCtrl: %+v", err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cc.buildResponse(w, fields)
+}
+
 func (cc *contactController) checkContactIDOrEmail(value string) (key string, check bool) {
 	err := validator.New().Var(value, "required,email")
 	if err == nil {
@@ -121,35 +476,85 @@ func (cc *contactController) parseContactFromReq(r *http.Request) (*entities.Con
 	dst := new(entities.ReqContact)
 
 	err = json.NewDecoder(r.Body).Decode(dst)
-	for k, v := range dst.Contact.Custom {
-		l := strings.SplitAfterN(k, "--", 2)
-		l[0] = strings.Replace(l[0], "--", "", -1)
-		l[1] = strings.Replace(l[1], "--", " ", -1)
-		var newValue interface{}
-		switch l[0] {
-		case "integer":
-			newValue, err = strconv.Atoi(v.(string))
-		case "boolean":
-			newValue, err = strconv.ParseBool(v.(string))
-		case "string":
-
-		case "date":
-			loc, _ := time.LoadLocation("Local")
-			newValue, err = time.ParseInLocation("2006-01-02 15:04:05", v.(string), loc)
-		case "float":
-			newValue, err = strconv.ParseFloat(v.(string), 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode contact")
+	}
 
-		default:
-			cc.log.Warnln("The type of custom field is error")
-			return nil, errors.New("the type of custom field is error")
+	err = cc.parseCustom(&dst.Contact)
+	return &dst.Contact, err
+}
+
+//parseCustom decode all the custom fields of the contact, the error is customfield.Errors
+func (cc *contactController) parseCustom(contact *entities.Contact) error {
+	if len(contact.Custom) == 0 {
+		return nil
+	}
+
+	values, types, err := customfield.Decode(contact.Custom)
+	if err != nil {
+		return err
+	}
+	contact.Custom = values
+	contact.CustomTypes = types
+
+	return nil
+}
+
+//parseCustomPatch parse the custom fields of the merge patch and their types, a null value is kept to clear the field
+func (cc *contactController) parseCustomPatch(custom map[string]interface{}) (map[string]interface{}, map[string]string, error) {
+	var errs customfield.Errors
+	cleared := make(map[string]bool, len(custom))
+	set := make(map[string]interface{}, len(custom))
+	for k, v := range custom {
+		if v != nil {
+			set[k] = v
+			continue
+		}
+		_, name, err := customfield.SplitKey(k)
+		if err != nil {
+			errs = append(errs, &customfield.FieldError{Key: k, Message: err.Error()})
+			continue
 		}
+		cleared[name] = true
+	}
 
-		dst.Contact.Custom[l[1]] = newValue
-		delete(dst.Contact.Custom, k)
-		break
+	parsed, types, err := customfield.Decode(set)
+	if err != nil {
+		errs = append(errs, err.(customfield.Errors)...)
+	}
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Key < errs[j].Key })
+		return nil, nil, errs
 	}
 
-	return &dst.Contact, err
+	for name := range cleared {
+		parsed[name] = nil
+	}
+
+	return parsed, types, nil
+}
+
+//wireContact the contact in the response, the custom fields in the "type--Name" format unless the request asks for the plain ones
+func (cc *contactController) wireContact(r *http.Request, contact *entities.Contact) *entities.Contact {
+	if contact == nil || contact.Custom == nil || r.URL.Query().Get("custom") == customPlain {
+		return contact
+	}
+
+	wire := *contact
+	wire.Custom = customfield.Encode(contact.Custom, contact.CustomTypes)
+
+	return &wire
+}
+
+//wirePage the page in the response, every contact by wireContact
+func (cc *contactController) wirePage(r *http.Request, page *entities.ContactPage) *entities.ContactPage {
+	wirePage := *page
+	wirePage.Contacts = make([]*entities.Contact, len(page.Contacts))
+	for i, contact := range page.Contacts {
+		wirePage.Contacts[i] = cc.wireContact(r, contact)
+	}
+
+	return &wirePage
 }
 
 func (cc *contactController) buildResponse(w http.ResponseWriter, body interface{}) {
@@ -158,6 +563,19 @@ func (cc *contactController) buildResponse(w http.ResponseWriter, body interface
 	_ = json.NewEncoder(w).Encode(body)
 }
 
+//handleFieldErrors 400 with the error of each custom field can not be decoded
+func (cc *contactController) handleFieldErrors(w http.ResponseWriter, errs customfield.Errors) {
+	body := map[string]interface{}{
+		"error":   "Bad Request",
+		"message": "Invalid custom field provided.",
+		"fields":  errs,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
 func (cc *contactController) handleError(w http.ResponseWriter, code int, msg string) {
 	body := map[string]string{}
 
@@ -166,6 +584,8 @@ func (cc *contactController) handleError(w http.ResponseWriter, code int, msg st
 		body["error"] = "Bad Request"
 	case http.StatusNotFound:
 		body["error"] = "Not Found"
+	case http.StatusConflict:
+		body["error"] = "Conflict"
 	case http.StatusInternalServerError:
 		body["error"] = "Internal Server Error"
 	default:
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"bou.ke/monkey"
 	"github.com/STreeChin/contactapi/internal/controller"
@@ -26,8 +27,8 @@ var mockContact = entities.Contact{
 	LastName:   "Gr",
 	Type:       "Contact",
 	Phone:      "4159945916",
-	CreatTime:  "2015-04-29T23:15:25.347Z",
-	UpdateTime: "2015-04-29T23:15:25.347Z",
+	CreatTime:  time.Date(2015, 4, 29, 23, 15, 25, 347000000, time.UTC),
+	UpdateTime: time.Date(2015, 4, 29, 23, 15, 25, 347000000, time.UTC),
 	LeadSource: "Autopilot",
 	Status:     "Testing",
 	Company:    "Magpie API",
@@ -46,7 +47,8 @@ var postJSONStr = []byte(
 				}
 			}`)
 
-var postContact = entities.Contact{FirstName: "Slarty", LastName: "Bartfast", Email: "Slarty@test.com", Custom: map[string]interface{}{"Test Field": 1024}}
+var postContact = entities.Contact{FirstName: "Slarty", LastName: "Bartfast", Email: "Slarty@test.com", Custom: map[string]interface{}{"Test Field": 1024},
+	CustomTypes: map[string]string{"Test Field": "integer"}}
 
 func formHTTTest(act, url string, body []byte) (*http.Request, *httptest.ResponseRecorder) {
 	req := httptest.NewRequest(act, url, bytes.NewBuffer(body))
@@ -57,7 +59,7 @@ func formHTTTest(act, url string, body []byte) (*http.Request, *httptest.Respons
 	return req, w
 }
 
-//TestGetOneContactCtrl Use GoConvey test framework
+// TestGetOneContactCtrl Use GoConvey test framework
 func TestGetOneContactCtrl(t *testing.T) {
 	Convey("GetOneContactCtrl", t, func() {
 		ctl := gomock.NewController(t)
@@ -81,7 +83,7 @@ func TestGetOneContactCtrl(t *testing.T) {
 					return map[string]string{"contact_id_or_email": "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23"}
 				})
 				gomock.InOrder(
-					mockSrv.EXPECT().GetOneContact("contactid", "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23").Return(&mockContact, nil),
+					mockSrv.EXPECT().GetOneContact("", "contactid", "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23").Return(&mockContact, nil),
 				)
 
 				cCtrl.GetOneContactCtrl(w, req)
@@ -101,7 +103,7 @@ func TestGetOneContactCtrl(t *testing.T) {
 					return map[string]string{"contact_id_or_email": "StGr@gmail.com"}
 				})
 				gomock.InOrder(
-					mockSrv.EXPECT().GetOneContact("email", "StGr@gmail.com").Return(&mockContact, nil),
+					mockSrv.EXPECT().GetOneContact("", "email", "StGr@gmail.com").Return(&mockContact, nil),
 				)
 
 				cCtrl.GetOneContactCtrl(w, req)
@@ -124,7 +126,7 @@ func TestGetOneContactCtrl(t *testing.T) {
 				return map[string]string{"contact_id_or_email": "StGr@gmail.com"}
 			})
 			gomock.InOrder(
-				mockSrv.EXPECT().GetOneContact("email", "StGr@gmail.com").Return(nil, mongo.ErrNoDocuments),
+				mockSrv.EXPECT().GetOneContact("", "email", "StGr@gmail.com").Return(nil, mongo.ErrNoDocuments),
 			)
 
 			cCtrl.GetOneContactCtrl(w, req)
@@ -179,7 +181,7 @@ func TestGetOneContactCtrl(t *testing.T) {
 				return map[string]string{"contact_id_or_email": "StGr@gmail.com"}
 			})
 			gomock.InOrder(
-				mockSrv.EXPECT().GetOneContact("email", "StGr@gmail.com").Return(nil, errors.New("other error")),
+				mockSrv.EXPECT().GetOneContact("", "email", "StGr@gmail.com").Return(nil, errors.New("other error")),
 			)
 
 			cCtrl.GetOneContactCtrl(w, req)
@@ -201,7 +203,7 @@ func TestGetOneContactCtrl(t *testing.T) {
 	})
 }
 
-//TestAddUpdateContactCtrl Use GoConvey test framework
+// TestAddUpdateContactCtrl Use GoConvey test framework
 func TestAddOrUpdateContactCtrl(t *testing.T) {
 	Convey("AddOrUpdateContactCtrl", t, func() {
 		ctl := gomock.NewController(t)
@@ -217,7 +219,7 @@ func TestAddOrUpdateContactCtrl(t *testing.T) {
 			Convey("UT Case1 Normal: 200", func() {
 				req, w := formHTTTest(act, contactURL, postJSONStr)
 				gomock.InOrder(
-					mockSrv.EXPECT().AddOrUpdateContact(&postContact).Return("person_9EAF39E4-9AEC-4134-964A-D9D8D54162E7", nil),
+					mockSrv.EXPECT().AddOrUpdateContact("", &postContact).Return("person_9EAF39E4-9AEC-4134-964A-D9D8D54162E7", true, nil),
 				)
 
 				cCtrl.AddOrUpdateContactCtrl(w, req)
@@ -227,6 +229,7 @@ func TestAddOrUpdateContactCtrl(t *testing.T) {
 				So(result["contact_id"], ShouldNotEqual, nil)
 				expected := map[string]string{"contact_id": "person_9EAF39E4-9AEC-4134-964A-D9D8D54162E7"}
 				So(result["contact_id"], ShouldEqual, expected["contact_id"])
+				So(result["status"], ShouldEqual, entities.ContactCreated)
 			})
 
 			Convey("UT Case2 Normal: 200", func() {
@@ -241,10 +244,11 @@ func TestAddOrUpdateContactCtrl(t *testing.T) {
                     }
 				}
 			}`)
-				var postContactBool = entities.Contact{FirstName: "Slarty", LastName: "Bartfast", Email: "Slarty@test.com", Custom: map[string]interface{}{"Test Field": true}}
+				var postContactBool = entities.Contact{FirstName: "Slarty", LastName: "Bartfast", Email: "Slarty@test.com", Custom: map[string]interface{}{"Test Field": true},
+					CustomTypes: map[string]string{"Test Field": "boolean"}}
 				req, w := formHTTTest(act, contactURL, postJSONStrBool)
 				gomock.InOrder(
-					mockSrv.EXPECT().AddOrUpdateContact(&postContactBool).Return("person_9EAF39E4-9AEC-4134-964A-D9D8D5410002", nil),
+					mockSrv.EXPECT().AddOrUpdateContact("", &postContactBool).Return("person_9EAF39E4-9AEC-4134-964A-D9D8D5410002", false, nil),
 				)
 
 				cCtrl.AddOrUpdateContactCtrl(w, req)
@@ -254,6 +258,7 @@ func TestAddOrUpdateContactCtrl(t *testing.T) {
 				So(result["contact_id"], ShouldNotEqual, nil)
 				expected := map[string]string{"contact_id": "person_9EAF39E4-9AEC-4134-964A-D9D8D5410002"}
 				So(result["contact_id"], ShouldEqual, expected["contact_id"])
+				So(result["status"], ShouldEqual, entities.ContactUpdated)
 			})
 
 			Convey("UT Case3 Normal: 200", func() {
@@ -268,10 +273,11 @@ func TestAddOrUpdateContactCtrl(t *testing.T) {
                     }
 				}
 			}`)
-				var postContactBool = entities.Contact{FirstName: "Slarty", LastName: "Bartfast", Email: "Slarty@test.com", Custom: map[string]interface{}{"Test Field": 3.0}}
+				var postContactBool = entities.Contact{FirstName: "Slarty", LastName: "Bartfast", Email: "Slarty@test.com", Custom: map[string]interface{}{"Test Field": 3.0},
+					CustomTypes: map[string]string{"Test Field": "float"}}
 				req, w := formHTTTest(act, contactURL, postJSONStrBool)
 				gomock.InOrder(
-					mockSrv.EXPECT().AddOrUpdateContact(&postContactBool).Return("person_9EAF39E4-9AEC-4134-964A-D9D8D5410002", nil),
+					mockSrv.EXPECT().AddOrUpdateContact("", &postContactBool).Return("person_9EAF39E4-9AEC-4134-964A-D9D8D5410002", false, nil),
 				)
 
 				cCtrl.AddOrUpdateContactCtrl(w, req)
@@ -310,7 +316,7 @@ func TestAddOrUpdateContactCtrl(t *testing.T) {
 			Convey("UT Abnormal Case2: 500, AddOrUpdateContact return error", func() {
 				req, w := formHTTTest(act, contactURL, postJSONStr)
 				gomock.InOrder(
-					mockSrv.EXPECT().AddOrUpdateContact(&postContact).Return("", errors.New("AddOrUpdateContact return error")),
+					mockSrv.EXPECT().AddOrUpdateContact("", &postContact).Return("", false, errors.New("AddOrUpdateContact return error")),
 				)
 				cCtrl.AddOrUpdateContactCtrl(w, req)
 
@@ -322,28 +328,61 @@ func TestAddOrUpdateContactCtrl(t *testing.T) {
 				So(result["message"], ShouldEqual, expected["message"])
 			})
 
-			Convey("UT Abnormal Case3: 500, parseContactFromReq return error", func() {
+			Convey("UT Abnormal Case3: 400, the error of each invalid custom field", func() {
 				var jsonStr = []byte(`{
 					"contact": {
 						"FirstName": "Slarty",
 						"LastName": "Bartfast",
 						"custom": {
-							"invalidType--Test--Field": "1024"
+							"invalidType--Test--Field": "1024",
+							"integer--Count": "many",
+							"NoSeparator": "x",
+							"string--Fine": "ok"
 						}
 					}
 				}`)
 				req, w := formHTTTest(act, contactURL, jsonStr)
 
+				cCtrl.AddOrUpdateContactCtrl(w, req)
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				result := struct {
+					Error   string
+					Message string
+					Fields  []map[string]string
+				}{}
+				_ = json.Unmarshal(w.Body.Bytes(), &result)
+				So(result.Error, ShouldEqual, "Bad Request")
+				So(result.Message, ShouldEqual, "Invalid custom field provided.")
+				So(len(result.Fields), ShouldEqual, 3)
+				So(result.Fields[0]["key"], ShouldEqual, "NoSeparator")
+				So(result.Fields[1]["key"], ShouldEqual, "integer--Count")
+				So(result.Fields[2]["key"], ShouldEqual, "invalidType--Test--Field")
+			})
+
+			Convey("UT Abnormal Case6: 500, the request can not be decoded", func() {
+				req, w := formHTTTest(act, contactURL, []byte(`{"contact": `))
+
 				cCtrl.AddOrUpdateContactCtrl(w, req)
 				So(w.Code, ShouldEqual, http.StatusInternalServerError)
 				result := map[string]string{}
 				_ = json.Unmarshal(w.Body.Bytes(), &result)
-				expected := map[string]string{"error": "Internal Server Error", "message": "Internal Error"}
-				So(result["error"], ShouldEqual, expected["error"])
-				So(result["message"], ShouldEqual, expected["message"])
+				So(result["message"], ShouldEqual, "Internal Error")
 			})
 
-			Convey("UT Abnormal Case4: req and w is nil", func() {
+			Convey("UT Abnormal Case4: 400, read-only field rejected", func() {
+				req, w := formHTTTest(act, contactURL, postJSONStr)
+				gomock.InOrder(
+					mockSrv.EXPECT().AddOrUpdateContact("", &postContact).Return("", false, &entities.ReadOnlyFieldError{Field: "owner_name"}),
+				)
+				cCtrl.AddOrUpdateContactCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				result := map[string]string{}
+				_ = json.Unmarshal(w.Body.Bytes(), &result)
+				So(result["message"], ShouldEqual, "The field owner_name is read-only.")
+			})
+
+			Convey("UT Abnormal Case5: req and w is nil", func() {
 				var w http.ResponseWriter
 				var req *http.Request
 				cCtrl.AddOrUpdateContactCtrl(w, req)
@@ -352,3 +391,552 @@ func TestAddOrUpdateContactCtrl(t *testing.T) {
 		})
 	})
 }
+
+// TestGetAllContactsCtrl Use GoConvey test framework
+func TestGetAllContactsCtrl(t *testing.T) {
+	Convey("GetAllContactsCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		act := "Get"
+		page := &entities.ContactPage{Contacts: []*entities.Contact{&mockContact}, TotalContacts: 2, Bookmark: "bookmark1"}
+
+		Convey("UT Normal Case", func() {
+			Convey("UT Normal Case1: 200, first page", func() {
+				req, w := formHTTTest(act, "/v1/contacts", nil)
+				gomock.InOrder(
+					mockSrv.EXPECT().GetAllContacts("", "").Return(page, nil),
+				)
+
+				cCtrl.GetAllContactsCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+				result := new(entities.ContactPage)
+				_ = json.NewDecoder(w.Body).Decode(result)
+				So(len(result.Contacts), ShouldEqual, 1)
+				So(result.Contacts[0].ContactID, ShouldEqual, mockContact.ContactID)
+				So(result.TotalContacts, ShouldEqual, 2)
+				So(result.Bookmark, ShouldEqual, "bookmark1")
+			})
+
+			Convey("UT Normal Case2: 200, page after the bookmark", func() {
+				req, w := formHTTTest(act, "/v1/contacts/bookmark1", nil)
+				defer monkey.UnpatchAll()
+				monkey.Patch(mux.Vars, func(r *http.Request) map[string]string {
+					return map[string]string{"bookmark": "bookmark1"}
+				})
+				gomock.InOrder(
+					mockSrv.EXPECT().GetAllContacts("", "bookmark1").Return(&entities.ContactPage{TotalContacts: 2}, nil),
+				)
+
+				cCtrl.GetAllContactsBookmarkCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+				result := map[string]interface{}{}
+				_ = json.NewDecoder(w.Body).Decode(&result)
+				_, ok := result["bookmark"]
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("UT AbNormal Case", func() {
+			Convey("UT AbNormal Case1: 400, invalid bookmark", func() {
+				req, w := formHTTTest(act, "/v1/contacts/bad", nil)
+				defer monkey.UnpatchAll()
+				monkey.Patch(mux.Vars, func(r *http.Request) map[string]string {
+					return map[string]string{"bookmark": "bad"}
+				})
+				gomock.InOrder(
+					mockSrv.EXPECT().GetAllContacts("", "bad").Return(nil, entities.ErrInvalidBookmark),
+				)
+
+				cCtrl.GetAllContactsBookmarkCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				result := map[string]string{}
+				_ = json.NewDecoder(w.Body).Decode(&result)
+				So(result["message"], ShouldEqual, "Invalid bookmark provided.")
+			})
+
+			Convey("UT AbNormal Case2: 500, service error", func() {
+				req, w := formHTTTest(act, "/v1/contacts", nil)
+				gomock.InOrder(
+					mockSrv.EXPECT().GetAllContacts("", "").Return(nil, errors.New("other error")),
+				)
+
+				cCtrl.GetAllContactsCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+}
+
+// TestAddBulkContactsCtrl Use GoConvey test framework
+func TestAddBulkContactsCtrl(t *testing.T) {
+	Convey("AddBulkContactsCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		act := "POST"
+		contactsURL := "/v1/contacts"
+
+		Convey("UT Normal Case", func() {
+			Convey("UT Normal Case1: 200, result of each contact", func() {
+				var jsonStr = []byte(`{"contacts": [
+					{"FirstName": "Slarty", "Email": "Slarty@test.com", "custom": {"integer--Test--Field": "1024"}},
+					{"FirstName": "Bad", "Email": "Bad@test.com", "custom": {"invalidType--Test--Field": "1024"}},
+					{"FirstName": "Old", "Email": "Old@test.com"}
+				]}`)
+				req, w := formHTTTest(act, contactsURL, jsonStr)
+				slarty := &entities.Contact{FirstName: "Slarty", Email: "Slarty@test.com", Custom: map[string]interface{}{"Test Field": 1024},
+					CustomTypes: map[string]string{"Test Field": "integer"}}
+				old := &entities.Contact{FirstName: "Old", Email: "Old@test.com"}
+				gomock.InOrder(
+					mockSrv.EXPECT().CheckBulkLimit(3).Return(nil),
+					mockSrv.EXPECT().AddBulkContacts("", []*entities.Contact{slarty, old}).Return([]entities.BulkContactResult{
+						{Email: "Slarty@test.com", ContactID: "person_AP2-1", Status: entities.ContactCreated},
+						{Email: "Old@test.com", ContactID: "person_AP2-2", Status: entities.ContactUpdated},
+					}, nil),
+				)
+
+				cCtrl.AddBulkContactsCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+				result := map[string][]entities.BulkContactResult{}
+				_ = json.Unmarshal(w.Body.Bytes(), &result)
+				So(len(result["contacts"]), ShouldEqual, 3)
+				So(result["contacts"][0].Status, ShouldEqual, entities.ContactCreated)
+				So(result["contacts"][1].Status, ShouldEqual, entities.ContactFailed)
+				So(result["contacts"][1].Email, ShouldEqual, "Bad@test.com")
+				So(result["contacts"][2].ContactID, ShouldEqual, "person_AP2-2")
+			})
+		})
+
+		Convey("UT AbNormal Case", func() {
+			Convey("UT AbNormal Case1: 400, no contacts", func() {
+				req, w := formHTTTest(act, contactsURL, []byte(`{"contacts": []}`))
+
+				cCtrl.AddBulkContactsCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				result := map[string]string{}
+				_ = json.Unmarshal(w.Body.Bytes(), &result)
+				So(result["message"], ShouldEqual, "No contact details provided.")
+			})
+
+			Convey("UT AbNormal Case2: 400, too many contacts, the ones failing to parse count", func() {
+				req, w := formHTTTest(act, contactsURL, []byte(`{"contacts": [{"Email": "Slarty@test.com"}, {"Email": "Bad@test.com", "custom": {"invalidType--Test--Field": "1024"}}]}`))
+				gomock.InOrder(
+					mockSrv.EXPECT().CheckBulkLimit(2).Return(entities.ErrTooManyContacts),
+				)
+
+				cCtrl.AddBulkContactsCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				result := map[string]string{}
+				_ = json.Unmarshal(w.Body.Bytes(), &result)
+				So(result["message"], ShouldEqual, "Too many contacts provided.")
+			})
+
+			Convey("UT AbNormal Case3: 400, invalid json", func() {
+				req, w := formHTTTest(act, contactsURL, []byte(`{"contacts": `))
+
+				cCtrl.AddBulkContactsCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+
+			Convey("UT AbNormal Case4: 500, service error", func() {
+				req, w := formHTTTest(act, contactsURL, []byte(`{"contacts": [{"Email": "Slarty@test.com"}]}`))
+				gomock.InOrder(
+					mockSrv.EXPECT().CheckBulkLimit(1).Return(nil),
+					mockSrv.EXPECT().AddBulkContacts("", gomock.Any()).Return(nil, errors.New("other error")),
+				)
+
+				cCtrl.AddBulkContactsCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+}
+
+// TestDeleteContactCtrl Use GoConvey test framework
+func TestDeleteContactCtrl(t *testing.T) {
+	Convey("DeleteContactCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		act := "DELETE"
+		emailURL := "/v1/contact/StGr@gmail.com"
+		defer monkey.UnpatchAll()
+		monkey.Patch(mux.Vars, func(r *http.Request) map[string]string {
+			return map[string]string{"contact_id_or_email": "StGr@gmail.com"}
+		})
+
+		Convey("UT Normal Case1: 200, delete by email", func() {
+			req, w := formHTTTest(act, emailURL, nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().DeleteContact("", "email", "StGr@gmail.com").Return(nil),
+			)
+
+			cCtrl.DeleteContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("UT AbNormal Case1: 404, contact could not be found", func() {
+			req, w := formHTTTest(act, emailURL, nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().DeleteContact("", "email", "StGr@gmail.com").Return(mongo.ErrNoDocuments),
+			)
+
+			cCtrl.DeleteContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			result := map[string]string{}
+			_ = json.NewDecoder(w.Body).Decode(&result)
+			So(result["message"], ShouldEqual, "Contact could not be found.")
+		})
+
+		Convey("UT AbNormal Case2: 400, invalid contact_id_or_email", func() {
+			req, w := formHTTTest(act, "/v1/contact/StGrgmail.com", nil)
+			monkey.Patch(mux.Vars, func(r *http.Request) map[string]string {
+				return map[string]string{"contact_id_or_email": "StGrgmail.com"}
+			})
+
+			cCtrl.DeleteContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("UT AbNormal Case3: 500, service error", func() {
+			req, w := formHTTTest(act, emailURL, nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().DeleteContact("", "email", "StGr@gmail.com").Return(errors.New("other error")),
+			)
+
+			cCtrl.DeleteContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+		})
+	})
+}
+
+// TestUnsubscribeContactCtrl Use GoConvey test framework
+func TestUnsubscribeContactCtrl(t *testing.T) {
+	Convey("UnsubscribeContactCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		act := "POST"
+		unsubscribeURL := "/v1/contact/StGr@gmail.com/unsubscribe"
+		defer monkey.UnpatchAll()
+		monkey.Patch(mux.Vars, func(r *http.Request) map[string]string {
+			return map[string]string{"contact_id_or_email": "StGr@gmail.com"}
+		})
+
+		Convey("UT Normal Case", func() {
+			Convey("UT Normal Case1: 200, unsubscribe with details", func() {
+				req, w := formHTTTest(act, unsubscribeURL, []byte(`{"by": "support", "source": "email_link"}`))
+				gomock.InOrder(
+					mockSrv.EXPECT().UnsubscribeContact("", "email", "StGr@gmail.com", &entities.Unsubscription{By: "support", Source: "email_link"}).Return(nil),
+				)
+
+				cCtrl.UnsubscribeContactCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("UT Normal Case2: 200, unsubscribe without body", func() {
+				req, w := formHTTTest(act, unsubscribeURL, nil)
+				gomock.InOrder(
+					mockSrv.EXPECT().UnsubscribeContact("", "email", "StGr@gmail.com", &entities.Unsubscription{By: "api", Source: "api"}).Return(nil),
+				)
+
+				cCtrl.UnsubscribeContactCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("UT Normal Case3: 200, resubscribe", func() {
+				req, w := formHTTTest(act, "/v1/contact/StGr@gmail.com/resubscribe", nil)
+				gomock.InOrder(
+					mockSrv.EXPECT().ResubscribeContact("", "email", "StGr@gmail.com").Return(nil),
+				)
+
+				cCtrl.ResubscribeContactCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("UT AbNormal Case", func() {
+			Convey("UT AbNormal Case1: 404, contact could not be found", func() {
+				req, w := formHTTTest(act, unsubscribeURL, nil)
+				gomock.InOrder(
+					mockSrv.EXPECT().UnsubscribeContact("", "email", "StGr@gmail.com", gomock.Any()).Return(mongo.ErrNoDocuments),
+				)
+
+				cCtrl.UnsubscribeContactCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+
+			Convey("UT AbNormal Case2: 400, invalid body", func() {
+				req, w := formHTTTest(act, unsubscribeURL, []byte(`{"by": `))
+
+				cCtrl.UnsubscribeContactCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+
+			Convey("UT AbNormal Case3: 500, service error", func() {
+				req, w := formHTTTest(act, "/v1/contact/StGr@gmail.com/resubscribe", nil)
+				gomock.InOrder(
+					mockSrv.EXPECT().ResubscribeContact("", "email", "StGr@gmail.com").Return(errors.New("other error")),
+				)
+
+				cCtrl.ResubscribeContactCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+}
+
+// TestPatchContactCtrl Use GoConvey test framework
+func TestPatchContactCtrl(t *testing.T) {
+	Convey("PatchContactCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		act := "PATCH"
+		emailURL := "/v1/contact/StGr@gmail.com"
+		defer monkey.UnpatchAll()
+		monkey.Patch(mux.Vars, func(r *http.Request) map[string]string {
+			return map[string]string{"contact_id_or_email": "StGr@gmail.com"}
+		})
+
+		Convey("UT Normal Case1: 200, custom fields parsed and null kept", func() {
+			req, w := formHTTTest(act, emailURL, []byte(`{"FirstName": "New", "Phone": null, "custom": {"integer--Test--Field": "5", "string--Old--Field": null}}`))
+			patch := map[string]interface{}{
+				"FirstName": "New",
+				"Phone":     nil,
+				"custom":    map[string]interface{}{"Test Field": 5, "Old Field": nil},
+			}
+			gomock.InOrder(
+				mockSrv.EXPECT().PatchContact("", "email", "StGr@gmail.com", patch, map[string]string{"Test Field": "integer"}).Return(&mockContact, nil),
+			)
+
+			cCtrl.PatchContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			result := new(entities.Contact)
+			_ = json.NewDecoder(w.Body).Decode(result)
+			So(result.ContactID, ShouldEqual, mockContact.ContactID)
+		})
+
+		Convey("UT AbNormal Case1: 400, read-only field", func() {
+			req, w := formHTTTest(act, emailURL, []byte(`{"Email": "other@gmail.com"}`))
+			gomock.InOrder(
+				mockSrv.EXPECT().PatchContact("", "email", "StGr@gmail.com", gomock.Any(), gomock.Any()).Return(nil, &entities.ReadOnlyFieldError{Field: "Email"}),
+			)
+
+			cCtrl.PatchContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			result := map[string]string{}
+			_ = json.NewDecoder(w.Body).Decode(&result)
+			So(result["message"], ShouldEqual, "The field Email is read-only.")
+		})
+
+		Convey("UT AbNormal Case2: 400, patch is not an object", func() {
+			req, w := formHTTTest(act, emailURL, []byte(`["FirstName"]`))
+
+			cCtrl.PatchContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("UT AbNormal Case3: 400, invalid custom field", func() {
+			req, w := formHTTTest(act, emailURL, []byte(`{"custom": {"invalidType--Test--Field": "5"}}`))
+
+			cCtrl.PatchContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("UT AbNormal Case5: 400, custom field of another type", func() {
+			req, w := formHTTTest(act, emailURL, []byte(`{"custom": {"string--Test--Field": "5"}}`))
+			gomock.InOrder(
+				mockSrv.EXPECT().PatchContact("", "email", "StGr@gmail.com", gomock.Any(), map[string]string{"Test Field": "string"}).
+					Return(nil, &entities.CustomFieldTypeError{Name: "Test Field", FieldType: "integer"}),
+			)
+
+			cCtrl.PatchContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			result := map[string]string{}
+			_ = json.NewDecoder(w.Body).Decode(&result)
+			So(result["message"], ShouldEqual, "The custom field Test Field is of type integer.")
+		})
+
+		Convey("UT AbNormal Case4: 404, contact could not be found", func() {
+			req, w := formHTTTest(act, emailURL, []byte(`{"FirstName": "New"}`))
+			gomock.InOrder(
+				mockSrv.EXPECT().PatchContact("", "email", "StGr@gmail.com", gomock.Any(), gomock.Any()).Return(nil, mongo.ErrNoDocuments),
+			)
+
+			cCtrl.PatchContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
+
+// TestGetCustomFieldsCtrl Use GoConvey test framework
+func TestGetCustomFieldsCtrl(t *testing.T) {
+	Convey("GetCustomFieldsCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		act := "GET"
+		fieldsURL := "/v1/contacts/custom_fields"
+
+		Convey("UT Normal Case1: 200, the registered fields", func() {
+			req, w := formHTTTest(act, fieldsURL, nil)
+			created := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+			gomock.InOrder(
+				mockSrv.EXPECT().GetCustomFields("").Return([]*entities.CustomField{
+					{Name: "Test Field", FieldType: "integer", CreatTime: created},
+				}, nil),
+			)
+
+			cCtrl.GetCustomFieldsCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			result := []map[string]string{}
+			_ = json.NewDecoder(w.Body).Decode(&result)
+			So(len(result), ShouldEqual, 1)
+			So(result[0]["name"], ShouldEqual, "Test Field")
+			So(result[0]["fieldType"], ShouldEqual, "integer")
+			So(result[0]["created_at"], ShouldEqual, "2020-05-01T00:00:00Z")
+		})
+
+		Convey("UT AbNormal Case1: 500, GetCustomFields return error", func() {
+			req, w := formHTTTest(act, fieldsURL, nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().GetCustomFields("").Return(nil, errors.New("GetCustomFields return error")),
+			)
+
+			cCtrl.GetCustomFieldsCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+		})
+	})
+}
+
+// TestCustomFieldsWireFormat Use GoConvey test framework
+func TestCustomFieldsWireFormat(t *testing.T) {
+	Convey("CustomFieldsWireFormat", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		emailURL := "/v1/contact/StGr@gmail.com"
+		defer monkey.UnpatchAll()
+		monkey.Patch(mux.Vars, func(r *http.Request) map[string]string {
+			return map[string]string{"contact_id_or_email": "StGr@gmail.com"}
+		})
+
+		typed := mockContact
+		typed.Custom = map[string]interface{}{
+			"Test Field": 1024,
+			"Is Member":  true,
+			"Ratio":      0.3,
+			"Joined":     time.Date(2020, 5, 1, 10, 30, 0, 0, time.Local),
+			"Note":       "hello",
+		}
+		typed.CustomTypes = map[string]string{"Test Field": "integer"}
+
+		Convey("UT Normal Case1: 200, the custom fields in the type--Name format", func() {
+			req, w := formHTTTest("GET", emailURL, nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().GetOneContact("", "email", "StGr@gmail.com").Return(&typed, nil),
+			)
+
+			cCtrl.GetOneContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			result := map[string]interface{}{}
+			_ = json.NewDecoder(w.Body).Decode(&result)
+			So(result["custom"], ShouldResemble, map[string]interface{}{
+				"integer--Test--Field": "1024",
+				"boolean--Is--Member":  "true",
+				"float--Ratio":         "0.3",
+				"date--Joined":         "2020-05-01 10:30:00",
+				"string--Note":         "hello",
+			})
+			So(typed.Custom["Test Field"], ShouldEqual, 1024)
+		})
+
+		Convey("UT Normal Case2: 200, the plain custom fields", func() {
+			req, w := formHTTTest("GET", emailURL+"?custom=plain", nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().GetOneContact("", "email", "StGr@gmail.com").Return(&typed, nil),
+			)
+
+			cCtrl.GetOneContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			result := map[string]map[string]interface{}{}
+			_ = json.NewDecoder(w.Body).Decode(&result)
+			So(result["custom"]["Test Field"], ShouldEqual, 1024.0)
+			So(result["custom"]["Note"], ShouldEqual, "hello")
+		})
+
+		Convey("UT Normal Case3: the read custom field can be posted back", func() {
+			for name, value := range map[string]interface{}{"Joined": typed.Custom["Joined"], "Ratio": 0.3, "Note": "hello"} {
+				contact := mockContact
+				contact.Custom = map[string]interface{}{name: value}
+				req, w := formHTTTest("GET", emailURL, nil)
+				mockSrv.EXPECT().GetOneContact("", "email", "StGr@gmail.com").Return(&contact, nil)
+				cCtrl.GetOneContactCtrl(w, req)
+				read := map[string]map[string]interface{}{}
+				_ = json.NewDecoder(w.Body).Decode(&read)
+
+				body, _ := json.Marshal(map[string]interface{}{"contact": map[string]interface{}{"Email": "StGr@gmail.com", "custom": read["custom"]}})
+				req, w = formHTTTest("POST", "/v1/contact", body)
+				mockSrv.EXPECT().AddOrUpdateContact("", gomock.Any()).DoAndReturn(
+					func(account string, posted *entities.Contact) (string, bool, error) {
+						So(posted.Custom, ShouldResemble, contact.Custom)
+						return contact.ContactID, false, nil
+					})
+				cCtrl.AddOrUpdateContactCtrl(w, req)
+				So(w.Code, ShouldEqual, http.StatusOK)
+			}
+		})
+	})
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/STreeChin/contactapi/pkg/route/middleware"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//EraseContactCtrl: erase one contact by contact id or email for the right to be forgotten
+func (cc *contactController) EraseContactCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	contactIDOrEmail := mux.Vars(r)["contact_id_or_email"]
+
+	key, check := cc.checkContactIDOrEmail(contactIDOrEmail)
+	if !check {
+		cc.log.Infoln("Invalid contact_id_or_email value provided")
+		cc.handleError(w, http.StatusBadRequest, "Invalid contact_id_or_email value provided.")
+		return
+	}
+
+	erasure, err := cc.contactService.EraseContact(middleware.AccountID(r.Context()), key, contactIDOrEmail)
+	if err != nil {
+		if errors.Cause(err) == mongo.ErrNoDocuments {
+			cc.log.Infof("EraseContactCtrl: %+v", err)
+			cc.handleError(w, http.StatusNotFound, "Contact could not be found.")
+			return
+		}
+
+		cc.log.Errorf("EraseContactCtrl: %+v", err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cc.buildResponse(w, erasure)
+}
@@ -0,0 +1,79 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"github.com/STreeChin/contactapi/internal/controller"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TestEraseContactCtrl Use GoConvey test framework
+func TestEraseContactCtrl(t *testing.T) {
+	Convey("EraseContactCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		act := "POST"
+		eraseURL := "/v1/contact/StGr@gmail.com/erase"
+		defer monkey.UnpatchAll()
+		monkey.Patch(mux.Vars, func(r *http.Request) map[string]string {
+			return map[string]string{"contact_id_or_email": "StGr@gmail.com"}
+		})
+
+		Convey("UT Normal Case1: 200, the tombstone", func() {
+			req, w := formHTTTest(act, eraseURL, nil)
+			erasure := &entities.Erasure{ErasureID: "erasure_9cbf7ac0-eec5-11e4-87bc-6df09cc44d23", ErasedAt: time.Date(2020, 6, 1, 8, 0, 0, 0, time.UTC)}
+			mockSrv.EXPECT().EraseContact("", "email", "StGr@gmail.com").Return(erasure, nil)
+
+			cCtrl.EraseContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			result := new(entities.Erasure)
+			_ = json.NewDecoder(w.Body).Decode(result)
+			So(result, ShouldResemble, erasure)
+		})
+
+		Convey("UT AbNormal Case1: 404, contact could not be found", func() {
+			req, w := formHTTTest(act, eraseURL, nil)
+			mockSrv.EXPECT().EraseContact("", "email", "StGr@gmail.com").Return(nil, errors.Wrap(mongo.ErrNoDocuments, "service EraseContact"))
+
+			cCtrl.EraseContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("UT AbNormal Case2: 400, invalid contact_id_or_email", func() {
+			req, w := formHTTTest(act, "/v1/contact/StGrgmail.com/erase", nil)
+			monkey.Patch(mux.Vars, func(r *http.Request) map[string]string {
+				return map[string]string{"contact_id_or_email": "StGrgmail.com"}
+			})
+
+			cCtrl.EraseContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("UT AbNormal Case3: 500, service error", func() {
+			req, w := formHTTTest(act, eraseURL, nil)
+			mockSrv.EXPECT().EraseContact("", "email", "StGr@gmail.com").Return(nil, errors.New("other error"))
+
+			cCtrl.EraseContactCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+		})
+	})
+}
@@ -0,0 +1,15 @@
+package controller
+
+import (
+	"net/http"
+)
+
+// HealthCtrl: get the health of the instance, 200 even when degraded: the contacts are still served from the db
+func (cc *contactController) HealthCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	cc.buildResponse(w, cc.contactService.GetHealth())
+}
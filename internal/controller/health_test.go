@@ -0,0 +1,54 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/STreeChin/contactapi/internal/controller"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestHealthCtrl Use GoConvey test framework
+func TestHealthCtrl(t *testing.T) {
+	Convey("HealthCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+
+		Convey("UT Normal Case1: 200, ok", func() {
+			req, w := formHTTTest("GET", "/health", nil)
+			mockSrv.EXPECT().GetHealth().Return(&entities.Health{Status: entities.HealthOK, Cache: entities.CacheHealth{Breaker: entities.BreakerClosed}})
+
+			cCtrl.HealthCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			result := new(entities.Health)
+			_ = json.NewDecoder(w.Body).Decode(result)
+			So(result.Status, ShouldEqual, entities.HealthOK)
+			So(result.Cache.OpenedAt, ShouldEqual, nil)
+		})
+
+		Convey("UT Normal Case2: 200 too while redis is down, the breaker is in the body", func() {
+			req, w := formHTTTest("GET", "/health", nil)
+			openedAt := time.Date(2020, 5, 1, 8, 30, 0, 0, time.UTC)
+			health := &entities.Health{Status: entities.HealthDegraded, Cache: entities.CacheHealth{Breaker: entities.BreakerOpen, Failures: 5, OpenedAt: &openedAt, PendingInvalidations: 4}}
+			mockSrv.EXPECT().GetHealth().Return(health)
+
+			cCtrl.HealthCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			result := new(entities.Health)
+			_ = json.NewDecoder(w.Body).Decode(result)
+			So(result, ShouldResemble, health)
+		})
+	})
+}
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/route/middleware"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//GetListsCtrl: get the lists of the account
+func (cc *contactController) GetListsCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	lists, err := cc.contactService.GetLists(middleware.AccountID(r.Context()))
+	if err != nil {
+		cc.log.Errorf("GetListsCtrl: %+v", err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	body := map[string][]*entities.List{"lists": lists}
+	cc.buildResponse(w, body)
+}
+
+//AddListCtrl: create a list with the name of the request
+func (cc *contactController) AddListCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	dst := new(entities.ReqList)
+	err := json.NewDecoder(r.Body).Decode(dst)
+	if err != nil || strings.TrimSpace(dst.Name) == "" {
+		cc.log.Infof("AddListCtrl: %+v", err)
+		cc.handleError(w, http.StatusBadRequest, "No list name provided.")
+		return
+	}
+
+	list, err := cc.contactService.AddList(middleware.AccountID(r.Context()), strings.TrimSpace(dst.Name))
+	if err != nil {
+		cc.log.Errorf("AddListCtrl: %+v", err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cc.buildResponse(w, map[string]string{"list_id": list.ListID})
+}
+
+//GetListContactsCtrl: get the first page of the contacts in the list
+func (cc *contactController) GetListContactsCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	cc.getListContactsPage(w, r, "")
+}
+
+//GetListContactsBookmarkCtrl: get the page of the contacts in the list after the bookmark
+func (cc *contactController) GetListContactsBookmarkCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	bookmark := mux.Vars(r)["bookmark"]
+	if bookmark == "" {
+		cc.log.Infoln("GetListContactsBookmarkCtrl: bookmark is nil")
+		cc.handleError(w, http.StatusBadRequest, "Invalid bookmark provided.")
+		return
+	}
+
+	cc.getListContactsPage(w, r, bookmark)
+}
+
+func (cc *contactController) getListContactsPage(w http.ResponseWriter, r *http.Request, bookmark string) {
+	listID := mux.Vars(r)["list_id"]
+	if !cc.checkListID(listID) {
+		cc.log.Infoln("Invalid list_id value provided")
+		cc.handleError(w, http.StatusBadRequest, "Invalid list_id value provided.")
+		return
+	}
+
+	page, err := cc.contactService.GetListContacts(middleware.AccountID(r.Context()), listID, bookmark)
+	if err != nil {
+		switch errors.Cause(err) {
+		case entities.ErrListNotFound:
+			cc.log.Infof("getListContactsPage: %+v", err)
+			cc.handleError(w, http.StatusNotFound, "List could not be found.")
+		case entities.ErrInvalidBookmark:
+			cc.log.Infof("getListContactsPage: %+v", err)
+			cc.handleError(w, http.StatusBadRequest, "Invalid bookmark provided.")
+		default:
+			cc.log.Errorf("getListContactsPage: %+v", err)
+			cc.handleError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	cc.buildResponse(w, cc.wirePage(r, page))
+}
+
+//AddContactToListCtrl: add one contact to the list
+func (cc *contactController) AddContactToListCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	cc.handleListMember(w, r, "AddContactToListCtrl", cc.contactService.AddContactToList)
+}
+
+//RemoveContactFromListCtrl: remove one contact from the list
+func (cc *contactController) RemoveContactFromListCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	cc.handleListMember(w, r, "RemoveContactFromListCtrl", cc.contactService.RemoveContactFromList)
+}
+
+//CheckContactInListCtrl: 200 if the contact is in the list, 404 if not
+func (cc *contactController) CheckContactInListCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	cc.handleListMember(w, r, "CheckContactInListCtrl", cc.contactService.CheckContactInList)
+}
+
+//handleListMember check the list and the contact of the request and call the service for the membership
+func (cc *contactController) handleListMember(w http.ResponseWriter, r *http.Request, name string, member func(account, listID, key, value string) error) {
+	listID := mux.Vars(r)["list_id"]
+	if !cc.checkListID(listID) {
+		cc.log.Infoln("Invalid list_id value provided")
+		cc.handleError(w, http.StatusBadRequest, "Invalid list_id value provided.")
+		return
+	}
+
+	contactIDOrEmail := mux.Vars(r)["contact_id_or_email"]
+	key, check := cc.checkContactIDOrEmail(contactIDOrEmail)
+	if !check {
+		cc.log.Infoln("Invalid contact_id_or_email value provided")
+		cc.handleError(w, http.StatusBadRequest, "Invalid contact_id_or_email value provided.")
+		return
+	}
+
+	err := member(middleware.AccountID(r.Context()), listID, key, contactIDOrEmail)
+	if err != nil {
+		switch errors.Cause(err) {
+		case entities.ErrListNotFound:
+			cc.log.Infof("%s: %+v", name, err)
+			cc.handleError(w, http.StatusNotFound, "List could not be found.")
+		case mongo.ErrNoDocuments:
+			cc.log.Infof("%s: %+v", name, err)
+			cc.handleError(w, http.StatusNotFound, "Contact could not be found.")
+		case entities.ErrNotInList:
+			cc.log.Infof("%s: %+v", name, err)
+			cc.handleError(w, http.StatusNotFound, "Contact is not in the list.")
+		default:
+			cc.log.Errorf("%s: %+v", name, err)
+			cc.handleError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	cc.buildResponse(w, map[string]string{})
+}
+
+func (cc *contactController) checkListID(value string) bool {
+	return strings.HasPrefix(value, entities.ListIDPrefix) && len(value) > len(entities.ListIDPrefix)
+}
@@ -0,0 +1,228 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/STreeChin/contactapi/internal/controller"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const mockListID = "contactlist_9EAF39E4-9AEC-4134-964A"
+
+// TestListsCtrl Use GoConvey test framework
+func TestListsCtrl(t *testing.T) {
+	Convey("ListsCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		listsURL := "/v1/lists"
+
+		Convey("UT Normal Case1: 200, get the lists", func() {
+			req, w := formHTTTest("GET", listsURL, nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().GetLists("").Return([]*entities.List{{ListID: mockListID, Title: "Newsletter"}}, nil),
+			)
+
+			cCtrl.GetListsCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			result := map[string][]map[string]string{}
+			_ = json.NewDecoder(w.Body).Decode(&result)
+			So(len(result["lists"]), ShouldEqual, 1)
+			So(result["lists"][0]["list_id"], ShouldEqual, mockListID)
+			So(result["lists"][0]["title"], ShouldEqual, "Newsletter")
+		})
+
+		Convey("UT Normal Case2: 200, add a list", func() {
+			req, w := formHTTTest("POST", listsURL, []byte(`{"name": " Newsletter "}`))
+			gomock.InOrder(
+				mockSrv.EXPECT().AddList("", "Newsletter").Return(&entities.List{ListID: mockListID, Title: "Newsletter"}, nil),
+			)
+
+			cCtrl.AddListCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			result := map[string]string{}
+			_ = json.NewDecoder(w.Body).Decode(&result)
+			So(result["list_id"], ShouldEqual, mockListID)
+		})
+
+		Convey("UT AbNormal Case1: 400, no list name", func() {
+			req, w := formHTTTest("POST", listsURL, []byte(`{"name": ""}`))
+
+			cCtrl.AddListCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("UT AbNormal Case2: 500, GetLists return error", func() {
+			req, w := formHTTTest("GET", listsURL, nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().GetLists("").Return(nil, errors.New("GetLists return error")),
+			)
+
+			cCtrl.GetListsCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+		})
+	})
+}
+
+// TestGetListContactsCtrl Use GoConvey test framework
+func TestGetListContactsCtrl(t *testing.T) {
+	Convey("GetListContactsCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		act := "GET"
+		vars := map[string]string{"list_id": mockListID}
+		defer monkey.UnpatchAll()
+		monkey.Patch(mux.Vars, func(r *http.Request) map[string]string {
+			return vars
+		})
+
+		Convey("UT Normal Case1: 200, the first page of the list", func() {
+			req, w := formHTTTest(act, "/v1/list/"+mockListID+"/contacts", nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().GetListContacts("", mockListID, "").Return(&entities.ContactPage{Contacts: []*entities.Contact{&mockContact}, TotalContacts: 1}, nil),
+			)
+
+			cCtrl.GetListContactsCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			page := new(entities.ContactPage)
+			_ = json.NewDecoder(w.Body).Decode(page)
+			So(page.TotalContacts, ShouldEqual, 1)
+			So(page.Contacts[0].Email, ShouldEqual, mockContact.Email)
+		})
+
+		Convey("UT AbNormal Case1: 404, list could not be found", func() {
+			req, w := formHTTTest(act, "/v1/list/"+mockListID+"/contacts", nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().GetListContacts("", mockListID, "").Return(nil, entities.ErrListNotFound),
+			)
+
+			cCtrl.GetListContactsCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("UT AbNormal Case2: 400, invalid bookmark", func() {
+			vars = map[string]string{"list_id": mockListID, "bookmark": "bad"}
+			req, w := formHTTTest(act, "/v1/list/"+mockListID+"/contacts/bad", nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().GetListContacts("", mockListID, "bad").Return(nil, entities.ErrInvalidBookmark),
+			)
+
+			cCtrl.GetListContactsBookmarkCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("UT AbNormal Case3: 400, invalid list_id", func() {
+			vars = map[string]string{"list_id": "newsletter"}
+			req, w := formHTTTest(act, "/v1/list/newsletter/contacts", nil)
+
+			cCtrl.GetListContactsCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
+
+// TestListMemberCtrl Use GoConvey test framework
+func TestListMemberCtrl(t *testing.T) {
+	Convey("ListMemberCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		memberURL := "/v1/list/" + mockListID + "/contact/StGr@gmail.com"
+		defer monkey.UnpatchAll()
+		monkey.Patch(mux.Vars, func(r *http.Request) map[string]string {
+			return map[string]string{"list_id": mockListID, "contact_id_or_email": "StGr@gmail.com"}
+		})
+
+		Convey("UT Normal Case1: 200, add the contact to the list", func() {
+			req, w := formHTTTest("POST", memberURL, nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().AddContactToList("", mockListID, "email", "StGr@gmail.com").Return(nil),
+			)
+
+			cCtrl.AddContactToListCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("UT Normal Case2: 200, remove the contact from the list", func() {
+			req, w := formHTTTest("DELETE", memberURL, nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().RemoveContactFromList("", mockListID, "email", "StGr@gmail.com").Return(nil),
+			)
+
+			cCtrl.RemoveContactFromListCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("UT AbNormal Case1: 404, contact is not in the list", func() {
+			req, w := formHTTTest("GET", memberURL, nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().CheckContactInList("", mockListID, "email", "StGr@gmail.com").Return(entities.ErrNotInList),
+			)
+
+			cCtrl.CheckContactInListCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			result := map[string]string{}
+			_ = json.NewDecoder(w.Body).Decode(&result)
+			So(result["message"], ShouldEqual, "Contact is not in the list.")
+		})
+
+		Convey("UT AbNormal Case2: 404, contact could not be found", func() {
+			req, w := formHTTTest("POST", memberURL, nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().AddContactToList("", mockListID, "email", "StGr@gmail.com").Return(mongo.ErrNoDocuments),
+			)
+
+			cCtrl.AddContactToListCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			result := map[string]string{}
+			_ = json.NewDecoder(w.Body).Decode(&result)
+			So(result["message"], ShouldEqual, "Contact could not be found.")
+		})
+
+		Convey("UT AbNormal Case3: 404, list could not be found", func() {
+			req, w := formHTTTest("DELETE", memberURL, nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().RemoveContactFromList("", mockListID, "email", "StGr@gmail.com").Return(entities.ErrListNotFound),
+			)
+
+			cCtrl.RemoveContactFromListCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			result := map[string]string{}
+			_ = json.NewDecoder(w.Body).Decode(&result)
+			So(result["message"], ShouldEqual, "List could not be found.")
+		})
+	})
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/route/middleware"
+	"github.com/STreeChin/contactapi/pkg/segment"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+//GetSegmentsCtrl: get the segments of the account
+func (cc *contactController) GetSegmentsCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	segments, err := cc.contactService.GetSegments(middleware.AccountID(r.Context()))
+	if err != nil {
+		cc.log.Errorf("GetSegmentsCtrl: %+v", err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	body := map[string][]*entities.Segment{"segments": segments}
+	cc.buildResponse(w, body)
+}
+
+//AddSegmentCtrl: create a segment with the name and the filter expression of the request
+func (cc *contactController) AddSegmentCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	dst := new(entities.ReqSegment)
+	err := json.NewDecoder(r.Body).Decode(dst)
+	if err != nil || strings.TrimSpace(dst.Name) == "" || strings.TrimSpace(dst.Expression) == "" {
+		cc.log.Infof("AddSegmentCtrl: %+v", err)
+		cc.handleError(w, http.StatusBadRequest, "No segment name or expression provided.")
+		return
+	}
+
+	seg, err := cc.contactService.AddSegment(middleware.AccountID(r.Context()), strings.TrimSpace(dst.Name), dst.Expression)
+	if err != nil {
+		if exprErr, ok := errors.Cause(err).(*segment.Error); ok {
+			cc.log.Infof("AddSegmentCtrl: %+v", err)
+			cc.handleError(w, http.StatusBadRequest, "Invalid segment expression: "+exprErr.Error()+".")
+			return
+		}
+
+		cc.log.Errorf("AddSegmentCtrl: %+v", err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cc.buildResponse(w, map[string]string{"segment_id": seg.SegmentID})
+}
+
+//CountSegmentContactsCtrl: count the members of the segment
+func (cc *contactController) CountSegmentContactsCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	segmentID := mux.Vars(r)["segment_id"]
+	if !cc.checkSegmentID(segmentID) {
+		cc.log.Infoln("Invalid segment_id value provided")
+		cc.handleError(w, http.StatusBadRequest, "Invalid segment_id value provided.")
+		return
+	}
+
+	count, err := cc.contactService.CountSegmentContacts(middleware.AccountID(r.Context()), segmentID)
+	if err != nil {
+		cc.handleSegmentError(w, "CountSegmentContactsCtrl", err)
+		return
+	}
+
+	cc.buildResponse(w, map[string]int64{"total_contacts": count})
+}
+
+//GetSegmentContactsCtrl: get the first page of the members of the segment
+func (cc *contactController) GetSegmentContactsCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	cc.getSegmentContactsPage(w, r, "")
+}
+
+//GetSegmentContactsBookmarkCtrl: get the page of the members of the segment after the bookmark
+func (cc *contactController) GetSegmentContactsBookmarkCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	bookmark := mux.Vars(r)["bookmark"]
+	if bookmark == "" {
+		cc.log.Infoln("GetSegmentContactsBookmarkCtrl: bookmark is nil")
+		cc.handleError(w, http.StatusBadRequest, "Invalid bookmark provided.")
+		return
+	}
+
+	cc.getSegmentContactsPage(w, r, bookmark)
+}
+
+func (cc *contactController) getSegmentContactsPage(w http.ResponseWriter, r *http.Request, bookmark string) {
+	segmentID := mux.Vars(r)["segment_id"]
+	if !cc.checkSegmentID(segmentID) {
+		cc.log.Infoln("Invalid segment_id value provided")
+		cc.handleError(w, http.StatusBadRequest, "Invalid segment_id value provided.")
+		return
+	}
+
+	page, err := cc.contactService.GetSegmentContacts(middleware.AccountID(r.Context()), segmentID, bookmark)
+	if err != nil {
+		cc.handleSegmentError(w, "getSegmentContactsPage", err)
+		return
+	}
+
+	cc.buildResponse(w, cc.wirePage(r, page))
+}
+
+func (cc *contactController) handleSegmentError(w http.ResponseWriter, name string, err error) {
+	if exprErr, ok := errors.Cause(err).(*segment.Error); ok {
+		//the custom fields of the expression are not registered any more
+		cc.log.Errorf("%s: %+v", name, err)
+		cc.handleError(w, http.StatusInternalServerError, "Invalid segment expression: "+exprErr.Error()+".")
+		return
+	}
+
+	switch errors.Cause(err) {
+	case entities.ErrSegmentNotFound:
+		cc.log.Infof("%s: %+v", name, err)
+		cc.handleError(w, http.StatusNotFound, "Segment could not be found.")
+	case entities.ErrInvalidBookmark:
+		cc.log.Infof("%s: %+v", name, err)
+		cc.handleError(w, http.StatusBadRequest, "Invalid bookmark provided.")
+	default:
+		cc.log.Errorf("%s: %+v", name, err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (cc *contactController) checkSegmentID(value string) bool {
+	return strings.HasPrefix(value, entities.SegmentIDPrefix) && len(value) > len(entities.SegmentIDPrefix)
+}
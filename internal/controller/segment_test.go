@@ -0,0 +1,125 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/STreeChin/contactapi/internal/controller"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/pkg/segment"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const mockSegmentID = "segment_0D4B6A2E-3C1F-4E8A-9B7D-5F2C8A1E6B30"
+
+// TestAddSegmentCtrl Use GoConvey test framework
+func TestAddSegmentCtrl(t *testing.T) {
+	Convey("AddSegmentCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		act := "POST"
+		segmentsURL := "/v1/segments"
+
+		Convey("UT Normal Case1: 200, add a segment", func() {
+			req, w := formHTTTest(act, segmentsURL, []byte(`{"name": "US leads", "expression": "Status=Lead AND MailingCountry=US"}`))
+			gomock.InOrder(
+				mockSrv.EXPECT().AddSegment("", "US leads", "Status=Lead AND MailingCountry=US").Return(&entities.Segment{SegmentID: mockSegmentID}, nil),
+			)
+
+			cCtrl.AddSegmentCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			result := map[string]string{}
+			_ = json.NewDecoder(w.Body).Decode(&result)
+			So(result["segment_id"], ShouldEqual, mockSegmentID)
+		})
+
+		Convey("UT AbNormal Case1: 400, invalid expression", func() {
+			req, w := formHTTTest(act, segmentsURL, []byte(`{"name": "US leads", "expression": "Status="}`))
+			gomock.InOrder(
+				mockSrv.EXPECT().AddSegment("", "US leads", "Status=").Return(nil, &segment.Error{Pos: 7, Message: "expected a value"}),
+			)
+
+			cCtrl.AddSegmentCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			result := map[string]string{}
+			_ = json.NewDecoder(w.Body).Decode(&result)
+			So(result["message"], ShouldEqual, "Invalid segment expression: expected a value at position 7.")
+		})
+
+		Convey("UT AbNormal Case2: 400, no expression", func() {
+			req, w := formHTTTest(act, segmentsURL, []byte(`{"name": "US leads"}`))
+
+			cCtrl.AddSegmentCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
+
+// TestSegmentContactsCtrl Use GoConvey test framework
+func TestSegmentContactsCtrl(t *testing.T) {
+	Convey("SegmentContactsCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		act := "GET"
+		defer monkey.UnpatchAll()
+		monkey.Patch(mux.Vars, func(r *http.Request) map[string]string {
+			return map[string]string{"segment_id": mockSegmentID}
+		})
+
+		Convey("UT Normal Case1: 200, count the members", func() {
+			req, w := formHTTTest(act, "/v1/segment/"+mockSegmentID+"/count", nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().CountSegmentContacts("", mockSegmentID).Return(int64(3), nil),
+			)
+
+			cCtrl.CountSegmentContactsCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			result := map[string]int64{}
+			_ = json.NewDecoder(w.Body).Decode(&result)
+			So(result["total_contacts"], ShouldEqual, 3)
+		})
+
+		Convey("UT Normal Case2: 200, the first page of the members", func() {
+			req, w := formHTTTest(act, "/v1/segment/"+mockSegmentID+"/contacts", nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().GetSegmentContacts("", mockSegmentID, "").Return(&entities.ContactPage{Contacts: []*entities.Contact{&mockContact}, TotalContacts: 1}, nil),
+			)
+
+			cCtrl.GetSegmentContactsCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			page := new(entities.ContactPage)
+			_ = json.NewDecoder(w.Body).Decode(page)
+			So(page.Contacts[0].Email, ShouldEqual, mockContact.Email)
+		})
+
+		Convey("UT AbNormal Case1: 404, segment could not be found", func() {
+			req, w := formHTTTest(act, "/v1/segment/"+mockSegmentID+"/count", nil)
+			gomock.InOrder(
+				mockSrv.EXPECT().CountSegmentContacts("", mockSegmentID).Return(int64(0), entities.ErrSegmentNotFound),
+			)
+
+			cCtrl.CountSegmentContactsCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/route/middleware"
+)
+
+//the usage report is of the last defaultUsageDays days if the request does not tell, never of more than maxUsageDays
+const (
+	defaultUsageDays = 30
+	maxUsageDays     = 366
+)
+
+//GetUsageCtrl: get the usage of the api keys of the account by day, from and to are days like 2020-05-01, key a key id
+func (cc *contactController) GetUsageCtrl(w http.ResponseWriter, r *http.Request) {
+	if w == nil || r == nil {
+		cc.log.Warningln("http input nil")
+		return
+	}
+
+	query := r.URL.Query()
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, 1-defaultUsageDays)
+	var err error
+	if value := query.Get("to"); value != "" {
+		if to, err = time.Parse(entities.UsageDayLayout, value); err != nil {
+			cc.log.Infof("GetUsageCtrl: %+v", err)
+			cc.handleError(w, http.StatusBadRequest, "Invalid to provided.")
+			return
+		}
+		from = to.AddDate(0, 0, 1-defaultUsageDays)
+	}
+	if value := query.Get("from"); value != "" {
+		if from, err = time.Parse(entities.UsageDayLayout, value); err != nil {
+			cc.log.Infof("GetUsageCtrl: %+v", err)
+			cc.handleError(w, http.StatusBadRequest, "Invalid from provided.")
+			return
+		}
+	}
+	if from.After(to) || to.Sub(from) >= maxUsageDays*24*time.Hour {
+		cc.log.Infof("GetUsageCtrl: invalid range %s %s", from, to)
+		cc.handleError(w, http.StatusBadRequest, "Invalid range provided.")
+		return
+	}
+
+	report, err := cc.contactService.GetUsageReport(middleware.AccountID(r.Context()), query.Get("key"), from, to)
+	if err != nil {
+		cc.log.Errorf("GetUsageCtrl: %+v", err)
+		cc.handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cc.buildResponse(w, report)
+}
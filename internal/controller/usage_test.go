@@ -0,0 +1,65 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/STreeChin/contactapi/internal/controller"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestGetUsageCtrl Use GoConvey test framework
+func TestGetUsageCtrl(t *testing.T) {
+	Convey("GetUsageCtrl", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockSrv := mocks.NewMockContactService(ctl)
+		cCtrl := controller.NewContactController(logger, mockSrv)
+		act := "GET"
+		usageURL := "/v1/admin/usage"
+
+		Convey("UT Normal Case1: 200, the range and the key of the request", func() {
+			req, w := formHTTTest(act, usageURL+"?from=2020-05-01&to=2020-05-31&key=ak_0123456789abcdef", nil)
+			report := &entities.UsageReport{From: "2020-05-01", To: "2020-05-31", Usage: []*entities.Usage{
+				{KeyID: "ak_0123456789abcdef", Day: "2020-05-02", Requests: 10, Writes: 4, BulkRows: 200, Contacts: 150},
+			}}
+			report.Total.Add(report.Usage[0])
+			mockSrv.EXPECT().GetUsageReport("", "ak_0123456789abcdef", time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 5, 31, 0, 0, 0, 0, time.UTC)).Return(report, nil)
+
+			cCtrl.GetUsageCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			result := new(entities.UsageReport)
+			_ = json.NewDecoder(w.Body).Decode(result)
+			So(result, ShouldResemble, report)
+		})
+
+		Convey("UT Normal Case2: 200, the last 30 days by default", func() {
+			req, w := formHTTTest(act, usageURL, nil)
+			today := time.Now().UTC().Truncate(24 * time.Hour)
+			mockSrv.EXPECT().GetUsageReport("", "", today.AddDate(0, 0, -29), today).Return(&entities.UsageReport{}, nil)
+
+			cCtrl.GetUsageCtrl(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("UT AbNormal Case1: 400, invalid days or range", func() {
+			for _, query := range []string{"?from=yesterday", "?to=2020-13-01", "?from=2020-06-01&to=2020-05-01", "?from=2019-01-01&to=2020-05-01"} {
+				req, w := formHTTTest(act, usageURL+query, nil)
+
+				cCtrl.GetUsageCtrl(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			}
+		})
+	})
+}
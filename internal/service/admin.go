@@ -0,0 +1,12 @@
+package service
+
+import (
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/pkg/errors"
+)
+
+//GetReencryption: get the progress of the re-encryption of the stored values by the current key
+func (c *contactService) GetReencryption() (*entities.Reencryption, error) {
+	progress, err := c.rep.GetReencryption()
+	return progress, errors.Wrap(err, "service GetReencryption")
+}
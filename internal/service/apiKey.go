@@ -0,0 +1,106 @@
+package service
+
+import (
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/route/middleware/crpt"
+	"github.com/pkg/errors"
+)
+
+//rotatedAPIKeyGrace the old key of a rotation keeps working for this long, the clients switch to the new one meanwhile
+const rotatedAPIKeyGrace = 24 * time.Hour
+
+//GetAPIKeys: get the api keys of the account, without their secrets
+func (c *contactService) GetAPIKeys(account string) ([]*entities.APIKey, error) {
+	apiKeys, err := c.rep.GetAPIKeys(account)
+	return apiKeys, errors.Wrap(err, "service GetAPIKeys")
+}
+
+//CreateAPIKey: create an api key with the scopes, the rate limits and the quotas in the account, it expires after entities.DefaultAPIKeyLifetime if expiresAt is zero,
+//the key is only in the returned one
+func (c *contactService) CreateAPIKey(account, name string, scopes []string, rateLimits map[string]int, quotas map[string]int64, expiresAt time.Time) (*entities.APIKey, error) {
+	if expiresAt.IsZero() {
+		expiresAt = c.now().Add(entities.DefaultAPIKeyLifetime)
+	}
+
+	apiKey, err := c.newAPIKey(account, name, scopes, rateLimits, quotas, expiresAt)
+	return apiKey, errors.Wrap(err, "service CreateAPIKey")
+}
+
+//RotateAPIKey: replace the api key by a new one with the same name, scopes, rate limits, quotas and lifetime, the old one expires after rotatedAPIKeyGrace;
+//the new key is revoked if the old one can not be expired
+func (c *contactService) RotateAPIKey(account, keyID string) (*entities.APIKey, error) {
+	old, err := c.rep.GetAPIKey(account, keyID)
+	if err != nil {
+		return nil, errors.Wrap(err, "service RotateAPIKey")
+	}
+	if old.RevokedAt != nil {
+		return nil, errors.Wrap(entities.ErrAPIKeyRevoked, "service RotateAPIKey")
+	}
+
+	now := c.now()
+	lifetime := old.ExpiresAt.Sub(old.CreatTime)
+	if lifetime <= 0 {
+		lifetime = entities.DefaultAPIKeyLifetime
+	}
+	apiKey, err := c.newAPIKey(account, old.Name, old.Scopes, old.RateLimits, old.Quotas, now.Add(lifetime))
+	if err != nil {
+		return nil, errors.Wrap(err, "service RotateAPIKey")
+	}
+
+	err = c.rep.ExpireAPIKey(account, keyID, now.Add(rotatedAPIKeyGrace))
+	if err != nil {
+		//the new key is not returned, it must not stay active beside the old one
+		if revokeErr := c.rep.RevokeAPIKey(account, apiKey.KeyID, now); revokeErr != nil {
+			c.log.Errorf("service RotateAPIKey revoke the new key %s: %+v", apiKey.KeyID, revokeErr)
+		}
+		return nil, errors.Wrap(err, "service RotateAPIKey")
+	}
+	//the cached principal of the old key would outlive its new expiry
+	c.invalidatePrincipals(keyID)
+
+	return apiKey, nil
+}
+
+//RevokeAPIKey: revoke the api key at once, the cached principals of the key are dropped by every instance,
+//later by RetryInvalidations while redis is down; a revoked key keeps the time it was first revoked
+func (c *contactService) RevokeAPIKey(account, keyID string) error {
+	apiKey, err := c.rep.GetAPIKey(account, keyID)
+	if err != nil {
+		return errors.Wrap(err, "service RevokeAPIKey")
+	}
+	if apiKey.RevokedAt == nil {
+		err = c.rep.RevokeAPIKey(account, keyID, c.now())
+		if err != nil {
+			return errors.Wrap(err, "service RevokeAPIKey")
+		}
+	}
+
+	//again for a key revoked before, in case the caches missed it
+	c.invalidatePrincipals(keyID)
+	return nil
+}
+
+func (c *contactService) newAPIKey(account, name string, scopes []string, rateLimits map[string]int, quotas map[string]int64, expiresAt time.Time) (*entities.APIKey, error) {
+	keyID, key, err := crpt.NewAPIKey(entities.APIKeyIDPrefix)
+	if err != nil {
+		return nil, err
+	}
+	apiKey := &entities.APIKey{
+		KeyID:      keyID,
+		Name:       name,
+		Scopes:     scopes,
+		RateLimits: rateLimits,
+		Quotas:     quotas,
+		Key:        key,
+		CreatTime:  c.now(),
+		ExpiresAt:  expiresAt.UTC().Truncate(time.Millisecond),
+	}
+
+	err = c.rep.InsertAPIKey(account, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
@@ -0,0 +1,154 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/STreeChin/contactapi/internal/service"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestAPIKeys
+func TestAPIKeys(t *testing.T) {
+	Convey("TestAPIKeys", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		account, keyID := "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23", "ak_0123456789abcdef"
+		created := time.Date(2020, 5, 1, 8, 0, 0, 0, time.UTC)
+		stored := &entities.APIKey{KeyID: keyID, Name: "zapier", Scopes: []string{entities.ScopeContactsRead}, RateLimits: map[string]int{entities.RateClassBulk: 10}, Quotas: map[string]int64{entities.UsageWrites: 1000}, CreatTime: created, ExpiresAt: created.Add(30 * 24 * time.Hour)}
+
+		Convey("Normal Case1: create, the key is returned once, it expires by default after a year", func() {
+			var inserted *entities.APIKey
+			mockRep.EXPECT().InsertAPIKey(account, gomock.Any()).DoAndReturn(func(account string, apiKey *entities.APIKey) error {
+				inserted = apiKey
+				return nil
+			})
+
+			apiKey, err := cSrv.CreateAPIKey(account, "zapier", []string{entities.ScopeContactsRead}, nil, nil, time.Time{})
+			So(err, ShouldEqual, nil)
+			So(apiKey, ShouldEqual, inserted)
+			So(strings.HasPrefix(apiKey.Key, apiKey.KeyID+"."), ShouldBeTrue)
+			So(strings.HasPrefix(apiKey.KeyID, entities.APIKeyIDPrefix), ShouldBeTrue)
+			So(apiKey.Scopes, ShouldResemble, []string{entities.ScopeContactsRead})
+			So(apiKey.ExpiresAt.Sub(apiKey.CreatTime), ShouldEqual, entities.DefaultAPIKeyLifetime)
+		})
+
+		Convey("Normal Case2: rotate, the new key keeps the name, the scopes, the rate limits, the quotas and the lifetime, the old one expires after the grace", func() {
+			gomock.InOrder(
+				mockRep.EXPECT().GetAPIKey(account, keyID).Return(stored, nil),
+				mockRep.EXPECT().InsertAPIKey(account, gomock.Any()).Return(nil),
+				mockRep.EXPECT().ExpireAPIKey(account, keyID, gomock.Any()).DoAndReturn(func(account, keyID string, expireTime time.Time) error {
+					So(time.Until(expireTime), ShouldBeBetween, 23*time.Hour, 25*time.Hour)
+					return nil
+				}),
+				mockCache.EXPECT().DelPrincipals(keyID).Return(nil),
+			)
+
+			apiKey, err := cSrv.RotateAPIKey(account, keyID)
+			So(err, ShouldEqual, nil)
+			So(apiKey.KeyID, ShouldNotEqual, keyID)
+			So(apiKey.Name, ShouldEqual, "zapier")
+			So(apiKey.Scopes, ShouldResemble, stored.Scopes)
+			So(apiKey.RateLimits, ShouldResemble, stored.RateLimits)
+			So(apiKey.Quotas, ShouldResemble, stored.Quotas)
+			So(apiKey.ExpiresAt.Sub(apiKey.CreatTime), ShouldEqual, 30*24*time.Hour)
+		})
+
+		Convey("Normal Case3: revoke, a revoked key keeps its first revocation, the cached principals are dropped both times", func() {
+			gomock.InOrder(
+				mockRep.EXPECT().GetAPIKey(account, keyID).Return(stored, nil),
+				mockRep.EXPECT().RevokeAPIKey(account, keyID, gomock.Any()).Return(nil),
+				mockCache.EXPECT().DelPrincipals(keyID).Return(nil),
+			)
+			So(cSrv.RevokeAPIKey(account, keyID), ShouldEqual, nil)
+
+			revoked := *stored
+			revoked.RevokedAt = &created
+			mockRep.EXPECT().GetAPIKey(account, keyID).Return(&revoked, nil)
+			mockCache.EXPECT().DelPrincipals(keyID).Return(nil)
+			So(cSrv.RevokeAPIKey(account, keyID), ShouldEqual, nil)
+		})
+
+		Convey("AbNormal Case1: rotate a revoked key", func() {
+			revoked := *stored
+			revoked.RevokedAt = &created
+			mockRep.EXPECT().GetAPIKey(account, keyID).Return(&revoked, nil)
+
+			_, err := cSrv.RotateAPIKey(account, keyID)
+			So(errors.Cause(err), ShouldEqual, entities.ErrAPIKeyRevoked)
+		})
+
+		Convey("AbNormal Case2: the key is not in the account", func() {
+			mockRep.EXPECT().GetAPIKey(account, keyID).Return(nil, errors.WithStack(entities.ErrAPIKeyNotFound)).Times(2)
+
+			_, err := cSrv.RotateAPIKey(account, keyID)
+			So(errors.Cause(err), ShouldEqual, entities.ErrAPIKeyNotFound)
+			err = cSrv.RevokeAPIKey(account, keyID)
+			So(errors.Cause(err), ShouldEqual, entities.ErrAPIKeyNotFound)
+		})
+
+		Convey("AbNormal Case3: the caches can not be told, the revocation is done and they are told by the retry", func() {
+			errDown := errors.New("redis down")
+			gomock.InOrder(
+				mockRep.EXPECT().GetAPIKey(account, keyID).Return(stored, nil),
+				mockRep.EXPECT().RevokeAPIKey(account, keyID, gomock.Any()).Return(nil),
+				mockCache.EXPECT().DelPrincipals(keyID).Return(errDown),
+				mockCache.EXPECT().DelPrincipals(keyID).Return(errDown),
+				mockCache.EXPECT().DelPrincipals(keyID).Return(nil),
+			)
+			So(cSrv.RevokeAPIKey(account, keyID), ShouldEqual, nil)
+			So(cSrv.GetHealth().Cache.PendingInvalidations, ShouldEqual, 1)
+
+			So(errors.Cause(cSrv.RetryInvalidations()), ShouldEqual, errDown)
+			So(cSrv.GetHealth().Cache.PendingInvalidations, ShouldEqual, 1)
+			So(cSrv.RetryInvalidations(), ShouldEqual, nil)
+			So(cSrv.GetHealth().Status, ShouldEqual, entities.HealthOK)
+		})
+
+		Convey("AbNormal Case4: the old key can not be expired, the rotation fails and the new key is revoked", func() {
+			errDB := errors.New("mongo down")
+			var inserted *entities.APIKey
+			gomock.InOrder(
+				mockRep.EXPECT().GetAPIKey(account, keyID).Return(stored, nil),
+				mockRep.EXPECT().InsertAPIKey(account, gomock.Any()).DoAndReturn(func(account string, apiKey *entities.APIKey) error {
+					inserted = apiKey
+					return nil
+				}),
+				mockRep.EXPECT().ExpireAPIKey(account, keyID, gomock.Any()).Return(errDB),
+				mockRep.EXPECT().RevokeAPIKey(account, gomock.Any(), gomock.Any()).DoAndReturn(func(account, newKeyID string, revokeTime time.Time) error {
+					So(newKeyID, ShouldEqual, inserted.KeyID)
+					return nil
+				}),
+			)
+
+			apiKey, err := cSrv.RotateAPIKey(account, keyID)
+			So(errors.Cause(err), ShouldEqual, errDB)
+			So(apiKey, ShouldEqual, nil)
+		})
+
+		Convey("AbNormal Case5: the caches can not be told, the rotation is done and they are told by the retry", func() {
+			gomock.InOrder(
+				mockRep.EXPECT().GetAPIKey(account, keyID).Return(stored, nil),
+				mockRep.EXPECT().InsertAPIKey(account, gomock.Any()).Return(nil),
+				mockRep.EXPECT().ExpireAPIKey(account, keyID, gomock.Any()).Return(nil),
+				mockCache.EXPECT().DelPrincipals(keyID).Return(errors.New("redis down")),
+			)
+
+			apiKey, err := cSrv.RotateAPIKey(account, keyID)
+			So(err, ShouldEqual, nil)
+			So(apiKey.KeyID, ShouldNotEqual, keyID)
+			So(cSrv.GetHealth().Cache.PendingInvalidations, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+//the circuit breaker opens after the failures of redis in a row, then tries it again after the cooldown
+const (
+	breakerFailures = 5
+	breakerCooldown = 10 * time.Second
+)
+
+//cacheBreaker the circuit breaker around the cache: while redis is down the calls fail at once with
+//entities.ErrCacheUnavailable instead of waiting for it, the misses of the cache are not failures.
+//The middlewares calling redis share it through Allow and Done
+type cacheBreaker struct {
+	log   *logrus.Logger
+	cache Cache
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	//probing the call trying redis again is not done yet
+	probing bool
+	//generation the number of the state, it changes with it
+	generation uint64
+}
+
+func newCacheBreaker(log *logrus.Logger, cache Cache) *cacheBreaker {
+	return &cacheBreaker{log: log, cache: cache, state: entities.BreakerClosed}
+}
+
+//Allow the call may go to redis, one at a time once the cooldown is over: the generation of the state it is allowed in
+//goes back to Done with its result
+func (b *cacheBreaker) Allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case entities.BreakerClosed:
+		return b.generation, nil
+	case entities.BreakerOpen:
+		if time.Now().Sub(b.openedAt) >= breakerCooldown {
+			b.setState(entities.BreakerHalfOpen)
+			b.probing = true
+			return b.generation, nil
+		}
+	case entities.BreakerHalfOpen:
+		if !b.probing {
+			b.probing = true
+			return b.generation, nil
+		}
+	}
+	return 0, errors.WithStack(entities.ErrCacheUnavailable)
+}
+
+//Done record the result of the call allowed in the generation, the misses and the invalid api keys cached are answers of redis;
+//the results of the calls allowed before the state changed are ignored: a slow call of the closed breaker does not close it again
+func (b *cacheBreaker) Done(generation uint64, err error) {
+	cause := errors.Cause(err)
+	failed := err != nil && cause != redis.ErrNil && cause != entities.ErrCachedNotFound && cause != entities.ErrInvalidAPIKey
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+	b.probing = false
+	if !failed {
+		if b.state == entities.BreakerHalfOpen {
+			b.log.Infoln("cache breaker closed, redis answers again")
+			b.setState(entities.BreakerClosed)
+		}
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == entities.BreakerHalfOpen || b.failures >= breakerFailures {
+		b.log.Errorf("cache breaker open for %s after %d failures: %+v", breakerCooldown, b.failures, err)
+		b.setState(entities.BreakerOpen)
+		b.openedAt = time.Now()
+	}
+}
+
+//setState a new generation begins with the state
+func (b *cacheBreaker) setState(state string) {
+	b.state = state
+	b.generation++
+}
+
+func (b *cacheBreaker) health() entities.CacheHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	health := entities.CacheHealth{Breaker: b.state, Failures: b.failures}
+	if b.state != entities.BreakerClosed {
+		openedAt := b.openedAt.UTC()
+		health.OpenedAt = &openedAt
+	}
+	return health
+}
+
+func (b *cacheBreaker) GetEmailByContactID(account, key string) (string, error) {
+	generation, err := b.Allow()
+	if err != nil {
+		return "", err
+	}
+	email, err := b.cache.GetEmailByContactID(account, key)
+	b.Done(generation, err)
+	return email, err
+}
+
+func (b *cacheBreaker) SetEmailByContactID(account, key, value string) error {
+	generation, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = b.cache.SetEmailByContactID(account, key, value)
+	b.Done(generation, err)
+	return err
+}
+
+func (b *cacheBreaker) GetOneContact(account, value string) (*entities.Contact, error) {
+	generation, err := b.Allow()
+	if err != nil {
+		return nil, err
+	}
+	contact, err := b.cache.GetOneContact(account, value)
+	b.Done(generation, err)
+	return contact, err
+}
+
+func (b *cacheBreaker) SetOneContact(account, value string, contact *entities.Contact) error {
+	generation, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = b.cache.SetOneContact(account, value, contact)
+	b.Done(generation, err)
+	return err
+}
+
+func (b *cacheBreaker) SetNotFound(account, value string) error {
+	generation, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = b.cache.SetNotFound(account, value)
+	b.Done(generation, err)
+	return err
+}
+
+func (b *cacheBreaker) DelContacts(account string, values ...string) error {
+	generation, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = b.cache.DelContacts(account, values...)
+	b.Done(generation, err)
+	return err
+}
+
+func (b *cacheBreaker) DelPrincipals(keyID string) error {
+	generation, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = b.cache.DelPrincipals(keyID)
+	b.Done(generation, err)
+	return err
+}
+
+func (b *cacheBreaker) GetUsages(day string) ([]*entities.Usage, error) {
+	generation, err := b.Allow()
+	if err != nil {
+		return nil, err
+	}
+	usages, err := b.cache.GetUsages(day)
+	b.Done(generation, err)
+	return usages, err
+}
@@ -0,0 +1,129 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"github.com/STreeChin/contactapi/internal/service"
+	"github.com/STreeChin/contactapi/pkg/config"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestCacheBreaker redis failing in a row opens the breaker: the reads go to the db, the writes wait to drop their keys
+func TestCacheBreaker(t *testing.T) {
+	Convey("TestCacheBreaker", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{}).AnyTimes()
+
+		errDown := errors.New("redis down")
+		email := getContact.Email
+		mockRep.EXPECT().GetOneContact("", "email", email).Return(&getContact, nil).AnyTimes()
+
+		//the misses are not failures
+		gomock.InOrder(
+			mockCache.EXPECT().GetOneContact("", email).Return(nil, errors.WithStack(redis.ErrNil)),
+			mockCache.EXPECT().SetOneContact("", email, &getContact).Return(nil),
+			mockCache.EXPECT().SetEmailByContactID("", getContact.ContactID, email).Return(nil),
+		)
+		_, err := cSrv.GetOneContact("", "email", email)
+		So(err, ShouldEqual, nil)
+
+		mockCache.EXPECT().GetOneContact("", email).Return(nil, errDown).Times(2)
+		mockCache.EXPECT().SetOneContact("", email, &getContact).Return(errDown).Times(2)
+		mockCache.EXPECT().SetEmailByContactID("", getContact.ContactID, email).Return(errDown)
+		for i := 0; i < 2; i++ {
+			cont, err := cSrv.GetOneContact("", "email", email)
+			So(err, ShouldEqual, nil)
+			So(cont.ContactID, ShouldEqual, getContact.ContactID)
+		}
+		health := cSrv.GetHealth()
+		So(health.Status, ShouldEqual, entities.HealthDegraded)
+		So(health.Cache.Breaker, ShouldEqual, entities.BreakerOpen)
+		So(health.Cache.Failures, ShouldEqual, 5)
+		So(health.Cache.OpenedAt, ShouldNotEqual, nil)
+
+		Convey("Normal Case1: while it is open redis is not called", func() {
+			cont, err := cSrv.GetOneContact("", "email", email)
+			So(err, ShouldEqual, nil)
+			So(cont.ContactID, ShouldEqual, getContact.ContactID)
+
+			stored := getContact
+			contact := postContact
+			gomock.InOrder(
+				mockRep.EXPECT().GetSuppressions("", []string{email}).Return(map[string]*entities.Unsubscription{}, nil),
+				mockRep.EXPECT().UpsertOneContact("", &contact).Return(&stored, nil),
+			)
+			_, _, err = cSrv.AddOrUpdateContact("", &contact)
+			So(err, ShouldEqual, nil)
+			So(cSrv.GetHealth().Cache.PendingInvalidations, ShouldEqual, 2)
+			So(errors.Cause(cSrv.RetryInvalidations()), ShouldEqual, entities.ErrCacheUnavailable)
+		})
+
+		Convey("Normal Case2: after the cooldown one call tries redis, it closes the breaker", func() {
+			later := time.Now().Add(time.Minute)
+			monkey.Patch(time.Now, func() time.Time { return later })
+			defer monkey.UnpatchAll()
+
+			gomock.InOrder(
+				mockCache.EXPECT().GetOneContact("", email).Return(&getContact, nil),
+				mockCache.EXPECT().GetOneContact("", email).Return(&getContact, nil),
+			)
+			for i := 0; i < 2; i++ {
+				_, err := cSrv.GetOneContact("", "email", email)
+				So(err, ShouldEqual, nil)
+			}
+			health := cSrv.GetHealth()
+			So(health.Status, ShouldEqual, entities.HealthOK)
+			So(health.Cache.Breaker, ShouldEqual, entities.BreakerClosed)
+			So(health.Cache.Failures, ShouldEqual, 0)
+		})
+
+		Convey("AbNormal Case1: the call after the cooldown fails, the breaker opens again", func() {
+			later := time.Now().Add(time.Minute)
+			monkey.Patch(time.Now, func() time.Time { return later })
+			defer monkey.UnpatchAll()
+
+			mockCache.EXPECT().GetOneContact("", email).Return(nil, errDown)
+			for i := 0; i < 2; i++ {
+				_, err := cSrv.GetOneContact("", "email", email)
+				So(err, ShouldEqual, nil)
+			}
+			So(cSrv.GetHealth().Cache.Breaker, ShouldEqual, entities.BreakerOpen)
+		})
+
+		Convey("AbNormal Case2: the late result of a call allowed in an earlier state is ignored", func() {
+			later := time.Now().Add(time.Minute)
+			monkey.Patch(time.Now, func() time.Time { return later })
+			defer monkey.UnpatchAll()
+			breaker := cSrv.CacheBreaker()
+
+			stale, err := breaker.Allow()
+			So(err, ShouldEqual, nil)
+			breaker.Done(stale, errDown)
+			So(cSrv.GetHealth().Cache.Breaker, ShouldEqual, entities.BreakerOpen)
+
+			later = later.Add(time.Minute)
+			probe, err := breaker.Allow()
+			So(err, ShouldEqual, nil)
+			So(probe, ShouldNotEqual, stale)
+			breaker.Done(stale, nil)
+			So(cSrv.GetHealth().Cache.Breaker, ShouldEqual, entities.BreakerHalfOpen)
+
+			breaker.Done(probe, nil)
+			So(cSrv.GetHealth().Cache.Breaker, ShouldEqual, entities.BreakerClosed)
+		})
+	})
+}
@@ -1,109 +1,579 @@
 package service
 
 import (
+	"encoding/json"
+	"reflect"
+	"time"
+
 	"github.com/STreeChin/contactapi/pkg/config"
 	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/mergepatch"
+	"github.com/STreeChin/contactapi/pkg/segment"
+	"github.com/go-playground/validator/v10"
 	"github.com/gomodule/redigo/redis"
-	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/sync/singleflight"
 )
 
 //Repository interface
 type Repository interface {
-	GetOneContact(key, value string) (*entities.Contact, error)
-	InsertOneContact(contact *entities.Contact) error
-	UpdateOneContact(contact *entities.Contact) error
-	GetContactIDByAPIKey(apiKey string) (string, error)
+	GetOneContact(account, key, value string) (*entities.Contact, error)
+	UpdateOneContact(account string, contact *entities.Contact) error
+	UpsertOneContact(account string, contact *entities.Contact) (*entities.Contact, error)
+	DeleteOneContact(account, key, value string) error
+	GetPrincipalByAPIKey(apiKey string) (*entities.Principal, error)
+	GetContacts(account, bookmark string, limit int64) ([]*entities.Contact, string, error)
+	CountContacts(account string) (int64, error)
+	SetUnsubscription(account, email string, unsub *entities.Unsubscription, updateTime time.Time) error
+	AddSuppression(account, email string, unsub *entities.Unsubscription) error
+	DelSuppression(account, email string) error
+	GetSuppressions(account string, emails []string) (map[string]*entities.Unsubscription, error)
+	UpsertContacts(account string, contacts []*entities.Contact) ([]entities.BulkContactResult, error)
+	GetCustomFields(account string) ([]*entities.CustomField, error)
+	RegisterCustomField(account string, field *entities.CustomField) (*entities.CustomField, error)
+	InsertList(account string, list *entities.List) error
+	GetLists(account string) ([]*entities.List, error)
+	GetList(account, listID string) (*entities.List, error)
+	AddToList(account, email, listID string) error
+	RemoveFromList(account, email, listID string) error
+	GetListContacts(account, listID, bookmark string, limit int64) ([]*entities.Contact, string, error)
+	CountListContacts(account, listID string) (int64, error)
+	InsertSegment(account string, seg *entities.Segment) error
+	GetSegments(account string) ([]*entities.Segment, error)
+	GetSegment(account, segmentID string) (*entities.Segment, error)
+	GetSegmentContacts(account string, filter *segment.Filter, bookmark string, limit int64) ([]*entities.Contact, string, error)
+	CountSegmentContacts(account string, filter *segment.Filter) (int64, error)
+	GetReencryption() (*entities.Reencryption, error)
+	EraseContact(account string, contact *entities.Contact, erasure *entities.Erasure) error
+	InsertAPIKey(account string, apiKey *entities.APIKey) error
+	GetAPIKeys(account string) ([]*entities.APIKey, error)
+	GetAPIKey(account, keyID string) (*entities.APIKey, error)
+	RevokeAPIKey(account, keyID string, revokeTime time.Time) error
+	ExpireAPIKey(account, keyID string, expireTime time.Time) error
+	UpsertUsages(usages []*entities.Usage) error
+	GetUsages(account, keyID, from, to string) ([]*entities.Usage, error)
 }
 
-//Cache interface
+//Cache interface, the keys are in the namespace of the account
 type Cache interface {
-	GetEmailByContactID(key string) (string, error)
-	SetEmailByContactID(key, value string) error
-	GetOneContact(value string) (*entities.Contact, error)
-	SetOneContact(value string, contact *entities.Contact) error
-	DelOneContact(value string) error
+	GetEmailByContactID(account, key string) (string, error)
+	SetEmailByContactID(account, key, value string) error
+	GetOneContact(account, value string) (*entities.Contact, error)
+	SetOneContact(account, value string, contact *entities.Contact) error
+	SetNotFound(account, value string) error
+	DelContacts(account string, values ...string) error
+	DelPrincipals(keyID string) error
+	GetUsages(day string) ([]*entities.Usage, error)
 }
 
+//patchReadOnlyFields the fields of the contact can not be changed by the merge patch, whatever the read-only policy
+var patchReadOnlyFields = []string{"contact_id", "Email", "unsubscribed", "unsubscription", "lists"}
+
+//the contact limits if they are not configured
+const (
+	defaultPageSize  = 100
+	defaultBulkLimit = 100
+)
+
 type contactService struct {
-	log   *logrus.Logger
-	cfg   config.Config
-	cache Cache
-	rep   Repository
+	log *logrus.Logger
+	cfg config.Config
+	//cache the cache behind the breaker
+	cache   Cache
+	breaker *cacheBreaker
+	rep     Repository
+	//misses the concurrent misses of the cache on the same contact read the db once
+	misses        *singleflight.Group
+	invalidations *invalidations
 }
 
-//NewContactService instance
+//NewContactService instance, the cache is called through a circuit breaker
 func NewContactService(log *logrus.Logger, cfg config.Config, rs Cache, cr Repository) *contactService {
-	return &contactService{log, cfg, rs, cr}
+	breaker := newCacheBreaker(log, rs)
+	return &contactService{log, cfg, breaker, breaker, cr, new(singleflight.Group), newInvalidations()}
 }
 
-//GetOneContact: get one contact
-func (c *contactService) GetOneContact(key, value string) (*entities.Contact, error) {
+//GetOneContact: get one contact of the account, from the db if the cache misses it or is down
+func (c *contactService) GetOneContact(account, key, value string) (*entities.Contact, error) {
 	var err error
 	var email string
 	contact := new(entities.Contact)
 
+	if key != "contactid" && key != "email" {
+		return nil, errors.New("invalid key")
+	}
+	//the cache may still have the contact before a write could not drop it
+	if c.invalidations.pending(account, value) {
+		contact, err = c.rep.GetOneContact(account, key, value)
+		return contact, errors.Wrap(err, "service getOneContact pending")
+	}
+
 	if key == "contactid" {
 		//get the contactID by email from cache, cache: contactID->email->contact{}
-		email, err = c.cache.GetEmailByContactID(value)
+		email, err = c.cache.GetEmailByContactID(account, value)
 		if err == nil {
-			contact, err = c.cache.GetOneContact(email)
+			contact, err = c.cache.GetOneContact(account, email)
 		}
-	} else if key == "email" {
-		contact, err = c.cache.GetOneContact(value)
 	} else {
-		return nil, errors.New("invalid key")
+		contact, err = c.cache.GetOneContact(account, value)
+	}
+
+	switch errors.Cause(err) {
+	case nil:
+		return contact, nil
+	case entities.ErrCachedNotFound:
+		return nil, errors.Wrap(mongo.ErrNoDocuments, "service getOneContact cached")
+	case redis.ErrNil, entities.ErrCacheUnavailable:
+	default:
+		//the db still answers without redis
+		c.log.Warnf("service getOneContact cache: %+v", err)
 	}
 
-	if err != nil && errors.Cause(err) == redis.ErrNil {
-		contact, err = c.rep.GetOneContact(key, value)
-		if err != nil {
-			return contact, errors.Wrap(err, "service getOneContact")
+	//the requests missing the same contact at once wait for the first one, they get their own copy of its contact
+	result, err, shared := c.misses.Do(account+"\x00"+key+"\x00"+value, func() (interface{}, error) {
+		return c.readContact(account, key, value)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "service getOneContact")
+	}
+	contact = result.(*entities.Contact)
+	if shared {
+		contact = copyContact(contact)
+	}
+	return contact, nil
+}
+
+//copyContact a copy of the contact sharing nothing with it, its slices and maps included
+func copyContact(contact *entities.Contact) *entities.Contact {
+	own := *contact
+	if contact.Lists != nil {
+		own.Lists = append([]string(nil), contact.Lists...)
+	}
+	if contact.Unsubscription != nil {
+		unsub := *contact.Unsubscription
+		own.Unsubscription = &unsub
+	}
+	if contact.Custom != nil {
+		own.Custom = make(map[string]interface{}, len(contact.Custom))
+		for name, value := range contact.Custom {
+			own.Custom[name] = copyValue(value)
 		}
-		//cache: contactID->email->contact{}
-		err = c.cache.SetOneContact(contact.Email, contact)
-		if err != nil {
-			c.log.Error("service SetOneContact", err)
+	}
+	if contact.CustomTypes != nil {
+		own.CustomTypes = make(map[string]string, len(contact.CustomTypes))
+		for name, fieldType := range contact.CustomTypes {
+			own.CustomTypes[name] = fieldType
 		}
-		err = c.cache.SetEmailByContactID(contact.ContactID, contact.Email)
-		if err != nil {
-			c.log.Error("service SetOneContact", err)
+	}
+	return &own
+}
+
+//copyValue a copy of the custom value, the arrays and the documents in it are copied too
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, item := range v {
+			values[i] = copyValue(item)
 		}
-		//response to the the http if getting db success but setting cache fail, log the fail
-		err = nil
+		return values
+	case map[string]interface{}:
+		values := make(map[string]interface{}, len(v))
+		for name, item := range v {
+			values[name] = copyValue(item)
+		}
+		return values
+	case []string:
+		return append([]string(nil), v...)
+	default:
+		return v
+	}
+}
+
+//readContact read the contact from the db and cache it, or remember it is not there
+func (c *contactService) readContact(account, key, value string) (*entities.Contact, error) {
+	contact, err := c.rep.GetOneContact(account, key, value)
+	if err != nil {
+		if errors.Cause(err) == mongo.ErrNoDocuments {
+			if err := c.cache.SetNotFound(account, value); err != nil {
+				c.cacheError("service SetNotFound", err)
+			}
+		}
+		return nil, err
+	}
+
+	//cache: contactID->email->contact{}, the response goes on if setting the cache fails
+	err = c.cache.SetOneContact(account, contact.Email, contact)
+	if err != nil {
+		c.cacheError("service SetOneContact", err)
+	}
+	err = c.cache.SetEmailByContactID(account, contact.ContactID, contact.Email)
+	if err != nil {
+		c.cacheError("service SetOneContact", err)
 	}
 
-	return contact, err
+	return contact, nil
 }
 
-//AddOrUpdateContact: add or update contact
-func (c *contactService) AddOrUpdateContact(contact *entities.Contact) (string, error) {
-	var err error
+//cacheError log the error of the cache, not the calls refused by the open breaker: it logged redis is down
+func (c *contactService) cacheError(msg string, err error) {
+	if errors.Cause(err) != entities.ErrCacheUnavailable {
+		c.log.Error(msg, err)
+	}
+}
+
+//AddOrUpdateContact: add the contact or update the one with the same email in one atomic upsert, created tells which
+func (c *contactService) AddOrUpdateContact(account string, contact *entities.Contact) (string, bool, error) {
+	err := c.guardReadOnly(contact)
+	if err != nil {
+		return "", false, errors.Wrap(err, "service AddOrUpdateContact")
+	}
+
+	err = c.registerCustomFields(account, contact.CustomTypes)
+	if err != nil {
+		return "", false, errors.Wrap(err, "service AddOrUpdateContact")
+	}
+
+	err = c.applySuppressions(account, []*entities.Contact{contact})
+	if err != nil {
+		return "", false, errors.Wrap(err, "service AddOrUpdateContact")
+	}
+
+	//the repository keeps the id, the type and the created_at of the contact already stored
+	c.stampNew(contact)
+	stored, err := c.rep.UpsertOneContact(account, contact)
+	if err != nil {
+		return "", false, errors.Wrap(err, "service AddOrUpdateContact")
+	}
+	created := stored == nil
+	if !created {
+		c.keepStored(contact, stored)
+	}
+
+	//invalid cache: contactID->email->contact{}
+	c.invalidate(account, contact.Email, contact.ContactID)
+
+	return contact.ContactID, created, nil
+}
+
+//PatchContact: apply the json merge patch (RFC 7386) to one contact, the custom fields merge key by key
+func (c *contactService) PatchContact(account, key, value string, patch map[string]interface{}, customTypes map[string]string) (*entities.Contact, error) {
+	if key != "contactid" && key != "email" {
+		return nil, errors.New("invalid key")
+	}
+
+	contact, err := c.rep.GetOneContact(account, key, value)
+	if err != nil {
+		return nil, errors.Wrap(err, "service PatchContact")
+	}
 
-	if contact.ContactID == "" {
-		contact.ContactID = "person_AP2-" + uuid.New().String()
+	patch, err = c.guardReadOnlyPatch(patch)
+	if err != nil {
+		return nil, errors.Wrap(err, "service PatchContact")
 	}
 
-	_, err = c.rep.GetOneContact("email", contact.Email)
+	err = c.registerCustomFields(account, customTypes)
 	if err != nil {
-		err = c.rep.InsertOneContact(contact)
-		if err != nil {
-			return "", errors.Wrap(err, "service AddOrUpdateContact")
+		return nil, errors.Wrap(err, "service PatchContact")
+	}
+
+	patched, err := c.mergeContact(contact, patch)
+	if err != nil {
+		return nil, errors.Wrap(err, "service PatchContact")
+	}
+	c.keepStored(patched, contact)
+	patched.UpdateTime = c.now()
+	patched.CustomTypes = patchCustomTypes(patched.Custom, contact.CustomTypes, customTypes)
+
+	err = c.rep.UpdateOneContact(account, patched)
+	if err != nil {
+		return nil, errors.Wrap(err, "service PatchContact")
+	}
+
+	//invalid cache: contactID->email->contact{}
+	c.invalidate(account, patched.Email, patched.ContactID)
+
+	return patched, nil
+}
+
+//mergeContact merge the patch to a copy of the contact, the custom fields of the patch are already parsed
+func (c *contactService) mergeContact(contact *entities.Contact, patch map[string]interface{}) (*entities.Contact, error) {
+	//the custom values keep their types, only the other fields go through json
+	custom := contact.Custom
+	plain := *contact
+	plain.Custom = nil
+	raw, err := json.Marshal(&plain)
+	if err != nil {
+		return nil, errors.Wrap(err, "merge contact")
+	}
+	doc := map[string]interface{}{}
+	err = json.Unmarshal(raw, &doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "merge contact")
+	}
+
+	for _, field := range patchReadOnlyFields {
+		if patchValue, ok := patch[field]; ok && !reflect.DeepEqual(patchValue, doc[field]) {
+			return nil, errors.WithStack(&entities.ReadOnlyFieldError{Field: field})
 		}
-	} else {
-		err = c.rep.UpdateOneContact(contact)
-		if err != nil {
-			return "", errors.Wrap(err, "service AddOrUpdateContact")
+	}
+
+	docPatch := make(map[string]interface{}, len(patch))
+	for field, patchValue := range patch {
+		if field != "custom" {
+			docPatch[field] = patchValue
 		}
 	}
+	raw, err = json.Marshal(mergepatch.Apply(doc, docPatch))
+	if err != nil {
+		return nil, errors.Wrap(err, "merge contact")
+	}
+	patched := new(entities.Contact)
+	err = json.Unmarshal(raw, patched)
+	if err != nil {
+		return nil, errors.Wrap(entities.ErrInvalidPatch, err.Error())
+	}
+
+	customPatch, ok := patch["custom"]
+	switch customPatch := customPatch.(type) {
+	case nil:
+		if ok {
+			custom = nil
+		}
+	case map[string]interface{}:
+		if custom == nil {
+			custom = make(map[string]interface{}, len(customPatch))
+		}
+		for name, patchValue := range customPatch {
+			if patchValue == nil {
+				delete(custom, name)
+				continue
+			}
+			custom[name] = patchValue
+		}
+	default:
+		return nil, errors.Wrap(entities.ErrInvalidPatch, "custom is not an object")
+	}
+	patched.Custom = custom
+
+	return patched, nil
+}
+
+//patchCustomTypes the types of the custom fields after the patch, the patched fields take the types of the patch
+func patchCustomTypes(custom map[string]interface{}, stored, patched map[string]string) map[string]string {
+	if len(custom) == 0 {
+		return nil
+	}
+
+	types := make(map[string]string, len(custom))
+	for name := range custom {
+		if fieldType, ok := patched[name]; ok {
+			types[name] = fieldType
+		} else if fieldType, ok := stored[name]; ok {
+			types[name] = fieldType
+		}
+	}
+
+	return types
+}
+
+//UnsubscribeContact: unsubscribe one contact and add its email to the suppression list of the account
+func (c *contactService) UnsubscribeContact(account, key, value string, unsub *entities.Unsubscription) error {
+	contact, err := c.rep.GetOneContact(account, key, value)
+	if err != nil {
+		return errors.Wrap(err, "service UnsubscribeContact")
+	}
+
+	unsub.Time = c.now()
+	err = c.rep.AddSuppression(account, contact.Email, unsub)
+	if err != nil {
+		return errors.Wrap(err, "service UnsubscribeContact")
+	}
+	err = c.rep.SetUnsubscription(account, contact.Email, unsub, unsub.Time)
+	if err != nil {
+		return errors.Wrap(err, "service UnsubscribeContact")
+	}
 
 	//invalid cache: contactID->email->contact{}
-	err = c.cache.DelOneContact(contact.Email)
+	c.invalidate(account, contact.Email, contact.ContactID)
+	return nil
+}
+
+//ResubscribeContact: subscribe one contact again and remove its email from the suppression list of the account
+func (c *contactService) ResubscribeContact(account, key, value string) error {
+	contact, err := c.rep.GetOneContact(account, key, value)
 	if err != nil {
-		return "", errors.Wrap(err, "service AddOrUpdateContact")
+		return errors.Wrap(err, "service ResubscribeContact")
+	}
+
+	err = c.rep.DelSuppression(account, contact.Email)
+	if err != nil {
+		return errors.Wrap(err, "service ResubscribeContact")
+	}
+	err = c.rep.SetUnsubscription(account, contact.Email, nil, c.now())
+	if err != nil {
+		return errors.Wrap(err, "service ResubscribeContact")
+	}
+
+	//invalid cache: contactID->email->contact{}
+	c.invalidate(account, contact.Email, contact.ContactID)
+	return nil
+}
+
+//applySuppressions keep the contacts in the suppression list unsubscribed, even after they were deleted and added again
+func (c *contactService) applySuppressions(account string, contacts []*entities.Contact) error {
+	emails := make([]string, 0, len(contacts))
+	for _, contact := range contacts {
+		emails = append(emails, contact.Email)
+	}
+
+	suppressions, err := c.rep.GetSuppressions(account, emails)
+	if err != nil {
+		return err
+	}
+
+	for _, contact := range contacts {
+		//the unsubscription is only recorded by the unsubscribe endpoint
+		contact.Unsubscription = suppressions[contact.Email]
+		if contact.Unsubscription != nil {
+			contact.Unsubscribed = true
+		}
+	}
+
+	return nil
+}
+
+//DeleteContact: delete one contact of the account by contact id or email
+func (c *contactService) DeleteContact(account, key, value string) error {
+	if key != "contactid" && key != "email" {
+		return errors.New("invalid key")
+	}
+
+	//read the contact first: to report not found and to know both keys of the cache
+	contact, err := c.rep.GetOneContact(account, key, value)
+	if err != nil {
+		return errors.Wrap(err, "service DeleteContact")
+	}
+
+	err = c.rep.DeleteOneContact(account, "email", contact.Email)
+	if err != nil {
+		return errors.Wrap(err, "service DeleteContact")
+	}
+
+	//invalid cache: contactID->email->contact{}
+	c.invalidate(account, contact.Email, contact.ContactID)
+	return nil
+}
+
+//GetAllContacts: get one page of the contacts of the account after the bookmark, the first page if bookmark is empty
+func (c *contactService) GetAllContacts(account, bookmark string) (*entities.ContactPage, error) {
+	pageSize := c.cfg.GetContactConfig().PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	contacts, next, err := c.rep.GetContacts(account, bookmark, pageSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "service GetAllContacts")
+	}
+
+	total, err := c.rep.CountContacts(account)
+	if err != nil {
+		return nil, errors.Wrap(err, "service GetAllContacts")
+	}
+
+	return &entities.ContactPage{Contacts: contacts, TotalContacts: total, Bookmark: next}, nil
+}
+
+//CheckBulkLimit: entities.ErrTooManyContacts if a bulk request of count contacts is over the limit
+func (c *contactService) CheckBulkLimit(count int) error {
+	bulkLimit := c.cfg.GetContactConfig().BulkLimit
+	if bulkLimit <= 0 {
+		bulkLimit = defaultBulkLimit
+	}
+	if count > bulkLimit {
+		return errors.WithStack(entities.ErrTooManyContacts)
+	}
+	return nil
+}
+
+//AddBulkContacts: add or update the contacts in one bulk write, the results are in the same order as the contacts
+func (c *contactService) AddBulkContacts(account string, contacts []*entities.Contact) ([]entities.BulkContactResult, error) {
+	if err := c.CheckBulkLimit(len(contacts)); err != nil {
+		return nil, err
+	}
+
+	results := make([]entities.BulkContactResult, len(contacts))
+	valid := make([]*entities.Contact, 0, len(contacts))
+	validIndex := make([]int, 0, len(contacts))
+	seen := make(map[string]bool, len(contacts))
+	validate := validator.New()
+	var registry *customFieldRegistry
+	for i, contact := range contacts {
+		results[i].Email = contact.Email
+		results[i].Status = entities.ContactFailed
+		if err := validate.Var(contact.Email, "required,email"); err != nil {
+			results[i].Error = "invalid Email"
+			continue
+		}
+		if seen[contact.Email] {
+			results[i].Error = "duplicate Email in the request"
+			continue
+		}
+		seen[contact.Email] = true
+
+		if err := c.guardReadOnly(contact); err != nil {
+			results[i].Error = errors.Cause(err).Error()
+			continue
+		}
+		if len(contact.CustomTypes) > 0 {
+			//the registry is read once for the whole batch
+			if registry == nil {
+				var err error
+				registry, err = c.loadCustomFields(account)
+				if err != nil {
+					return nil, errors.Wrap(err, "service AddBulkContacts")
+				}
+			}
+			if err := c.checkCustomFields(registry, contact.CustomTypes); err != nil {
+				if _, ok := errors.Cause(err).(*entities.CustomFieldTypeError); !ok {
+					return nil, errors.Wrap(err, "service AddBulkContacts")
+				}
+				results[i].Error = errors.Cause(err).Error()
+				continue
+			}
+		}
+		//the repository keeps the id, the type and the created_at of the contacts already stored
+		c.stampNew(contact)
+		valid = append(valid, contact)
+		validIndex = append(validIndex, i)
+	}
+
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	err := c.applySuppressions(account, valid)
+	if err != nil {
+		return nil, errors.Wrap(err, "service AddBulkContacts")
+	}
+
+	written, err := c.rep.UpsertContacts(account, valid)
+	if err != nil {
+		return nil, errors.Wrap(err, "service AddBulkContacts")
+	}
+
+	//invalid cache of the whole batch at once: contactID->email->contact{}
+	keys := make([]string, 0, 2*len(written))
+	for j, result := range written {
+		results[validIndex[j]] = result
+		if result.Status != entities.ContactFailed {
+			keys = append(keys, result.Email, result.ContactID)
+		}
 	}
-	err = c.cache.DelOneContact(contact.ContactID)
+	c.invalidate(account, keys...)
 
-	return contact.ContactID, errors.Wrap(err, "service AddOrUpdateContact")
+	return results, nil
 }
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+//defaultReadOnlyFields the fields owned by the service if they are not configured
+var defaultReadOnlyFields = []string{"contact_id", "type", "owner_name", "created_at", "updated_at", "lists"}
+
+//the policies for the read-only fields in the request
+const (
+	readOnlyIgnore = "ignore"
+	readOnlyReject = "reject"
+)
+
+//readOnlyFields the configured read-only fields and whether to reject them
+func (c *contactService) readOnlyFields() (map[string]bool, bool) {
+	cfg := c.cfg.GetContactConfig()
+	names := cfg.ReadOnlyFields
+	if len(names) == 0 {
+		names = defaultReadOnlyFields
+	}
+
+	fields := make(map[string]bool, len(names))
+	for _, name := range names {
+		fields[name] = true
+	}
+
+	return fields, strings.ToLower(cfg.ReadOnlyPolicy) == readOnlyReject
+}
+
+//guardReadOnly clear the read-only fields the client set, or reject them by the policy
+func (c *contactService) guardReadOnly(contact *entities.Contact) error {
+	fields, reject := c.readOnlyFields()
+
+	v := reflect.ValueOf(contact).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if !fields[name] || v.Field(i).IsZero() {
+			continue
+		}
+		if reject {
+			return errors.WithStack(&entities.ReadOnlyFieldError{Field: name})
+		}
+		v.Field(i).Set(reflect.Zero(t.Field(i).Type))
+	}
+
+	return nil
+}
+
+//guardReadOnlyPatch drop the read-only fields of the merge patch, or reject them by the policy
+func (c *contactService) guardReadOnlyPatch(patch map[string]interface{}) (map[string]interface{}, error) {
+	fields, reject := c.readOnlyFields()
+
+	guarded := make(map[string]interface{}, len(patch))
+	for name, value := range patch {
+		if fields[name] {
+			if reject {
+				return nil, errors.WithStack(&entities.ReadOnlyFieldError{Field: name})
+			}
+			continue
+		}
+		guarded[name] = value
+	}
+
+	return guarded, nil
+}
+
+//stampNew set the fields the service owns on a new contact
+func (c *contactService) stampNew(contact *entities.Contact) {
+	now := c.now()
+	contact.ContactID = "person_AP2-" + uuid.New().String()
+	contact.Type = entities.ContactType
+	contact.CreatTime = now
+	contact.UpdateTime = now
+}
+
+//keepStored keep the fields the service owns from the stored contact, except updated_at
+func (c *contactService) keepStored(contact, stored *entities.Contact) {
+	contact.ContactID = stored.ContactID
+	contact.Type = stored.Type
+	contact.OwnerName = stored.OwnerName
+	contact.CreatTime = stored.CreatTime
+}
+
+//now mongo keeps milliseconds, so do the contacts in the cache and in the responses
+func (c *contactService) now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
@@ -1,10 +1,14 @@
 package service_test
 
 import (
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"bou.ke/monkey"
 	"github.com/STreeChin/contactapi/internal/service"
+	"github.com/STreeChin/contactapi/pkg/config"
 	"github.com/STreeChin/contactapi/pkg/entities"
 	"github.com/STreeChin/contactapi/pkg/log"
 	"github.com/STreeChin/contactapi/test/mocks"
@@ -12,6 +16,7 @@ import (
 	"github.com/gomodule/redigo/redis"
 	"github.com/pkg/errors"
 	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 /*var postJsonStr = []byte(
@@ -26,7 +31,7 @@ import (
 				}
 			}`)*/
 
-//var postContact = model.Contact{FirstName: "Slarty", LastName: "Bartfast", Email: "test@slarty.com", Custom: map[string]interface{}{"Test Field": 1024}}
+// var postContact = model.Contact{FirstName: "Slarty", LastName: "Bartfast", Email: "test@slarty.com", Custom: map[string]interface{}{"Test Field": 1024}}
 var getContact = entities.Contact{
 	ContactID:  "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23",
 	Email:      "StGr@gmail.com",
@@ -34,8 +39,8 @@ var getContact = entities.Contact{
 	LastName:   "Gr",
 	Type:       "Contact",
 	Phone:      "4159945916",
-	CreatTime:  "2015-04-29T23:15:25.347Z",
-	UpdateTime: "2015-04-29T23:15:25.347Z",
+	CreatTime:  time.Date(2015, 4, 29, 23, 15, 25, 347000000, time.UTC),
+	UpdateTime: time.Date(2015, 4, 29, 23, 15, 25, 347000000, time.UTC),
 	LeadSource: "Autopilot",
 	Status:     "Testing",
 	Company:    "Magpie API",
@@ -50,7 +55,7 @@ var postContact = entities.Contact{
 	Phone:     "4159945916",
 }
 
-//TestGetOneContact
+// TestGetOneContact
 func TestGetOneContact(t *testing.T) {
 	Convey("TestGetOneContact", t, func() {
 		ctl := gomock.NewController(t)
@@ -68,10 +73,10 @@ func TestGetOneContact(t *testing.T) {
 			Convey("UT Normal Case1: Get from cache by email", func() {
 				key, value := "email", "StGr@gmail.com"
 				gomock.InOrder(
-					mockCache.EXPECT().GetOneContact(getContact.Email).Return(&getContact, nil),
+					mockCache.EXPECT().GetOneContact("", getContact.Email).Return(&getContact, nil),
 				)
 
-				cont, err := cSrv.GetOneContact(key, value)
+				cont, err := cSrv.GetOneContact("", key, value)
 				So(err, ShouldEqual, nil)
 				So(cont.ContactID, ShouldEqual, expected.ContactID)
 				So(cont.Email, ShouldEqual, expected.Email)
@@ -79,11 +84,11 @@ func TestGetOneContact(t *testing.T) {
 
 			Convey("UT Normal Case2: Get from cache by contactID", func() {
 				gomock.InOrder(
-					mockCache.EXPECT().GetEmailByContactID(valueContactID).Return(getContact.Email, nil),
-					mockCache.EXPECT().GetOneContact(getContact.Email).Return(&getContact, nil),
+					mockCache.EXPECT().GetEmailByContactID("", valueContactID).Return(getContact.Email, nil),
+					mockCache.EXPECT().GetOneContact("", getContact.Email).Return(&getContact, nil),
 				)
 
-				cont, err := cSrv.GetOneContact(keyContactID, valueContactID)
+				cont, err := cSrv.GetOneContact("", keyContactID, valueContactID)
 				So(err, ShouldEqual, nil)
 				So(cont.ContactID, ShouldEqual, expected.ContactID)
 				So(cont.Email, ShouldEqual, expected.Email)
@@ -91,16 +96,16 @@ func TestGetOneContact(t *testing.T) {
 
 			Convey("UT Normal Case3: Get from db by contactId", func() {
 				gomock.InOrder(
-					mockCache.EXPECT().GetEmailByContactID(valueContactID).Return("", errors.New("redigo: nil returned")),
-					mockRep.EXPECT().GetOneContact(keyContactID, valueContactID).Return(&getContact, nil),
-					mockCache.EXPECT().SetOneContact(getContact.Email, &getContact).Return(nil),
-					mockCache.EXPECT().SetEmailByContactID(getContact.ContactID, getContact.Email).Return(nil),
+					mockCache.EXPECT().GetEmailByContactID("", valueContactID).Return("", errors.New("redigo: nil returned")),
+					mockRep.EXPECT().GetOneContact("", keyContactID, valueContactID).Return(&getContact, nil),
+					mockCache.EXPECT().SetOneContact("", getContact.Email, &getContact).Return(nil),
+					mockCache.EXPECT().SetEmailByContactID("", getContact.ContactID, getContact.Email).Return(nil),
 				)
 				defer monkey.UnpatchAll()
 				monkey.Patch(errors.Cause, func(error) error {
 					return redis.ErrNil
 				})
-				cont, err := cSrv.GetOneContact(keyContactID, valueContactID)
+				cont, err := cSrv.GetOneContact("", keyContactID, valueContactID)
 				So(err, ShouldEqual, nil)
 				So(cont.ContactID, ShouldEqual, expected.ContactID)
 				So(cont.Email, ShouldEqual, expected.Email)
@@ -109,16 +114,16 @@ func TestGetOneContact(t *testing.T) {
 
 		Convey("AbNormal Case:", func() {
 			gomock.InOrder(
-				mockCache.EXPECT().GetEmailByContactID(valueContactID).Return("", redis.ErrNil),
+				mockCache.EXPECT().GetEmailByContactID("", valueContactID).Return("", redis.ErrNil),
 			)
 
 			Convey("AbNormal Case1: Get from db fail", func() {
 				err := errors.New("read db fail")
 				gomock.InOrder(
-					mockRep.EXPECT().GetOneContact(keyContactID, valueContactID).Return(nil, err),
+					mockRep.EXPECT().GetOneContact("", keyContactID, valueContactID).Return(nil, err),
 				)
 
-				cont, resErr := cSrv.GetOneContact(keyContactID, valueContactID)
+				cont, resErr := cSrv.GetOneContact("", keyContactID, valueContactID)
 				So(errors.Cause(resErr), ShouldEqual, err)
 				So(cont, ShouldEqual, nil)
 			})
@@ -126,20 +131,99 @@ func TestGetOneContact(t *testing.T) {
 			Convey("Normal Case2: Set cache fail", func() {
 				err := errors.New("set cache fail")
 				gomock.InOrder(
-					mockRep.EXPECT().GetOneContact(keyContactID, valueContactID).Return(&getContact, nil),
-					mockCache.EXPECT().SetOneContact(getContact.Email, &getContact).Return(err),
-					mockCache.EXPECT().SetEmailByContactID(getContact.ContactID, getContact.Email).Return(err),
+					mockRep.EXPECT().GetOneContact("", keyContactID, valueContactID).Return(&getContact, nil),
+					mockCache.EXPECT().SetOneContact("", getContact.Email, &getContact).Return(err),
+					mockCache.EXPECT().SetEmailByContactID("", getContact.ContactID, getContact.Email).Return(err),
 				)
 
-				cont, resErr := cSrv.GetOneContact(keyContactID, valueContactID)
+				cont, resErr := cSrv.GetOneContact("", keyContactID, valueContactID)
 				So(resErr, ShouldEqual, nil)
 				So(cont.ContactID, ShouldEqual, cont.ContactID)
 			})
+
+			Convey("Normal Case3: a contact not in the db is remembered, the db is not read again", func() {
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyContactID, valueContactID).Return(nil, errors.WithStack(mongo.ErrNoDocuments)),
+					mockCache.EXPECT().SetNotFound("", valueContactID).Return(nil),
+					mockCache.EXPECT().GetEmailByContactID("", valueContactID).Return("", errors.WithStack(entities.ErrCachedNotFound)),
+				)
+
+				_, resErr := cSrv.GetOneContact("", keyContactID, valueContactID)
+				So(errors.Cause(resErr), ShouldEqual, mongo.ErrNoDocuments)
+				_, resErr = cSrv.GetOneContact("", keyContactID, valueContactID)
+				So(errors.Cause(resErr), ShouldEqual, mongo.ErrNoDocuments)
+			})
 		})
 	})
 }
 
-//TestAddOrUpdateContact
+// TestGetOneContactMisses the concurrent misses on the same contact read the db once, each gets its own contact
+func TestGetOneContactMisses(t *testing.T) {
+	Convey("TestGetOneContactMisses", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		const readers = 10
+
+		var missed sync.WaitGroup
+		missed.Add(readers)
+		release := make(chan struct{})
+		mockCache.EXPECT().GetOneContact("", getContact.Email).DoAndReturn(func(account, key string) (*entities.Contact, error) {
+			missed.Done()
+			return nil, errors.WithStack(redis.ErrNil)
+		}).Times(readers)
+		mockRep.EXPECT().GetOneContact("", "email", getContact.Email).DoAndReturn(func(account, key, value string) (*entities.Contact, error) {
+			<-release
+			contact := getContact
+			contact.Lists = []string{"contactlist_1"}
+			contact.Custom = map[string]interface{}{"Tags": []interface{}{"vip"}, "Plan": "free"}
+			contact.CustomTypes = map[string]string{"Tags": "array", "Plan": "string"}
+			return &contact, nil
+		}).Times(1)
+		mockCache.EXPECT().SetOneContact("", getContact.Email, gomock.Any()).Return(nil).Times(1)
+		mockCache.EXPECT().SetEmailByContactID("", getContact.ContactID, getContact.Email).Return(nil).Times(1)
+
+		contacts := make([]*entities.Contact, readers)
+		errs := make([]error, readers)
+		var done sync.WaitGroup
+		done.Add(readers)
+		for i := 0; i < readers; i++ {
+			go func(i int) {
+				defer done.Done()
+				contacts[i], errs[i] = cSrv.GetOneContact("", "email", getContact.Email)
+			}(i)
+		}
+		//every reader missed the cache, let the first read of the db finish once the others wait for it
+		missed.Wait()
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		done.Wait()
+
+		seen := map[*entities.Contact]bool{}
+		for i := 0; i < readers; i++ {
+			So(errs[i], ShouldEqual, nil)
+			So(contacts[i].ContactID, ShouldEqual, getContact.ContactID)
+			So(seen[contacts[i]], ShouldBeFalse)
+			seen[contacts[i]] = true
+		}
+		//the lists and the custom fields of a reader are not the ones of the others either
+		for i := 0; i < readers; i++ {
+			So(contacts[i].Lists, ShouldResemble, []string{"contactlist_1"})
+			So(contacts[i].Custom, ShouldResemble, map[string]interface{}{"Tags": []interface{}{"vip"}, "Plan": "free"})
+			So(contacts[i].CustomTypes, ShouldResemble, map[string]string{"Tags": "array", "Plan": "string"})
+			contacts[i].Lists[0] = "contactlist_2"
+			contacts[i].Custom["Tags"].([]interface{})[0] = "churned"
+			contacts[i].Custom["Plan"] = "paid"
+			contacts[i].CustomTypes["Plan"] = "enum"
+		}
+	})
+}
+
+// TestAddOrUpdateContact
 func TestAddOrUpdateContact(t *testing.T) {
 	Convey("TestAddOrUpdateContact", t, func() {
 		ctl := gomock.NewController(t)
@@ -149,80 +233,874 @@ func TestAddOrUpdateContact(t *testing.T) {
 		mockCache := mocks.NewMockCache(ctl)
 		mockRep := mocks.NewMockRepository(ctl)
 		cCtrl := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{}).AnyTimes()
+
+		//the service owns the read-only fields, so each case posts its own copy
+		contact := postContact
+		stored := getContact
 
 		Convey("Normal Case", func() {
-			expected := postContact.ContactID
 			Convey("Normal Case1: Update to db", func() {
 				gomock.InOrder(
-					mockRep.EXPECT().GetOneContact("email", postContact.Email).Return(&postContact, nil),
-					mockRep.EXPECT().UpdateOneContact(&postContact).Return(nil),
-					mockCache.EXPECT().DelOneContact(postContact.Email).Return(nil),
-					mockCache.EXPECT().DelOneContact(postContact.ContactID).Return(nil),
+					mockRep.EXPECT().GetSuppressions("", []string{postContact.Email}).Return(map[string]*entities.Unsubscription{}, nil),
+					mockRep.EXPECT().UpsertOneContact("", &contact).Return(&stored, nil),
+					mockCache.EXPECT().DelContacts("", postContact.Email, stored.ContactID).Return(nil),
 				)
 
-				contactID, err := cCtrl.AddOrUpdateContact(&postContact)
+				contactID, created, err := cCtrl.AddOrUpdateContact("", &contact)
 				So(err, ShouldEqual, nil)
-				So(contactID, ShouldEqual, expected)
+				So(created, ShouldBeFalse)
+				So(contactID, ShouldEqual, stored.ContactID)
+				So(contact.Type, ShouldEqual, stored.Type)
+				So(contact.CreatTime, ShouldEqual, stored.CreatTime)
+				So(contact.UpdateTime.After(stored.UpdateTime), ShouldBeTrue)
 			})
 
 			Convey("UT Normal Case2: Insert to db", func() {
 				gomock.InOrder(
-					mockRep.EXPECT().GetOneContact("email", postContact.Email).Return(&postContact, errors.New("find fail")),
-					mockRep.EXPECT().InsertOneContact(&postContact).Return(nil),
-					mockCache.EXPECT().DelOneContact(postContact.Email).Return(nil),
-					mockCache.EXPECT().DelOneContact(postContact.ContactID).Return(nil),
+					mockRep.EXPECT().GetSuppressions("", []string{postContact.Email}).Return(map[string]*entities.Unsubscription{}, nil),
+					mockRep.EXPECT().UpsertOneContact("", &contact).Return(nil, nil),
+					mockCache.EXPECT().DelContacts("", postContact.Email, gomock.Any()).Return(nil),
+				)
+
+				contactID, created, err := cCtrl.AddOrUpdateContact("", &contact)
+				So(err, ShouldEqual, nil)
+				So(created, ShouldBeTrue)
+				So(contactID, ShouldStartWith, "person_AP2-")
+				So(contactID, ShouldNotEqual, postContact.ContactID)
+				So(contact.Type, ShouldEqual, entities.ContactType)
+				So(contact.CreatTime.IsZero(), ShouldBeFalse)
+				So(contact.UpdateTime, ShouldEqual, contact.CreatTime)
+			})
+
+			Convey("UT Normal Case3: the read-only fields of the request are ignored", func() {
+				contact.OwnerName = "someone"
+				contact.CreatTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+				gomock.InOrder(
+					mockRep.EXPECT().GetSuppressions("", []string{postContact.Email}).Return(map[string]*entities.Unsubscription{}, nil),
+					mockRep.EXPECT().UpsertOneContact("", &contact).Return(nil, nil),
+					mockCache.EXPECT().DelContacts("", postContact.Email, gomock.Any()).Return(nil),
 				)
 
-				contactID, err := cCtrl.AddOrUpdateContact(&postContact)
+				_, _, err := cCtrl.AddOrUpdateContact("", &contact)
 				So(err, ShouldEqual, nil)
-				So(contactID, ShouldEqual, expected)
+				So(contact.OwnerName, ShouldEqual, "")
+				So(contact.CreatTime.Year(), ShouldNotEqual, 2000)
 			})
 		})
 
 		Convey("AbNormal Case", func() {
-			Convey("AbNormal Case1: insert to db fail", func() {
-				err := errors.New("insert to db fail")
+			Convey("AbNormal Case1: upsert to db fail", func() {
+				err := errors.New("upsert to db fail")
 				gomock.InOrder(
-					mockRep.EXPECT().GetOneContact("email", postContact.Email).Return(&postContact, errors.New("find fail")),
-					mockRep.EXPECT().InsertOneContact(&postContact).Return(err),
+					mockRep.EXPECT().GetSuppressions("", []string{postContact.Email}).Return(map[string]*entities.Unsubscription{}, nil),
+					mockRep.EXPECT().UpsertOneContact("", &contact).Return(nil, err),
 				)
 
-				_, resErr := cCtrl.AddOrUpdateContact(&postContact)
+				_, _, resErr := cCtrl.AddOrUpdateContact("", &contact)
 				So(errors.Cause(resErr), ShouldEqual, err)
 			})
-			Convey("AbNormal Case2: update to db fail", func() {
-				err := errors.New("update to db fail")
+			Convey("AbNormal Case2: del cache fail, the write is done and the keys are dropped later", func() {
+				err := errors.New("del cache fail")
 				gomock.InOrder(
-					mockRep.EXPECT().GetOneContact("email", postContact.Email).Return(&postContact, nil),
-					mockRep.EXPECT().UpdateOneContact(&postContact).Return(err),
+					mockRep.EXPECT().GetSuppressions("", []string{postContact.Email}).Return(map[string]*entities.Unsubscription{}, nil),
+					mockRep.EXPECT().UpsertOneContact("", &contact).Return(&stored, nil),
+					mockCache.EXPECT().DelContacts("", postContact.Email, stored.ContactID).Return(err),
 				)
 
-				_, resErr := cCtrl.AddOrUpdateContact(&postContact)
-				So(errors.Cause(resErr), ShouldEqual, err)
+				contactID, _, resErr := cCtrl.AddOrUpdateContact("", &contact)
+				So(resErr, ShouldEqual, nil)
+				So(contactID, ShouldEqual, stored.ContactID)
+				So(cCtrl.GetHealth().Status, ShouldEqual, entities.HealthDegraded)
+				So(cCtrl.GetHealth().Cache.PendingInvalidations, ShouldEqual, 2)
+
+				//the cache may have the contact before the write, the reads skip it
+				mockRep.EXPECT().GetOneContact("", "email", postContact.Email).Return(&stored, nil)
+				cont, resErr := cCtrl.GetOneContact("", "email", postContact.Email)
+				So(resErr, ShouldEqual, nil)
+				So(cont.ContactID, ShouldEqual, stored.ContactID)
+
+				gomock.InOrder(
+					mockCache.EXPECT().DelContacts("", gomock.Any()).Return(err),
+					mockCache.EXPECT().DelContacts("", gomock.Any()).Return(nil),
+				)
+				So(errors.Cause(cCtrl.RetryInvalidations()), ShouldEqual, err)
+				So(cCtrl.GetHealth().Cache.PendingInvalidations, ShouldEqual, 2)
+				So(cCtrl.RetryInvalidations(), ShouldEqual, nil)
+				So(cCtrl.GetHealth().Status, ShouldEqual, entities.HealthOK)
+
+				mockCache.EXPECT().GetOneContact("", postContact.Email).Return(&stored, nil)
+				_, resErr = cCtrl.GetOneContact("", "email", postContact.Email)
+				So(resErr, ShouldEqual, nil)
 			})
-			Convey("AbNormal Case3: del cache fail", func() {
-				err := errors.New("del cache fail")
+		})
+	})
+}
+
+// TestAddOrUpdateContactConcurrent
+func TestAddOrUpdateContactConcurrent(t *testing.T) {
+	Convey("TestAddOrUpdateContactConcurrent", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{}).AnyTimes()
+		mockCache.EXPECT().DelContacts("", gomock.Any()).Return(nil).AnyTimes()
+		mockRep.EXPECT().GetSuppressions("", gomock.Any()).Return(map[string]*entities.Unsubscription{}, nil).AnyTimes()
+
+		//the db: one document per email, the upsert is atomic like the one backed by the unique index
+		var mu sync.Mutex
+		docs := map[string]entities.Contact{}
+		mockRep.EXPECT().UpsertOneContact("", gomock.Any()).DoAndReturn(func(account string, contact *entities.Contact) (*entities.Contact, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			stored, ok := docs[contact.Email]
+			if !ok {
+				docs[contact.Email] = *contact
+				return nil, nil
+			}
+			updated := *contact
+			updated.ContactID, updated.CreatTime = stored.ContactID, stored.CreatTime
+			docs[contact.Email] = updated
+			return &stored, nil
+		}).AnyTimes()
+
+		Convey("Normal Case1: concurrent posts of the same email create one contact", func() {
+			const posts = 50
+			var wg sync.WaitGroup
+			contactIDs := make([]string, posts)
+			created := make([]bool, posts)
+			errs := make([]error, posts)
+			for i := 0; i < posts; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					contact := entities.Contact{Email: postContact.Email, FirstName: postContact.FirstName}
+					contactIDs[i], created[i], errs[i] = cSrv.AddOrUpdateContact("", &contact)
+				}(i)
+			}
+			wg.Wait()
+
+			createdCount := 0
+			for i := 0; i < posts; i++ {
+				So(errs[i], ShouldEqual, nil)
+				So(contactIDs[i], ShouldEqual, contactIDs[0])
+				if created[i] {
+					createdCount++
+				}
+			}
+			So(createdCount, ShouldEqual, 1)
+			So(len(docs), ShouldEqual, 1)
+		})
+	})
+}
+
+// TestAddOrUpdateContactReadOnlyReject
+func TestAddOrUpdateContactReadOnlyReject(t *testing.T) {
+	Convey("TestAddOrUpdateContactReadOnlyReject", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{
+			ReadOnlyFields: []string{"owner_name"},
+			ReadOnlyPolicy: "reject",
+		}).AnyTimes()
+
+		Convey("AbNormal Case1: a configured read-only field is rejected", func() {
+			contact := entities.Contact{Email: postContact.Email, OwnerName: "someone"}
+
+			_, _, err := cSrv.AddOrUpdateContact("", &contact)
+			roErr, ok := errors.Cause(err).(*entities.ReadOnlyFieldError)
+			So(ok, ShouldBeTrue)
+			So(roErr.Field, ShouldEqual, "owner_name")
+		})
+	})
+}
+
+// TestGetAllContacts
+func TestGetAllContacts(t *testing.T) {
+	Convey("TestGetAllContacts", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		contacts := []*entities.Contact{&getContact, &postContact}
+
+		Convey("Normal Case", func() {
+			Convey("Normal Case1: first page with the configured page size", func() {
 				gomock.InOrder(
-					mockRep.EXPECT().GetOneContact("email", postContact.Email).Return(&postContact, errors.New("find fail")),
-					mockRep.EXPECT().InsertOneContact(&postContact).Return(nil),
-					mockCache.EXPECT().DelOneContact(postContact.Email).Return(err),
+					mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{PageSize: 2}),
+					mockRep.EXPECT().GetContacts("", "", int64(2)).Return(contacts, "bookmark1", nil),
+					mockRep.EXPECT().CountContacts("").Return(int64(3), nil),
 				)
 
-				_, resErr := cCtrl.AddOrUpdateContact(&postContact)
-				So(errors.Cause(resErr), ShouldEqual, err)
+				page, err := cSrv.GetAllContacts("", "")
+				So(err, ShouldEqual, nil)
+				So(len(page.Contacts), ShouldEqual, 2)
+				So(page.TotalContacts, ShouldEqual, 3)
+				So(page.Bookmark, ShouldEqual, "bookmark1")
 			})
-			Convey("AbNormal Case4: del cache fail", func() {
-				err := errors.New("del cache fail")
+
+			Convey("Normal Case2: last page with the default page size", func() {
 				gomock.InOrder(
-					mockRep.EXPECT().GetOneContact("email", postContact.Email).Return(&postContact, errors.New("find fail")),
-					mockRep.EXPECT().InsertOneContact(&postContact).Return(nil),
-					mockCache.EXPECT().DelOneContact(postContact.Email).Return(nil),
-					mockCache.EXPECT().DelOneContact(postContact.ContactID).Return(err),
+					mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{}),
+					mockRep.EXPECT().GetContacts("", "bookmark1", int64(100)).Return(contacts[:1], "", nil),
+					mockRep.EXPECT().CountContacts("").Return(int64(3), nil),
 				)
 
-				_, resErr := cCtrl.AddOrUpdateContact(&postContact)
-				So(errors.Cause(resErr), ShouldEqual, err)
+				page, err := cSrv.GetAllContacts("", "bookmark1")
+				So(err, ShouldEqual, nil)
+				So(len(page.Contacts), ShouldEqual, 1)
+				So(page.Bookmark, ShouldEqual, "")
+			})
+		})
+
+		Convey("AbNormal Case", func() {
+			Convey("AbNormal Case1: invalid bookmark", func() {
+				gomock.InOrder(
+					mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{PageSize: 2}),
+					mockRep.EXPECT().GetContacts("", "bad", int64(2)).Return(nil, "", errors.WithStack(entities.ErrInvalidBookmark)),
+				)
+
+				page, err := cSrv.GetAllContacts("", "bad")
+				So(errors.Cause(err), ShouldEqual, entities.ErrInvalidBookmark)
+				So(page, ShouldEqual, nil)
+			})
+
+			Convey("AbNormal Case2: count fail", func() {
+				errCount := errors.New("count fail")
+				gomock.InOrder(
+					mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{PageSize: 2}),
+					mockRep.EXPECT().GetContacts("", "", int64(2)).Return(contacts, "bookmark1", nil),
+					mockRep.EXPECT().CountContacts("").Return(int64(0), errCount),
+				)
+
+				page, err := cSrv.GetAllContacts("", "")
+				So(errors.Cause(err), ShouldEqual, errCount)
+				So(page, ShouldEqual, nil)
+			})
+		})
+	})
+}
+
+// TestAddBulkContacts
+func TestAddBulkContacts(t *testing.T) {
+	Convey("TestAddBulkContacts", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		newContact := &entities.Contact{Email: "new@gmail.com", FirstName: "New"}
+		oldContact := &entities.Contact{Email: "old@gmail.com", FirstName: "Old"}
+
+		Convey("Normal Case", func() {
+			Convey("Normal Case1: created, updated and failed", func() {
+				invalid := &entities.Contact{Email: "invalid.com"}
+				dup := &entities.Contact{Email: "new@gmail.com"}
+				written := []entities.BulkContactResult{
+					{Email: "new@gmail.com", ContactID: "person_AP2-new", Status: entities.ContactCreated},
+					{Email: "old@gmail.com", ContactID: "person_AP2-old", Status: entities.ContactUpdated},
+				}
+				gomock.InOrder(
+					mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{BulkLimit: 4}).AnyTimes(),
+					mockRep.EXPECT().GetSuppressions("", []string{"new@gmail.com", "old@gmail.com"}).Return(map[string]*entities.Unsubscription{}, nil),
+					mockRep.EXPECT().UpsertContacts("", []*entities.Contact{newContact, oldContact}).Return(written, nil),
+					mockCache.EXPECT().DelContacts("", "new@gmail.com", "person_AP2-new", "old@gmail.com", "person_AP2-old").Return(nil),
+				)
+
+				results, err := cSrv.AddBulkContacts("", []*entities.Contact{newContact, invalid, oldContact, dup})
+				So(err, ShouldEqual, nil)
+				So(len(results), ShouldEqual, 4)
+				So(results[0].Status, ShouldEqual, entities.ContactCreated)
+				So(results[1].Status, ShouldEqual, entities.ContactFailed)
+				So(results[1].Email, ShouldEqual, "invalid.com")
+				So(results[2].Status, ShouldEqual, entities.ContactUpdated)
+				So(results[2].ContactID, ShouldEqual, "person_AP2-old")
+				So(results[3].Status, ShouldEqual, entities.ContactFailed)
+				So(newContact.ContactID, ShouldStartWith, "person_AP2-")
+				So(newContact.Type, ShouldEqual, entities.ContactType)
+				So(newContact.CreatTime.IsZero(), ShouldBeFalse)
+			})
+
+			Convey("Normal Case2: all contacts invalid", func() {
+				gomock.InOrder(
+					mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{}).AnyTimes(),
+				)
+
+				results, err := cSrv.AddBulkContacts("", []*entities.Contact{{Email: "invalid.com"}})
+				So(err, ShouldEqual, nil)
+				So(results[0].Status, ShouldEqual, entities.ContactFailed)
+			})
+		})
+
+		Convey("AbNormal Case", func() {
+			Convey("AbNormal Case1: too many contacts", func() {
+				gomock.InOrder(
+					mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{BulkLimit: 1}).AnyTimes(),
+				)
+
+				results, err := cSrv.AddBulkContacts("", []*entities.Contact{newContact, oldContact})
+				So(errors.Cause(err), ShouldEqual, entities.ErrTooManyContacts)
+				So(results, ShouldEqual, nil)
+			})
+
+			Convey("AbNormal Case2: bulk write fail", func() {
+				errWrite := errors.New("bulk write fail")
+				gomock.InOrder(
+					mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{BulkLimit: 2}).AnyTimes(),
+					mockRep.EXPECT().GetSuppressions("", gomock.Any()).Return(map[string]*entities.Unsubscription{}, nil),
+					mockRep.EXPECT().UpsertContacts("", gomock.Any()).Return(nil, errWrite),
+				)
+
+				_, err := cSrv.AddBulkContacts("", []*entities.Contact{newContact, oldContact})
+				So(errors.Cause(err), ShouldEqual, errWrite)
+			})
+
+			Convey("AbNormal Case3: del cache fail, the contacts are written and the keys dropped later", func() {
+				errDel := errors.New("del cache fail")
+				written := []entities.BulkContactResult{
+					{Email: "new@gmail.com", ContactID: "person_AP2-new", Status: entities.ContactCreated},
+				}
+				gomock.InOrder(
+					mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{BulkLimit: 2}).AnyTimes(),
+					mockRep.EXPECT().GetSuppressions("", gomock.Any()).Return(map[string]*entities.Unsubscription{}, nil),
+					mockRep.EXPECT().UpsertContacts("", gomock.Any()).Return(written, nil),
+					mockCache.EXPECT().DelContacts("", "new@gmail.com", "person_AP2-new").Return(errDel),
+				)
+
+				results, err := cSrv.AddBulkContacts("", []*entities.Contact{newContact})
+				So(err, ShouldEqual, nil)
+				So(results[0].Status, ShouldEqual, entities.ContactCreated)
+				So(cSrv.GetHealth().Cache.PendingInvalidations, ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+// TestDeleteContact
+func TestDeleteContact(t *testing.T) {
+	Convey("TestDeleteContact", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		keyContactID, valueContactID := "contactid", getContact.ContactID
+
+		Convey("Normal Case", func() {
+			Convey("Normal Case1: delete by contactID", func() {
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyContactID, valueContactID).Return(&getContact, nil),
+					mockRep.EXPECT().DeleteOneContact("", "email", getContact.Email).Return(nil),
+					mockCache.EXPECT().DelContacts("", getContact.Email, getContact.ContactID).Return(nil),
+				)
+
+				err := cSrv.DeleteContact("", keyContactID, valueContactID)
+				So(err, ShouldEqual, nil)
+			})
+		})
+
+		Convey("AbNormal Case", func() {
+			Convey("AbNormal Case1: contact not found", func() {
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", "email", "none@gmail.com").Return(nil, mongo.ErrNoDocuments),
+				)
+
+				err := cSrv.DeleteContact("", "email", "none@gmail.com")
+				So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
+			})
+
+			Convey("AbNormal Case2: delete from db fail", func() {
+				errDel := errors.New("delete fail")
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyContactID, valueContactID).Return(&getContact, nil),
+					mockRep.EXPECT().DeleteOneContact("", "email", getContact.Email).Return(errDel),
+				)
+
+				err := cSrv.DeleteContact("", keyContactID, valueContactID)
+				So(errors.Cause(err), ShouldEqual, errDel)
+			})
+
+			Convey("AbNormal Case3: del cache fail, the contact is deleted and the reads skip the cache", func() {
+				errDel := errors.New("del cache fail")
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyContactID, valueContactID).Return(&getContact, nil),
+					mockRep.EXPECT().DeleteOneContact("", "email", getContact.Email).Return(nil),
+					mockCache.EXPECT().DelContacts("", getContact.Email, getContact.ContactID).Return(errDel),
+					mockRep.EXPECT().GetOneContact("", keyContactID, valueContactID).Return(nil, mongo.ErrNoDocuments),
+				)
+
+				err := cSrv.DeleteContact("", keyContactID, valueContactID)
+				So(err, ShouldEqual, nil)
+				_, err = cSrv.GetOneContact("", keyContactID, valueContactID)
+				So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
 			})
+
+			Convey("AbNormal Case4: invalid key", func() {
+				err := cSrv.DeleteContact("", "phone", "4159945916")
+				So(err, ShouldNotEqual, nil)
+			})
+		})
+	})
+}
+
+// TestUnsubscribeContact
+func TestUnsubscribeContact(t *testing.T) {
+	Convey("TestUnsubscribeContact", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{}).AnyTimes()
+		keyContactID, valueContactID := "contactid", getContact.ContactID
+
+		Convey("Normal Case", func() {
+			Convey("Normal Case1: unsubscribe", func() {
+				unsub := &entities.Unsubscription{By: "support", Source: "email_link"}
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyContactID, valueContactID).Return(&getContact, nil),
+					mockRep.EXPECT().AddSuppression("", getContact.Email, unsub).Return(nil),
+					mockRep.EXPECT().SetUnsubscription("", getContact.Email, unsub, gomock.Any()).Return(nil),
+					mockCache.EXPECT().DelContacts("", getContact.Email, getContact.ContactID).Return(nil),
+				)
+
+				err := cSrv.UnsubscribeContact("", keyContactID, valueContactID, unsub)
+				So(err, ShouldEqual, nil)
+				So(unsub.Time.IsZero(), ShouldBeFalse)
+			})
+
+			Convey("Normal Case2: resubscribe", func() {
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyContactID, valueContactID).Return(&getContact, nil),
+					mockRep.EXPECT().DelSuppression("", getContact.Email).Return(nil),
+					mockRep.EXPECT().SetUnsubscription("", getContact.Email, nil, gomock.Any()).Return(nil),
+					mockCache.EXPECT().DelContacts("", getContact.Email, getContact.ContactID).Return(nil),
+				)
+
+				err := cSrv.ResubscribeContact("", keyContactID, valueContactID)
+				So(err, ShouldEqual, nil)
+			})
+
+			Convey("Normal Case3: a suppressed contact added again stays unsubscribed", func() {
+				unsub := &entities.Unsubscription{By: "support", Source: "email_link"}
+				readded := &entities.Contact{ContactID: "person_AP2-readded", Email: "readded@gmail.com"}
+				gomock.InOrder(
+					mockRep.EXPECT().GetSuppressions("", []string{readded.Email}).Return(map[string]*entities.Unsubscription{readded.Email: unsub}, nil),
+					mockRep.EXPECT().UpsertOneContact("", readded).Return(nil, nil),
+					mockCache.EXPECT().DelContacts("", readded.Email, gomock.Any()).Return(nil),
+				)
+
+				_, _, err := cSrv.AddOrUpdateContact("", readded)
+				So(err, ShouldEqual, nil)
+				So(readded.Unsubscribed, ShouldBeTrue)
+				So(readded.Unsubscription, ShouldEqual, unsub)
+			})
+		})
+
+		Convey("AbNormal Case", func() {
+			Convey("AbNormal Case1: contact not found", func() {
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyContactID, valueContactID).Return(nil, mongo.ErrNoDocuments),
+				)
+
+				err := cSrv.UnsubscribeContact("", keyContactID, valueContactID, &entities.Unsubscription{})
+				So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
+			})
+
+			Convey("AbNormal Case2: add suppression fail", func() {
+				errAdd := errors.New("add suppression fail")
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyContactID, valueContactID).Return(&getContact, nil),
+					mockRep.EXPECT().AddSuppression("", getContact.Email, gomock.Any()).Return(errAdd),
+				)
+
+				err := cSrv.UnsubscribeContact("", keyContactID, valueContactID, &entities.Unsubscription{})
+				So(errors.Cause(err), ShouldEqual, errAdd)
+			})
+
+			Convey("AbNormal Case3: get suppressions fail", func() {
+				errGet := errors.New("get suppressions fail")
+				gomock.InOrder(
+					mockRep.EXPECT().GetSuppressions("", []string{postContact.Email}).Return(nil, errGet),
+				)
+
+				_, _, err := cSrv.AddOrUpdateContact("", &postContact)
+				So(errors.Cause(err), ShouldEqual, errGet)
+			})
+		})
+	})
+}
+
+// TestPatchContact
+func TestPatchContact(t *testing.T) {
+	Convey("TestPatchContact", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{}).AnyTimes()
+		keyEmail, valueEmail := "email", getContact.Email
+		current := getContact
+		current.Custom = map[string]interface{}{"Old": "old", "Keep": 1}
+		current.CustomTypes = map[string]string{"Old": "string", "Keep": "integer"}
+
+		Convey("Normal Case", func() {
+			Convey("Normal Case1: only the present fields change, null clears, custom merges by key", func() {
+				patch := map[string]interface{}{
+					"FirstName": "New",
+					"Phone":     nil,
+					"custom":    map[string]interface{}{"Score": 5, "Old": nil},
+				}
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyEmail, valueEmail).Return(&current, nil),
+					mockRep.EXPECT().GetCustomFields("").Return([]*entities.CustomField{{Name: "Score", FieldType: "integer"}}, nil),
+					mockRep.EXPECT().UpdateOneContact("", gomock.Any()).Return(nil),
+					mockCache.EXPECT().DelContacts("", getContact.Email, getContact.ContactID).Return(nil),
+				)
+
+				patched, err := cSrv.PatchContact("", keyEmail, valueEmail, patch, map[string]string{"Score": "integer"})
+				So(err, ShouldEqual, nil)
+				So(patched.FirstName, ShouldEqual, "New")
+				So(patched.LastName, ShouldEqual, getContact.LastName)
+				So(patched.Phone, ShouldEqual, "")
+				So(patched.Lists, ShouldResemble, getContact.Lists)
+				So(patched.Custom, ShouldResemble, map[string]interface{}{"Keep": 1, "Score": 5})
+				So(patched.CustomTypes, ShouldResemble, map[string]string{"Keep": "integer", "Score": "integer"})
+				So(patched.CreatTime, ShouldEqual, getContact.CreatTime)
+				So(patched.UpdateTime.After(getContact.UpdateTime), ShouldBeTrue)
+			})
+
+			Convey("Normal Case3: the configured read-only fields are ignored", func() {
+				patch := map[string]interface{}{"owner_name": "someone", "created_at": "2000-01-01T00:00:00Z", "LastName": "New"}
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyEmail, valueEmail).Return(&current, nil),
+					mockRep.EXPECT().UpdateOneContact("", gomock.Any()).Return(nil),
+					mockCache.EXPECT().DelContacts("", getContact.Email, getContact.ContactID).Return(nil),
+				)
+
+				patched, err := cSrv.PatchContact("", keyEmail, valueEmail, patch, nil)
+				So(err, ShouldEqual, nil)
+				So(patched.LastName, ShouldEqual, "New")
+				So(patched.OwnerName, ShouldEqual, getContact.OwnerName)
+				So(patched.CreatTime, ShouldEqual, getContact.CreatTime)
+			})
+
+			Convey("Normal Case2: unchanged read-only field and null custom", func() {
+				patch := map[string]interface{}{"Email": getContact.Email, "custom": nil}
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyEmail, valueEmail).Return(&current, nil),
+					mockRep.EXPECT().UpdateOneContact("", gomock.Any()).Return(nil),
+					mockCache.EXPECT().DelContacts("", getContact.Email, getContact.ContactID).Return(nil),
+				)
+
+				patched, err := cSrv.PatchContact("", keyEmail, valueEmail, patch, nil)
+				So(err, ShouldEqual, nil)
+				So(patched.Custom, ShouldEqual, nil)
+			})
+		})
+
+		Convey("AbNormal Case", func() {
+			Convey("AbNormal Case1: change read-only field", func() {
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyEmail, valueEmail).Return(&current, nil),
+				)
+
+				_, err := cSrv.PatchContact("", keyEmail, valueEmail, map[string]interface{}{"Email": "other@gmail.com"}, nil)
+				roErr, ok := errors.Cause(err).(*entities.ReadOnlyFieldError)
+				So(ok, ShouldBeTrue)
+				So(roErr.Field, ShouldEqual, "Email")
+			})
+
+			Convey("AbNormal Case2: invalid type of field", func() {
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyEmail, valueEmail).Return(&current, nil),
+				)
+
+				_, err := cSrv.PatchContact("", keyEmail, valueEmail, map[string]interface{}{"FirstName": 5.0}, nil)
+				So(errors.Cause(err), ShouldEqual, entities.ErrInvalidPatch)
+			})
+
+			Convey("AbNormal Case3: contact not found", func() {
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyEmail, valueEmail).Return(nil, mongo.ErrNoDocuments),
+				)
+
+				_, err := cSrv.PatchContact("", keyEmail, valueEmail, map[string]interface{}{"FirstName": "New"}, nil)
+				So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
+			})
+
+			Convey("AbNormal Case4: update db fail", func() {
+				errUpdate := errors.New("update fail")
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyEmail, valueEmail).Return(&current, nil),
+					mockRep.EXPECT().UpdateOneContact("", gomock.Any()).Return(errUpdate),
+				)
+
+				_, err := cSrv.PatchContact("", keyEmail, valueEmail, map[string]interface{}{"FirstName": "New"}, nil)
+				So(errors.Cause(err), ShouldEqual, errUpdate)
+			})
+
+			Convey("AbNormal Case5: the contact deleted after it was read is not created again", func() {
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyEmail, valueEmail).Return(&current, nil),
+					mockRep.EXPECT().UpdateOneContact("", gomock.Any()).Return(errors.WithStack(mongo.ErrNoDocuments)),
+				)
+
+				_, err := cSrv.PatchContact("", keyEmail, valueEmail, map[string]interface{}{"FirstName": "New"}, nil)
+				So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
+			})
+		})
+	})
+}
+
+// TestCustomFieldRegistry
+func TestCustomFieldRegistry(t *testing.T) {
+	Convey("TestCustomFieldRegistry", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{}).AnyTimes()
+		account := getContact.ContactID
+		registered := []*entities.CustomField{{Name: "Score", FieldType: "integer"}}
+
+		Convey("Normal Case", func() {
+			Convey("Normal Case1: a new field is registered on first use", func() {
+				contact := entities.Contact{
+					Email:       postContact.Email,
+					Custom:      map[string]interface{}{"Score": 5, "Color": "red"},
+					CustomTypes: map[string]string{"Score": "integer", "Color": "string"},
+				}
+				gomock.InOrder(
+					mockRep.EXPECT().GetCustomFields(account).Return(registered, nil),
+					mockRep.EXPECT().RegisterCustomField(account, gomock.Any()).DoAndReturn(
+						func(account string, field *entities.CustomField) (*entities.CustomField, error) {
+							So(field.Name, ShouldEqual, "Color")
+							So(field.FieldType, ShouldEqual, "string")
+							So(field.CreatTime.IsZero(), ShouldBeFalse)
+							return field, nil
+						}),
+					mockRep.EXPECT().GetSuppressions(account, gomock.Any()).Return(map[string]*entities.Unsubscription{}, nil),
+					mockRep.EXPECT().UpsertOneContact(account, &contact).Return(nil, nil),
+					mockCache.EXPECT().DelContacts(account, gomock.Any(), gomock.Any()).Return(nil),
+				)
+
+				_, _, err := cSrv.AddOrUpdateContact(account, &contact)
+				So(err, ShouldEqual, nil)
+			})
+
+			Convey("Normal Case2: the registered fields of the account", func() {
+				gomock.InOrder(
+					mockRep.EXPECT().GetCustomFields(account).Return(registered, nil),
+				)
+
+				fields, err := cSrv.GetCustomFields(account)
+				So(err, ShouldEqual, nil)
+				So(fields, ShouldResemble, registered)
+			})
+		})
+
+		Convey("AbNormal Case", func() {
+			Convey("AbNormal Case1: a registered field written with another type", func() {
+				contact := entities.Contact{
+					Email:       postContact.Email,
+					Custom:      map[string]interface{}{"Score": "high", "Color": "red"},
+					CustomTypes: map[string]string{"Score": "string", "Color": "string"},
+				}
+				gomock.InOrder(
+					mockRep.EXPECT().GetCustomFields(account).Return(registered, nil),
+				)
+
+				_, _, err := cSrv.AddOrUpdateContact(account, &contact)
+				cfErr, ok := errors.Cause(err).(*entities.CustomFieldTypeError)
+				So(ok, ShouldBeTrue)
+				So(cfErr.Name, ShouldEqual, "Score")
+				So(cfErr.FieldType, ShouldEqual, "integer")
+			})
+
+			Convey("AbNormal Case2: another request registered the field first with another type", func() {
+				contact := entities.Contact{
+					Email:       postContact.Email,
+					Custom:      map[string]interface{}{"Color": "red"},
+					CustomTypes: map[string]string{"Color": "string"},
+				}
+				gomock.InOrder(
+					mockRep.EXPECT().GetCustomFields(account).Return(registered, nil),
+					mockRep.EXPECT().RegisterCustomField(account, gomock.Any()).Return(&entities.CustomField{Name: "Color", FieldType: "integer"}, nil),
+				)
+
+				_, _, err := cSrv.AddOrUpdateContact(account, &contact)
+				cfErr, ok := errors.Cause(err).(*entities.CustomFieldTypeError)
+				So(ok, ShouldBeTrue)
+				So(cfErr.FieldType, ShouldEqual, "integer")
+			})
+
+			Convey("AbNormal Case3: the bulk contact of another type fails alone", func() {
+				bad := &entities.Contact{Email: "bad@gmail.com", Custom: map[string]interface{}{"Score": "high"},
+					CustomTypes: map[string]string{"Score": "string"}}
+				good := &entities.Contact{Email: "good@gmail.com", Custom: map[string]interface{}{"Score": 1},
+					CustomTypes: map[string]string{"Score": "integer"}}
+				written := []entities.BulkContactResult{{Email: "good@gmail.com", ContactID: "person_AP2-good", Status: entities.ContactCreated}}
+				gomock.InOrder(
+					mockRep.EXPECT().GetCustomFields(account).Return(registered, nil),
+					mockRep.EXPECT().GetSuppressions(account, []string{"good@gmail.com"}).Return(map[string]*entities.Unsubscription{}, nil),
+					mockRep.EXPECT().UpsertContacts(account, []*entities.Contact{good}).Return(written, nil),
+					mockCache.EXPECT().DelContacts(account, "good@gmail.com", "person_AP2-good").Return(nil),
+				)
+
+				results, err := cSrv.AddBulkContacts(account, []*entities.Contact{bad, good})
+				So(err, ShouldEqual, nil)
+				So(results[0].Status, ShouldEqual, entities.ContactFailed)
+				So(results[1].Status, ShouldEqual, entities.ContactCreated)
+			})
+		})
+	})
+}
+
+// TestTenantIsolation the repository and the cache are modeled by account, the contacts of an account
+// can not be read, patched or deleted by another one, even after the first one cached them
+func TestTenantIsolation(t *testing.T) {
+	Convey("TestTenantIsolation", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{}).AnyTimes()
+		accountA, accountB := "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23", "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc45555"
+
+		//the contacts by account and email, the cache by account and key, false for a contact not found
+		stored := map[string]*entities.Contact{}
+		cached := map[string]interface{}{}
+		find := func(account, key, value string) (*entities.Contact, error) {
+			for id, contact := range stored {
+				if strings.HasPrefix(id, account+"/") && ((key == "email" && contact.Email == value) || (key == "contactid" && contact.ContactID == value)) {
+					found := *contact
+					return &found, nil
+				}
+			}
+			return nil, errors.WithStack(mongo.ErrNoDocuments)
+		}
+		mockRep.EXPECT().GetSuppressions(gomock.Any(), gomock.Any()).Return(map[string]*entities.Unsubscription{}, nil).AnyTimes()
+		mockRep.EXPECT().GetOneContact(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(find).AnyTimes()
+		mockRep.EXPECT().UpsertOneContact(gomock.Any(), gomock.Any()).DoAndReturn(func(account string, contact *entities.Contact) (*entities.Contact, error) {
+			before, _ := find(account, "email", contact.Email)
+			stored[account+"/"+contact.Email] = contact
+			return before, nil
+		}).AnyTimes()
+		mockRep.EXPECT().UpdateOneContact(gomock.Any(), gomock.Any()).DoAndReturn(func(account string, contact *entities.Contact) error {
+			stored[account+"/"+contact.Email] = contact
+			return nil
+		}).AnyTimes()
+		mockRep.EXPECT().DeleteOneContact(gomock.Any(), "email", gomock.Any()).DoAndReturn(func(account, key, value string) error {
+			delete(stored, account+"/"+value)
+			return nil
+		}).AnyTimes()
+		mockCache.EXPECT().GetOneContact(gomock.Any(), gomock.Any()).DoAndReturn(func(account, key string) (*entities.Contact, error) {
+			switch contact := cached[account+":"+key].(type) {
+			case *entities.Contact:
+				return contact, nil
+			case bool:
+				return nil, errors.WithStack(entities.ErrCachedNotFound)
+			}
+			return nil, errors.WithStack(redis.ErrNil)
+		}).AnyTimes()
+		mockCache.EXPECT().GetEmailByContactID(gomock.Any(), gomock.Any()).DoAndReturn(func(account, key string) (string, error) {
+			switch email := cached[account+":"+key].(type) {
+			case string:
+				return email, nil
+			case bool:
+				return "", errors.WithStack(entities.ErrCachedNotFound)
+			}
+			return "", errors.WithStack(redis.ErrNil)
+		}).AnyTimes()
+		mockCache.EXPECT().SetOneContact(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(account, key string, contact *entities.Contact) error {
+			cached[account+":"+key] = contact
+			return nil
+		}).AnyTimes()
+		mockCache.EXPECT().SetEmailByContactID(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(account, key, value string) error {
+			cached[account+":"+key] = value
+			return nil
+		}).AnyTimes()
+		mockCache.EXPECT().SetNotFound(gomock.Any(), gomock.Any()).DoAndReturn(func(account, key string) error {
+			cached[account+":"+key] = false
+			return nil
+		}).AnyTimes()
+		mockCache.EXPECT().DelContacts(gomock.Any(), gomock.Any()).DoAndReturn(func(account string, keys ...string) error {
+			for _, key := range keys {
+				delete(cached, account+":"+key)
+			}
+			return nil
+		}).AnyTimes()
+
+		contact := postContact
+		contactID, created, err := cSrv.AddOrUpdateContact(accountA, &contact)
+		So(err, ShouldEqual, nil)
+		So(created, ShouldBeTrue)
+		//the contact of A is in the cache of A
+		_, err = cSrv.GetOneContact(accountA, "contactid", contactID)
+		So(err, ShouldEqual, nil)
+
+		Convey("Normal Case1: B can not read it, by email or by contact id", func() {
+			_, err := cSrv.GetOneContact(accountB, "email", contact.Email)
+			So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
+			_, err = cSrv.GetOneContact(accountB, "contactid", contactID)
+			So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
+		})
+
+		Convey("Normal Case2: B can not patch or delete it", func() {
+			_, err := cSrv.PatchContact(accountB, "email", contact.Email, map[string]interface{}{"FirstName": "B"}, nil)
+			So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
+			err = cSrv.DeleteContact(accountB, "contactid", contactID)
+			So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
+
+			result, err := cSrv.GetOneContact(accountA, "email", contact.Email)
+			So(err, ShouldEqual, nil)
+			So(result.FirstName, ShouldEqual, postContact.FirstName)
+		})
+
+		Convey("Normal Case3: B adding the same email gets its own contact, A keeps its own", func() {
+			other := entities.Contact{Email: postContact.Email, FirstName: "B"}
+			otherID, created, err := cSrv.AddOrUpdateContact(accountB, &other)
+			So(err, ShouldEqual, nil)
+			So(created, ShouldBeTrue)
+			So(otherID, ShouldNotEqual, contactID)
+
+			result, err := cSrv.GetOneContact(accountA, "email", contact.Email)
+			So(err, ShouldEqual, nil)
+			So(result.ContactID, ShouldEqual, contactID)
+			So(result.FirstName, ShouldEqual, postContact.FirstName)
+
+			err = cSrv.DeleteContact(accountB, "email", other.Email)
+			So(err, ShouldEqual, nil)
+			_, err = cSrv.GetOneContact(accountA, "contactid", contactID)
+			So(err, ShouldEqual, nil)
 		})
 	})
 }
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/pkg/errors"
+)
+
+//GetCustomFields: get the custom fields registered in the account
+func (c *contactService) GetCustomFields(account string) ([]*entities.CustomField, error) {
+	fields, err := c.rep.GetCustomFields(account)
+	return fields, errors.Wrap(err, "service GetCustomFields")
+}
+
+//customFieldRegistry the custom fields of one account, name->type, loaded once per request
+type customFieldRegistry struct {
+	account string
+	types   map[string]string
+}
+
+func (c *contactService) loadCustomFields(account string) (*customFieldRegistry, error) {
+	fields, err := c.rep.GetCustomFields(account)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := &customFieldRegistry{account: account, types: make(map[string]string, len(fields))}
+	for _, field := range fields {
+		registry.types[field.Name] = field.FieldType
+	}
+
+	return registry, nil
+}
+
+//checkCustomFields validate the custom field types against the registry, the new fields are registered on first use
+func (c *contactService) checkCustomFields(registry *customFieldRegistry, types map[string]string) error {
+	//nothing is registered if any field does not match
+	for name, fieldType := range types {
+		if registered, ok := registry.types[name]; ok && registered != fieldType {
+			return errors.WithStack(&entities.CustomFieldTypeError{Name: name, FieldType: registered})
+		}
+	}
+
+	for name, fieldType := range types {
+		if _, ok := registry.types[name]; ok {
+			continue
+		}
+
+		//another request may register the same field at the same time, the first one wins
+		field := &entities.CustomField{Name: name, FieldType: fieldType, CreatTime: c.now()}
+		registered, err := c.rep.RegisterCustomField(registry.account, field)
+		if err != nil {
+			return err
+		}
+		registry.types[name] = registered.FieldType
+		if registered.FieldType != fieldType {
+			return errors.WithStack(&entities.CustomFieldTypeError{Name: name, FieldType: registered.FieldType})
+		}
+	}
+
+	return nil
+}
+
+//registerCustomFields validate and register the custom fields of one contact
+func (c *contactService) registerCustomFields(account string, types map[string]string) error {
+	if len(types) == 0 {
+		return nil
+	}
+
+	registry, err := c.loadCustomFields(account)
+	if err != nil {
+		return err
+	}
+
+	return c.checkCustomFields(registry, types)
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+//EraseContact: erase one contact by contact id or email for the right to be forgotten, the tombstone is returned
+func (c *contactService) EraseContact(account, key, value string) (*entities.Erasure, error) {
+	if key != "contactid" && key != "email" {
+		return nil, errors.New("invalid key")
+	}
+
+	contact, err := c.rep.GetOneContact(account, key, value)
+	if err != nil {
+		return nil, errors.Wrap(err, "service EraseContact")
+	}
+
+	//purge the cache first: if it fails the contact is still there to erase again
+	err = c.cache.DelContacts(account, contact.Email, contact.ContactID)
+	if err != nil {
+		return nil, errors.Wrap(err, "service EraseContact")
+	}
+
+	erasure := &entities.Erasure{ErasureID: entities.ErasureIDPrefix + uuid.New().String(), ErasedAt: c.now()}
+	err = c.rep.EraseContact(account, contact, erasure)
+	if err != nil {
+		return nil, errors.Wrap(err, "service EraseContact")
+	}
+
+	//and again, a read may have cached the contact meanwhile; the contact is erased, so it is retried if redis can not
+	c.invalidate(account, contact.Email, contact.ContactID)
+
+	return erasure, nil
+}
@@ -0,0 +1,88 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/STreeChin/contactapi/internal/service"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TestEraseContact
+func TestEraseContact(t *testing.T) {
+	Convey("TestEraseContact", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		keyContactID, valueContactID := "contactid", getContact.ContactID
+
+		Convey("Normal Case", func() {
+			Convey("Normal Case1: the cache is purged before and after the erasure, the tombstone is returned", func() {
+				var erased *entities.Erasure
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyContactID, valueContactID).Return(&getContact, nil),
+					mockCache.EXPECT().DelContacts("", getContact.Email, getContact.ContactID).Return(nil),
+					mockRep.EXPECT().EraseContact("", &getContact, gomock.Any()).DoAndReturn(
+						func(account string, contact *entities.Contact, erasure *entities.Erasure) error {
+							erased = erasure
+							return nil
+						}),
+					mockCache.EXPECT().DelContacts("", getContact.Email, getContact.ContactID).Return(nil),
+				)
+
+				erasure, err := cSrv.EraseContact("", keyContactID, valueContactID)
+				So(err, ShouldEqual, nil)
+				So(erasure, ShouldEqual, erased)
+				So(strings.HasPrefix(erasure.ErasureID, entities.ErasureIDPrefix), ShouldBeTrue)
+				So(erasure.ErasedAt.IsZero(), ShouldBeFalse)
+			})
+		})
+
+		Convey("AbNormal Case", func() {
+			Convey("AbNormal Case1: contact not found", func() {
+				mockRep.EXPECT().GetOneContact("", "email", "none@gmail.com").Return(nil, mongo.ErrNoDocuments)
+
+				_, err := cSrv.EraseContact("", "email", "none@gmail.com")
+				So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
+			})
+
+			Convey("AbNormal Case2: the cache can not be purged, nothing is erased", func() {
+				errDel := errors.New("del cache fail")
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyContactID, valueContactID).Return(&getContact, nil),
+					mockCache.EXPECT().DelContacts("", getContact.Email, getContact.ContactID).Return(errDel),
+				)
+
+				_, err := cSrv.EraseContact("", keyContactID, valueContactID)
+				So(errors.Cause(err), ShouldEqual, errDel)
+			})
+
+			Convey("AbNormal Case3: erase from db fail", func() {
+				errErase := errors.New("erase fail")
+				gomock.InOrder(
+					mockRep.EXPECT().GetOneContact("", keyContactID, valueContactID).Return(&getContact, nil),
+					mockCache.EXPECT().DelContacts("", getContact.Email, getContact.ContactID).Return(nil),
+					mockRep.EXPECT().EraseContact("", &getContact, gomock.Any()).Return(errErase),
+				)
+
+				_, err := cSrv.EraseContact("", keyContactID, valueContactID)
+				So(errors.Cause(err), ShouldEqual, errErase)
+			})
+
+			Convey("AbNormal Case4: invalid key", func() {
+				_, err := cSrv.EraseContact("", "phone", "4159945916")
+				So(err, ShouldNotEqual, nil)
+			})
+		})
+	})
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/pkg/errors"
+)
+
+//InvalidationRetryInterval the keys the writes could not drop from the cache are dropped again at this interval
+const InvalidationRetryInterval = 5 * time.Second
+
+//maxPendingInvalidations the keys waiting for redis, past them the contacts written only leave the cache by its ttl
+const maxPendingInvalidations = 100000
+
+type pendingKey struct {
+	account, key string
+}
+
+//invalidations the keys of the contacts to drop from the cache once redis is back, the reads skip the cache for them,
+//and the api keys whose cached principals are to drop.
+//Each key has the sequence of its last write: a retry started before that write does not take the key off
+type invalidations struct {
+	mu     sync.Mutex
+	seq    uint64
+	keys   map[pendingKey]uint64
+	keyIDs map[string]uint64
+}
+
+func newInvalidations() *invalidations {
+	return &invalidations{keys: make(map[pendingKey]uint64), keyIDs: make(map[string]uint64)}
+}
+
+//add the keys of the account, false if some are dropped because too many are waiting
+func (q *invalidations) add(account string, keys ...string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	for _, key := range keys {
+		k := pendingKey{account, key}
+		if _, ok := q.keys[k]; !ok && len(q.keys)+len(q.keyIDs) >= maxPendingInvalidations {
+			return false
+		}
+		q.keys[k] = q.seq
+	}
+	return true
+}
+
+func (q *invalidations) pending(account, key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	_, ok := q.keys[pendingKey{account, key}]
+	return ok
+}
+
+func (q *invalidations) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.keys) + len(q.keyIDs)
+}
+
+//take the keys waiting, by account, with their sequences
+func (q *invalidations) take() map[string]map[string]uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	byAccount := make(map[string]map[string]uint64)
+	for k, seq := range q.keys {
+		if byAccount[k.account] == nil {
+			byAccount[k.account] = make(map[string]uint64)
+		}
+		byAccount[k.account][k.key] = seq
+	}
+	return byAccount
+}
+
+//done take the keys dropped off, unless they were written again since they were taken
+func (q *invalidations) done(account string, keys map[string]uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for key, seq := range keys {
+		k := pendingKey{account, key}
+		if q.keys[k] == seq {
+			delete(q.keys, k)
+		}
+	}
+}
+
+//addPrincipals the api key, false if too many keys are waiting
+func (q *invalidations) addPrincipals(keyID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.keyIDs[keyID]; !ok && len(q.keys)+len(q.keyIDs) >= maxPendingInvalidations {
+		return false
+	}
+	q.seq++
+	q.keyIDs[keyID] = q.seq
+	return true
+}
+
+//takePrincipals the api keys waiting, with their sequences
+func (q *invalidations) takePrincipals() map[string]uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	keyIDs := make(map[string]uint64, len(q.keyIDs))
+	for keyID, seq := range q.keyIDs {
+		keyIDs[keyID] = seq
+	}
+	return keyIDs
+}
+
+//donePrincipals take the api keys purged off, unless they were added again since they were taken
+func (q *invalidations) donePrincipals(keyIDs map[string]uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for keyID, seq := range keyIDs {
+		if q.keyIDs[keyID] == seq {
+			delete(q.keyIDs, keyID)
+		}
+	}
+}
+
+//invalidate drop the keys of the contacts written from the cache: the write is done, so if redis can not
+//the keys are kept and dropped again by RetryInvalidations
+func (c *contactService) invalidate(account string, keys ...string) {
+	err := c.cache.DelContacts(account, keys...)
+	if err == nil {
+		return
+	}
+	if errors.Cause(err) != entities.ErrCacheUnavailable {
+		c.log.Warnf("service invalidate, retried later: %+v", err)
+	}
+	if !c.invalidations.add(account, keys...) {
+		c.log.Errorf("service invalidate: %d keys already wait for the cache, the contact is left to the ttl", maxPendingInvalidations)
+	}
+}
+
+//invalidatePrincipals drop the cached principals of the api key changed: the change is done, so if redis can not
+//the key is kept and purged again by RetryInvalidations, the instances read the key from the db meanwhile
+func (c *contactService) invalidatePrincipals(keyID string) {
+	err := c.cache.DelPrincipals(keyID)
+	if err == nil {
+		return
+	}
+	if errors.Cause(err) != entities.ErrCacheUnavailable {
+		c.log.Warnf("service invalidatePrincipals, retried later: %+v", err)
+	}
+	if !c.invalidations.addPrincipals(keyID) {
+		c.log.Errorf("service invalidatePrincipals: %d keys already wait for the cache, the principals of %s are left to their ttl", maxPendingInvalidations, keyID)
+	}
+}
+
+//RetryInvalidations: drop the keys the writes could not drop from the cache and the principals of the api keys changed,
+//the ones still failing are kept for the next retry
+func (c *contactService) RetryInvalidations() error {
+	for keyID, seq := range c.invalidations.takePrincipals() {
+		err := c.cache.DelPrincipals(keyID)
+		if err != nil {
+			return errors.Wrap(err, "service RetryInvalidations")
+		}
+		c.invalidations.donePrincipals(map[string]uint64{keyID: seq})
+	}
+	for account, keys := range c.invalidations.take() {
+		values := make([]string, 0, len(keys))
+		for key := range keys {
+			values = append(values, key)
+		}
+		err := c.cache.DelContacts(account, values...)
+		if err != nil {
+			return errors.Wrap(err, "service RetryInvalidations")
+		}
+		c.invalidations.done(account, keys)
+	}
+	return nil
+}
+
+//CacheBreaker the circuit breaker of redis, for the other users of redis than the service
+func (c *contactService) CacheBreaker() *cacheBreaker {
+	return c.breaker
+}
+
+//GetHealth: the health of the instance, degraded while the cache is skipped or has contacts to drop
+func (c *contactService) GetHealth() *entities.Health {
+	health := &entities.Health{Status: entities.HealthOK, Cache: c.breaker.health()}
+	health.Cache.PendingInvalidations = c.invalidations.len()
+	if health.Cache.Breaker != entities.BreakerClosed || health.Cache.PendingInvalidations > 0 {
+		health.Status = entities.HealthDegraded
+	}
+	return health
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+//GetLists: get the lists of the account
+func (c *contactService) GetLists(account string) ([]*entities.List, error) {
+	lists, err := c.rep.GetLists(account)
+	return lists, errors.Wrap(err, "service GetLists")
+}
+
+//AddList: create a list in the account
+func (c *contactService) AddList(account, title string) (*entities.List, error) {
+	list := &entities.List{
+		ListID:    entities.ListIDPrefix + strings.ToUpper(uuid.New().String()),
+		Title:     title,
+		CreatTime: c.now(),
+	}
+
+	err := c.rep.InsertList(account, list)
+	if err != nil {
+		return nil, errors.Wrap(err, "service AddList")
+	}
+
+	return list, nil
+}
+
+//GetListContacts: get one page of the contacts in the list after the bookmark, the first page if bookmark is empty
+func (c *contactService) GetListContacts(account, listID, bookmark string) (*entities.ContactPage, error) {
+	_, err := c.rep.GetList(account, listID)
+	if err != nil {
+		return nil, errors.Wrap(err, "service GetListContacts")
+	}
+
+	pageSize := c.cfg.GetContactConfig().PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	contacts, next, err := c.rep.GetListContacts(account, listID, bookmark, pageSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "service GetListContacts")
+	}
+
+	total, err := c.rep.CountListContacts(account, listID)
+	if err != nil {
+		return nil, errors.Wrap(err, "service GetListContacts")
+	}
+
+	return &entities.ContactPage{Contacts: contacts, TotalContacts: total, Bookmark: next}, nil
+}
+
+//AddContactToList: add one contact to the list, nothing changes if it is already in
+func (c *contactService) AddContactToList(account, listID, key, value string) error {
+	contact, err := c.listMember(account, listID, key, value)
+	if err != nil {
+		return errors.Wrap(err, "service AddContactToList")
+	}
+
+	err = c.rep.AddToList(account, contact.Email, listID)
+	if err != nil {
+		return errors.Wrap(err, "service AddContactToList")
+	}
+
+	//invalid cache: contactID->email->contact{}
+	c.invalidate(account, contact.Email, contact.ContactID)
+	return nil
+}
+
+//RemoveContactFromList: remove one contact from the list, nothing changes if it is not in
+func (c *contactService) RemoveContactFromList(account, listID, key, value string) error {
+	contact, err := c.listMember(account, listID, key, value)
+	if err != nil {
+		return errors.Wrap(err, "service RemoveContactFromList")
+	}
+
+	err = c.rep.RemoveFromList(account, contact.Email, listID)
+	if err != nil {
+		return errors.Wrap(err, "service RemoveContactFromList")
+	}
+
+	//invalid cache: contactID->email->contact{}
+	c.invalidate(account, contact.Email, contact.ContactID)
+	return nil
+}
+
+//CheckContactInList: entities.ErrNotInList if the contact is not in the list
+func (c *contactService) CheckContactInList(account, listID, key, value string) error {
+	_, err := c.rep.GetList(account, listID)
+	if err != nil {
+		return errors.Wrap(err, "service CheckContactInList")
+	}
+
+	//the cache is invalidated by every change of the membership
+	contact, err := c.GetOneContact(account, key, value)
+	if err != nil {
+		return errors.Wrap(err, "service CheckContactInList")
+	}
+
+	for _, id := range contact.Lists {
+		if id == listID {
+			return nil
+		}
+	}
+
+	return errors.WithStack(entities.ErrNotInList)
+}
+
+//listMember check the list is in the account and read the contact, from the db to know both keys of the cache
+func (c *contactService) listMember(account, listID, key, value string) (*entities.Contact, error) {
+	if key != "contactid" && key != "email" {
+		return nil, errors.New("invalid key")
+	}
+
+	_, err := c.rep.GetList(account, listID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.rep.GetOneContact(account, key, value)
+}
@@ -0,0 +1,173 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/STreeChin/contactapi/internal/service"
+	"github.com/STreeChin/contactapi/pkg/config"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var getList = entities.List{ListID: "contactlist_9EAF39E4-9AEC-4134-964A", Title: "Newsletter"}
+
+// TestAddList
+func TestAddList(t *testing.T) {
+	Convey("TestAddList", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+
+		Convey("Normal Case1: the list gets a new id", func() {
+			var inserted *entities.List
+			mockRep.EXPECT().InsertList("account", gomock.Any()).DoAndReturn(func(account string, list *entities.List) error {
+				inserted = list
+				return nil
+			})
+
+			list, err := cSrv.AddList("account", "Newsletter")
+			So(err, ShouldEqual, nil)
+			So(list, ShouldEqual, inserted)
+			So(strings.HasPrefix(list.ListID, entities.ListIDPrefix), ShouldBeTrue)
+			So(list.Title, ShouldEqual, "Newsletter")
+			So(list.CreatTime.IsZero(), ShouldBeFalse)
+		})
+
+		Convey("AbNormal Case1: insert fail", func() {
+			errInsert := errors.New("insert fail")
+			mockRep.EXPECT().InsertList("account", gomock.Any()).Return(errInsert)
+
+			_, err := cSrv.AddList("account", "Newsletter")
+			So(errors.Cause(err), ShouldEqual, errInsert)
+		})
+	})
+}
+
+// TestGetListContacts
+func TestGetListContacts(t *testing.T) {
+	Convey("TestGetListContacts", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{PageSize: 2}).AnyTimes()
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+
+		Convey("Normal Case1: one page of the members", func() {
+			gomock.InOrder(
+				mockRep.EXPECT().GetList("account", getList.ListID).Return(&getList, nil),
+				mockRep.EXPECT().GetListContacts("account", getList.ListID, "", int64(2)).Return([]*entities.Contact{&getContact}, "", nil),
+				mockRep.EXPECT().CountListContacts("account", getList.ListID).Return(int64(1), nil),
+			)
+
+			page, err := cSrv.GetListContacts("account", getList.ListID, "")
+			So(err, ShouldEqual, nil)
+			So(page.Contacts, ShouldResemble, []*entities.Contact{&getContact})
+			So(page.TotalContacts, ShouldEqual, 1)
+		})
+
+		Convey("AbNormal Case1: the list is not in the account", func() {
+			mockRep.EXPECT().GetList("other", getList.ListID).Return(nil, errors.WithStack(entities.ErrListNotFound))
+
+			_, err := cSrv.GetListContacts("other", getList.ListID, "")
+			So(errors.Cause(err), ShouldEqual, entities.ErrListNotFound)
+		})
+	})
+}
+
+// TestListMembership
+func TestListMembership(t *testing.T) {
+	Convey("TestListMembership", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		keyContactID, valueContactID := "contactid", getContact.ContactID
+
+		Convey("Normal Case1: add the contact to the list", func() {
+			gomock.InOrder(
+				mockRep.EXPECT().GetList("account", getList.ListID).Return(&getList, nil),
+				mockRep.EXPECT().GetOneContact("account", keyContactID, valueContactID).Return(&getContact, nil),
+				mockRep.EXPECT().AddToList("account", getContact.Email, getList.ListID).Return(nil),
+				mockCache.EXPECT().DelContacts("account", getContact.Email, getContact.ContactID).Return(nil),
+			)
+
+			err := cSrv.AddContactToList("account", getList.ListID, keyContactID, valueContactID)
+			So(err, ShouldEqual, nil)
+		})
+
+		Convey("Normal Case2: remove the contact from the list", func() {
+			gomock.InOrder(
+				mockRep.EXPECT().GetList("account", getList.ListID).Return(&getList, nil),
+				mockRep.EXPECT().GetOneContact("account", "email", getContact.Email).Return(&getContact, nil),
+				mockRep.EXPECT().RemoveFromList("account", getContact.Email, getList.ListID).Return(nil),
+				mockCache.EXPECT().DelContacts("account", getContact.Email, getContact.ContactID).Return(nil),
+			)
+
+			err := cSrv.RemoveContactFromList("account", getList.ListID, "email", getContact.Email)
+			So(err, ShouldEqual, nil)
+		})
+
+		Convey("Normal Case3: check the contact is in the list, from the cache", func() {
+			gomock.InOrder(
+				mockRep.EXPECT().GetList("account", getList.ListID).Return(&getList, nil),
+				mockCache.EXPECT().GetOneContact("account", getContact.Email).Return(&getContact, nil),
+			)
+
+			err := cSrv.CheckContactInList("account", getList.ListID, "email", getContact.Email)
+			So(err, ShouldEqual, nil)
+		})
+
+		Convey("AbNormal Case1: the contact is not in the list", func() {
+			other := getContact
+			other.Lists = nil
+			gomock.InOrder(
+				mockRep.EXPECT().GetList("account", getList.ListID).Return(&getList, nil),
+				mockCache.EXPECT().GetOneContact("account", getContact.Email).Return(&other, nil),
+			)
+
+			err := cSrv.CheckContactInList("account", getList.ListID, "email", getContact.Email)
+			So(errors.Cause(err), ShouldEqual, entities.ErrNotInList)
+		})
+
+		Convey("AbNormal Case2: the list is not in the account", func() {
+			mockRep.EXPECT().GetList("account", getList.ListID).Return(nil, errors.WithStack(entities.ErrListNotFound))
+
+			err := cSrv.AddContactToList("account", getList.ListID, keyContactID, valueContactID)
+			So(errors.Cause(err), ShouldEqual, entities.ErrListNotFound)
+		})
+
+		Convey("AbNormal Case3: contact not found", func() {
+			gomock.InOrder(
+				mockRep.EXPECT().GetList("account", getList.ListID).Return(&getList, nil),
+				mockCache.EXPECT().GetOneContact("account", "none@gmail.com").Return(nil, redis.ErrNil),
+				mockRep.EXPECT().GetOneContact("account", "email", "none@gmail.com").Return(nil, mongo.ErrNoDocuments),
+				mockCache.EXPECT().SetNotFound("account", "none@gmail.com").Return(nil),
+			)
+
+			err := cSrv.CheckContactInList("account", getList.ListID, "email", "none@gmail.com")
+			So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
+		})
+
+		Convey("AbNormal Case4: invalid key", func() {
+			err := cSrv.AddContactToList("account", getList.ListID, "phone", "4159945916")
+			So(err, ShouldNotEqual, nil)
+		})
+	})
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/segment"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+//GetSegments: get the segments of the account
+func (c *contactService) GetSegments(account string) ([]*entities.Segment, error) {
+	segments, err := c.rep.GetSegments(account)
+	return segments, errors.Wrap(err, "service GetSegments")
+}
+
+//AddSegment: create a segment in the account, the error is *segment.Error if the expression is invalid
+func (c *contactService) AddSegment(account, name, expression string) (*entities.Segment, error) {
+	//the expression is checked against the custom fields registered now, the members are evaluated on demand
+	_, err := c.parseSegment(account, expression)
+	if err != nil {
+		return nil, errors.Wrap(err, "service AddSegment")
+	}
+
+	seg := &entities.Segment{
+		SegmentID:  entities.SegmentIDPrefix + strings.ToUpper(uuid.New().String()),
+		Name:       name,
+		Expression: expression,
+		CreatTime:  c.now(),
+	}
+	err = c.rep.InsertSegment(account, seg)
+	if err != nil {
+		return nil, errors.Wrap(err, "service AddSegment")
+	}
+
+	return seg, nil
+}
+
+//CountSegmentContacts: count the members of the segment
+func (c *contactService) CountSegmentContacts(account, segmentID string) (int64, error) {
+	filter, err := c.segmentFilter(account, segmentID)
+	if err != nil {
+		return 0, errors.Wrap(err, "service CountSegmentContacts")
+	}
+
+	count, err := c.rep.CountSegmentContacts(account, filter)
+	return count, errors.Wrap(err, "service CountSegmentContacts")
+}
+
+//GetSegmentContacts: get one page of the members of the segment after the bookmark, the first page if bookmark is empty
+func (c *contactService) GetSegmentContacts(account, segmentID, bookmark string) (*entities.ContactPage, error) {
+	filter, err := c.segmentFilter(account, segmentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "service GetSegmentContacts")
+	}
+
+	pageSize := c.cfg.GetContactConfig().PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	contacts, next, err := c.rep.GetSegmentContacts(account, filter, bookmark, pageSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "service GetSegmentContacts")
+	}
+
+	total, err := c.rep.CountSegmentContacts(account, filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "service GetSegmentContacts")
+	}
+
+	return &entities.ContactPage{Contacts: contacts, TotalContacts: total, Bookmark: next}, nil
+}
+
+//segmentFilter parse the expression of the segment with the custom fields of the account
+func (c *contactService) segmentFilter(account, segmentID string) (*segment.Filter, error) {
+	seg, err := c.rep.GetSegment(account, segmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.parseSegment(account, seg.Expression)
+}
+
+func (c *contactService) parseSegment(account, expression string) (*segment.Filter, error) {
+	registry, err := c.loadCustomFields(account)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := segment.Parse(expression, registry.types, c.encryptedSegmentFields()...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return filter, nil
+}
+
+//encryptedSegmentFields the fields of the encryption policy in the names of the expressions, mongo can not compare them
+func (c *contactService) encryptedSegmentFields() []string {
+	cryptoCfg := c.cfg.GetCryptoConfig()
+	fields := append([]string{}, cryptoCfg.EncryptedFields...)
+	for _, name := range cryptoCfg.EncryptedCustomFields {
+		fields = append(fields, segment.CustomPrefix+name)
+	}
+	return fields
+}
@@ -0,0 +1,124 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/STreeChin/contactapi/internal/service"
+	"github.com/STreeChin/contactapi/pkg/config"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/pkg/segment"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var getSegment = entities.Segment{SegmentID: "segment_0D4B6A2E-3C1F-4E8A-9B7D-5F2C8A1E6B30", Name: "US leads", Expression: "Status=Lead AND custom.Score>50"}
+
+// TestAddSegment
+func TestAddSegment(t *testing.T) {
+	Convey("TestAddSegment", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		mockCfg.EXPECT().GetCryptoConfig().Return(&config.CryptoConfig{}).AnyTimes()
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		fields := []*entities.CustomField{{Name: "Score", FieldType: "integer"}}
+
+		Convey("Normal Case1: the expression is checked and the segment saved", func() {
+			gomock.InOrder(
+				mockRep.EXPECT().GetCustomFields("account").Return(fields, nil),
+				mockRep.EXPECT().InsertSegment("account", gomock.Any()).Return(nil),
+			)
+
+			seg, err := cSrv.AddSegment("account", getSegment.Name, getSegment.Expression)
+			So(err, ShouldEqual, nil)
+			So(strings.HasPrefix(seg.SegmentID, entities.SegmentIDPrefix), ShouldBeTrue)
+			So(seg.Expression, ShouldEqual, getSegment.Expression)
+		})
+
+		Convey("AbNormal Case1: the custom field is not registered", func() {
+			mockRep.EXPECT().GetCustomFields("account").Return(nil, nil)
+
+			_, err := cSrv.AddSegment("account", getSegment.Name, getSegment.Expression)
+			_, ok := errors.Cause(err).(*segment.Error)
+			So(ok, ShouldBeTrue)
+		})
+	})
+}
+
+// TestSegmentContacts
+func TestSegmentContacts(t *testing.T) {
+	Convey("TestSegmentContacts", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		mockCfg.EXPECT().GetContactConfig().Return(&config.ContactConfig{PageSize: 2}).AnyTimes()
+		mockCfg.EXPECT().GetCryptoConfig().Return(&config.CryptoConfig{EncryptedFields: []string{"Phone"}, EncryptedCustomFields: []string{"Salary"}}).AnyTimes()
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		fields := []*entities.CustomField{{Name: "Score", FieldType: "integer"}}
+
+		Convey("Normal Case1: count the members", func() {
+			gomock.InOrder(
+				mockRep.EXPECT().GetSegment("account", getSegment.SegmentID).Return(&getSegment, nil),
+				mockRep.EXPECT().GetCustomFields("account").Return(fields, nil),
+				mockRep.EXPECT().CountSegmentContacts("account", gomock.Any()).DoAndReturn(func(account string, filter *segment.Filter) (int64, error) {
+					So(filter.Exact(), ShouldBeTrue)
+					return 3, nil
+				}),
+			)
+
+			count, err := cSrv.CountSegmentContacts("account", getSegment.SegmentID)
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 3)
+		})
+
+		Convey("Normal Case2: one page of the members", func() {
+			gomock.InOrder(
+				mockRep.EXPECT().GetSegment("account", getSegment.SegmentID).Return(&getSegment, nil),
+				mockRep.EXPECT().GetCustomFields("account").Return(fields, nil),
+				mockRep.EXPECT().GetSegmentContacts("account", gomock.Any(), "", int64(2)).Return([]*entities.Contact{&getContact}, "next", nil),
+				mockRep.EXPECT().CountSegmentContacts("account", gomock.Any()).Return(int64(3), nil),
+			)
+
+			page, err := cSrv.GetSegmentContacts("account", getSegment.SegmentID, "")
+			So(err, ShouldEqual, nil)
+			So(page.Contacts, ShouldResemble, []*entities.Contact{&getContact})
+			So(page.Bookmark, ShouldEqual, "next")
+			So(page.TotalContacts, ShouldEqual, 3)
+		})
+
+		Convey("Normal Case3: the fields of the encryption policy are matched in the process", func() {
+			encrypted := entities.Segment{SegmentID: getSegment.SegmentID, Expression: "Status=Lead AND Phone=4159945916 AND custom.Salary>1000"}
+			gomock.InOrder(
+				mockRep.EXPECT().GetSegment("account", getSegment.SegmentID).Return(&encrypted, nil),
+				mockRep.EXPECT().GetCustomFields("account").Return([]*entities.CustomField{{Name: "Salary", FieldType: "integer"}}, nil),
+				mockRep.EXPECT().CountSegmentContacts("account", gomock.Any()).DoAndReturn(func(account string, filter *segment.Filter) (int64, error) {
+					So(filter.Exact(), ShouldBeFalse)
+					So(filter.Query(), ShouldResemble, bson.M{"status": "Lead"})
+					return 1, nil
+				}),
+			)
+
+			count, err := cSrv.CountSegmentContacts("account", getSegment.SegmentID)
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 1)
+		})
+
+		Convey("AbNormal Case1: the segment is not in the account", func() {
+			mockRep.EXPECT().GetSegment("other", getSegment.SegmentID).Return(nil, errors.WithStack(entities.ErrSegmentNotFound))
+
+			_, err := cSrv.CountSegmentContacts("other", getSegment.SegmentID)
+			So(errors.Cause(err), ShouldEqual, entities.ErrSegmentNotFound)
+		})
+	})
+}
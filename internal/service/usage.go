@@ -0,0 +1,42 @@
+package service
+
+import (
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/pkg/errors"
+)
+
+//UsageFlushInterval the counters of the usage in redis are written to the db at this interval, the reports lag behind by as much
+const UsageFlushInterval = time.Minute
+
+//FlushUsage: write the counters of the usage of yesterday and today to the db, yesterday for the requests of its last minutes
+func (c *contactService) FlushUsage() error {
+	now := c.now()
+	for _, day := range []time.Time{now.AddDate(0, 0, -1), now} {
+		usages, err := c.cache.GetUsages(day.Format(entities.UsageDayLayout))
+		if err != nil {
+			return errors.Wrap(err, "service FlushUsage")
+		}
+		err = c.rep.UpsertUsages(usages)
+		if err != nil {
+			return errors.Wrap(err, "service FlushUsage")
+		}
+	}
+	return nil
+}
+
+//GetUsageReport: get the usage of the api keys of the account by day from the day to the day, of one key if keyID is not empty
+func (c *contactService) GetUsageReport(account, keyID string, from, to time.Time) (*entities.UsageReport, error) {
+	report := &entities.UsageReport{From: from.Format(entities.UsageDayLayout), To: to.Format(entities.UsageDayLayout)}
+	usages, err := c.rep.GetUsages(account, keyID, report.From, report.To)
+	if err != nil {
+		return nil, errors.Wrap(err, "service GetUsageReport")
+	}
+
+	report.Usage = usages
+	for _, usage := range usages {
+		report.Total.Add(usage)
+	}
+	return report, nil
+}
@@ -0,0 +1,64 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/STreeChin/contactapi/internal/service"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestUsage
+func TestUsage(t *testing.T) {
+	Convey("TestUsage", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCache := mocks.NewMockCache(ctl)
+		mockRep := mocks.NewMockRepository(ctl)
+		cSrv := service.NewContactService(logger, mockCfg, mockCache, mockRep)
+		account := "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23"
+		now := time.Now().UTC()
+		yesterday, today := now.AddDate(0, 0, -1).Format(entities.UsageDayLayout), now.Format(entities.UsageDayLayout)
+
+		Convey("Normal Case1: flush yesterday then today", func() {
+			usages := []*entities.Usage{{KeyID: "ak_0123456789abcdef", Day: today, Account: account, Requests: 3, Writes: 1}}
+			gomock.InOrder(
+				mockCache.EXPECT().GetUsages(yesterday).Return(nil, nil),
+				mockRep.EXPECT().UpsertUsages(nil).Return(nil),
+				mockCache.EXPECT().GetUsages(today).Return(usages, nil),
+				mockRep.EXPECT().UpsertUsages(usages).Return(nil),
+			)
+
+			So(cSrv.FlushUsage(), ShouldEqual, nil)
+		})
+
+		Convey("Normal Case2: the report has the total of the days", func() {
+			from, to := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 5, 31, 0, 0, 0, 0, time.UTC)
+			usages := []*entities.Usage{
+				{KeyID: "ak_0123456789abcdef", Day: "2020-05-01", Requests: 10, Writes: 4, BulkRows: 200, Contacts: 150},
+				{KeyID: "ak_0123456789abcdef", Day: "2020-05-02", Requests: 5, Writes: 1, Contacts: 1},
+			}
+			mockRep.EXPECT().GetUsages(account, "", "2020-05-01", "2020-05-31").Return(usages, nil)
+
+			report, err := cSrv.GetUsageReport(account, "", from, to)
+			So(err, ShouldEqual, nil)
+			So(report.From, ShouldEqual, "2020-05-01")
+			So(report.To, ShouldEqual, "2020-05-31")
+			So(report.Usage, ShouldResemble, usages)
+			So(report.Total, ShouldResemble, entities.Usage{Requests: 15, Writes: 5, BulkRows: 200, Contacts: 151})
+		})
+
+		Convey("AbNormal Case: the flush stops at the first failure", func() {
+			mockCache.EXPECT().GetUsages(yesterday).Return(nil, errors.New("redis down"))
+
+			So(cSrv.FlushUsage(), ShouldNotEqual, nil)
+		})
+	})
+}
@@ -3,30 +3,75 @@ package cache
 import (
 	"bytes"
 	"encoding/gob"
+	"math/rand"
 	"time"
 
 	"github.com/STreeChin/contactapi/pkg/config"
+	"github.com/STreeChin/contactapi/pkg/customfield"
 	"github.com/STreeChin/contactapi/pkg/entities"
 	"github.com/gomodule/redigo/redis"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
+//the custom fields of the contact are interface{} values, gob needs their concrete types other than the basic ones
+func init() {
+	gob.Register(time.Time{})
+	gob.Register([]interface{}{})
+	gob.Register(customfield.Currency{})
+}
+
+//keyPrefix the prefix of the keys of the contacts, then the account and the email or the contact id
+const keyPrefix = "contact:"
+
+//the ttls of the keys of the contacts and the jitter of them if the config has none
+const (
+	defaultContactTTL  = time.Hour
+	defaultNotFoundTTL = 30 * time.Second
+	defaultTTLJitter   = 0.1
+)
+
+//the timeouts of the connections to redis: a request waits for them at most when redis is down
+const (
+	connectTimeout = 500 * time.Millisecond
+	readTimeout    = 500 * time.Millisecond
+	writeTimeout   = 500 * time.Millisecond
+)
+
+//notFound the value of the key of a contact not in the db, a contact or an email is never empty
+const notFound = ""
+
 type cache struct {
-	log  *logrus.Logger
-	pool *redis.Pool
+	log         *logrus.Logger
+	pool        *redis.Pool
+	contactTTL  time.Duration
+	notFoundTTL time.Duration
+	jitter      float64
 }
 
 //NewCache instance
 func NewCache(log *logrus.Logger, cfg config.Config) *cache {
 	c := new(cache)
 	c.log = log
+	c.contactTTL = time.Duration(cfg.GetCacheConfig().ContactTTL) * time.Second
+	if c.contactTTL <= 0 {
+		c.contactTTL = defaultContactTTL
+	}
+	c.notFoundTTL = time.Duration(cfg.GetCacheConfig().NotFoundTTL) * time.Second
+	if c.notFoundTTL <= 0 {
+		c.notFoundTTL = defaultNotFoundTTL
+	}
+	c.jitter = cfg.GetCacheConfig().TTLJitter
+	if c.jitter <= 0 || c.jitter >= 1 {
+		c.jitter = defaultTTLJitter
+	}
 
 	c.pool = &redis.Pool{
 		MaxIdle:     10,
 		IdleTimeout: 240 * time.Second,
 		Dial: func() (redis.Conn, error) {
-			c, err := redis.Dial("tcp", cfg.GetCacheConfig().URL)
+			c, err := redis.Dial("tcp", cfg.GetCacheConfig().URL,
+				redis.DialConnectTimeout(connectTimeout), redis.DialReadTimeout(readTimeout), redis.DialWriteTimeout(writeTimeout))
 			if err != nil {
 				return nil, errors.Wrap(err, "redis.Dial")
 			}
@@ -49,37 +94,43 @@ func NewCache(log *logrus.Logger, cfg config.Config) *cache {
 	return c
 }
 
-func (c *cache) GetEmailByContactID(key string) (string, error) {
+func (c *cache) GetEmailByContactID(account, key string) (string, error) {
 	conn := c.pool.Get()
 	defer conn.Close()
 
-	email, err := redis.String(conn.Do("get", key))
+	email, err := redis.String(conn.Do("get", accountKey(account, key)))
+	if err == nil && email == notFound {
+		return "", errors.Wrap(entities.ErrCachedNotFound, "redis get email")
+	}
 	return email, errors.Wrap(err, "redis get email")
 }
 
-func (c *cache) SetEmailByContactID(key, value string) error {
+func (c *cache) SetEmailByContactID(account, key, value string) error {
 	conn := c.pool.Get()
 	defer conn.Close()
 
-	_, err := conn.Do("set", key, value)
+	_, err := conn.Do("set", accountKey(account, key), value, "PX", c.ttl(c.contactTTL).Milliseconds())
 	return errors.Wrap(err, "redis set email")
 }
 
-func (c *cache) GetOneContact(key string) (*entities.Contact, error) {
+func (c *cache) GetOneContact(account, key string) (*entities.Contact, error) {
 	conn := c.pool.Get()
 	defer conn.Close()
 
 	contact := new(entities.Contact)
-	result, err := redis.Bytes(conn.Do("get", key))
+	result, err := redis.Bytes(conn.Do("get", accountKey(account, key)))
 	if err != nil {
 		return contact, errors.Wrap(err, "redis get contact")
 	}
+	if string(result) == notFound {
+		return nil, errors.Wrap(entities.ErrCachedNotFound, "redis get contact")
+	}
 	reader := bytes.NewReader(result)
 	err = gob.NewDecoder(reader).Decode(contact)
 	return contact, errors.Wrap(err, "redis get contact decode")
 }
 
-func (c *cache) SetOneContact(key string, contact *entities.Contact) error {
+func (c *cache) SetOneContact(account, key string, contact *entities.Contact) error {
 	conn := c.pool.Get()
 	defer conn.Close()
 
@@ -88,14 +139,42 @@ func (c *cache) SetOneContact(key string, contact *entities.Contact) error {
 	if err != nil {
 		return errors.Wrap(err, "redis set contact encode")
 	}
-	_, err = conn.Do("set", key, buffer.Bytes())
+	_, err = conn.Do("set", accountKey(account, key), buffer.Bytes(), "PX", c.ttl(c.contactTTL).Milliseconds())
 	return errors.Wrap(err, "redis set contact")
 }
 
-func (c *cache) DelOneContact(key string) error {
+//SetNotFound remember the contact of the email or the contact id is not in the db, for a short while:
+//writing the contact deletes its keys anyway
+func (c *cache) SetNotFound(account, key string) error {
 	conn := c.pool.Get()
 	defer conn.Close()
 
-	_, err := conn.Do("DEL", key)
-	return errors.Wrap(err, "redis del contact")
+	_, err := conn.Do("set", accountKey(account, key), notFound, "PX", c.ttl(c.notFoundTTL).Milliseconds())
+	return errors.Wrap(err, "redis set not found")
+}
+
+func (c *cache) DelContacts(account string, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	args := make(redis.Args, 0, len(keys))
+	for _, key := range keys {
+		args = append(args, accountKey(account, key))
+	}
+	_, err := conn.Do("DEL", args...)
+	return errors.Wrap(err, "redis del contacts")
+}
+
+//ttl the ttl with the jitter, at random between ttl*(1-jitter) and ttl*(1+jitter)
+func (c *cache) ttl(ttl time.Duration) time.Duration {
+	return ttl + time.Duration((rand.Float64()*2-1)*c.jitter*float64(ttl))
+}
+
+//accountKey the key of the email or the contact id in the namespace of the account, the accounts never share a key
+func accountKey(account, key string) string {
+	return keyPrefix + account + ":" + key
 }
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+const (
+	//principalPrefix the principal of an api key by the digest of the key, an empty value for an invalid key
+	principalPrefix = "principal:"
+	//principalsPrefix the digests of the cached principals of a key id, to drop them all at a revocation
+	principalsPrefix = "principals:"
+	//revokedPrefix the key id was revoked lately, its principals read before are not cached again
+	revokedPrefix = "revoked:"
+	//revokedChannel the key ids revoked are published to every instance on it
+	revokedChannel = "principals:revoked"
+)
+
+//revokedMarkerTTL the marker of a revocation outlives any principal cached before it
+const revokedMarkerTTL = 5 * time.Minute
+
+//setPrincipalScript set the principal unless its key id was revoked since, and add its digest to the ones of the key id
+var setPrincipalScript = redis.NewScript(3, `
+if redis.call("EXISTS", KEYS[3]) == 1 then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+redis.call("SADD", KEYS[2], ARGV[3])
+redis.call("PEXPIRE", KEYS[2], ARGV[2])
+return 1
+`)
+
+//GetPrincipal the principal of the api key by its digest, entities.ErrInvalidAPIKey if the key was found invalid, redis.ErrNil if it is not cached
+func (c *cache) GetPrincipal(digest string) (*entities.Principal, error) {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	result, err := redis.Bytes(conn.Do("get", principalPrefix+digest))
+	if err != nil {
+		return nil, errors.Wrap(err, "redis get principal")
+	}
+	if len(result) == 0 {
+		return nil, errors.Wrap(entities.ErrInvalidAPIKey, "redis get principal")
+	}
+	principal := new(entities.Principal)
+	err = gob.NewDecoder(bytes.NewReader(result)).Decode(principal)
+	return principal, errors.Wrap(err, "redis get principal decode")
+}
+
+//SetPrincipal cache the principal of the api key for the ttl, a nil principal for an invalid key
+func (c *cache) SetPrincipal(digest string, principal *entities.Principal, ttl time.Duration) error {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	if principal == nil {
+		_, err := conn.Do("set", principalPrefix+digest, "", "PX", ttl.Milliseconds())
+		return errors.Wrap(err, "redis set principal")
+	}
+
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(principal)
+	if err != nil {
+		return errors.Wrap(err, "redis set principal encode")
+	}
+	_, err = setPrincipalScript.Do(conn, principalPrefix+digest, principalsPrefix+principal.KeyID, revokedPrefix+principal.KeyID,
+		buffer.Bytes(), ttl.Milliseconds(), digest)
+	return errors.Wrap(err, "redis set principal")
+}
+
+//DelPrincipals drop the cached principals of the key id and tell every instance to drop theirs
+func (c *cache) DelPrincipals(keyID string) error {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("set", revokedPrefix+keyID, 1, "PX", revokedMarkerTTL.Milliseconds())
+	if err != nil {
+		return errors.Wrap(err, "redis del principals marker")
+	}
+	digests, err := redis.Strings(conn.Do("SMEMBERS", principalsPrefix+keyID))
+	if err != nil {
+		return errors.Wrap(err, "redis del principals members")
+	}
+	args := redis.Args{}.Add(principalsPrefix + keyID)
+	for _, digest := range digests {
+		args = args.Add(principalPrefix + digest)
+	}
+	if _, err = conn.Do("DEL", args...); err != nil {
+		return errors.Wrap(err, "redis del principals")
+	}
+	_, err = conn.Do("PUBLISH", revokedChannel, keyID)
+	return errors.Wrap(err, "redis del principals publish")
+}
+
+//SubscribePrincipals call onRevoke with each key id revoked by any instance, it never returns:
+//after the subscription is lost it calls onRevoke with an empty key id, the revocations meanwhile are missed
+func (c *cache) SubscribePrincipals(onRevoke func(keyID string)) {
+	for {
+		err := c.receivePrincipals(onRevoke)
+		c.log.Errorf("redis subscribe principals, subscribing again: %+v", err)
+		onRevoke("")
+		time.Sleep(time.Second)
+	}
+}
+
+func (c *cache) receivePrincipals(onRevoke func(keyID string)) error {
+	conn := redis.PubSubConn{Conn: c.pool.Get()}
+	defer conn.Close()
+
+	if err := conn.Subscribe(revokedChannel); err != nil {
+		return errors.Wrap(err, "redis subscribe")
+	}
+	for {
+		//the subscription waits for the revocations without the read timeout of the pool
+		switch v := conn.ReceiveWithTimeout(0).(type) {
+		case redis.Message:
+			onRevoke(string(v.Data))
+		case error:
+			return errors.Wrap(v, "redis receive")
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+//rateLimitPrefix the token bucket of an api key in a class of routes, then the key id and the class
+const rateLimitPrefix = "ratelimit:"
+
+//takeTokenScript take a token from the bucket refilled by limit tokens per period, by the clock of redis so the instances agree;
+//the bucket is dropped once it would be full again, a missing bucket is full
+var takeTokenScript = redis.NewScript(1, `
+redis.replicate_commands()
+local t = redis.call("TIME")
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local limit = tonumber(ARGV[1])
+local rate = limit / tonumber(ARGV[2])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1]) or limit
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(limit, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+local reset = math.ceil((limit - tokens) / rate)
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.max(reset, 1))
+return {allowed, math.floor(tokens), reset, retry}
+`)
+
+//Take take a token from the bucket of the key, it holds limit tokens and gets them back over period
+func (c *cache) Take(key string, limit int, period time.Duration) (*entities.RateLimit, error) {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	result, err := redis.Int64s(takeTokenScript.Do(conn, rateLimitPrefix+key, limit, period.Milliseconds()))
+	if err != nil {
+		return nil, errors.Wrap(err, "redis take token")
+	}
+	if len(result) != 4 {
+		return nil, errors.Errorf("redis take token: %d values", len(result))
+	}
+	return &entities.RateLimit{
+		Allowed:    result[0] == 1,
+		Limit:      limit,
+		Remaining:  int(result[1]),
+		Reset:      time.Duration(result[2]) * time.Millisecond,
+		RetryAfter: time.Duration(result[3]) * time.Millisecond,
+	}, nil
+}
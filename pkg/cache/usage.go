@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"strings"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+//usagePrefix the counters of an api key in a day, then the day, the account and the key id;
+//the set of the day alone has the account and the key id of each one
+const usagePrefix = "usage:"
+
+//usageTTL the counters of a day stay until the day after is over, its last minutes are flushed then
+const usageTTL = 48 * time.Hour
+
+//IncrUsage add the usage to the counters of its api key in the day
+func (c *cache) IncrUsage(day string, usage *entities.Usage) error {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	member := usage.Account + ":" + usage.KeyID
+	key := usagePrefix + day + ":" + member
+	_ = conn.Send("MULTI")
+	for field, value := range usageFields(usage) {
+		if value != 0 {
+			_ = conn.Send("HINCRBY", key, field, value)
+		}
+	}
+	_ = conn.Send("EXPIRE", key, int64(usageTTL.Seconds()))
+	_ = conn.Send("SADD", usagePrefix+day, member)
+	_ = conn.Send("EXPIRE", usagePrefix+day, int64(usageTTL.Seconds()))
+	_, err := conn.Do("EXEC")
+	return errors.Wrap(err, "redis incr usage")
+}
+
+//GetUsage the counters of the api key in the day, zero if it has none
+func (c *cache) GetUsage(day, account, keyID string) (*entities.Usage, error) {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	counters, err := redis.Int64Map(conn.Do("HGETALL", usagePrefix+day+":"+account+":"+keyID))
+	if err != nil {
+		return nil, errors.Wrap(err, "redis get usage")
+	}
+	return usageOf(day, account, keyID, counters), nil
+}
+
+//GetUsages the counters of all the api keys in the day
+func (c *cache) GetUsages(day string) ([]*entities.Usage, error) {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	members, err := redis.Strings(conn.Do("SMEMBERS", usagePrefix+day))
+	if err != nil {
+		return nil, errors.Wrap(err, "redis get usages members")
+	}
+	for _, member := range members {
+		_ = conn.Send("HGETALL", usagePrefix+day+":"+member)
+	}
+	if err = conn.Flush(); err != nil {
+		return nil, errors.Wrap(err, "redis get usages")
+	}
+
+	usages := make([]*entities.Usage, 0, len(members))
+	for _, member := range members {
+		counters, err := redis.Int64Map(conn.Receive())
+		if err != nil {
+			return nil, errors.Wrap(err, "redis get usages")
+		}
+		//the key ids have no colon, the accounts may
+		i := strings.LastIndex(member, ":")
+		if i < 0 {
+			continue
+		}
+		usages = append(usages, usageOf(day, member[:i], member[i+1:], counters))
+	}
+	return usages, nil
+}
+
+func usageFields(usage *entities.Usage) map[string]int64 {
+	return map[string]int64{
+		"requests":             usage.Requests,
+		entities.UsageWrites:   usage.Writes,
+		entities.UsageBulkRows: usage.BulkRows,
+		entities.UsageContacts: usage.Contacts,
+	}
+}
+
+func usageOf(day, account, keyID string, counters map[string]int64) *entities.Usage {
+	return &entities.Usage{
+		KeyID:    keyID,
+		Day:      day,
+		Account:  account,
+		Requests: counters["requests"],
+		Writes:   counters[entities.UsageWrites],
+		BulkRows: counters[entities.UsageBulkRows],
+		Contacts: counters[entities.UsageContacts],
+	}
+}
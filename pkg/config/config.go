@@ -6,6 +6,7 @@ package config
 import (
 	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -16,6 +17,10 @@ import (
 type Config interface {
 	GetCacheConfig() *CacheConfig
 	GetDBConfig() *DatabaseConfig
+	GetContactConfig() *ContactConfig
+	GetCryptoConfig() *CryptoConfig
+	GetRateLimitConfig() *RateLimitConfig
+	GetUsageConfig() *UsageConfig
 }
 
 //HostConfig host
@@ -30,6 +35,12 @@ type CacheConfig struct {
 	Host    string
 	Port    string
 	URL     string
+	//ContactTTL the seconds a contact stays in the cache, 0 for an hour
+	ContactTTL int
+	//NotFoundTTL the seconds the cache remembers a contact is not in the db, 0 for 30 seconds
+	NotFoundTTL int
+	//TTLJitter the fraction of the ttls added or taken at random, the keys cached together do not expire together; 0 for 0.1
+	TTLJitter float64
 }
 
 //DatabaseConfig db
@@ -39,13 +50,66 @@ type DatabaseConfig struct {
 	UserName string
 	Password string
 	URL      string
+	//LegacyAccount the account of the contacts stored before the accounts, needed if the api keys belong to several accounts
+	LegacyAccount string
+}
+
+//ContactConfig contact
+type ContactConfig struct {
+	PageSize  int64
+	BulkLimit int
+	//ReadOnlyFields the json names of the fields the clients can not write
+	ReadOnlyFields []string
+	//ReadOnlyPolicy "ignore" drops the read-only fields of the request, "reject" fails the request
+	ReadOnlyPolicy string
+}
+
+//CryptoConfig crypto
+type CryptoConfig struct {
+	//IndexKey the base64 key of the blind indexes of the encrypted fields, at least 32 bytes
+	IndexKey string
+	//DataKey the source of the key of the encrypted fields: file:<path> for a file or a docker secret, env:<name> for an environment variable
+	DataKey string
+	//EncryptedFields the json names of the string fields of the contacts stored encrypted, besides contact_id and Email which always are;
+	//the fields not listed, the lists and the dates are stored in plaintext
+	EncryptedFields []string
+	//EncryptedCustomFields the names of the custom fields stored encrypted, "*" for all of them
+	EncryptedCustomFields []string
+	//DisableLegacyCBC stop reading the values of the cipher from before AES-GCM, once the re-encryption is done
+	DisableLegacyCBC bool
+}
+
+//RateLimitConfig rate limit
+type RateLimitConfig struct {
+	//Mode "redis" shares the buckets of the api keys between the instances, "local" keeps them in the process for a single instance
+	Mode string
+	//Read the requests per minute of an api key on the routes that read, unless the key has its own limit
+	Read int
+	//Write the requests per minute on the routes that write one contact, list or segment
+	Write int
+	//Bulk the requests per minute on the routes that write many contacts at once
+	Bulk int
+}
+
+//UsageConfig usage
+type UsageConfig struct {
+	//Writes the quota of the requests per day of an api key on the routes that write, unless the key has its own; 0 has none
+	Writes int64
+	//BulkRows the quota of the contacts per day sent by an api key in the bulk requests
+	BulkRows int64
+	//Contacts the quota of the new contacts per day stored by an api key
+	Contacts int64
 }
 
 type config struct {
-	docker   string
-	Host     HostConfig
-	Cache    CacheConfig
-	Database DatabaseConfig
+	docker    string
+	Host      HostConfig
+	Cache     CacheConfig
+	Database  DatabaseConfig
+	Contact   ContactConfig
+	Crypto    CryptoConfig
+	RateLimit RateLimitConfig
+	Usage     UsageConfig
 }
 
 //var configChange = make(chan int, 1)
@@ -64,7 +128,27 @@ func NewConfig() (*config, error) {
 		}
 		config.Database.Password = strings.TrimSpace(string(buf))
 		config.Database.URL = os.Getenv("MONGOURL")
+		config.Database.LegacyAccount = os.Getenv("LEGACYACCOUNT")
 		config.Cache.URL = os.Getenv("REDISURL")
+		config.Cache.ContactTTL, _ = strconv.Atoi(os.Getenv("CACHECONTACTTTL"))
+		config.Cache.NotFoundTTL, _ = strconv.Atoi(os.Getenv("CACHENOTFOUNDTTL"))
+		config.Cache.TTLJitter, _ = strconv.ParseFloat(os.Getenv("CACHETTLJITTER"), 64)
+		buf, err = ioutil.ReadFile(os.Getenv("INDEXKEY"))
+		if err != nil {
+			panic(errors.New("read the env var fail"))
+		}
+		config.Crypto.IndexKey = strings.TrimSpace(string(buf))
+		config.Crypto.DataKey = "file:" + os.Getenv("DATAKEY")
+		config.Crypto.EncryptedFields = splitList(os.Getenv("ENCRYPTEDFIELDS"))
+		config.Crypto.EncryptedCustomFields = splitList(os.Getenv("ENCRYPTEDCUSTOMFIELDS"))
+		config.Crypto.DisableLegacyCBC, _ = strconv.ParseBool(os.Getenv("DISABLELEGACYCBC"))
+		config.RateLimit.Mode = os.Getenv("RATELIMITMODE")
+		config.RateLimit.Read, _ = strconv.Atoi(os.Getenv("RATELIMITREAD"))
+		config.RateLimit.Write, _ = strconv.Atoi(os.Getenv("RATELIMITWRITE"))
+		config.RateLimit.Bulk, _ = strconv.Atoi(os.Getenv("RATELIMITBULK"))
+		config.Usage.Writes, _ = strconv.ParseInt(os.Getenv("QUOTAWRITES"), 10, 64)
+		config.Usage.BulkRows, _ = strconv.ParseInt(os.Getenv("QUOTABULKROWS"), 10, 64)
+		config.Usage.Contacts, _ = strconv.ParseInt(os.Getenv("QUOTACONTACTS"), 10, 64)
 	} else if env == "local" || env == "" {
 		fileName := "local.config"
 		config, err = local(fileName)
@@ -79,6 +163,17 @@ func NewConfig() (*config, error) {
 	return config, err
 }
 
+//splitList the comma separated values of the env var, none if it is empty
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
 func local(fileName string) (*config, error) {
 	path := os.Getenv("CONFIGPATH")
 	if path == "" {
@@ -133,6 +228,18 @@ func (c *config) GetCacheConfig() *CacheConfig {
 func (c *config) GetDBConfig() *DatabaseConfig {
 	return &c.Database
 }
+func (c *config) GetContactConfig() *ContactConfig {
+	return &c.Contact
+}
+func (c *config) GetCryptoConfig() *CryptoConfig {
+	return &c.Crypto
+}
+func (c *config) GetRateLimitConfig() *RateLimitConfig {
+	return &c.RateLimit
+}
+func (c *config) GetUsageConfig() *UsageConfig {
+	return &c.Usage
+}
 
 /*func WatchConfig(change chan int) {
 	viper.WatchConfig()
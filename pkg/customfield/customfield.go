@@ -0,0 +1,396 @@
+/*
+Package customfield implements the codec of the custom fields of the contacts.
+
+On the wire a custom field is keyed by "type--Name--Parts", the name is "Name Parts".
+The values are strings, or the native json values of the type.
+*/
+package customfield
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//the types of the custom fields
+const (
+	TypeString   = "string"
+	TypeInteger  = "integer"
+	TypeBoolean  = "boolean"
+	TypeFloat    = "float"
+	TypeDate     = "date"
+	TypeDatetime = "datetime"
+	TypeArray    = "array"
+	TypeEnum     = "enum"
+	TypeCurrency = "currency"
+)
+
+//DateLayout the layout of the date field, in the local time
+const DateLayout = "2006-01-02 15:04:05"
+
+//keySeparator separates the type and the parts of the name in the key
+const keySeparator = "--"
+
+var (
+	currencyAmount = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+	currencyCode   = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+//Currency an amount of money, the amount is a decimal string to keep it exact
+type Currency struct {
+	Amount string `json:"amount"`
+	Code   string `json:"code"`
+}
+
+//FieldError one custom field can not be decoded
+type FieldError struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return "custom field " + e.Key + ": " + e.Message
+}
+
+//Errors the errors of all the custom fields can not be decoded, sorted by key
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+//SplitKey "integer--Test--Field" -> "integer", "Test Field"
+func SplitKey(key string) (string, string, error) {
+	parts := strings.SplitN(key, keySeparator, 2)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("the key is not in the type--Name format")
+	}
+	if !knownType(parts[0]) {
+		return "", "", fmt.Errorf("unknown type %q", parts[0])
+	}
+
+	name := strings.Replace(parts[1], keySeparator, " ", -1)
+	if strings.TrimSpace(name) == "" {
+		return "", "", fmt.Errorf("the name is empty")
+	}
+
+	return parts[0], name, nil
+}
+
+//Key "integer", "Test Field" -> "integer--Test--Field"
+func Key(fieldType, name string) string {
+	return fieldType + keySeparator + strings.Replace(name, " ", keySeparator, -1)
+}
+
+//Decode decode all the custom fields of the request, name->value and name->type,
+//the error is Errors with one FieldError for each field can not be decoded
+func Decode(custom map[string]interface{}) (map[string]interface{}, map[string]string, error) {
+	values := make(map[string]interface{}, len(custom))
+	types := make(map[string]string, len(custom))
+	var errs Errors
+
+	//in the order of the keys: the errors are sorted, and the later key of a name used twice is the one reported
+	keys := make([]string, 0, len(custom))
+	for key := range custom {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := custom[key]
+		fieldType, name, err := SplitKey(key)
+		if err != nil {
+			errs = append(errs, &FieldError{Key: key, Message: err.Error()})
+			continue
+		}
+		if _, ok := types[name]; ok {
+			errs = append(errs, &FieldError{Key: key, Message: "the name is used by another key"})
+			continue
+		}
+
+		decoded, err := DecodeValue(fieldType, value)
+		if err != nil {
+			errs = append(errs, &FieldError{Key: key, Message: err.Error()})
+			continue
+		}
+		values[name] = decoded
+		types[name] = fieldType
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, errs
+	}
+
+	return values, types, nil
+}
+
+//DecodeValue decode the value to the go type of the field type, a value already decoded is returned as it is
+func DecodeValue(fieldType string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, fmt.Errorf("the value is null")
+	}
+
+	switch fieldType {
+	case TypeString:
+		return decodeString(value)
+	case TypeInteger:
+		return decodeInteger(value)
+	case TypeBoolean:
+		return decodeBoolean(value)
+	case TypeFloat:
+		return decodeFloat(value)
+	case TypeDate:
+		return decodeTime(value, func(s string) (time.Time, error) {
+			return time.ParseInLocation(DateLayout, s, time.Local)
+		})
+	case TypeDatetime:
+		return decodeTime(value, func(s string) (time.Time, error) {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			return t.UTC(), err
+		})
+	case TypeArray:
+		return decodeArray(value)
+	case TypeEnum:
+		return decodeEnum(value)
+	case TypeCurrency:
+		return decodeCurrency(value)
+	default:
+		return nil, fmt.Errorf("unknown type %q", fieldType)
+	}
+}
+
+//Encode encode the custom fields to the wire format, the type of a field not in the types comes from its value
+func Encode(values map[string]interface{}, types map[string]string) map[string]interface{} {
+	custom := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		fieldType, ok := types[name]
+		if !ok {
+			fieldType = TypeOf(value)
+		}
+		custom[Key(fieldType, name)] = EncodeValue(fieldType, value)
+	}
+
+	return custom
+}
+
+//EncodeValue the wire form of the decoded value: a string for the scalars, an array or an object for the others
+func EncodeValue(fieldType string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		if fieldType == TypeDatetime {
+			return v.UTC().Format(time.RFC3339Nano)
+		}
+		return v.Local().Format(DateLayout)
+	case []interface{}, Currency:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+//TypeOf the type of the custom field stored without its type, from the go type of the value
+func TypeOf(value interface{}) string {
+	switch value.(type) {
+	case int:
+		return TypeInteger
+	case bool:
+		return TypeBoolean
+	case float64:
+		return TypeFloat
+	case time.Time:
+		return TypeDate
+	case []interface{}:
+		return TypeArray
+	case Currency:
+		return TypeCurrency
+	default:
+		return TypeString
+	}
+}
+
+func knownType(fieldType string) bool {
+	switch fieldType {
+	case TypeString, TypeInteger, TypeBoolean, TypeFloat, TypeDate, TypeDatetime, TypeArray, TypeEnum, TypeCurrency:
+		return true
+	}
+	return false
+}
+
+func decodeString(value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected a string")
+	}
+	return s, nil
+}
+
+func decodeInteger(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case float64:
+		//a json number is exact up to 2^53
+		if v != math.Trunc(v) || math.Abs(v) > 1<<53 {
+			return nil, fmt.Errorf("expected an integer")
+		}
+		return int(v), nil
+	case json.Number:
+		return decodeInteger(v.String())
+	case string:
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer")
+		}
+		return i, nil
+	default:
+		return nil, fmt.Errorf("expected an integer")
+	}
+}
+
+func decodeBoolean(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("expected a boolean")
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("expected a boolean")
+	}
+}
+
+func decodeFloat(value interface{}) (interface{}, error) {
+	var f float64
+	var err error
+	switch v := value.(type) {
+	case float64:
+		f = v
+	case int:
+		f = float64(v)
+	case json.Number:
+		f, err = v.Float64()
+	case string:
+		f, err = strconv.ParseFloat(strings.TrimSpace(v), 64)
+	default:
+		return nil, fmt.Errorf("expected a number")
+	}
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("expected a number")
+	}
+
+	return f, nil
+}
+
+func decodeTime(value interface{}, parse func(string) (time.Time, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		t, err := parse(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("expected a time in the layout of the type")
+		}
+		return t, nil
+	default:
+		return nil, fmt.Errorf("expected a time in the layout of the type")
+	}
+}
+
+//decodeArray an array of strings, numbers and booleans
+func decodeArray(value interface{}) (interface{}, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an array")
+	}
+
+	decoded := make([]interface{}, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case string, bool, int:
+			decoded[i] = v
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("item %d is not a number", i)
+			}
+			decoded[i] = v
+		default:
+			return nil, fmt.Errorf("item %d is not a string, a number or a boolean", i)
+		}
+	}
+
+	return decoded, nil
+}
+
+//decodeEnum one value of a set, a non-empty string without spaces around
+func decodeEnum(value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok || s == "" || strings.TrimSpace(s) != s {
+		return nil, fmt.Errorf("expected a non-empty string")
+	}
+	return s, nil
+}
+
+//decodeCurrency {"amount": "12.30", "code": "USD"} or "12.30 USD", the amount may be a json number
+func decodeCurrency(value interface{}) (interface{}, error) {
+	var amount interface{}
+	var code string
+	switch v := value.(type) {
+	case Currency:
+		amount, code = v.Amount, v.Code
+	case map[string]interface{}:
+		amount = v["amount"]
+		code, _ = v["code"].(string)
+	case string:
+		parts := strings.Fields(v)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected an amount and a currency code")
+		}
+		amount, code = parts[0], parts[1]
+	default:
+		return nil, fmt.Errorf("expected an amount and a currency code")
+	}
+
+	var amountStr string
+	switch a := amount.(type) {
+	case string:
+		amountStr = a
+	case float64:
+		if math.IsNaN(a) || math.IsInf(a, 0) {
+			return nil, fmt.Errorf("expected a decimal amount")
+		}
+		amountStr = strconv.FormatFloat(a, 'f', -1, 64)
+	case json.Number:
+		amountStr = a.String()
+	}
+	if !currencyAmount.MatchString(amountStr) {
+		return nil, fmt.Errorf("expected a decimal amount")
+	}
+	if !currencyCode.MatchString(code) {
+		return nil, fmt.Errorf("expected an ISO 4217 currency code")
+	}
+
+	return Currency{Amount: amountStr, Code: code}, nil
+}
@@ -0,0 +1,223 @@
+package customfield_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/customfield"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//TestDecode
+func TestDecode(t *testing.T) {
+	Convey("TestDecode", t, func() {
+		Convey("Normal Case1: every field of every type, strings or native json values", func() {
+			var custom map[string]interface{}
+			So(json.Unmarshal([]byte(`{
+				"string--Note": "hello",
+				"integer--Test--Field": "1024",
+				"integer--Count": 7,
+				"boolean--Is--Member": true,
+				"boolean--Opted--In": "false",
+				"float--Ratio": 0.25,
+				"float--Score": "1.5",
+				"date--Joined": "2020-05-01 10:30:00",
+				"datetime--Last--Seen": "2020-05-01T10:30:00.123+02:00",
+				"array--Tags": ["a", 1, true],
+				"enum--Plan": "gold",
+				"currency--Price": {"amount": "12.30", "code": "USD"},
+				"currency--Fee": "0.99 EUR"
+			}`), &custom), ShouldEqual, nil)
+
+			values, types, err := customfield.Decode(custom)
+			So(err, ShouldEqual, nil)
+			So(len(values), ShouldEqual, 13)
+			So(values["Note"], ShouldEqual, "hello")
+			So(values["Test Field"], ShouldEqual, 1024)
+			So(values["Count"], ShouldEqual, 7)
+			So(values["Is Member"], ShouldEqual, true)
+			So(values["Opted In"], ShouldEqual, false)
+			So(values["Ratio"], ShouldEqual, 0.25)
+			So(values["Score"], ShouldEqual, 1.5)
+			So(values["Joined"], ShouldResemble, time.Date(2020, 5, 1, 10, 30, 0, 0, time.Local))
+			So(values["Last Seen"], ShouldResemble, time.Date(2020, 5, 1, 8, 30, 0, 123000000, time.UTC))
+			So(values["Tags"], ShouldResemble, []interface{}{"a", 1.0, true})
+			So(values["Plan"], ShouldEqual, "gold")
+			So(values["Price"], ShouldResemble, customfield.Currency{Amount: "12.30", Code: "USD"})
+			So(values["Fee"], ShouldResemble, customfield.Currency{Amount: "0.99", Code: "EUR"})
+			So(types["Test Field"], ShouldEqual, customfield.TypeInteger)
+			So(types["Last Seen"], ShouldEqual, customfield.TypeDatetime)
+			So(types["Fee"], ShouldEqual, customfield.TypeCurrency)
+		})
+
+		Convey("AbNormal Case1: one error for each invalid field, sorted by key", func() {
+			custom := map[string]interface{}{
+				"NoSeparator":          "x",
+				"unknown--Field":       "x",
+				"integer--Count":       "many",
+				"integer--Half":        1.5,
+				"boolean--Flag":        "maybe",
+				"string--Number":       5.0,
+				"date--Joined":         "2020-05-01",
+				"datetime--Seen":       "2020-05-01 10:30:00",
+				"array--Nested":        []interface{}{[]interface{}{"a"}},
+				"enum--Plan":           " gold",
+				"currency--Price":      map[string]interface{}{"amount": "12,30", "code": "USD"},
+				"currency--Fee":        "0.99 euro",
+				"float--Ratio":         nil,
+				"string--Fine":         "ok",
+				"integer--Test--Field": "1",
+				"string--Test--Field":  "1",
+			}
+
+			values, types, err := customfield.Decode(custom)
+			So(values, ShouldEqual, nil)
+			So(types, ShouldEqual, nil)
+			errs, ok := err.(customfield.Errors)
+			So(ok, ShouldBeTrue)
+			So(len(errs), ShouldEqual, 14)
+			for i := 1; i < len(errs); i++ {
+				So(errs[i-1].Key, ShouldBeLessThan, errs[i].Key)
+			}
+			So(errs[0].Key, ShouldEqual, "NoSeparator")
+		})
+	})
+}
+
+//TestEncode
+func TestEncode(t *testing.T) {
+	Convey("TestEncode", t, func() {
+		Convey("Normal Case1: the encoded fields decode to the same values", func() {
+			values := map[string]interface{}{
+				"Note":      "hello",
+				"Count":     7,
+				"Member":    true,
+				"Ratio":     0.1,
+				"Joined":    time.Date(2020, 5, 1, 10, 30, 0, 0, time.Local),
+				"Last Seen": time.Date(2020, 5, 1, 8, 30, 0, 123000000, time.UTC),
+				"Tags":      []interface{}{"a", 1.0, true},
+				"Plan":      "gold",
+				"Price":     customfield.Currency{Amount: "12.30", Code: "USD"},
+			}
+			types := map[string]string{"Last Seen": customfield.TypeDatetime, "Plan": customfield.TypeEnum}
+
+			custom := customfield.Encode(values, types)
+			So(custom["integer--Count"], ShouldEqual, "7")
+			So(custom["date--Joined"], ShouldEqual, "2020-05-01 10:30:00")
+			So(custom["datetime--Last--Seen"], ShouldEqual, "2020-05-01T08:30:00.123Z")
+			So(custom["enum--Plan"], ShouldEqual, "gold")
+
+			//through json, like the client reads and posts back
+			raw, err := json.Marshal(custom)
+			So(err, ShouldEqual, nil)
+			var read map[string]interface{}
+			So(json.Unmarshal(raw, &read), ShouldEqual, nil)
+
+			decoded, decodedTypes, err := customfield.Decode(read)
+			So(err, ShouldEqual, nil)
+			So(decoded, ShouldResemble, values)
+			So(decodedTypes["Plan"], ShouldEqual, customfield.TypeEnum)
+		})
+	})
+}
+
+//TestDecodeRoundTrip the codec never panics, and a decoded field survives the encode and decode round trip
+func TestDecodeRoundTrip(t *testing.T) {
+	Convey("TestDecodeRoundTrip", t, func() {
+		Convey("Normal Case1: the fields of every type and the malformed ones", func() {
+			cases := []string{
+				`{"integer--Test--Field": "1024", "string--Note": "hello"}`,
+				`{"float--Ratio": 0.25, "boolean--Flag": true, "array--Tags": ["a", 1, false]}`,
+				`{"date--Joined": "2020-05-01 10:30:00", "datetime--Seen": "2020-05-01T10:30:00Z"}`,
+				`{"currency--Price": {"amount": 12.3, "code": "USD"}, "enum--Plan": "gold"}`,
+				`{"NoSeparator": null, "--": 1, "integer----": [], "currency--Fee": "1 2 3"}`,
+				`{"integer--Big": 1e300, "float--Nan": "NaN", "boolean--Flag": "yes", "array--Nested": [[1]]}`,
+				`{"datetime--Seen": "2020-05-01T10:30:00.123456789+14:00", "enum--Plan": " gold", "currency--Fee": {"amount": true}}`,
+			}
+			for _, data := range cases {
+				So(roundTrip(data), ShouldEqual, nil)
+			}
+		})
+
+		Convey("Normal Case2: random fields of random types", func() {
+			fieldTypes := []string{"string", "integer", "boolean", "float", "date", "datetime", "array", "enum", "currency", "nope", ""}
+			check := func(names, values []string, kinds []uint8) bool {
+				custom := make(map[string]interface{}, len(names))
+				for i, name := range names {
+					var kind uint8
+					if len(kinds) > 0 {
+						kind = kinds[i%len(kinds)]
+					}
+					value := ""
+					if len(values) > 0 {
+						value = values[i%len(values)]
+					}
+					key := fieldTypes[int(kind)%len(fieldTypes)] + "--" + name
+					switch kind % 5 {
+					case 0:
+						custom[key] = value
+					case 1:
+						custom[key] = float64(len(value)) / 4
+					case 2:
+						custom[key] = len(value)%2 == 0
+					case 3:
+						custom[key] = []interface{}{value, float64(len(value))}
+					default:
+						custom[key] = map[string]interface{}{"amount": value, "code": "USD"}
+					}
+				}
+				data, err := json.Marshal(custom)
+				return err == nil && roundTrip(string(data)) == nil
+			}
+			So(quick.Check(check, &quick.Config{MaxCount: 2000}), ShouldEqual, nil)
+		})
+	})
+}
+
+//roundTrip decode the json of the custom fields, encode them and decode them again: the same fields must come back
+func roundTrip(data string) error {
+	var custom map[string]interface{}
+	if json.Unmarshal([]byte(data), &custom) != nil {
+		return nil
+	}
+
+	values, types, err := customfield.Decode(custom)
+	if err != nil {
+		if _, ok := err.(customfield.Errors); !ok {
+			return fmt.Errorf("the error is not customfield.Errors: %v", err)
+		}
+		return nil
+	}
+
+	raw, err := json.Marshal(customfield.Encode(values, types))
+	if err != nil {
+		return fmt.Errorf("encode: %v", err)
+	}
+	var read map[string]interface{}
+	if err := json.Unmarshal(raw, &read); err != nil {
+		return fmt.Errorf("read: %v", err)
+	}
+	again, againTypes, err := customfield.Decode(read)
+	if err != nil {
+		return fmt.Errorf("decode the encoded fields: %v", err)
+	}
+	if !reflect.DeepEqual(againTypes, types) {
+		return fmt.Errorf("the types changed: %v != %v", againTypes, types)
+	}
+	for name, value := range values {
+		if t1, ok := value.(time.Time); ok {
+			if t2, ok := again[name].(time.Time); !ok || !t1.Equal(t2) {
+				return fmt.Errorf("field %q changed: %v != %v", name, value, again[name])
+			}
+			continue
+		}
+		if !reflect.DeepEqual(again[name], value) {
+			return fmt.Errorf("field %q changed: %#v != %#v", name, value, again[name])
+		}
+	}
+	return nil
+}
@@ -13,7 +13,6 @@ import (
 //InitMongoDB init db
 func InitMongoDB(m *mongoDB) {
 	//InitAPIKey(m)
-	InitContactInfo(m)
 }
 
 //InitAPIKey init apiKey
@@ -38,7 +37,7 @@ func InitAPIKey(mgoDB *mongoDB) {
 
 	contact := new(entities.Contact)
 	//dbDoc, err = mgoDB.FindOne("contact", "apiKey", "apikey", string(encryptApiKey))
-	dbDoc, err := mgoDB.FindOne("contact", "apiKey", "apikey", encryptAPIKey)
+	dbDoc, err := mgoDB.FindOne("contact", "apiKey", bson.M{"apikey": encryptAPIKey})
 	if err != nil {
 		fmt.Println("Init DB apiKey: no document in DB apiKey")
 		if err := mgoDB.InsertOne("contact", "apiKey", oneDoc); err != nil {
@@ -46,7 +45,7 @@ func InitAPIKey(mgoDB *mongoDB) {
 		}
 		fmt.Println("Init DB apiKey: insert  document to DB apiKey success. ")
 
-		readDoc, err := mgoDB.FindOne("contact", "apiKey", "apikey", encryptAPIKey)
+		readDoc, err := mgoDB.FindOne("contact", "apiKey", bson.M{"apikey": encryptAPIKey})
 		if err != nil {
 			fmt.Println(err)
 			return
@@ -67,36 +66,3 @@ func InitAPIKey(mgoDB *mongoDB) {
 	fmt.Println("Init DB apiKey: read apiKey success. ", string(dbContactID), contactID)
 
 }
-
-//InitContactInfo init contactInfo
-func InitContactInfo(mgoDB *mongoDB) {
-	contact := new(entities.Contact)
-	contactID := "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23"
-	email := "repositoryInit@gmail.com"
-	contact.ContactID = contactID
-	contact.Email = email
-	contact.FirstName = "rep"
-	contact.LastName = "InitDB"
-	contact.Phone = "4159945916"
-
-	enContactID, _ := crpt.AesEncrypt([]byte(contact.ContactID))
-	enEmail, _ := crpt.AesEncrypt([]byte(contact.Email))
-
-	contact.ContactID = string(enContactID)
-	contact.Email = string(enEmail)
-	//readDoc, err := mgoDB.FindOne("contact", "contactInfo", "email", "init@test.com")
-	readDoc, err := mgoDB.FindOne("contact", "contactInfo", "email", string(enEmail))
-	if err != nil {
-		fmt.Println("Init DB contactInfo: read fail.", err)
-		if err := mgoDB.InsertOne("contact", "contactInfo", contact); err != nil {
-			fmt.Println("Init DB contactInfo: insert DB fail.", err)
-			return
-		}
-		fmt.Println("Init DB contactInfo: insert DB success. ", contactID, email)
-		return
-	}
-
-	dbContactID, _ := crpt.AesDecrypt([]byte(readDoc["contactid"].(string)))
-	dbEmail, _ := crpt.AesDecrypt([]byte(readDoc["email"].(string)))
-	fmt.Println("Init DB contactInfo: read success. ", string(dbEmail), string(dbContactID), contact.FirstName)
-}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +15,12 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+//BulkItemResult the result of one operation in the bulk write
+type BulkItemResult struct {
+	Upserted bool
+	Err      error
+}
+
 type mongoDB struct {
 	log    *logrus.Logger
 	db     *mongo.Database
@@ -55,8 +62,8 @@ func NewDataStore(log *logrus.Logger, config config.Config) *mongoDB {
 	return nil
 }
 
-//FindOne find one document
-func (m *mongoDB) FindOne(db, coll, key string, value interface{}) (bson.M, error) {
+//FindOne find one document match the filter
+func (m *mongoDB) FindOne(db, coll string, filter interface{}) (bson.M, error) {
 	var result bson.M
 	var err error
 	var collection *mongo.Collection
@@ -65,7 +72,6 @@ func (m *mongoDB) FindOne(db, coll, key string, value interface{}) (bson.M, erro
 		return nil, errors.Wrap(err, "mongodb find one")
 	}
 
-	filter := bson.D{primitive.E{Key: key, Value: value}}
 	err = collection.FindOne(context.TODO(), filter).Decode(&result)
 
 	return result, errors.Wrap(err, "mongodb find")
@@ -79,9 +85,8 @@ func (m *mongoDB) InsertOne(db, coll string, value interface{}) error {
 	return errors.Wrap(err, "mongodb insert one")
 }
 
-//InsertOne insert one doc
-func (m *mongoDB) UpdateOne(db, coll string, key string, value interface{}, doc interface{}) error {
-	filter := bson.D{primitive.E{Key: key, Value: value}}
+//UpdateOne set the doc to the doc match the filter, insert it if none
+func (m *mongoDB) UpdateOne(db, coll string, filter interface{}, doc interface{}) error {
 	update := bson.M{"$set": doc}
 
 	collection := m.Client.Database(db).Collection(coll)
@@ -91,35 +96,176 @@ func (m *mongoDB) UpdateOne(db, coll string, key string, value interface{}, doc
 	return errors.Wrap(err, "mongodb update one")
 }
 
-//DeleteOne delete one doc
-func (m *mongoDB) DeleteOne(db, coll, key string, value interface{}) error {
+//SetFields set the fields of one doc, mongo.ErrNoDocuments if no doc matched
+func (m *mongoDB) SetFields(db, coll string, filter interface{}, fields interface{}) error {
+	update := bson.M{"$set": fields}
+
+	collection := m.Client.Database(db).Collection(coll)
+	result, err := collection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return errors.Wrap(err, "mongodb set fields")
+	}
+	if result.MatchedCount == 0 {
+		return errors.Wrap(mongo.ErrNoDocuments, "mongodb set fields")
+	}
+
+	return nil
+}
+
+//UpdateMatched apply the update to the first doc match the filter, false if no doc matched
+func (m *mongoDB) UpdateMatched(db, coll string, filter interface{}, update interface{}) (bool, error) {
+	collection := m.Client.Database(db).Collection(coll)
+	result, err := collection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return false, errors.Wrap(err, "mongodb update matched")
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+//UpdateMany apply the update to every doc match the filter, return how many matched
+func (m *mongoDB) UpdateMany(db, coll string, filter interface{}, update interface{}) (int64, error) {
+	collection := m.Client.Database(db).Collection(coll)
+	result, err := collection.UpdateMany(context.TODO(), filter, update)
+	if err != nil {
+		return 0, errors.Wrap(err, "mongodb update many")
+	}
+
+	return result.MatchedCount, nil
+}
+
+//FindOneAndUpsert apply the update to one doc or insert it atomically, return the doc before the update, nil if inserted
+func (m *mongoDB) FindOneAndUpsert(db, coll string, filter interface{}, update interface{}) (bson.M, error) {
+	collection := m.Client.Database(db).Collection(coll)
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+
+	var before bson.M
+	err := collection.FindOneAndUpdate(context.TODO(), filter, update, opts).Decode(&before)
+	//two concurrent upserts may both try to insert, the loser hits the unique index and updates the winner's doc
+	if isDuplicateKey(err) {
+		before = nil
+		err = collection.FindOneAndUpdate(context.TODO(), filter, update, opts).Decode(&before)
+	}
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	return before, errors.Wrap(err, "mongodb find one and upsert")
+}
+
+//EnsureUniqueIndex create the unique index on the keys, in their order, if it does not exist
+func (m *mongoDB) EnsureUniqueIndex(db, coll string, keys ...string) error {
+	indexKeys := make(bson.D, 0, len(keys))
+	for _, key := range keys {
+		indexKeys = append(indexKeys, primitive.E{Key: key, Value: 1})
+	}
+	index := mongo.IndexModel{
+		Keys:    indexKeys,
+		Options: options.Index().SetUnique(true),
+	}
+
+	collection := m.Client.Database(db).Collection(coll)
+	_, err := collection.Indexes().CreateOne(context.TODO(), index)
+
+	return errors.Wrap(err, "mongodb ensure unique index")
+}
+
+//DropIndex drop the index on the keys, in their order, nothing happens if it does not exist
+func (m *mongoDB) DropIndex(db, coll string, keys ...string) error {
+	const indexNotFound = 27
+
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, key+"_1")
+	}
+
+	collection := m.Client.Database(db).Collection(coll)
+	_, err := collection.Indexes().DropOne(context.TODO(), strings.Join(names, "_"))
+	if e, ok := err.(mongo.CommandError); ok && e.Code == indexNotFound {
+		return nil
+	}
+
+	return errors.Wrap(err, "mongodb drop index")
+}
+
+//isDuplicateKey the error is a violation of the unique index
+func isDuplicateKey(err error) bool {
+	const duplicateKey = 11000
+
+	switch e := err.(type) {
+	case mongo.CommandError:
+		return e.Code == duplicateKey
+	case mongo.WriteException:
+		for _, we := range e.WriteErrors {
+			if we.Code == duplicateKey {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//DeleteOne delete one doc match the filter
+func (m *mongoDB) DeleteOne(db, coll string, filter interface{}) error {
 	collection := m.Client.Database(db).Collection(coll)
-	filter := bson.D{primitive.E{Key: key, Value: value}}
 	_, err := collection.DeleteOne(context.TODO(), filter, nil)
 
 	return errors.Wrap(err, "mongodb delete one")
 }
 
-/*
-//CollectionCount count the doc of the Collection
-func (m *mongoDB) CollectionCount() (string, int64) {
+//BulkWrite execute the operations in one unordered bulk write, the results are in the same order as the models
+func (m *mongoDB) BulkWrite(db, coll string, models []mongo.WriteModel) ([]BulkItemResult, error) {
 	collection := m.Client.Database(db).Collection(coll)
-	name := collection.Name()
-	size, _ := collection.EstimatedDocumentCount(context.TODO())
-	return name, size
+	opts := options.BulkWrite().SetOrdered(false)
+	results := make([]BulkItemResult, len(models))
+
+	res, err := collection.BulkWrite(context.TODO(), models, opts)
+	if err != nil {
+		bulkErr, ok := err.(mongo.BulkWriteException)
+		if !ok || bulkErr.WriteConcernError != nil {
+			return nil, errors.Wrap(err, "mongodb bulk write")
+		}
+		for _, writeErr := range bulkErr.WriteErrors {
+			results[writeErr.Index].Err = errors.New(writeErr.Message)
+		}
+	}
+
+	if res != nil {
+		for index := range res.UpsertedIDs {
+			results[index].Upserted = true
+		}
+	}
+
+	return results, nil
 }
 
-//按选项查询集合 Skip 跳过 Limit 读取数量 sort 1 ，-1 . 1 为最初时间读取 ， -1 为最新时间读取
-func (m *mongoDB) CollectionDocuments(Skip, Limit int64, sort int) *mongo.Cursor {
-	collection := m.Client.Database(m.Database).Collection(m.Collection)
-	SORT := bson.D{{"_id", sort}} //filter := bson.D{{key,value}}
-	filter := bson.D{{}}
-	findOptions := options.Find().SetSort(SORT).SetLimit(Limit).SetSkip(Skip)
-	//findOptions.SetLimit(i)
-	temp, _ := collection.Find(context.Background(), filter, findOptions)
-	return temp
+//Find find the documents match the filter, sorted by _id ascending, limit 0 means no limit
+func (m *mongoDB) Find(db, coll string, filter interface{}, limit int64) ([]bson.M, error) {
+	collection := m.Client.Database(db).Collection(coll)
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "_id", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+
+	cursor, err := collection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "mongodb find")
+	}
+
+	results := make([]bson.M, 0, limit)
+	err = cursor.All(context.TODO(), &results)
+
+	return results, errors.Wrap(err, "mongodb find all")
+}
+
+//Count count the documents match the filter
+func (m *mongoDB) Count(db, coll string, filter interface{}) (int64, error) {
+	collection := m.Client.Database(db).Collection(coll)
+	count, err := collection.CountDocuments(context.TODO(), filter)
+
+	return count, errors.Wrap(err, "mongodb count")
 }
-*/
 
 /*
 //DeleteMany: delete many
@@ -0,0 +1,35 @@
+package entities
+
+import "time"
+
+//APIKey one api key of the account, only the key id and the salted hash of the secret are stored
+type APIKey struct {
+	KeyID  string   `json:"key_id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	//RateLimits the requests per RateLimitPeriod of the key by the class of the routes, the configured ones for the classes it has not
+	RateLimits map[string]int `json:"rate_limits,omitempty"`
+	//Quotas the daily quotas of the key by usage counter, the configured ones for the counters it has not
+	Quotas map[string]int64 `json:"quotas,omitempty"`
+	//Key the whole key, only in the response of the creation or the rotation: it can not be read again
+	Key        string     `json:"key,omitempty"`
+	CreatTime  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+//ReqAPIKey the request to create an api key, it expires after DefaultAPIKeyLifetime if the request does not tell
+type ReqAPIKey struct {
+	Name       string           `json:"name"`
+	Scopes     []string         `json:"scopes"`
+	RateLimits map[string]int   `json:"rate_limits"`
+	Quotas     map[string]int64 `json:"quotas"`
+	ExpiresAt  *time.Time       `json:"expires_at"`
+}
+
+//APIKeyIDPrefix the prefix of all the api key ids
+const APIKeyIDPrefix = "ak_"
+
+//DefaultAPIKeyLifetime the lifetime of the api keys created without an expiry date, and of the ones migrated
+const DefaultAPIKeyLifetime = 365 * 24 * time.Hour
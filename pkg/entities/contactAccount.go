@@ -1,5 +1,7 @@
 package entities
 
+import "time"
+
 //Contact  a struct to parse the request, the difference between with the Contact is the custom field
 type Contact struct {
 	// used internally
@@ -29,15 +31,19 @@ type Contact struct {
 	// used internally
 	Type string `json:"type"`
 	// used internally
-	CreatTime string `json:"created_at"`
+	CreatTime time.Time `json:"created_at" bson:"created_at"`
 	// used internally
-	UpdateTime string `json:"updated_at"`
+	UpdateTime time.Time `json:"updated_at" bson:"updated_at"`
 	// used internally
 	OwnerName string `json:"owner_name"`
 	// used internally
 	Unsubscribed bool `json:"unsubscribed"`
 	// used internally
+	Unsubscription *Unsubscription `json:"unsubscription,omitempty"`
+	// used internally
 	Custom map[string]interface{} `json:"custom"`
+	// used internally, the types declared by the "type--Name" keys of the request, name->type
+	CustomTypes map[string]string `json:"-" bson:"customtypes,omitempty"`
 	// used internally
 	AutopilotSessionID string `json:"_autopilot_session_id"`
 	// used internally
@@ -50,3 +56,53 @@ type Contact struct {
 type ReqContact struct {
 	Contact Contact `json:"contact"`
 }
+
+//ContactType the type of all the contacts
+const ContactType = "Contact"
+
+//EncryptedLookupFields the json names of the fields the contacts are looked up by, always stored encrypted with a blind index
+var EncryptedLookupFields = []string{"contact_id", "Email"}
+
+//AllCustomFields the name in the encrypted custom fields standing for every custom field
+const AllCustomFields = "*"
+
+//Unsubscription who unsubscribed the contact, when and from which source
+type Unsubscription struct {
+	By     string    `json:"by"`
+	Source string    `json:"source"`
+	Time   time.Time `json:"time"`
+}
+
+//ReqContacts bulk request
+type ReqContacts struct {
+	Contacts []Contact `json:"contacts"`
+}
+
+//the status of one written contact
+const (
+	ContactCreated = "created"
+	ContactUpdated = "updated"
+	ContactFailed  = "failed"
+)
+
+//BulkContactResult the result of one contact in the bulk request
+type BulkContactResult struct {
+	Email     string `json:"Email"`
+	ContactID string `json:"contact_id,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+//ContactPage one page of the contacts
+type ContactPage struct {
+	Contacts      []*Contact `json:"contacts"`
+	TotalContacts int64      `json:"total_contacts"`
+	Bookmark      string     `json:"bookmark,omitempty"`
+}
+
+//CustomField one custom field registered in the account
+type CustomField struct {
+	Name      string    `json:"name"`
+	FieldType string    `json:"fieldType"`
+	CreatTime time.Time `json:"created_at"`
+}
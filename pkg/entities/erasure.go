@@ -0,0 +1,12 @@
+package entities
+
+import "time"
+
+//Erasure the audit tombstone of an erased contact, it keeps no personal data
+type Erasure struct {
+	ErasureID string    `json:"erasure_id"`
+	ErasedAt  time.Time `json:"erased_at"`
+}
+
+//ErasureIDPrefix the prefix of all the erasure ids
+const ErasureIDPrefix = "erasure_"
@@ -0,0 +1,51 @@
+package entities
+
+import "github.com/pkg/errors"
+
+var (
+	//ErrInvalidBookmark the bookmark of the contacts page can not be decoded
+	ErrInvalidBookmark = errors.New("invalid bookmark")
+	//ErrTooManyContacts the bulk request is over the limit
+	ErrTooManyContacts = errors.New("too many contacts in one request")
+	//ErrInvalidPatch the merge patch can not be applied to the contact
+	ErrInvalidPatch = errors.New("invalid merge patch")
+	//ErrListNotFound the list is not in the account
+	ErrListNotFound = errors.New("list not found")
+	//ErrNotInList the contact is not a member of the list
+	ErrNotInList = errors.New("contact not in the list")
+	//ErrSegmentNotFound the segment is not in the account
+	ErrSegmentNotFound = errors.New("segment not found")
+	//ErrReencryptionNotRun the re-encryption job has never run
+	ErrReencryptionNotRun = errors.New("re-encryption not run")
+	//ErrInvalidAPIKey the api key is unknown, wrong, expired or revoked
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	//ErrAPIKeyNotFound the api key is not in the account
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	//ErrAPIKeyRevoked the api key has been revoked
+	ErrAPIKeyRevoked = errors.New("api key revoked")
+	//ErrNoAccount the contact is written without the account owns it
+	ErrNoAccount = errors.New("no account")
+	//ErrCachedNotFound the cache remembers the contact is not in the db
+	ErrCachedNotFound = errors.New("cached not found")
+	//ErrCacheUnavailable the circuit breaker of the cache is open, redis is not called
+	ErrCacheUnavailable = errors.New("cache unavailable")
+)
+
+//ReadOnlyFieldError the request tries to change a read-only field of the contact
+type ReadOnlyFieldError struct {
+	Field string
+}
+
+func (e *ReadOnlyFieldError) Error() string {
+	return "read-only field: " + e.Field
+}
+
+//CustomFieldTypeError the custom field is registered with another type
+type CustomFieldTypeError struct {
+	Name      string
+	FieldType string
+}
+
+func (e *CustomFieldTypeError) Error() string {
+	return "custom field " + e.Name + " is registered as " + e.FieldType
+}
@@ -0,0 +1,38 @@
+package entities
+
+import "time"
+
+//the states of the circuit breaker of the cache
+const (
+	//BreakerClosed the calls go to redis
+	BreakerClosed = "closed"
+	//BreakerOpen redis failed, the calls fail at once until the cooldown is over
+	BreakerOpen = "open"
+	//BreakerHalfOpen the cooldown is over, one call tries redis again
+	BreakerHalfOpen = "half-open"
+)
+
+//the status of the instance
+const (
+	//HealthOK the db and the cache answer
+	HealthOK = "ok"
+	//HealthDegraded the requests are served without the cache, or the cache may still have contacts the writes could not drop
+	HealthDegraded = "degraded"
+)
+
+//Health the health of the instance, the cache only degrades it: the contacts are read from the db without it
+type Health struct {
+	Status string      `json:"status"`
+	Cache  CacheHealth `json:"cache"`
+}
+
+//CacheHealth the circuit breaker of the cache and the invalidations waiting for redis
+type CacheHealth struct {
+	Breaker string `json:"breaker"`
+	//Failures the calls to redis failed in a row
+	Failures int `json:"failures"`
+	//OpenedAt the last time the breaker opened, none while it is closed
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+	//PendingInvalidations the keys of the contacts the writes could not drop from the cache yet
+	PendingInvalidations int `json:"pending_invalidations"`
+}
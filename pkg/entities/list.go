@@ -0,0 +1,18 @@
+package entities
+
+import "time"
+
+//List one contact list of the account, the members keep the list id in their lists
+type List struct {
+	ListID    string    `json:"list_id"`
+	Title     string    `json:"title"`
+	CreatTime time.Time `json:"created_at"`
+}
+
+//ReqList the request to create a list
+type ReqList struct {
+	Name string `json:"name"`
+}
+
+//ListIDPrefix the prefix of all the list ids
+const ListIDPrefix = "contactlist_"
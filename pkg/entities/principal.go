@@ -0,0 +1,57 @@
+package entities
+
+import "time"
+
+//the scopes of the api keys
+const (
+	ScopeContactsRead   = "contacts:read"
+	ScopeContactsWrite  = "contacts:write"
+	ScopeContactsDelete = "contacts:delete"
+	ScopeListsRead      = "lists:read"
+	ScopeListsWrite     = "lists:write"
+	ScopeSegmentsRead   = "segments:read"
+	ScopeSegmentsWrite  = "segments:write"
+	//ScopeAdmin the api keys and the jobs, it grants every other scope too
+	ScopeAdmin = "admin"
+)
+
+//Scopes all the scopes
+var Scopes = []string{
+	ScopeContactsRead, ScopeContactsWrite, ScopeContactsDelete,
+	ScopeListsRead, ScopeListsWrite,
+	ScopeSegmentsRead, ScopeSegmentsWrite,
+	ScopeAdmin,
+}
+
+//ValidScope the scope is one of Scopes
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+//Principal who the authenticated request is: the account and the api key with its scopes
+type Principal struct {
+	Account string
+	KeyID   string
+	Scopes  []string
+	//RateLimits the requests per RateLimitPeriod of the api key by the class of the routes
+	RateLimits map[string]int
+	//Quotas the daily quotas of the api key by usage counter
+	Quotas map[string]int64
+	//ExpiresAt the api key expires, a cached principal is not kept past it
+	ExpiresAt time.Time
+}
+
+//HasScope the principal is granted the scope, by the scope itself or by ScopeAdmin
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package entities
+
+import "time"
+
+//the classes of the routes, each api key has a rate limit per class
+const (
+	RateClassRead  = "read"
+	RateClassWrite = "write"
+	RateClassBulk  = "bulk"
+)
+
+//RateClasses all the classes of the routes
+var RateClasses = []string{RateClassRead, RateClassWrite, RateClassBulk}
+
+//ValidRateClass the class is one of RateClasses
+func ValidRateClass(class string) bool {
+	for _, c := range RateClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+//RateLimitPeriod the rate limits are requests per period, the bucket of a limit holds the requests of one period
+const RateLimitPeriod = time.Minute
+
+//RateLimit the bucket of the requests of an api key in a class after a request took a token
+type RateLimit struct {
+	//Allowed the request took a token
+	Allowed bool
+	//Limit the tokens of a full bucket
+	Limit     int
+	Remaining int
+	//Reset the bucket is full again after it
+	Reset time.Duration
+	//RetryAfter the next token is in the bucket after it, zero if the request was allowed
+	RetryAfter time.Duration
+}
@@ -0,0 +1,18 @@
+package entities
+
+import "time"
+
+//Reencryption the progress of the job encrypts the stored values again by the current key
+type Reencryption struct {
+	//KeyID the current key, the job starts again when it changes
+	KeyID string `json:"key_id" bson:"key_id"`
+	//Collection the collection being walked
+	Collection string `json:"collection" bson:"collection"`
+	//Scanned the documents read, Rewritten the ones encrypted again, Failed the ones could not be decrypted
+	Scanned   int64     `json:"scanned" bson:"scanned"`
+	Rewritten int64     `json:"rewritten" bson:"rewritten"`
+	Failed    int64     `json:"failed" bson:"failed"`
+	Done      bool      `json:"done" bson:"done"`
+	StartedAt time.Time `json:"started_at" bson:"started_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
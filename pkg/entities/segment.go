@@ -0,0 +1,20 @@
+package entities
+
+import "time"
+
+//Segment a saved filter of the contacts of the account, the members are evaluated on demand
+type Segment struct {
+	SegmentID  string    `json:"segment_id"`
+	Name       string    `json:"name"`
+	Expression string    `json:"expression"`
+	CreatTime  time.Time `json:"created_at"`
+}
+
+//ReqSegment the request to create a segment
+type ReqSegment struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+//SegmentIDPrefix the prefix of all the segment ids
+const SegmentIDPrefix = "segment_"
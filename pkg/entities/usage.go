@@ -0,0 +1,68 @@
+package entities
+
+//UsageDayLayout the days of the usage, in UTC
+const UsageDayLayout = "2006-01-02"
+
+//the counters of the usage with a quota
+const (
+	UsageWrites   = "writes"
+	UsageBulkRows = "bulk_rows"
+	UsageContacts = "contacts"
+)
+
+//UsageCounters the counters of the usage with a quota
+var UsageCounters = []string{UsageWrites, UsageBulkRows, UsageContacts}
+
+//ValidUsageCounter the counter is one of UsageCounters
+func ValidUsageCounter(counter string) bool {
+	for _, c := range UsageCounters {
+		if c == counter {
+			return true
+		}
+	}
+	return false
+}
+
+//Usage the usage of an api key in a day, of all of them in the total of a report
+type Usage struct {
+	KeyID string `json:"key_id,omitempty"`
+	Day   string `json:"day,omitempty"`
+	//Account the account owns the api key, not shown
+	Account  string `json:"-"`
+	Requests int64  `json:"requests"`
+	//Writes the requests to the routes that write
+	Writes int64 `json:"writes"`
+	//BulkRows the contacts sent in the bulk requests
+	BulkRows int64 `json:"bulk_rows"`
+	//Contacts the new contacts stored
+	Contacts int64 `json:"contacts"`
+}
+
+//Counter the value of the counter of the usage with a quota
+func (u *Usage) Counter(counter string) int64 {
+	switch counter {
+	case UsageWrites:
+		return u.Writes
+	case UsageBulkRows:
+		return u.BulkRows
+	case UsageContacts:
+		return u.Contacts
+	}
+	return 0
+}
+
+//Add add the counters of the other usage
+func (u *Usage) Add(other *Usage) {
+	u.Requests += other.Requests
+	u.Writes += other.Writes
+	u.BulkRows += other.BulkRows
+	u.Contacts += other.Contacts
+}
+
+//UsageReport the usage of the api keys of an account by day in a range of days
+type UsageReport struct {
+	From  string   `json:"from"`
+	To    string   `json:"to"`
+	Usage []*Usage `json:"usage"`
+	Total Usage    `json:"total"`
+}
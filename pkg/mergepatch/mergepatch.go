@@ -0,0 +1,27 @@
+/*
+Package mergepatch implements the JSON Merge Patch of RFC 7386.
+*/
+package mergepatch
+
+//Apply apply the patch to the target, both are decoded json values, the target maps are modified in place
+func Apply(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+
+	for name, value := range patchObj {
+		if value == nil {
+			delete(targetObj, name)
+			continue
+		}
+		targetObj[name] = Apply(targetObj[name], value)
+	}
+
+	return targetObj
+}
@@ -0,0 +1,41 @@
+package mergepatch_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/STreeChin/contactapi/pkg/mergepatch"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//TestApply the examples of the RFC 7386 Appendix A
+func TestApply(t *testing.T) {
+	Convey("TestApply", t, func() {
+		cases := [][3]string{
+			{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+			{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+			{`{"a":"b"}`, `{"a":null}`, `{}`},
+			{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+			{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+			{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+			{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+			{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+			{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+			{`{"a":"b"}`, `["c"]`, `["c"]`},
+			{`{"a":"foo"}`, `null`, `null`},
+			{`{"a":"foo"}`, `"bar"`, `"bar"`},
+			{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+			{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+			{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		}
+
+		for _, c := range cases {
+			var target, patch, expected interface{}
+			So(json.Unmarshal([]byte(c[0]), &target), ShouldEqual, nil)
+			So(json.Unmarshal([]byte(c[1]), &patch), ShouldEqual, nil)
+			So(json.Unmarshal([]byte(c[2]), &expected), ShouldEqual, nil)
+
+			So(mergepatch.Apply(target, patch), ShouldResemble, expected)
+		}
+	})
+}
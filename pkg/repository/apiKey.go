@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/route/middleware/crpt"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//apiKeyTouchInterval the last use of an api key is written at most once per interval, not on every request
+const apiKeyTouchInterval = time.Minute
+
+//legacyAPIKeyName the name of the api keys issued before the names
+const legacyAPIKeyName = "legacy"
+
+//apiKeyDoc one api key in the db: the key id and the salted hash of the secret, never the secret,
+//the keys issued before the key ids are looked up by the blind index of the whole key
+type apiKeyDoc struct {
+	KeyID      string           `bson:"keyid"`
+	Salt       []byte           `bson:"salt"`
+	Hash       []byte           `bson:"hash"`
+	Scopes     []string         `bson:"scopes"`
+	RateLimits map[string]int   `bson:"rate_limits,omitempty"`
+	Quotas     map[string]int64 `bson:"quotas,omitempty"`
+	//ContactID the account owns the key, encrypted
+	ContactID  interface{} `bson:"contactid"`
+	Account    string      `bson:"account_bidx"`
+	Name       string      `bson:"name"`
+	CreatTime  time.Time   `bson:"created_at"`
+	LastUsedAt *time.Time  `bson:"last_used_at"`
+	ExpiresAt  time.Time   `bson:"expires_at"`
+	RevokedAt  *time.Time  `bson:"revoked_at"`
+}
+
+//GetPrincipalByAPIKey the account and the scopes of the api key, entities.ErrInvalidAPIKey if the key is unknown, wrong, expired or revoked
+func (r *repository) GetPrincipalByAPIKey(apiKey string) (*entities.Principal, error) {
+	keyID, secret, ok := crpt.SplitAPIKey(apiKey)
+	filter := bson.M{"keyid": keyID}
+	if !ok {
+		secret = apiKey
+		filter = bson.M{"apikey" + bidxSuffix: r.blindIndex("apikey", apiKey)}
+	}
+	readDocs, err := r.DbHandler.Find("contact", "apiKey", filter, 1)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep getPrincipal find")
+	}
+	if len(readDocs) == 0 {
+		return nil, errors.WithStack(entities.ErrInvalidAPIKey)
+	}
+	doc, err := r.docToAPIKeyDoc(readDocs[0])
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep getPrincipal")
+	}
+
+	now := time.Now().UTC()
+	if doc.RevokedAt != nil || !now.Before(doc.ExpiresAt) || !crpt.CheckAPIKey(secret, doc.Salt, doc.Hash) {
+		return nil, errors.WithStack(entities.ErrInvalidAPIKey)
+	}
+	if doc.LastUsedAt == nil || now.Sub(*doc.LastUsedAt) >= apiKeyTouchInterval {
+		//the request goes on if the last use can not be written
+		if _, err := r.DbHandler.UpdateMatched("contact", "apiKey", bson.M{"_id": readDocs[0]["_id"]}, bson.M{"$set": bson.M{"last_used_at": now}}); err != nil {
+			r.log.Errorf("rep getPrincipal last use: %+v", err)
+		}
+	}
+
+	encContactID, _ := encryptedBytes(doc.ContactID)
+	contactID, err := r.cipher.Decrypt(encContactID)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep getPrincipal decrypt")
+	}
+
+	return &entities.Principal{Account: string(contactID), KeyID: doc.KeyID, Scopes: doc.Scopes, RateLimits: doc.RateLimits, Quotas: doc.Quotas, ExpiresAt: doc.ExpiresAt}, nil
+}
+
+//InsertAPIKey store the new api key of the account, the key of the entity is hashed
+func (r *repository) InsertAPIKey(account string, apiKey *entities.APIKey) error {
+	_, secret, _ := crpt.SplitAPIKey(apiKey.Key)
+	salt, hash, err := crpt.HashAPIKey(secret)
+	if err != nil {
+		return errors.WithMessage(err, "rep insertAPIKey")
+	}
+	encAccount, err := r.cipher.Encrypt([]byte(account))
+	if err != nil {
+		return errors.WithMessage(err, "rep insertAPIKey")
+	}
+
+	doc := &apiKeyDoc{
+		KeyID:      apiKey.KeyID,
+		Salt:       salt,
+		Hash:       hash,
+		ContactID:  encAccount,
+		Account:    r.blindIndex("account", account),
+		Scopes:     apiKey.Scopes,
+		RateLimits: apiKey.RateLimits,
+		Quotas:     apiKey.Quotas,
+		Name:       apiKey.Name,
+		CreatTime:  apiKey.CreatTime,
+		ExpiresAt:  apiKey.ExpiresAt,
+	}
+	err = r.DbHandler.InsertOne("contact", "apiKey", doc)
+
+	return errors.WithMessage(err, "rep insertAPIKey")
+}
+
+//GetAPIKeys the api keys of the account, in the order they were created
+func (r *repository) GetAPIKeys(account string) ([]*entities.APIKey, error) {
+	readDocs, err := r.DbHandler.Find("contact", "apiKey", bson.M{"account" + bidxSuffix: r.blindIndex("account", account)}, 0)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep getAPIKeys")
+	}
+
+	apiKeys := make([]*entities.APIKey, 0, len(readDocs))
+	for _, readDoc := range readDocs {
+		doc, err := r.docToAPIKeyDoc(readDoc)
+		if err != nil {
+			return nil, errors.WithMessage(err, "rep getAPIKeys")
+		}
+		apiKeys = append(apiKeys, doc.apiKey())
+	}
+
+	return apiKeys, nil
+}
+
+//GetAPIKey one api key of the account, entities.ErrAPIKeyNotFound if the account has no such key
+func (r *repository) GetAPIKey(account, keyID string) (*entities.APIKey, error) {
+	readDocs, err := r.DbHandler.Find("contact", "apiKey", r.apiKeyFilter(account, keyID), 1)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep getAPIKey")
+	}
+	if len(readDocs) == 0 {
+		return nil, errors.WithStack(entities.ErrAPIKeyNotFound)
+	}
+
+	doc, err := r.docToAPIKeyDoc(readDocs[0])
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep getAPIKey")
+	}
+	return doc.apiKey(), nil
+}
+
+//RevokeAPIKey revoke the api key of the account at the time, entities.ErrAPIKeyNotFound if the account has no such key
+func (r *repository) RevokeAPIKey(account, keyID string, revokeTime time.Time) error {
+	matched, err := r.DbHandler.UpdateMatched("contact", "apiKey", r.apiKeyFilter(account, keyID), bson.M{"$set": bson.M{"revoked_at": revokeTime}})
+	if err != nil {
+		return errors.WithMessage(err, "rep revokeAPIKey")
+	}
+	if !matched {
+		return errors.WithStack(entities.ErrAPIKeyNotFound)
+	}
+	return nil
+}
+
+//ExpireAPIKey bring the expiry of the api key of the account forward to the time, a key expires earlier keeps its expiry
+func (r *repository) ExpireAPIKey(account, keyID string, expireTime time.Time) error {
+	filter := r.apiKeyFilter(account, keyID)
+	filter["expires_at"] = bson.M{"$gt": expireTime}
+	_, err := r.DbHandler.UpdateMatched("contact", "apiKey", filter, bson.M{"$set": bson.M{"expires_at": expireTime}})
+
+	return errors.WithMessage(err, "rep expireAPIKey")
+}
+
+func (r *repository) apiKeyFilter(account, keyID string) bson.M {
+	return bson.M{"keyid": keyID, "account" + bidxSuffix: r.blindIndex("account", account)}
+}
+
+//migrateAPIKey the api key issued before the hashes: hash it, keep the blind index of the whole key to look it up,
+//drop the encrypted key; it gets a key id, a name and an expiry like the new ones, and the scopes from migrateAPIKeyScopes
+func (r *repository) migrateAPIKey(readDoc bson.M) error {
+	encAPIKey, _ := encryptedBytes(readDoc["apikey"])
+	apiKey, err := r.cipher.Decrypt(encAPIKey)
+	if err != nil {
+		return err
+	}
+	encContactID, _ := encryptedBytes(readDoc["contactid"])
+	contactID, err := r.cipher.Decrypt(encContactID)
+	if err != nil {
+		return err
+	}
+	salt, hash, err := crpt.HashAPIKey(string(apiKey))
+	if err != nil {
+		return err
+	}
+	keyID, _, err := crpt.NewAPIKey(entities.APIKeyIDPrefix)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	created := now
+	if id, ok := readDoc["_id"].(primitive.ObjectID); ok {
+		created = id.Timestamp().UTC()
+	}
+	set := bson.M{
+		"keyid":                keyID,
+		"salt":                 salt,
+		"hash":                 hash,
+		"apikey" + bidxSuffix:  r.blindIndex("apikey", string(apiKey)),
+		"account" + bidxSuffix: r.blindIndex("account", string(contactID)),
+		"name":                 legacyAPIKeyName,
+		"created_at":           created,
+		"expires_at":           now.Add(entities.DefaultAPIKeyLifetime),
+	}
+	return r.setMigrated("apiKey", readDoc["_id"], bson.M{"$set": set, "$unset": bson.M{"apikey": ""}})
+}
+
+//migrateAPIKeyScopes the api keys issued before the scopes could do everything, they keep doing it
+func (r *repository) migrateAPIKeyScopes(readDoc bson.M) error {
+	return r.setMigrated("apiKey", readDoc["_id"], bson.M{"$set": bson.M{"scopes": []string{entities.ScopeAdmin}}})
+}
+
+func (r *repository) docToAPIKeyDoc(readDoc bson.M) (*apiKeyDoc, error) {
+	bsonBytes, err := bson.Marshal(readDoc)
+	if err != nil {
+		return nil, errors.Wrap(err, "rep docToAPIKeyDoc")
+	}
+
+	doc := new(apiKeyDoc)
+	err = bson.Unmarshal(bsonBytes, doc)
+
+	return doc, errors.Wrap(err, "rep docToAPIKeyDoc")
+}
+
+func (d *apiKeyDoc) apiKey() *entities.APIKey {
+	return &entities.APIKey{
+		KeyID:      d.KeyID,
+		Name:       d.Name,
+		Scopes:     d.Scopes,
+		RateLimits: d.RateLimits,
+		Quotas:     d.Quotas,
+		CreatTime:  d.CreatTime,
+		LastUsedAt: d.LastUsedAt,
+		ExpiresAt:  d.ExpiresAt,
+		RevokedAt:  d.RevokedAt,
+	}
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//customFieldDoc one custom field in the db, the _id keeps one field per account and name
+type customFieldDoc struct {
+	ID struct {
+		Account string `bson:"account_bidx"`
+		Name    string `bson:"name"`
+	} `bson:"_id"`
+	FieldType string    `bson:"type"`
+	CreatTime time.Time `bson:"created_at"`
+}
+
+//GetCustomFields the custom fields registered in the account, sorted by name
+func (r *repository) GetCustomFields(account string) ([]*entities.CustomField, error) {
+	readDocs, err := r.DbHandler.Find("contact", "customField", bson.M{"_id.account" + bidxSuffix: r.blindIndex("account", account)}, 0)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep getCustomFields")
+	}
+
+	fields := make([]*entities.CustomField, 0, len(readDocs))
+	for _, readDoc := range readDocs {
+		field, err := r.docToCustomField(readDoc)
+		if err != nil {
+			return nil, errors.WithMessage(err, "rep getCustomFields")
+		}
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+//RegisterCustomField register the field in the account if it is not yet, return the registered one
+func (r *repository) RegisterCustomField(account string, field *entities.CustomField) (*entities.CustomField, error) {
+	id := bson.D{primitive.E{Key: "account" + bidxSuffix, Value: r.blindIndex("account", account)}, primitive.E{Key: "name", Value: field.Name}}
+	update := bson.M{"$setOnInsert": bson.M{"type": field.FieldType, "created_at": field.CreatTime}}
+	readDoc, err := r.DbHandler.FindOneAndUpsert("contact", "customField", bson.M{"_id": id}, update)
+	if err != nil || readDoc == nil {
+		return field, errors.WithMessage(err, "rep registerCustomField")
+	}
+
+	registered, err := r.docToCustomField(readDoc)
+	return registered, errors.WithMessage(err, "rep registerCustomField")
+}
+
+func (r *repository) docToCustomField(doc bson.M) (*entities.CustomField, error) {
+	bsonBytes, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "rep docToCustomField")
+	}
+
+	fieldDoc := new(customFieldDoc)
+	err = bson.Unmarshal(bsonBytes, fieldDoc)
+	if err != nil {
+		return nil, errors.Wrap(err, "rep docToCustomField")
+	}
+
+	return &entities.CustomField{Name: fieldDoc.ID.Name, FieldType: fieldDoc.FieldType, CreatTime: fieldDoc.CreatTime}, nil
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/route/middleware/crpt"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//keystoreDB the database of the wrapped data keys, apart from the contacts: its backups are not kept with theirs,
+//once the key of a contact is destroyed the backups of the contacts can not be decrypted either
+const keystoreDB = "keystore"
+
+//dataKeyColl the wrapped data key of each contact, by the blind indexes of its account and its email
+const dataKeyColl = "dataKey"
+
+//dataKeyOf the blind indexes of the account and the email of the contact, a data key belongs to one contact of one account
+type dataKeyOf struct {
+	account, email string
+}
+
+//contactCipher the cipher of the contact of the document: its own data key, created if missing and create,
+//the keyring decrypts the values stored before the contact had one
+func (r *repository) contactCipher(doc bson.M, create bool) (crpt.Cipher, error) {
+	accountIndex, _ := doc["account"+bidxSuffix].(string)
+	emailIndex, _ := doc["email"+bidxSuffix].(string)
+	filter := bson.M{"account" + bidxSuffix: accountIndex, "email" + bidxSuffix: emailIndex}
+	readDocs, err := r.DbHandler.Find(keystoreDB, dataKeyColl, filter, 1)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep contactCipher")
+	}
+	if len(readDocs) > 0 {
+		return r.dataKeyCipher(readDocs[0]["key"])
+	}
+	if !create {
+		return r.cipher, nil
+	}
+
+	plain, wrapped, err := r.kms.GenerateDataKey()
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep contactCipher")
+	}
+	update := bson.M{"$setOnInsert": bson.M{"key": wrapped, "created_at": time.Now().UTC()}}
+	readDoc, err := r.DbHandler.FindOneAndUpsert(keystoreDB, dataKeyColl, filter, update)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep contactCipher")
+	}
+	//another write created the key first, use that one
+	if readDoc != nil {
+		return r.dataKeyCipher(readDoc["key"])
+	}
+
+	return r.newContactCipher(plain)
+}
+
+//contactCiphers the ciphers of the contacts of the documents at once
+func (r *repository) contactCiphers(docs []bson.M) (map[dataKeyOf]crpt.Cipher, error) {
+	accountIndexes := make([]string, 0, 1)
+	emailIndexes := make([]string, 0, len(docs))
+	seen := make(map[string]bool, 1)
+	for _, doc := range docs {
+		of := keyOf(doc)
+		if !seen[of.account] {
+			seen[of.account] = true
+			accountIndexes = append(accountIndexes, of.account)
+		}
+		emailIndexes = append(emailIndexes, of.email)
+	}
+
+	filter := bson.M{"account" + bidxSuffix: bson.M{"$in": accountIndexes}, "email" + bidxSuffix: bson.M{"$in": emailIndexes}}
+	readDocs, err := r.DbHandler.Find(keystoreDB, dataKeyColl, filter, 0)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep contactCiphers")
+	}
+
+	ciphers := make(map[dataKeyOf]crpt.Cipher, len(readDocs))
+	for _, readDoc := range readDocs {
+		if ciphers[keyOf(readDoc)], err = r.dataKeyCipher(readDoc["key"]); err != nil {
+			return nil, errors.WithMessage(err, "rep contactCiphers")
+		}
+	}
+
+	return ciphers, nil
+}
+
+//createContactCiphers the ciphers of the contacts of the documents at once, the data keys missing are created
+//in one bulk write: the ones another write created first are read back and used instead
+func (r *repository) createContactCiphers(docs []bson.M) (map[dataKeyOf]crpt.Cipher, error) {
+	ciphers, err := r.contactCiphers(docs)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep createContactCiphers")
+	}
+
+	missing := make([]dataKeyOf, 0, len(docs))
+	plains := make(map[dataKeyOf][]byte, len(docs))
+	models := make([]mongo.WriteModel, 0, len(docs))
+	now := time.Now().UTC()
+	for _, doc := range docs {
+		of := keyOf(doc)
+		if _, ok := ciphers[of]; ok {
+			continue
+		}
+		if _, ok := plains[of]; ok {
+			continue
+		}
+		plain, wrapped, err := r.kms.GenerateDataKey()
+		if err != nil {
+			return nil, errors.WithMessage(err, "rep createContactCiphers")
+		}
+		plains[of] = plain
+		missing = append(missing, of)
+		model := mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"account" + bidxSuffix: of.account, "email" + bidxSuffix: of.email}).
+			SetUpdate(bson.M{"$setOnInsert": bson.M{"key": wrapped, "created_at": now}}).
+			SetUpsert(true)
+		models = append(models, model)
+	}
+	if len(models) == 0 {
+		return ciphers, nil
+	}
+
+	writeResults, err := r.DbHandler.BulkWrite(keystoreDB, dataKeyColl, models)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep createContactCiphers")
+	}
+
+	//the keys not inserted were created by another write, or lost the race on the unique index
+	raced := make([]bson.M, 0)
+	writeErrs := make(map[dataKeyOf]error)
+	for i, writeResult := range writeResults {
+		of := missing[i]
+		if writeResult.Upserted {
+			if ciphers[of], err = r.newContactCipher(plains[of]); err != nil {
+				return nil, errors.WithMessage(err, "rep createContactCiphers")
+			}
+			continue
+		}
+		if writeResult.Err != nil {
+			writeErrs[of] = writeResult.Err
+		}
+		raced = append(raced, bson.M{"account" + bidxSuffix: of.account, "email" + bidxSuffix: of.email})
+	}
+	if len(raced) == 0 {
+		return ciphers, nil
+	}
+
+	readCiphers, err := r.contactCiphers(raced)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep createContactCiphers")
+	}
+	for _, doc := range raced {
+		of := keyOf(doc)
+		c, ok := readCiphers[of]
+		if !ok {
+			err = writeErrs[of]
+			if err == nil {
+				err = errors.New("data key not stored")
+			}
+			return nil, errors.WithMessage(err, "rep createContactCiphers")
+		}
+		ciphers[of] = c
+	}
+
+	return ciphers, nil
+}
+
+//cipherOf the cipher of the contact in the ciphers, the keyring if it has no data key yet
+func (r *repository) cipherOf(ciphers map[dataKeyOf]crpt.Cipher, doc bson.M) crpt.Cipher {
+	if c, ok := ciphers[keyOf(doc)]; ok {
+		return c
+	}
+	return r.cipher
+}
+
+func keyOf(doc bson.M) dataKeyOf {
+	accountIndex, _ := doc["account"+bidxSuffix].(string)
+	emailIndex, _ := doc["email"+bidxSuffix].(string)
+	return dataKeyOf{accountIndex, emailIndex}
+}
+
+func (r *repository) dataKeyCipher(wrapped interface{}) (crpt.Cipher, error) {
+	data, _ := encryptedBytes(wrapped)
+	plain, err := r.kms.Decrypt(data)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep dataKeyCipher")
+	}
+	return r.newContactCipher(plain)
+}
+
+func (r *repository) newContactCipher(plain []byte) (crpt.Cipher, error) {
+	dek, err := crpt.NewDataKeyCipher(plain)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep newContactCipher")
+	}
+	return crpt.Fallback{dek, r.cipher}, nil
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/STreeChin/contactapi/pkg/config"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/route/middleware/crpt"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//encryptedCustomSubtype the binary subtype of an encrypted custom value, the user defined range of bson
+const encryptedCustomSubtype byte = 0x80
+
+//encryptionPolicy the fields of the contacts stored encrypted
+type encryptionPolicy struct {
+	//fields the bson keys of the encrypted fields of entities.Contact
+	fields map[string]bool
+	//lookup the bson keys of the lookup fields, stored encrypted before any policy
+	lookup map[string]bool
+	//custom the names of the encrypted custom fields, allCustom if every custom field is
+	custom    map[string]bool
+	allCustom bool
+	//stringKeys the bson keys of all the string fields of entities.Contact
+	stringKeys []string
+}
+
+//newEncryptionPolicy the policy of the config, the lookup fields are always encrypted: the blind indexes find them
+func newEncryptionPolicy(cfg *config.CryptoConfig) (*encryptionPolicy, error) {
+	keys := contactBsonKeys()
+	policy := &encryptionPolicy{fields: make(map[string]bool), lookup: make(map[string]bool), custom: make(map[string]bool)}
+	for _, key := range keys {
+		policy.stringKeys = append(policy.stringKeys, key)
+	}
+	sort.Strings(policy.stringKeys)
+
+	for i, name := range append(append([]string{}, entities.EncryptedLookupFields...), cfg.EncryptedFields...) {
+		key, ok := keys[name]
+		if !ok {
+			return nil, errors.Errorf("the field %q can not be encrypted, only the string fields of the contacts can", name)
+		}
+		policy.fields[key] = true
+		policy.lookup[key] = policy.lookup[key] || i < len(entities.EncryptedLookupFields)
+	}
+	for _, name := range cfg.EncryptedCustomFields {
+		policy.custom[name] = true
+		policy.allCustom = policy.allCustom || name == entities.AllCustomFields
+	}
+
+	return policy, nil
+}
+
+//encryptsCustom the custom field is stored encrypted
+func (p *encryptionPolicy) encryptsCustom(name string) bool {
+	return p.allCustom || p.custom[name]
+}
+
+//String the fields of the policy, sorted: the re-encryption starts again when it changes
+func (p *encryptionPolicy) String() string {
+	names := make([]string, 0, len(p.fields)+len(p.custom))
+	for key := range p.fields {
+		names = append(names, key)
+	}
+	for name := range p.custom {
+		names = append(names, "custom."+name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+//contactBsonKeys the json name->bson key of the string fields of entities.Contact, but the type of all the contacts
+func contactBsonKeys() map[string]string {
+	keys := make(map[string]string)
+	t := reflect.TypeOf(entities.Contact{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.Type.Kind() != reflect.String || name == "type" {
+			continue
+		}
+		key := strings.Split(field.Tag.Get("bson"), ",")[0]
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		keys[name] = key
+	}
+	return keys
+}
+
+//encryptDoc encrypt the fields of the policy in the document of the contact by its cipher
+func (r *repository) encryptDoc(doc bson.M, c crpt.Cipher) error {
+	for key := range r.policy.fields {
+		value, _ := doc[key].(string)
+		if value == "" {
+			continue
+		}
+		encrypted, err := c.Encrypt([]byte(value))
+		if err != nil {
+			return errors.WithMessage(err, key)
+		}
+		doc[key] = string(encrypted)
+	}
+
+	return r.eachCustomValue(doc, func(name string, value interface{}) (interface{}, error) {
+		if !r.policy.encryptsCustom(name) || value == nil {
+			return value, nil
+		}
+		encrypted, err := r.encryptCustomValue(c, value)
+		return encrypted, errors.WithMessage(err, name)
+	})
+}
+
+//encryptCustomValue the value in a document keeps its bson type through the encryption
+func (r *repository) encryptCustomValue(c crpt.Cipher, value interface{}) (interface{}, error) {
+	plain, err := bson.Marshal(bson.D{primitive.E{Key: "v", Value: value}})
+	if err != nil {
+		return nil, errors.Wrap(err, "rep encryptCustomValue")
+	}
+	encrypted, err := c.Encrypt(plain)
+	if err != nil {
+		return nil, err
+	}
+	return primitive.Binary{Subtype: encryptedCustomSubtype, Data: encrypted}, nil
+}
+
+//decryptDoc decrypt the string fields and the custom values stored encrypted, whatever the policy is now:
+//the values stored before their field was in the policy are plain, the ones stored after it left are encrypted
+func (r *repository) decryptDoc(doc bson.M, c crpt.Cipher) error {
+	for _, key := range r.policy.stringKeys {
+		value, _ := doc[key].(string)
+		if value == "" {
+			continue
+		}
+		plain, _, err := r.plainValue(c, key, value)
+		if err != nil {
+			return errors.WithMessage(err, key)
+		}
+		doc[key] = plain
+	}
+
+	return r.eachCustomValue(doc, func(name string, value interface{}) (interface{}, error) {
+		plain, _, err := r.plainCustomValue(c, value)
+		return plain, errors.WithMessage(err, name)
+	})
+}
+
+//plainValue the plain value of the stored string field, and whether it is stored encrypted: the lookup fields are always,
+//the others only once they carry a key id, and out of the policy a value failing the decryption is a plain one looks like it
+func (r *repository) plainValue(c crpt.Cipher, key, value string) (string, bool, error) {
+	//the lookup fields may be on the ciphers before the key ids
+	if _, ok := crpt.KeyID([]byte(value)); !ok && !r.policy.lookup[key] {
+		return value, false, nil
+	}
+	plain, err := c.Decrypt([]byte(value))
+	if err != nil && !r.policy.fields[key] {
+		return value, false, nil
+	}
+	return string(plain), true, err
+}
+
+//plainCustomValue the plain custom value, and whether it is stored encrypted
+func (r *repository) plainCustomValue(c crpt.Cipher, value interface{}) (interface{}, bool, error) {
+	binary, ok := value.(primitive.Binary)
+	if !ok || binary.Subtype != encryptedCustomSubtype {
+		return value, false, nil
+	}
+	plain, err := c.Decrypt(binary.Data)
+	if err != nil {
+		return nil, true, err
+	}
+	v, err := bson.Raw(plain).LookupErr("v")
+	if err != nil {
+		return nil, true, errors.Wrap(err, "rep plainCustomValue")
+	}
+	if v.Type == bsontype.Null {
+		return nil, true, nil
+	}
+	var decrypted interface{}
+	err = v.Unmarshal(&decrypted)
+	return decrypted, true, errors.Wrap(err, "rep plainCustomValue")
+}
+
+//eachCustomValue replace each custom value of the document by the one of the func
+func (r *repository) eachCustomValue(doc bson.M, each func(name string, value interface{}) (interface{}, error)) error {
+	switch custom := doc["custom"].(type) {
+	case bson.M:
+		for name, value := range custom {
+			v, err := each(name, value)
+			if err != nil {
+				return err
+			}
+			custom[name] = v
+		}
+	case primitive.D:
+		for i := range custom {
+			v, err := each(custom[i].Key, custom[i].Value)
+			if err != nil {
+				return err
+			}
+			custom[i].Value = v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+//erasureDoc the tombstone in the db, the blind indexes tell whether an email or a contact id was erased, not which
+type erasureDoc struct {
+	ErasureID string    `bson:"erasureid"`
+	Email     string    `bson:"email_bidx"`
+	ContactID string    `bson:"contactid_bidx"`
+	Account   string    `bson:"account_bidx"`
+	ErasedAt  time.Time `bson:"erased_at"`
+}
+
+//EraseContact make the contact unrecoverable: its data key is destroyed first, the copies encrypted by it,
+//backups included, can no longer be decrypted; then the contact is deleted and the tombstone left.
+//The suppression of the email is kept by its blind index only, the email stays unsubscribed
+func (r *repository) EraseContact(account string, contact *entities.Contact, erasure *entities.Erasure) error {
+	filter := r.contactFilter(account, "email", contact.Email)
+	err := r.DbHandler.DeleteOne(keystoreDB, dataKeyColl, filter)
+	if err != nil {
+		return errors.WithMessage(err, "rep eraseContact data key")
+	}
+
+	err = r.DbHandler.DeleteOne("contact", "contactInfo", filter)
+	if err != nil {
+		return errors.WithMessage(err, "rep eraseContact")
+	}
+
+	_, err = r.DbHandler.UpdateMatched("contact", "suppression", filter, bson.M{"$unset": bson.M{"email": ""}})
+	if err != nil {
+		return errors.WithMessage(err, "rep eraseContact suppression")
+	}
+
+	doc := &erasureDoc{
+		ErasureID: erasure.ErasureID,
+		Email:     r.blindIndex("email", contact.Email),
+		ContactID: r.blindIndex("contactid", contact.ContactID),
+		Account:   r.blindIndex("account", account),
+		ErasedAt:  erasure.ErasedAt,
+	}
+	err = r.DbHandler.InsertOne("contact", "erasure", doc)
+
+	return errors.WithMessage(err, "rep eraseContact tombstone")
+}
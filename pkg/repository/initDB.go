@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+)
+
+//initContactInfo init contactInfo, through the repository: the contact is encrypted and indexed like the others,
+//in the account of the api key of mongo-init.js
+func (r *repository) initContactInfo() {
+	account := "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23"
+	contactID := "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23"
+	email := "repositoryInit@gmail.com"
+
+	contact, err := r.GetOneContact(account, "email", email)
+	if err != nil {
+		fmt.Println("Init DB contactInfo: read fail.", err)
+		contact = &entities.Contact{ContactID: contactID, Email: email, FirstName: "rep", LastName: "InitDB", Phone: "4159945916"}
+		if err := r.InsertOneContact(account, contact); err != nil {
+			fmt.Println("Init DB contactInfo: insert DB fail.", err)
+			return
+		}
+		fmt.Println("Init DB contactInfo: insert DB success. ", contactID, email)
+		return
+	}
+
+	fmt.Println("Init DB contactInfo: read success. ", contact.Email, contact.ContactID, contact.FirstName)
+}
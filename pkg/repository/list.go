@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//listDoc one list in the db, the members are the contacts with the list id in their lists
+type listDoc struct {
+	ListID    string    `bson:"listid"`
+	Account   string    `bson:"account_bidx"`
+	Title     string    `bson:"title"`
+	CreatTime time.Time `bson:"created_at"`
+}
+
+func (r *repository) InsertList(account string, list *entities.List) error {
+	doc := &listDoc{ListID: list.ListID, Account: r.blindIndex("account", account), Title: list.Title, CreatTime: list.CreatTime}
+	err := r.DbHandler.InsertOne("contact", "list", doc)
+
+	return errors.WithMessage(err, "rep insertList")
+}
+
+//GetLists the lists of the account, in the order they were created
+func (r *repository) GetLists(account string) ([]*entities.List, error) {
+	readDocs, err := r.DbHandler.Find("contact", "list", bson.M{"account" + bidxSuffix: r.blindIndex("account", account)}, 0)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep getLists")
+	}
+
+	lists := make([]*entities.List, 0, len(readDocs))
+	for _, readDoc := range readDocs {
+		list, err := r.docToList(readDoc)
+		if err != nil {
+			return nil, errors.WithMessage(err, "rep getLists")
+		}
+		lists = append(lists, list)
+	}
+
+	return lists, nil
+}
+
+//GetList one list of the account, entities.ErrListNotFound if the account has no such list
+func (r *repository) GetList(account, listID string) (*entities.List, error) {
+	filter := bson.M{"listid": listID, "account" + bidxSuffix: r.blindIndex("account", account)}
+	readDocs, err := r.DbHandler.Find("contact", "list", filter, 1)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep getList")
+	}
+	if len(readDocs) == 0 {
+		return nil, errors.WithStack(entities.ErrListNotFound)
+	}
+
+	list, err := r.docToList(readDocs[0])
+	return list, errors.WithMessage(err, "rep getList")
+}
+
+//AddToList add the list id to the lists of the contact of the account, mongo.ErrNoDocuments if there is no such contact
+func (r *repository) AddToList(account, email, listID string) error {
+	//$addToSet needs an array, the contacts stored before the lists were managed may have null,
+	//the array is tried again in case a concurrent request just set it
+	addToSet := bson.M{"$addToSet": bson.M{"lists": listID}}
+	updates := []struct {
+		lists  bson.M
+		update bson.M
+	}{
+		{bson.M{"$type": "array"}, addToSet},
+		{bson.M{"$not": bson.M{"$type": "array"}}, bson.M{"$set": bson.M{"lists": bson.A{listID}}}},
+		{bson.M{"$type": "array"}, addToSet},
+	}
+	for _, u := range updates {
+		filter := r.contactFilter(account, "email", email)
+		filter["lists"] = u.lists
+		matched, err := r.DbHandler.UpdateMatched("contact", "contactInfo", filter, u.update)
+		if err != nil {
+			return errors.WithMessage(err, "rep addToList")
+		}
+		if matched {
+			return nil
+		}
+	}
+
+	return errors.WithMessage(errors.WithStack(mongo.ErrNoDocuments), "rep addToList")
+}
+
+//RemoveFromList remove the list id from the lists of the contact of the account, nothing happens if the contact is not in the list
+func (r *repository) RemoveFromList(account, email, listID string) error {
+	filter := r.contactFilter(account, "email", email)
+	filter["lists"] = listID
+	_, err := r.DbHandler.UpdateMatched("contact", "contactInfo", filter, bson.M{"$pull": bson.M{"lists": listID}})
+
+	return errors.WithMessage(err, "rep removeFromList")
+}
+
+func (r *repository) GetListContacts(account, listID, bookmark string, limit int64) ([]*entities.Contact, string, error) {
+	contacts, next, err := r.findContacts(account, bson.M{"lists": listID}, bookmark, limit)
+	return contacts, next, errors.WithMessage(err, "rep getListContacts")
+}
+
+func (r *repository) CountListContacts(account, listID string) (int64, error) {
+	filter := r.accountFilter(account)
+	filter["lists"] = listID
+	count, err := r.DbHandler.Count("contact", "contactInfo", filter)
+	return count, errors.WithMessage(err, "rep countListContacts")
+}
+
+func (r *repository) docToList(doc bson.M) (*entities.List, error) {
+	bsonBytes, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "rep docToList")
+	}
+
+	listDoc := new(listDoc)
+	err = bson.Unmarshal(bsonBytes, listDoc)
+	if err != nil {
+		return nil, errors.Wrap(err, "rep docToList")
+	}
+
+	return &entities.List{ListID: listDoc.ListID, Title: listDoc.Title, CreatTime: listDoc.CreatTime}, nil
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/route/middleware/crpt"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+//migrateBatch the documents migrated at once, the progress is saved after each batch
+const migrateBatch = 500
+
+//migrateJobID the _id of the progress of the migration in the job collection
+const migrateJobID = "migration"
+
+//migrationDoc the progress of the migration at the last start, by collection: the documents that can not be decrypted
+//are skipped and counted, the migration fails once the others are done and they are tried again at the next start
+type migrationDoc struct {
+	Scanned   map[string]int64 `bson:"scanned"`
+	Skipped   map[string]int64 `bson:"skipped"`
+	StartedAt time.Time        `bson:"started_at"`
+	UpdatedAt time.Time        `bson:"updated_at"`
+}
+
+//MigrateBlindIndexes add the blind indexes to the documents stored before them, it can run again and resume:
+//the contacts and the suppressions get email_bidx and contactid_bidx from the decrypted values, the encrypted api keys are hashed and get the scopes;
+//it fails once the documents that can not be decrypted are counted, they have no blind index
+func (r *repository) MigrateBlindIndexes() error {
+	job := &migrationDoc{Scanned: map[string]int64{}, Skipped: map[string]int64{}, StartedAt: time.Now().UTC()}
+	missing := bson.M{"email" + bidxSuffix: bson.M{"$exists": false}}
+	err := r.migrate(job, "contactInfo", missing, func(doc bson.M) error {
+		email, contactID, err := r.decryptIDs(doc)
+		if err != nil {
+			return err
+		}
+		fields := bson.M{}
+		r.setContactIndexes(fields, email, contactID)
+		return r.setMigrated("contactInfo", doc["_id"], bson.M{"$set": fields})
+	})
+	if err != nil {
+		return errors.WithMessage(err, "rep migrateBlindIndexes contacts")
+	}
+
+	err = r.migrate(job, "suppression", missing, func(doc bson.M) error {
+		email, _, err := r.decryptIDs(doc)
+		if err != nil {
+			return err
+		}
+		return r.setMigrated("suppression", doc["_id"], bson.M{"$set": bson.M{"email" + bidxSuffix: r.blindIndex("email", email)}})
+	})
+	if err != nil {
+		return errors.WithMessage(err, "rep migrateBlindIndexes suppressions")
+	}
+
+	err = r.migrate(job, "apiKey", bson.M{"apikey": bson.M{"$exists": true}}, r.migrateAPIKey)
+	if err != nil {
+		return errors.WithMessage(err, "rep migrateBlindIndexes api keys")
+	}
+	err = r.migrate(job, "apiKey", bson.M{"scopes": bson.M{"$exists": false}}, r.migrateAPIKeyScopes)
+	if err != nil {
+		return errors.WithMessage(err, "rep migrateBlindIndexes api key scopes")
+	}
+
+	//the skipped documents have no blind index, the unique indexes and the lookups can not rely on them
+	var skipped int64
+	for coll, count := range job.Skipped {
+		r.log.Errorf("rep migrateBlindIndexes: %d documents of %s skipped, they can not be decrypted", count, coll)
+		skipped += count
+	}
+	if skipped > 0 {
+		return errors.Errorf("rep migrateBlindIndexes: %d documents can not be decrypted, fix or remove them and start again", skipped)
+	}
+	return nil
+}
+
+//MigrateAccounts the contacts, the suppressions and the data keys stored before the accounts belong to legacyAccount,
+//or if it is empty to the account of the api keys, the one of mongo-init.js: any api key could read them until then.
+//It fails if they are left and the api keys belong to several accounts without legacyAccount, it can run again,
+//nothing moves if there is no api key yet
+func (r *repository) MigrateAccounts(legacyAccount string) error {
+	missing := bson.M{"account" + bidxSuffix: bson.M{"$exists": false}}
+	legacyColls := []struct{ db, coll string }{{"contact", "contactInfo"}, {"contact", "suppression"}, {keystoreDB, dataKeyColl}}
+	var left int64
+	for _, legacy := range legacyColls {
+		count, err := r.DbHandler.Count(legacy.db, legacy.coll, missing)
+		if err != nil {
+			return errors.WithMessage(err, "rep migrateAccounts "+legacy.coll)
+		}
+		left += count
+	}
+	if left == 0 {
+		return nil
+	}
+
+	var accountIndex interface{}
+	if legacyAccount != "" {
+		accountIndex = r.blindIndex("account", legacyAccount)
+	} else {
+		readDocs, err := r.DbHandler.Find("contact", "apiKey", bson.M{"account" + bidxSuffix: bson.M{"$exists": true}}, 1)
+		if err != nil {
+			return errors.WithMessage(err, "rep migrateAccounts")
+		}
+		if len(readDocs) == 0 {
+			return nil
+		}
+		accountIndex = readDocs[0]["account"+bidxSuffix]
+		others, err := r.DbHandler.Count("contact", "apiKey", bson.M{"account" + bidxSuffix: bson.M{"$exists": true, "$ne": accountIndex}})
+		if err != nil {
+			return errors.WithMessage(err, "rep migrateAccounts")
+		}
+		if others > 0 {
+			return errors.Errorf("rep migrateAccounts: %d documents are stored before the accounts and the api keys belong to several accounts, set Database.LegacyAccount to the account of the documents", left)
+		}
+	}
+
+	update := bson.M{"$set": bson.M{"account" + bidxSuffix: accountIndex}}
+	for _, legacy := range legacyColls {
+		count, err := r.DbHandler.UpdateMany(legacy.db, legacy.coll, missing, update)
+		if err != nil {
+			return errors.WithMessage(err, "rep migrateAccounts "+legacy.coll)
+		}
+		if count > 0 {
+			r.log.Infof("rep migrateAccounts: %d documents of %s moved to the legacy account", count, legacy.coll)
+		}
+	}
+
+	return nil
+}
+
+//migrate apply the migration to the documents match the filter in batches, in the order of their _id:
+//the documents that can not be decrypted are logged and counted in the job, the other errors stop it
+func (r *repository) migrate(job *migrationDoc, coll string, filter bson.M, each func(bson.M) error) error {
+	var lastID interface{}
+	for {
+		query := filter
+		if lastID != nil {
+			query = bson.M{"$and": []interface{}{filter, bson.M{"_id": bson.M{"$gt": lastID}}}}
+		}
+		readDocs, err := r.DbHandler.Find("contact", coll, query, migrateBatch)
+		if err != nil {
+			return err
+		}
+		for _, readDoc := range readDocs {
+			job.Scanned[coll]++
+			lastID = readDoc["_id"]
+			err := each(readDoc)
+			if errors.Cause(err) == crpt.ErrDecrypt {
+				job.Skipped[coll]++
+				r.log.Errorf("migration of %s %v skipped: %+v", coll, readDoc["_id"], err)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := r.saveMigrationDoc(job); err != nil {
+			return err
+		}
+		if len(readDocs) < migrateBatch {
+			return nil
+		}
+	}
+}
+
+func (r *repository) saveMigrationDoc(job *migrationDoc) error {
+	job.UpdatedAt = time.Now().UTC()
+	return r.DbHandler.UpdateOne("contact", "job", bson.M{"_id": migrateJobID}, job)
+}
+
+func (r *repository) setMigrated(coll string, id, update interface{}) error {
+	_, err := r.DbHandler.UpdateMatched("contact", coll, bson.M{"_id": id}, update)
+	return err
+}
+
+//decryptIDs the decrypted email and contact id of the document
+func (r *repository) decryptIDs(doc bson.M) (string, string, error) {
+	var ids [2]string
+	for i, key := range []string{"email", "contactid"} {
+		value, _ := doc[key].(string)
+		if value == "" {
+			continue
+		}
+		decrypted, err := r.repDecrypt(value)
+		if err != nil {
+			return "", "", err
+		}
+		ids[i] = decrypted
+	}
+
+	return ids[0], ids[1], nil
+}
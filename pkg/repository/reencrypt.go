@@ -0,0 +1,338 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//reencryptBatch the documents encrypted again at once, the progress is saved after each batch
+const reencryptBatch = 200
+
+//reencryptJobID the _id of the progress of the job in the job collection
+const reencryptJobID = "reencryption"
+
+//reencryptLeaseID the _id of the lease of the job in the job collection, reencryptLease how long it is held without a renewal:
+//one instance runs the job, the others wait for the lease and find the job done
+const (
+	reencryptLeaseID = "reencryption-lease"
+	reencryptLease   = 5 * time.Minute
+)
+
+//reencryptVersion the version of what the job applies, it starts again when it changes:
+//2 the contacts are encrypted by their own data keys
+const reencryptVersion = 2
+
+//reencryptCollections the collections walked in this order, the data keys of the contacts are wrapped again by the current key
+var reencryptCollections = []struct {
+	db   string
+	coll string
+}{
+	{"contact", "contactInfo"},
+	{"contact", "apiKey"},
+	{keystoreDB, dataKeyColl},
+}
+
+//reencryptFields the encrypted fields of the collections other than the contacts
+var reencryptFields = map[string][]string{
+	"apiKey":    {"contactid"},
+	dataKeyColl: {"key"},
+}
+
+//reencryptionDoc the progress and where the job resumes
+type reencryptionDoc struct {
+	entities.Reencryption `bson:",inline"`
+	//Policy the encryption policy of the contacts the job applies
+	Policy string `bson:"policy"`
+	//Version reencryptVersion
+	Version int `bson:"version"`
+	//LastID the last _id done in the collection
+	LastID interface{} `bson:"last_id"`
+}
+
+//Reencrypt encrypt again by the current key the accounts of the api keys and the data keys of the contacts still on the older keys,
+//and the contacts stored before they had a data key or before the encryption policy changed by their data keys,
+//the progress is saved after each batch: the job resumes where it stopped, and starts again when the current key or the policy changes
+//or when documents failed; it runs on one instance at a time, under a lease renewed after each batch
+func (r *repository) Reencrypt() error {
+	owner := primitive.NewObjectID().Hex()
+	for waiting := false; ; waiting = true {
+		leased, err := r.leaseReencryption(owner, false)
+		if err != nil {
+			return errors.WithMessage(err, "rep reencrypt")
+		}
+		if leased {
+			break
+		}
+		if !waiting {
+			r.log.Infof("re-encryption run by another instance, waiting for its lease")
+		}
+		time.Sleep(reencryptLease)
+	}
+	defer r.releaseReencryption(owner)
+
+	job, err := r.getReencryptionDoc()
+	if err != nil && errors.Cause(err) != entities.ErrReencryptionNotRun {
+		return errors.WithMessage(err, "rep reencrypt")
+	}
+	if job == nil || job.KeyID != r.keyID || job.Policy != r.policy.String() || job.Version != reencryptVersion {
+		job = &reencryptionDoc{Reencryption: entities.Reencryption{
+			KeyID:      r.keyID,
+			Collection: reencryptCollections[0].coll,
+			StartedAt:  time.Now().UTC(),
+		}, Policy: r.policy.String(), Version: reencryptVersion}
+	}
+	if job.Done {
+		return nil
+	}
+	//a pass starts again from the first collection, the counts are the ones of the pass
+	if job.Collection == reencryptCollections[0].coll && job.LastID == nil {
+		job.Scanned, job.Rewritten, job.Failed = 0, 0, 0
+	}
+
+	started := false
+	for _, c := range reencryptCollections {
+		if !started && c.coll != job.Collection {
+			continue
+		}
+		if started {
+			job.Collection, job.LastID = c.coll, nil
+		}
+		started = true
+
+		if err := r.reencryptCollection(job, c.db, owner); err != nil {
+			return errors.WithMessage(err, "rep reencrypt "+c.coll)
+		}
+	}
+
+	//the failed documents are tried again by the next pass, at the next run: the job is not done until none fails
+	if job.Failed > 0 {
+		failed := job.Failed
+		job.Collection, job.LastID = reencryptCollections[0].coll, nil
+		if err := r.saveReencryptionDoc(job); err != nil {
+			return errors.WithMessage(err, "rep reencrypt")
+		}
+		return errors.Errorf("rep reencrypt: %d documents failed, the re-encryption starts again at the next run", failed)
+	}
+
+	job.Done = true
+	r.log.Infof("re-encryption by the key %s done: %d scanned, %d rewritten", job.KeyID, job.Scanned, job.Rewritten)
+	return errors.WithMessage(r.saveReencryptionDoc(job), "rep reencrypt")
+}
+
+func (r *repository) reencryptCollection(job *reencryptionDoc, db, owner string) error {
+	for {
+		filter := bson.M{}
+		if job.LastID != nil {
+			filter = bson.M{"_id": bson.M{"$gt": job.LastID}}
+		}
+		readDocs, err := r.DbHandler.Find(db, job.Collection, filter, reencryptBatch)
+		if err != nil {
+			return err
+		}
+
+		for _, readDoc := range readDocs {
+			job.Scanned++
+			rewritten, err := r.reencryptDoc(db, job.Collection, readDoc)
+			if err != nil {
+				job.Failed++
+				r.log.Errorf("re-encryption of %s %v: %+v", job.Collection, readDoc["_id"], err)
+			} else if rewritten {
+				job.Rewritten++
+			}
+			job.LastID = readDoc["_id"]
+		}
+
+		if err := r.saveReencryptionDoc(job); err != nil {
+			return err
+		}
+		//another instance took the job over, the lease expired meanwhile
+		leased, err := r.leaseReencryption(owner, true)
+		if err != nil {
+			return err
+		}
+		if !leased {
+			return errors.New("the lease of the re-encryption is lost")
+		}
+		r.log.Infof("re-encryption of %s: %d scanned, %d rewritten, %d failed", job.Collection, job.Scanned, job.Rewritten, job.Failed)
+
+		if len(readDocs) < reencryptBatch {
+			return nil
+		}
+	}
+}
+
+//reencryptDoc write the document again if it is not on the current key or the policy,
+//false if it already is or changed meanwhile
+func (r *repository) reencryptDoc(db, coll string, doc bson.M) (bool, error) {
+	var filter, set bson.M
+	var err error
+	if coll == "contactInfo" {
+		filter, set, err = r.reencryptContact(doc)
+	} else {
+		filter, set, err = r.reencryptValues(doc, reencryptFields[coll])
+	}
+	if err != nil || len(set) == 0 {
+		return false, err
+	}
+
+	return r.DbHandler.UpdateMatched(db, coll, filter, bson.M{"$set": set})
+}
+
+//reencryptValues encrypt again the fields on the older keys, only if no one wrote them meanwhile
+func (r *repository) reencryptValues(doc bson.M, fields []string) (bson.M, bson.M, error) {
+	filter := bson.M{"_id": doc["_id"]}
+	set := bson.M{}
+	for _, field := range fields {
+		data, ok := encryptedBytes(doc[field])
+		if !ok || len(data) == 0 || r.cipher.Current(data) {
+			continue
+		}
+
+		plain, err := r.cipher.Decrypt(data)
+		if err != nil {
+			return nil, nil, errors.WithMessage(err, field)
+		}
+		encrypted, err := r.cipher.Encrypt(plain)
+		if err != nil {
+			return nil, nil, errors.WithMessage(err, field)
+		}
+
+		//written back in the bson type it was read
+		filter[field] = doc[field]
+		if _, ok := doc[field].(primitive.Binary); ok {
+			set[field] = encrypted
+		} else {
+			set[field] = string(encrypted)
+		}
+	}
+	return filter, set, nil
+}
+
+//reencryptContact encrypt by the data key of the contact the fields of the policy, decrypt the ones left it,
+//only if no one updated the contact meanwhile
+func (r *repository) reencryptContact(doc bson.M) (bson.M, bson.M, error) {
+	c, err := r.contactCipher(doc, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filter := bson.M{"_id": doc["_id"], "updated_at": doc["updated_at"]}
+	set := bson.M{}
+	for _, key := range r.policy.stringKeys {
+		value, _ := doc[key].(string)
+		if value == "" || (r.policy.fields[key] && c.Current([]byte(value))) {
+			continue
+		}
+		plain, encrypted, err := r.plainValue(c, key, value)
+		if err != nil {
+			return nil, nil, errors.WithMessage(err, key)
+		}
+		switch {
+		case r.policy.fields[key]:
+			data, err := c.Encrypt([]byte(plain))
+			if err != nil {
+				return nil, nil, errors.WithMessage(err, key)
+			}
+			set[key] = string(data)
+		case encrypted:
+			set[key] = plain
+		}
+	}
+
+	err = r.eachCustomValue(doc, func(name string, value interface{}) (interface{}, error) {
+		//the names the update can not address are left to the next write of the contact
+		if value == nil || strings.Contains(name, ".") || strings.HasPrefix(name, "$") {
+			return value, nil
+		}
+		if binary, ok := value.(primitive.Binary); ok && r.policy.encryptsCustom(name) && c.Current(binary.Data) {
+			return value, nil
+		}
+		plain, encrypted, err := r.plainCustomValue(c, value)
+		if err != nil {
+			return nil, errors.WithMessage(err, name)
+		}
+		switch {
+		case r.policy.encryptsCustom(name):
+			if set["custom."+name], err = r.encryptCustomValue(c, plain); err != nil {
+				return nil, errors.WithMessage(err, name)
+			}
+		case encrypted:
+			set["custom."+name] = plain
+		}
+		return value, nil
+	})
+
+	return filter, set, err
+}
+
+//GetReencryption the progress of the re-encryption, entities.ErrReencryptionNotRun if it has never run
+func (r *repository) GetReencryption() (*entities.Reencryption, error) {
+	job, err := r.getReencryptionDoc()
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep getReencryption")
+	}
+	return &job.Reencryption, nil
+}
+
+func (r *repository) getReencryptionDoc() (*reencryptionDoc, error) {
+	readDocs, err := r.DbHandler.Find("contact", "job", bson.M{"_id": reencryptJobID}, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(readDocs) == 0 {
+		return nil, errors.WithStack(entities.ErrReencryptionNotRun)
+	}
+
+	bsonBytes, err := bson.Marshal(readDocs[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "rep getReencryptionDoc")
+	}
+	job := new(reencryptionDoc)
+	err = bson.Unmarshal(bsonBytes, job)
+
+	return job, errors.Wrap(err, "rep getReencryptionDoc")
+}
+
+func (r *repository) saveReencryptionDoc(job *reencryptionDoc) error {
+	job.UpdatedAt = time.Now().UTC()
+	return r.DbHandler.UpdateOne("contact", "job", bson.M{"_id": reencryptJobID}, job)
+}
+
+//leaseReencryption take the lease of the job for reencryptLease, or renew it: false if another instance holds it
+func (r *repository) leaseReencryption(owner string, renew bool) (bool, error) {
+	now := time.Now().UTC()
+	filter := bson.M{"_id": reencryptLeaseID, "owner": owner}
+	if !renew {
+		_, err := r.DbHandler.FindOneAndUpsert("contact", "job", bson.M{"_id": reencryptLeaseID}, bson.M{"$setOnInsert": bson.M{"until": time.Time{}}})
+		if err != nil {
+			return false, err
+		}
+		filter = bson.M{"_id": reencryptLeaseID, "$or": []interface{}{bson.M{"owner": owner}, bson.M{"until": bson.M{"$lte": now}}}}
+	}
+
+	return r.DbHandler.UpdateMatched("contact", "job", filter, bson.M{"$set": bson.M{"owner": owner, "until": now.Add(reencryptLease)}})
+}
+
+//releaseReencryption let the other instances take the lease at once
+func (r *repository) releaseReencryption(owner string) {
+	filter := bson.M{"_id": reencryptLeaseID, "owner": owner}
+	if _, err := r.DbHandler.UpdateMatched("contact", "job", filter, bson.M{"$set": bson.M{"until": time.Time{}}}); err != nil {
+		r.log.Warnf("re-encryption lease not released, it expires in %s: %+v", reencryptLease, err)
+	}
+}
+
+//encryptedBytes the encrypted value stored as a string, like the contacts, or as binary, like the api keys
+func encryptedBytes(value interface{}) ([]byte, bool) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), true
+	case primitive.Binary:
+		return v.Data, true
+	}
+	return nil, false
+}
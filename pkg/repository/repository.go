@@ -1,7 +1,11 @@
 package repository
 
 import (
+	"encoding/base64"
+	"time"
+
 	"github.com/STreeChin/contactapi/pkg/config"
+	"github.com/STreeChin/contactapi/pkg/customfield"
 	"github.com/STreeChin/contactapi/pkg/database"
 	"github.com/STreeChin/contactapi/pkg/entities"
 	"github.com/STreeChin/contactapi/pkg/route/middleware/crpt"
@@ -9,159 +13,577 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 //DBHandler db
 type DBHandler interface {
-	FindOne(db, coll, key string, value interface{}) (bson.M, error)
+	FindOne(db, coll string, filter interface{}) (bson.M, error)
 	InsertOne(db, coll string, value interface{}) error
-	UpdateOne(db, coll string, key string, value interface{}, update interface{}) error
-	DeleteOne(db, coll, key string, value interface{}) error
+	UpdateOne(db, coll string, filter interface{}, update interface{}) error
+	DeleteOne(db, coll string, filter interface{}) error
+	SetFields(db, coll string, filter interface{}, fields interface{}) error
+	Find(db, coll string, filter interface{}, limit int64) ([]bson.M, error)
+	Count(db, coll string, filter interface{}) (int64, error)
+	BulkWrite(db, coll string, models []mongo.WriteModel) ([]database.BulkItemResult, error)
+	FindOneAndUpsert(db, coll string, filter interface{}, update interface{}) (bson.M, error)
+	UpdateMatched(db, coll string, filter interface{}, update interface{}) (bool, error)
+	UpdateMany(db, coll string, filter interface{}, update interface{}) (int64, error)
+	EnsureUniqueIndex(db, coll string, keys ...string) error
+	DropIndex(db, coll string, keys ...string) error
 }
 
+//bidxSuffix the suffix of the blind index of an encrypted field, email -> email_bidx
+const bidxSuffix = "_bidx"
+
 type repository struct {
 	log       *logrus.Logger
 	DbHandler DBHandler
+	//indexKey the key of the blind indexes
+	indexKey []byte
+	//cipher the encryption of the stored email and contact id
+	cipher crpt.Cipher
+	//keyID the id of the current key of the cipher
+	keyID string
+	//policy the fields of the contacts stored encrypted
+	policy *encryptionPolicy
+	//kms wraps the data key of each contact, the fields of the policy are encrypted by it
+	kms crpt.KMS
 }
 
 //NewRepository instance
 func NewRepository(log *logrus.Logger, cfg config.Config) *repository {
+	indexKey, err := base64.StdEncoding.DecodeString(cfg.GetCryptoConfig().IndexKey)
+	if err != nil || len(indexKey) < crpt.BlindIndexKeySize {
+		log.Fatal("the index key must be the base64 of at least 32 bytes: ", err)
+	}
+	keyProvider, err := crpt.NewKeyProvider(cfg.GetCryptoConfig().DataKey)
+	if err != nil {
+		log.Fatal("invalid data key: ", err)
+	}
+	gcm, err := crpt.NewAESGCM(keyProvider)
+	if err != nil {
+		log.Fatal("invalid data key: ", err)
+	}
+	if gcm.CurrentKeyID() == crpt.DataKeyID {
+		log.Fatal("invalid data key: the key id ", crpt.DataKeyID, " is the one of the data keys of the contacts")
+	}
+	policy, err := newEncryptionPolicy(cfg.GetCryptoConfig())
+	if err != nil {
+		log.Fatal("invalid encryption policy: ", err)
+	}
+
 	dbHandler := database.NewDataStore(log, cfg)
 	// debug
 	database.InitMongoDB(dbHandler)
-	return &repository{log, dbHandler}
+	//the values stored before AES-GCM are read by the legacy cipher until they are written again or it is disabled
+	var cipher crpt.Cipher = crpt.Fallback{gcm, crpt.LegacyCBC{}}
+	if cfg.GetCryptoConfig().DisableLegacyCBC {
+		cipher = gcm
+	}
+	rep := &repository{log, dbHandler, indexKey, cipher, gcm.CurrentKeyID(), policy, crpt.LocalKMS{Master: cipher}}
+	//the documents stored before the blind indexes and the accounts must have them before the unique indexes
+	if err := rep.MigrateBlindIndexes(); err != nil {
+		log.Fatal("failed to migrate the blind indexes: ", err)
+	}
+	if err := rep.MigrateAccounts(cfg.GetDBConfig().LegacyAccount); err != nil {
+		log.Fatal("failed to migrate the accounts: ", err)
+	}
+	//one contact, one suppression and one data key per email of each account, the upserts rely on it;
+	//the unique indexes on the email alone, from before the accounts, would keep the accounts from sharing an email
+	for _, index := range []struct{ db, coll string }{{"contact", "contactInfo"}, {"contact", "suppression"}, {keystoreDB, dataKeyColl}} {
+		if err := dbHandler.DropIndex(index.db, index.coll, "email"+bidxSuffix); err != nil {
+			log.Fatal("failed to drop the unique index of ", index.coll, ": ", err)
+		}
+		if err := dbHandler.EnsureUniqueIndex(index.db, index.coll, "account"+bidxSuffix, "email"+bidxSuffix); err != nil {
+			log.Fatal("failed to create the unique index of ", index.coll, ": ", err)
+		}
+	}
+	if err := dbHandler.EnsureUniqueIndex("contact", "apiKey", "keyid"); err != nil {
+		log.Fatal("failed to create the unique index of the api keys: ", err)
+	}
+	if err := dbHandler.EnsureUniqueIndex("contact", usageColl, "account"+bidxSuffix, "day", "keyid"); err != nil {
+		log.Fatal("failed to create the unique index of the usage: ", err)
+	}
+	// debug
+	rep.initContactInfo()
+	return rep
 }
 
-func (r *repository) GetOneContact(key, value string) (*entities.Contact, error) {
+func (r *repository) GetOneContact(account, key, value string) (*entities.Contact, error) {
 	var err error
 	var readDoc bson.M
 	var contact *entities.Contact
 
-	value, err = r.repEncrypt(value)
+	readDoc, err = r.DbHandler.FindOne("contact", "contactInfo", r.contactFilter(account, key, value))
 	if err != nil {
 		return nil, errors.WithMessage(err, "rep getOneContact")
 	}
 
-	readDoc, err = r.DbHandler.FindOne("contact", "contactInfo", key, value)
+	contact, err = r.docToContact(readDoc)
 	if err != nil {
 		return nil, errors.WithMessage(err, "rep getOneContact")
 	}
 
-	contact, err = r.bsonToContact(readDoc)
+	return contact, nil
+}
+
+func (r *repository) GetContacts(account, bookmark string, limit int64) ([]*entities.Contact, string, error) {
+	contacts, next, err := r.findContacts(account, bson.M{}, bookmark, limit)
+	return contacts, next, errors.WithMessage(err, "rep getContacts")
+}
+
+func (r *repository) CountContacts(account string) (int64, error) {
+	count, err := r.DbHandler.Count("contact", "contactInfo", r.accountFilter(account))
+	return count, errors.WithMessage(err, "rep countContacts")
+}
+
+func (r *repository) InsertOneContact(account string, contact *entities.Contact) error {
+	doc, err := r.contactDoc(account, contact)
+	if err == nil {
+		err = r.DbHandler.InsertOne("contact", "contactInfo", doc)
+	}
+
+	return errors.WithMessage(err, "rep insertOneContact")
+}
+
+//UpdateOneContact update the stored contact with the same email, mongo.ErrNoDocuments if there is none: a deleted contact is not created again
+func (r *repository) UpdateOneContact(account string, contact *entities.Contact) error {
+	//the lists are only changed by the membership of the lists
+	doc, err := r.contactDoc(account, contact)
 	if err != nil {
-		return nil, errors.WithMessage(err, "rep getOneContact")
+		return errors.WithMessage(err, "rep updateOneContact")
 	}
+	delete(doc, "lists")
 
-	if contact.ContactID != "" {
-		contact.ContactID, err = r.repDecrypt(contact.ContactID)
+	matched, err := r.DbHandler.UpdateMatched("contact", "contactInfo", r.contactFilter(account, "email", contact.Email), bson.M{"$set": doc})
+	if err == nil && !matched {
+		err = errors.WithStack(mongo.ErrNoDocuments)
+	}
+	return errors.WithMessage(err, "rep updateOneContact")
+}
+
+//UpsertOneContact insert the contact or update the one with the same email atomically, return the stored contact before the update, nil if inserted
+func (r *repository) UpsertOneContact(account string, contact *entities.Contact) (*entities.Contact, error) {
+	update, err := r.upsertUpdate(account, contact)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep upsertOneContact")
+	}
+
+	readDoc, err := r.DbHandler.FindOneAndUpsert("contact", "contactInfo", r.contactFilter(account, "email", contact.Email), update)
+	if err != nil || readDoc == nil {
+		return nil, errors.WithMessage(err, "rep upsertOneContact")
+	}
+
+	stored, err := r.docToContact(readDoc)
+	return stored, errors.WithMessage(err, "rep upsertOneContact")
+}
+
+func (r *repository) UpsertContacts(account string, contacts []*entities.Contact) ([]entities.BulkContactResult, error) {
+	docs := make([]bson.M, 0, len(contacts))
+	for _, contact := range contacts {
+		doc, err := r.plainContactDoc(account, contact)
 		if err != nil {
-			return nil, errors.WithMessage(err, "rep getOneContact")
+			return nil, errors.WithMessage(err, "rep upsertContacts")
 		}
+		docs = append(docs, doc)
 	}
-	if contact.Email != "" {
-		contact.Email, err = r.repDecrypt(contact.Email)
+
+	//the data keys of the contacts are read and created at once
+	ciphers, err := r.createContactCiphers(docs)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep upsertContacts")
 	}
 
-	return contact, errors.WithMessage(err, "rep getOneContact")
+	models := make([]mongo.WriteModel, 0, len(contacts))
+	for i, contact := range contacts {
+		if err = r.encryptDoc(docs[i], r.cipherOf(ciphers, docs[i])); err != nil {
+			return nil, errors.WithMessage(err, "rep upsertContacts")
+		}
+
+		model := mongo.NewUpdateOneModel().
+			SetFilter(r.contactFilter(account, "email", contact.Email)).
+			SetUpdate(upsertOf(docs[i])).
+			SetUpsert(true)
+		models = append(models, model)
+	}
+
+	writeResults, err := r.DbHandler.BulkWrite("contact", "contactInfo", models)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep upsertContacts")
+	}
+
+	results := make([]entities.BulkContactResult, len(contacts))
+	updatedEmails := make([]string, 0, len(contacts))
+	for i, writeResult := range writeResults {
+		results[i].Email = contacts[i].Email
+		switch {
+		case writeResult.Err != nil:
+			results[i].Status = entities.ContactFailed
+			results[i].Error = writeResult.Err.Error()
+		case writeResult.Upserted:
+			results[i].Status = entities.ContactCreated
+			results[i].ContactID = contacts[i].ContactID
+		default:
+			results[i].Status = entities.ContactUpdated
+			updatedEmails = append(updatedEmails, contacts[i].Email)
+		}
+	}
+
+	//the updated contacts keep their own contact id, read them back in one query
+	if len(updatedEmails) > 0 {
+		contactIDs, err := r.getContactIDsByEmails(account, updatedEmails)
+		if err != nil {
+			return nil, errors.WithMessage(err, "rep upsertContacts")
+		}
+		for i := range results {
+			if results[i].Status == entities.ContactUpdated {
+				results[i].ContactID = contactIDs[results[i].Email]
+			}
+		}
+	}
+
+	return results, nil
 }
 
-func (r *repository) InsertOneContact(contact *entities.Contact) error {
-	var err error
-	contact.Email, err = r.repEncrypt(contact.Email)
+func (r *repository) DeleteOneContact(account, key, value string) error {
+	err := r.DbHandler.DeleteOne("contact", "contactInfo", r.contactFilter(account, key, value))
 	if err != nil {
-		return errors.WithMessage(err, "rep insertOneContact")
+		return errors.WithMessage(err, "rep deleteOneContact")
 	}
-	contact.ContactID, err = r.repEncrypt(contact.ContactID)
+
+	return errors.WithMessage(err, "rep deleteOneContact")
+}
+
+func (r *repository) SetUnsubscription(account, email string, unsub *entities.Unsubscription, updateTime time.Time) error {
+	fields := bson.M{"unsubscribed": unsub != nil, "unsubscription": unsub, "updated_at": updateTime}
+	err := r.DbHandler.SetFields("contact", "contactInfo", r.contactFilter(account, "email", email), fields)
+
+	return errors.WithMessage(err, "rep setUnsubscription")
+}
+
+func (r *repository) AddSuppression(account, email string, unsub *entities.Unsubscription) error {
+	encEmail, err := r.repEncrypt(email)
 	if err != nil {
-		return errors.WithMessage(err, "rep insertOneContact")
+		return errors.WithMessage(err, "rep addSuppression")
 	}
 
-	err = r.DbHandler.InsertOne("contact", "contactInfo", contact)
+	filter := r.contactFilter(account, "email", email)
+	doc := bson.M{"email": encEmail, "by": unsub.By, "source": unsub.Source, "time": unsub.Time}
+	err = r.DbHandler.UpdateOne("contact", "suppression", filter, doc)
+
+	return errors.WithMessage(err, "rep addSuppression")
+}
+
+func (r *repository) DelSuppression(account, email string) error {
+	err := r.DbHandler.DeleteOne("contact", "suppression", r.contactFilter(account, "email", email))
+
+	return errors.WithMessage(err, "rep delSuppression")
+}
+
+//GetSuppressions get the suppressed emails among the emails, email->unsubscription
+func (r *repository) GetSuppressions(account string, emails []string) (map[string]*entities.Unsubscription, error) {
+	emailIndexes := make(map[string]string, len(emails))
+	values := make([]string, 0, len(emails))
+	for _, email := range emails {
+		emailIndex := r.blindIndex("email", email)
+		emailIndexes[emailIndex] = email
+		values = append(values, emailIndex)
+	}
+
+	filter := r.accountFilter(account)
+	filter["email"+bidxSuffix] = bson.M{"$in": values}
+	readDocs, err := r.DbHandler.Find("contact", "suppression", filter, 0)
 	if err != nil {
-		return errors.WithMessage(err, "rep insertOneContact")
+		return nil, errors.WithMessage(err, "rep getSuppressions")
+	}
+
+	suppressions := make(map[string]*entities.Unsubscription, len(readDocs))
+	for _, readDoc := range readDocs {
+		emailIndex, _ := readDoc["email"+bidxSuffix].(string)
+		bsonBytes, err := bson.Marshal(readDoc)
+		if err != nil {
+			return nil, errors.Wrap(err, "rep getSuppressions")
+		}
+		unsub := new(entities.Unsubscription)
+		err = bson.Unmarshal(bsonBytes, unsub)
+		if err != nil {
+			return nil, errors.Wrap(err, "rep getSuppressions")
+		}
+		suppressions[emailIndexes[emailIndex]] = unsub
 	}
 
-	contact.Email, err = r.repDecrypt(contact.Email)
+	return suppressions, nil
+}
+
+func (r *repository) bsonToContact(doc bson.M) (*entities.Contact, error) {
+	bsonBytes, err := bson.Marshal(doc)
 	if err != nil {
-		return errors.WithMessage(err, "rep insertOneContact")
+		return nil, errors.Wrap(err, "rep bsonToContact")
 	}
 
-	contact.ContactID, err = r.repDecrypt(contact.ContactID)
-	return errors.WithMessage(err, "rep insertOneContact")
+	contact := new(entities.Contact)
+	err = bson.Unmarshal(bsonBytes, contact)
+
+	return contact, errors.Wrap(err, "rep bsonToContact")
 }
 
-func (r *repository) UpdateOneContact(contact *entities.Contact) error {
-	var err error
-	contact.Email, err = r.repEncrypt(contact.Email)
+func (r *repository) getContactIDsByEmails(account string, emails []string) (map[string]string, error) {
+	emailIndexes := make([]string, 0, len(emails))
+	for _, email := range emails {
+		emailIndexes = append(emailIndexes, r.blindIndex("email", email))
+	}
+
+	filter := r.accountFilter(account)
+	filter["email"+bidxSuffix] = bson.M{"$in": emailIndexes}
+	readDocs, err := r.DbHandler.Find("contact", "contactInfo", filter, 0)
 	if err != nil {
-		return errors.WithMessage(err, "rep updateOneContact")
+		return nil, err
 	}
-	contact.ContactID, err = r.repEncrypt(contact.ContactID)
+
+	contacts, err := r.docsToContacts(readDocs)
 	if err != nil {
-		return errors.WithMessage(err, "rep updateOneContact")
+		return nil, err
+	}
+	contactIDs := make(map[string]string, len(contacts))
+	for _, contact := range contacts {
+		contactIDs[contact.Email] = contact.ContactID
 	}
 
-	err = r.DbHandler.UpdateOne("contact", "contactInfo", "email", contact.Email, contact)
+	return contactIDs, nil
+}
+
+//contactDoc the document of the contact of the account to store: the fields of the encryption policy encrypted by the data key of the contact,
+//the blind indexes of the account and the lookup fields
+func (r *repository) contactDoc(account string, contact *entities.Contact) (bson.M, error) {
+	doc, err := r.plainContactDoc(account, contact)
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.contactCipher(doc, true)
 	if err != nil {
-		return errors.WithMessage(err, "rep UpdateOneContact")
+		return nil, err
+	}
+	if err = r.encryptDoc(doc, c); err != nil {
+		return nil, errors.WithMessage(err, "rep contactDoc")
 	}
 
-	contact.Email, err = r.repDecrypt(contact.Email)
+	return doc, nil
+}
+
+//plainContactDoc the document of the contact of the account with its blind indexes, not encrypted yet
+func (r *repository) plainContactDoc(account string, contact *entities.Contact) (bson.M, error) {
+	if account == "" {
+		return nil, errors.WithStack(entities.ErrNoAccount)
+	}
+	doc, err := r.contactToBson(contact)
 	if err != nil {
-		return errors.WithMessage(err, "rep updateOneContact")
+		return nil, err
 	}
+	doc["account"+bidxSuffix] = r.blindIndex("account", account)
+	r.setContactIndexes(doc, contact.Email, contact.ContactID)
 
-	contact.ContactID, err = r.repDecrypt(contact.ContactID)
-	return errors.WithMessage(err, "rep updateOneContact")
+	return doc, nil
 }
 
-func (r *repository) DeleteOneContact(key, value string) error {
-	value, err := r.repEncrypt(value)
+func (r *repository) contactToBson(contact *entities.Contact) (bson.M, error) {
+	bsonBytes, err := bson.Marshal(contact)
 	if err != nil {
-		return errors.WithMessage(err, "rep deleteOneContact")
+		return nil, errors.Wrap(err, "rep contactToBson")
 	}
 
-	err = r.DbHandler.DeleteOne("contact", "contactInfo", key, value)
+	doc := bson.M{}
+	err = bson.Unmarshal(bsonBytes, &doc)
+
+	return doc, errors.Wrap(err, "rep contactToBson")
+}
+
+//upsertUpdate the update of the upsert of the contact of the account
+func (r *repository) upsertUpdate(account string, contact *entities.Contact) (bson.M, error) {
+	doc, err := r.contactDoc(account, contact)
 	if err != nil {
-		return errors.WithMessage(err, "rep deleteOneContact")
+		return nil, err
 	}
 
-	return errors.WithMessage(err, "rep deleteOneContact")
+	return upsertOf(doc), nil
 }
 
-func (r *repository) GetContactIDByAPIKey(apiKey string) (string, error) {
-	query, err := crpt.AesEncrypt([]byte(apiKey))
+//upsertOf the update of the upsert of the encrypted document of a contact,
+//the contact id, the type, the owner and the creation time of an existing contact never change,
+//the lists are only changed by the membership of the lists
+func upsertOf(doc bson.M) bson.M {
+	onInsert := bson.M{
+		"contactid":              doc["contactid"],
+		"contactid" + bidxSuffix: doc["contactid"+bidxSuffix],
+		"type":                   doc["type"],
+		"ownername":              doc["ownername"],
+		"created_at":             doc["created_at"],
+		"lists":                  bson.A{},
+	}
+	for field := range onInsert {
+		delete(doc, field)
+	}
+
+	return bson.M{"$set": doc, "$setOnInsert": onInsert}
+}
+
+func (r *repository) docToContact(doc bson.M) (*entities.Contact, error) {
+	c, err := r.contactCipher(doc, false)
 	if err != nil {
-		return "", errors.WithMessage(err, "rep getContactId encrypt")
+		return nil, err
 	}
+	return r.decryptContact(doc, c)
+}
 
-	readDoc, err := r.DbHandler.FindOne("contact", "apiKey", "apikey", query)
+//docsToContacts the contacts of the documents, their data keys are read at once
+func (r *repository) docsToContacts(docs []bson.M) ([]*entities.Contact, error) {
+	ciphers, err := r.contactCiphers(docs)
 	if err != nil {
-		return "", errors.WithMessage(err, "rep getContactId find")
+		return nil, err
 	}
 
-	contactID, err := crpt.AesDecrypt(readDoc["contactid"].(primitive.Binary).Data)
-	return string(contactID), errors.WithMessage(err, "rep getContactId decrypt")
+	contacts := make([]*entities.Contact, 0, len(docs))
+	for _, doc := range docs {
+		contact, err := r.decryptContact(doc, r.cipherOf(ciphers, doc))
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, nil
 }
 
-func (r *repository) bsonToContact(doc bson.M) (*entities.Contact, error) {
-	bsonBytes, err := bson.Marshal(doc)
+func (r *repository) decryptContact(doc bson.M, c crpt.Cipher) (*entities.Contact, error) {
+	if err := r.decryptDoc(doc, c); err != nil {
+		return nil, errors.WithMessage(err, "rep decryptContact")
+	}
+	contact, err := r.bsonToContact(doc)
 	if err != nil {
-		return nil, errors.Wrap(err, "rep bsonToContact")
+		return nil, err
 	}
 
-	contact := new(entities.Contact)
-	err = bson.Unmarshal(bsonBytes, contact)
+	for name, value := range contact.Custom {
+		contact.Custom[name] = customValue(value)
+		//back to the go type of the field type, like the currency stored as a document
+		if fieldType, ok := contact.CustomTypes[name]; ok {
+			if decoded, err := customfield.DecodeValue(fieldType, contact.Custom[name]); err == nil {
+				contact.Custom[name] = decoded
+			}
+		}
+	}
 
-	return contact, errors.Wrap(err, "rep bsonToContact")
+	return contact, nil
+}
+
+//customValue the custom value in the go type the controller parsed it to, not the one the bson decoder picked
+func customValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case primitive.DateTime:
+		return time.Unix(0, int64(v)*int64(time.Millisecond)).UTC()
+	case primitive.A:
+		values := make([]interface{}, len(v))
+		for i := range v {
+			values[i] = customValue(v[i])
+		}
+		return values
+	case primitive.D:
+		return customValue(v.Map())
+	case primitive.M:
+		values := make(map[string]interface{}, len(v))
+		for name := range v {
+			values[name] = customValue(v[name])
+		}
+		return values
+	default:
+		return value
+	}
+}
+
+//findContacts one page of the contacts of the account match the filter after the bookmark, and the bookmark of the next page
+func (r *repository) findContacts(account string, query bson.M, bookmark string, limit int64) ([]*entities.Contact, string, error) {
+	filter := bson.M{"$and": []interface{}{r.accountFilter(account), query}}
+	if bookmark != "" {
+		lastID, err := r.decodeBookmark(bookmark)
+		if err != nil {
+			return nil, "", err
+		}
+		filter = bson.M{"$and": []interface{}{r.accountFilter(account), query, bson.M{"_id": bson.M{"$gt": lastID}}}}
+	}
+
+	//read one more document to know whether there is a next page
+	readDocs, err := r.DbHandler.Find("contact", "contactInfo", filter, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if int64(len(readDocs)) > limit {
+		readDocs = readDocs[:limit]
+		lastID, ok := readDocs[limit-1]["_id"].(primitive.ObjectID)
+		if !ok {
+			return nil, "", errors.New("_id is not an ObjectID")
+		}
+		next = r.encodeBookmark(lastID)
+	}
+
+	contacts, err := r.docsToContacts(readDocs)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return contacts, next, nil
+}
+
+//encodeBookmark the bookmark is the opaque form of the last _id of the page
+func (r *repository) encodeBookmark(lastID primitive.ObjectID) string {
+	return base64.RawURLEncoding.EncodeToString(lastID[:])
+}
+
+func (r *repository) decodeBookmark(bookmark string) (primitive.ObjectID, error) {
+	var lastID primitive.ObjectID
+	raw, err := base64.RawURLEncoding.DecodeString(bookmark)
+	if err != nil || len(raw) != len(lastID) {
+		return lastID, errors.WithStack(entities.ErrInvalidBookmark)
+	}
+	copy(lastID[:], raw)
+
+	return lastID, nil
+}
+
+//blindIndex the blind index of the value of the encrypted field, the queries match on it instead of the ciphertext
+func (r *repository) blindIndex(field, value string) string {
+	return crpt.BlindIndex(r.indexKey, field, value)
+}
+
+//accountFilter the filter of the documents of the account, every query of the contacts starts from it
+func (r *repository) accountFilter(account string) bson.M {
+	return bson.M{"account" + bidxSuffix: r.blindIndex("account", account)}
+}
+
+//contactFilter the filter of the contact of the account by the lookup field, email or contactid
+func (r *repository) contactFilter(account, key, value string) bson.M {
+	filter := r.accountFilter(account)
+	filter[key+bidxSuffix] = r.blindIndex(key, value)
+	return filter
+}
+
+//setContactIndexes set the blind indexes of the email and the contact id to the contact document
+func (r *repository) setContactIndexes(doc bson.M, email, contactID string) {
+	doc["email"+bidxSuffix] = r.blindIndex("email", email)
+	doc["contactid"+bidxSuffix] = r.blindIndex("contactid", contactID)
 }
 
 func (r *repository) repEncrypt(key string) (string, error) {
-	encryptKey, err := crpt.AesEncrypt([]byte(key))
+	encryptKey, err := r.cipher.Encrypt([]byte(key))
 	return string(encryptKey), errors.WithMessage(err, "rep repEncrypt")
 }
 
 func (r *repository) repDecrypt(key string) (string, error) {
-	decryptKey, err := crpt.AesDecrypt([]byte(key))
+	decryptKey, err := r.cipher.Decrypt([]byte(key))
 	return string(decryptKey), errors.WithMessage(err, "rep repDecrypt")
 }
@@ -43,6 +43,9 @@ var updateContact = entities.Contact{
 }
 var dbc = config.DatabaseConfig{URL: "mongodb://localhost:27017"}
 
+//account the account of the api key of mongo-init.js, otherAccount another tenant
+const account, otherAccount = "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23", "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc45555"
+
 /*var readDoc = bson.M{
 	"contactid": "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc40000",
 	"email":     "StGr@gmail.com",
@@ -63,11 +66,11 @@ func TestGetOneContact(t *testing.T) {
 		rep := repository.NewRepository(logger, mockCfg)
 
 		key, value := "email", getContact.Email
-		_ = rep.InsertOneContact(&getContact)
+		_ = rep.InsertOneContact(account, &getContact)
 
 		Convey("UT Normal Case", func() {
 			Convey("UT Normal Case1: Get from cache by email", func() {
-				cont, err := rep.GetOneContact(key, value)
+				cont, err := rep.GetOneContact(account, key, value)
 				expected := &getContact
 				So(err, ShouldEqual, nil)
 				So(cont.ContactID, ShouldEqual, expected.ContactID)
@@ -77,7 +80,7 @@ func TestGetOneContact(t *testing.T) {
 
 		Convey("UT AbNormal Case", func() {
 			Convey("UT AbNormal Case1: mongo fail", func() {
-				cont, err := rep.GetOneContact(key, "abnormal.com")
+				cont, err := rep.GetOneContact(account, key, "abnormal.com")
 				So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
 				So(cont, ShouldEqual, nil)
 			})
@@ -89,7 +92,7 @@ func TestGetOneContact(t *testing.T) {
 					return nil, errResult
 				})
 
-				cont, err := rep.GetOneContact(key, value)
+				cont, err := rep.GetOneContact(account, key, value)
 				So(errors.Cause(err), ShouldEqual, errResult)
 				So(cont, ShouldEqual, nil)
 			})
@@ -101,7 +104,7 @@ func TestGetOneContact(t *testing.T) {
 					return nil, errResult
 				})
 
-				cont, err := rep.GetOneContact(key, value)
+				cont, err := rep.GetOneContact(account, key, value)
 				So(errors.Cause(err), ShouldEqual, errResult)
 				So(cont, ShouldEqual, nil)
 			})
@@ -113,7 +116,7 @@ func TestGetOneContact(t *testing.T) {
 							return nil, errMarshal
 						})
 
-						cont, errM := rep.GetOneContact(key, value)
+						cont, errM := rep.GetOneContact(account, key, value)
 						So(errors.Cause(errM), ShouldEqual, errMarshal)
 						So(cont, ShouldEqual, nil)
 					})*/
@@ -137,16 +140,16 @@ func TestInsertOneContact(t *testing.T) {
 
 		Convey("UT Normal Case", func() {
 			Convey("UT Normal Case1: insert one", func() {
-				err := rep.DeleteOneContact(key, value)
+				err := rep.DeleteOneContact(account, key, value)
 				if err != nil {
 					panic(err)
 				}
 
-				err = rep.InsertOneContact(&insertThenDelContact)
+				err = rep.InsertOneContact(account, &insertThenDelContact)
 				So(err, ShouldEqual, nil)
 
 				expected := &insertThenDelContact
-				result, err := rep.GetOneContact(key, value)
+				result, err := rep.GetOneContact(account, key, value)
 				if err != nil {
 					panic(err)
 				}
@@ -158,7 +161,7 @@ func TestInsertOneContact(t *testing.T) {
 
 		Convey("UT AbNormal Case", func() {
 			Convey("UT AbNormal Case1: mongo fail: dup email", func() {
-				err := rep.InsertOneContact(&insertThenDelContact)
+				err := rep.InsertOneContact(account, &insertThenDelContact)
 				fmt.Println(err)
 				So(errors.Cause(err), ShouldNotEqual, nil)
 			})
@@ -170,12 +173,12 @@ func TestInsertOneContact(t *testing.T) {
 					return nil, errResult
 				})
 
-				err := rep.InsertOneContact(&insertThenDelContact)
+				err := rep.InsertOneContact(account, &insertThenDelContact)
 				So(errors.Cause(err), ShouldEqual, errResult)
 			})
 
 			Convey("UT AbNormal Case3: AesDecrypt fail", func() {
-				err := rep.DeleteOneContact(key, value)
+				err := rep.DeleteOneContact(account, key, value)
 				if err != nil {
 					panic(err)
 				}
@@ -186,7 +189,7 @@ func TestInsertOneContact(t *testing.T) {
 					return nil, errResult
 				})
 
-				err = rep.InsertOneContact(&insertThenDelContact)
+				err = rep.InsertOneContact(account, &insertThenDelContact)
 				So(errors.Cause(err), ShouldEqual, errResult)
 			})
 
@@ -207,15 +210,15 @@ func TestUpdateOneContact(t *testing.T) {
 		rep := repository.NewRepository(logger, mockCfg)
 
 		key, value := "email", updateContact.Email
-		_ = rep.InsertOneContact(&insertThenDelContact)
+		_ = rep.InsertOneContact(account, &insertThenDelContact)
 
 		Convey("UT Normal Case", func() {
 			Convey("UT Normal Case1: update one", func() {
-				err := rep.UpdateOneContact(&updateContact)
+				err := rep.UpdateOneContact(account, &updateContact)
 				So(err, ShouldEqual, nil)
 
 				expected := &updateContact
-				result, err := rep.GetOneContact(key, value)
+				result, err := rep.GetOneContact(account, key, value)
 				if err != nil {
 					panic(err)
 				}
@@ -233,12 +236,12 @@ func TestUpdateOneContact(t *testing.T) {
 					return nil, errResult
 				})
 
-				err := rep.UpdateOneContact(&updateContact)
+				err := rep.UpdateOneContact(account, &updateContact)
 				So(errors.Cause(err), ShouldEqual, errResult)
 			})
 
 			Convey("UT AbNormal Case2: AesDecrypt fail", func() {
-				err := rep.DeleteOneContact(key, value)
+				err := rep.DeleteOneContact(account, key, value)
 				if err != nil {
 					panic(err)
 				}
@@ -249,7 +252,7 @@ func TestUpdateOneContact(t *testing.T) {
 					return nil, errResult
 				})
 
-				err = rep.UpdateOneContact(&updateContact)
+				err = rep.UpdateOneContact(account, &updateContact)
 				So(errors.Cause(err), ShouldEqual, errResult)
 			})
 
@@ -270,13 +273,13 @@ func TestDeleteOneContact(t *testing.T) {
 		rep := repository.NewRepository(logger, mockCfg)
 
 		key, value := "email", insertThenDelContact.Email
-		_ = rep.InsertOneContact(&insertThenDelContact)
+		_ = rep.InsertOneContact(account, &insertThenDelContact)
 
 		Convey("UT Normal Case", func() {
 			Convey("UT Normal Case1: delete one", func() {
-				err := rep.DeleteOneContact(key, value)
+				err := rep.DeleteOneContact(account, key, value)
 				So(err, ShouldEqual, nil)
-				result, err := rep.GetOneContact(key, value)
+				result, err := rep.GetOneContact(account, key, value)
 				So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
 				So(result, ShouldEqual, nil)
 			})
@@ -284,26 +287,86 @@ func TestDeleteOneContact(t *testing.T) {
 
 		Convey("UT AbNormal Case", func() {
 			Convey("UT AbNormal Case1: mongo fail: dup email", func() {
-				err := rep.DeleteOneContact(key, "norecord@g.com")
+				err := rep.DeleteOneContact(account, key, "norecord@g.com")
 				fmt.Println(err)
 				So(errors.Cause(err), ShouldEqual, nil)
 			})
 
 			Convey("UT AbNormal Case2: AesEncrypt fail", func() {
-				_ = rep.InsertOneContact(&insertThenDelContact)
+				_ = rep.InsertOneContact(account, &insertThenDelContact)
 				errResult := errors.New("AesEncrypt fail")
 				defer monkey.UnpatchAll()
 				monkey.Patch(crpt.AesEncrypt, func([]byte) ([]byte, error) {
 					return nil, errResult
 				})
 
-				err := rep.DeleteOneContact(key, value)
+				err := rep.DeleteOneContact(account, key, value)
 				So(errors.Cause(err), ShouldEqual, errResult)
 			})
 		})
 	})
 }
 
+//TestTenantIsolation the contacts of an account can not be read, updated or deleted by another one, the accounts can share an email
+func TestTenantIsolation(t *testing.T) {
+	Convey("TestTenantIsolation", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		gomock.InOrder(
+			mockCfg.EXPECT().GetDBConfig().Return(&dbc).AnyTimes(),
+		)
+		rep := repository.NewRepository(logger, mockCfg)
+
+		key, value := "email", insertThenDelContact.Email
+		_ = rep.DeleteOneContact(otherAccount, key, value)
+		_ = rep.InsertOneContact(account, &insertThenDelContact)
+
+		Convey("UT Normal Case1: the other account can not read it, by email or by contact id", func() {
+			result, err := rep.GetOneContact(otherAccount, key, value)
+			So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
+			So(result, ShouldEqual, nil)
+
+			result, err = rep.GetOneContact(otherAccount, "contactid", insertThenDelContact.ContactID)
+			So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
+			So(result, ShouldEqual, nil)
+
+			contacts, _, err := rep.GetContacts(otherAccount, "", 100)
+			So(err, ShouldEqual, nil)
+			for _, contact := range contacts {
+				So(contact.ContactID, ShouldNotEqual, insertThenDelContact.ContactID)
+			}
+		})
+
+		Convey("UT Normal Case2: the update of the other account writes its own contact", func() {
+			err := rep.UpdateOneContact(otherAccount, &updateContact)
+			So(err, ShouldEqual, nil)
+
+			result, err := rep.GetOneContact(account, key, value)
+			So(err, ShouldEqual, nil)
+			So(result.FirstName, ShouldEqual, insertThenDelContact.FirstName)
+			result, err = rep.GetOneContact(otherAccount, key, value)
+			So(err, ShouldEqual, nil)
+			So(result.FirstName, ShouldEqual, updateContact.FirstName)
+		})
+
+		Convey("UT Normal Case3: the delete of the other account leaves it", func() {
+			err := rep.DeleteOneContact(otherAccount, key, value)
+			So(err, ShouldEqual, nil)
+
+			result, err := rep.GetOneContact(account, key, value)
+			So(err, ShouldEqual, nil)
+			So(result.ContactID, ShouldEqual, insertThenDelContact.ContactID)
+		})
+
+		Convey("UT AbNormal Case1: no contact is written without an account", func() {
+			err := rep.InsertOneContact("", &insertThenDelContact)
+			So(errors.Cause(err), ShouldEqual, entities.ErrNoAccount)
+		})
+	})
+}
+
 //TestGetOneContact
 func TestGetContactIdByApiKey(t *testing.T) {
 	Convey("TestGetContactIdByApiKey", t, func() {
@@ -319,17 +382,18 @@ func TestGetContactIdByApiKey(t *testing.T) {
 
 		Convey("UT Normal Case", func() {
 			Convey("UT Normal Case1: Get from cache by email", func() {
-				result, err := rep.GetContactIDByAPIKey(apiKey)
+				result, err := rep.GetPrincipalByAPIKey(apiKey)
 				So(err, ShouldEqual, nil)
-				So(result, ShouldEqual, contactID)
+				So(result.Account, ShouldEqual, contactID)
+				So(result.Scopes, ShouldResemble, []string{entities.ScopeAdmin})
 			})
 		})
 
 		Convey("UT AbNormal Case", func() {
-			Convey("UT AbNormal Case1: mongo fail", func() {
-				result, err := rep.GetContactIDByAPIKey("invalidKey")
-				So(errors.Cause(err), ShouldEqual, mongo.ErrNoDocuments)
-				So(result, ShouldEqual, "")
+			Convey("UT AbNormal Case1: unknown key", func() {
+				result, err := rep.GetPrincipalByAPIKey("invalidKey")
+				So(errors.Cause(err), ShouldEqual, entities.ErrInvalidAPIKey)
+				So(result, ShouldBeNil)
 			})
 
 			Convey("UT AbNormal Case2: AesEncrypt fail", func() {
@@ -339,9 +403,9 @@ func TestGetContactIdByApiKey(t *testing.T) {
 					return nil, errResult
 				})
 
-				result, err := rep.GetContactIDByAPIKey(apiKey)
+				result, err := rep.GetPrincipalByAPIKey(apiKey)
 				So(errors.Cause(err), ShouldEqual, errResult)
-				So(result, ShouldEqual, "")
+				So(result, ShouldBeNil)
 			})
 
 			Convey("UT AbNormal Case3: AesDecrypt fail", func() {
@@ -351,9 +415,9 @@ func TestGetContactIdByApiKey(t *testing.T) {
 					return nil, errResult
 				})
 
-				result, err := rep.GetContactIDByAPIKey(apiKey)
+				result, err := rep.GetPrincipalByAPIKey(apiKey)
 				So(errors.Cause(err), ShouldEqual, errResult)
-				So(result, ShouldEqual, "")
+				So(result, ShouldBeNil)
 			})
 		})
 	})
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/segment"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//segmentScanBatch the contacts read at once when the segment has to be matched in the process
+const segmentScanBatch = 500
+
+//segmentDoc one segment in the db
+type segmentDoc struct {
+	SegmentID  string    `bson:"segmentid"`
+	Account    string    `bson:"account_bidx"`
+	Name       string    `bson:"name"`
+	Expression string    `bson:"expression"`
+	CreatTime  time.Time `bson:"created_at"`
+}
+
+func (r *repository) InsertSegment(account string, seg *entities.Segment) error {
+	doc := &segmentDoc{SegmentID: seg.SegmentID, Account: r.blindIndex("account", account), Name: seg.Name, Expression: seg.Expression, CreatTime: seg.CreatTime}
+	err := r.DbHandler.InsertOne("contact", "segment", doc)
+
+	return errors.WithMessage(err, "rep insertSegment")
+}
+
+//GetSegments the segments of the account, in the order they were created
+func (r *repository) GetSegments(account string) ([]*entities.Segment, error) {
+	readDocs, err := r.DbHandler.Find("contact", "segment", bson.M{"account" + bidxSuffix: r.blindIndex("account", account)}, 0)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep getSegments")
+	}
+
+	segments := make([]*entities.Segment, 0, len(readDocs))
+	for _, readDoc := range readDocs {
+		seg, err := r.docToSegment(readDoc)
+		if err != nil {
+			return nil, errors.WithMessage(err, "rep getSegments")
+		}
+		segments = append(segments, seg)
+	}
+
+	return segments, nil
+}
+
+//GetSegment one segment of the account, entities.ErrSegmentNotFound if the account has no such segment
+func (r *repository) GetSegment(account, segmentID string) (*entities.Segment, error) {
+	filter := bson.M{"segmentid": segmentID, "account" + bidxSuffix: r.blindIndex("account", account)}
+	readDocs, err := r.DbHandler.Find("contact", "segment", filter, 1)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep getSegment")
+	}
+	if len(readDocs) == 0 {
+		return nil, errors.WithStack(entities.ErrSegmentNotFound)
+	}
+
+	seg, err := r.docToSegment(readDocs[0])
+	return seg, errors.WithMessage(err, "rep getSegment")
+}
+
+//GetSegmentContacts one page of the members of the segment in the account after the bookmark, and the bookmark of the next page
+func (r *repository) GetSegmentContacts(account string, filter *segment.Filter, bookmark string, limit int64) ([]*entities.Contact, string, error) {
+	if filter.Exact() {
+		contacts, next, err := r.findContacts(account, filter.Query(), bookmark, limit)
+		return contacts, next, errors.WithMessage(err, "rep getSegmentContacts")
+	}
+
+	//the bookmark is the last member of the page, the page is full and there is a next one if one more member is found
+	contacts := make([]*entities.Contact, 0, limit)
+	next := ""
+	var lastID primitive.ObjectID
+	err := r.scanContacts(account, filter.Query(), bookmark, func(id primitive.ObjectID, contact *entities.Contact) bool {
+		if !filter.Match(contact) {
+			return true
+		}
+		if int64(len(contacts)) == limit {
+			next = r.encodeBookmark(lastID)
+			return false
+		}
+		contacts = append(contacts, contact)
+		lastID = id
+		return true
+	})
+	if err != nil {
+		return nil, "", errors.WithMessage(err, "rep getSegmentContacts")
+	}
+
+	return contacts, next, nil
+}
+
+//CountSegmentContacts count the members of the segment in the account
+func (r *repository) CountSegmentContacts(account string, filter *segment.Filter) (int64, error) {
+	if filter.Exact() {
+		query := bson.M{"$and": []interface{}{r.accountFilter(account), filter.Query()}}
+		count, err := r.DbHandler.Count("contact", "contactInfo", query)
+		return count, errors.WithMessage(err, "rep countSegmentContacts")
+	}
+
+	var count int64
+	err := r.scanContacts(account, filter.Query(), "", func(_ primitive.ObjectID, contact *entities.Contact) bool {
+		if filter.Match(contact) {
+			count++
+		}
+		return true
+	})
+
+	return count, errors.WithMessage(err, "rep countSegmentContacts")
+}
+
+//scanContacts read the contacts of the account match the query after the bookmark in batches, until each returns false
+func (r *repository) scanContacts(account string, query bson.M, bookmark string, each func(primitive.ObjectID, *entities.Contact) bool) error {
+	var lastID *primitive.ObjectID
+	if bookmark != "" {
+		id, err := r.decodeBookmark(bookmark)
+		if err != nil {
+			return err
+		}
+		lastID = &id
+	}
+
+	for {
+		filter := bson.M{"$and": []interface{}{r.accountFilter(account), query}}
+		if lastID != nil {
+			filter = bson.M{"$and": []interface{}{r.accountFilter(account), query, bson.M{"_id": bson.M{"$gt": *lastID}}}}
+		}
+		readDocs, err := r.DbHandler.Find("contact", "contactInfo", filter, segmentScanBatch)
+		if err != nil {
+			return err
+		}
+
+		contacts, err := r.docsToContacts(readDocs)
+		if err != nil {
+			return err
+		}
+		for i, readDoc := range readDocs {
+			id, ok := readDoc["_id"].(primitive.ObjectID)
+			if !ok {
+				return errors.New("_id is not an ObjectID")
+			}
+			if !each(id, contacts[i]) {
+				return nil
+			}
+			lastID = &id
+		}
+
+		if len(readDocs) < segmentScanBatch {
+			return nil
+		}
+	}
+}
+
+func (r *repository) docToSegment(doc bson.M) (*entities.Segment, error) {
+	bsonBytes, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "rep docToSegment")
+	}
+
+	segDoc := new(segmentDoc)
+	err = bson.Unmarshal(bsonBytes, segDoc)
+	if err != nil {
+		return nil, errors.Wrap(err, "rep docToSegment")
+	}
+
+	return &entities.Segment{SegmentID: segDoc.SegmentID, Name: segDoc.Name, Expression: segDoc.Expression, CreatTime: segDoc.CreatTime}, nil
+}
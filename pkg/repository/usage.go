@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"sort"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//usageColl the usage of each api key by day, flushed from the counters in redis
+const usageColl = "usage"
+
+//usageDoc the usage of an api key in a day in the db, by the blind index of its account
+type usageDoc struct {
+	Account  string `bson:"account_bidx"`
+	KeyID    string `bson:"keyid"`
+	Day      string `bson:"day"`
+	Requests int64  `bson:"requests"`
+	Writes   int64  `bson:"writes"`
+	BulkRows int64  `bson:"bulk_rows"`
+	Contacts int64  `bson:"contacts"`
+}
+
+//UpsertUsages write the counters of the usages, a counter never goes down: the counters in redis restart from zero if redis loses them
+func (r *repository) UpsertUsages(usages []*entities.Usage) error {
+	if len(usages) == 0 {
+		return nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(usages))
+	for _, usage := range usages {
+		filter := bson.M{"account" + bidxSuffix: r.blindIndex("account", usage.Account), "keyid": usage.KeyID, "day": usage.Day}
+		update := bson.M{"$max": bson.M{
+			"requests":  usage.Requests,
+			"writes":    usage.Writes,
+			"bulk_rows": usage.BulkRows,
+			"contacts":  usage.Contacts,
+		}}
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true))
+	}
+
+	writeResults, err := r.DbHandler.BulkWrite("contact", usageColl, models)
+	if err != nil {
+		return errors.WithMessage(err, "rep upsertUsages")
+	}
+	for _, writeResult := range writeResults {
+		if writeResult.Err != nil {
+			return errors.WithMessage(writeResult.Err, "rep upsertUsages")
+		}
+	}
+	return nil
+}
+
+//GetUsages the usages of the api keys of the account from the day to the day, of one key if keyID is not empty, by day then key id
+func (r *repository) GetUsages(account, keyID, from, to string) ([]*entities.Usage, error) {
+	filter := bson.M{"account" + bidxSuffix: r.blindIndex("account", account), "day": bson.M{"$gte": from, "$lte": to}}
+	if keyID != "" {
+		filter["keyid"] = keyID
+	}
+	readDocs, err := r.DbHandler.Find("contact", usageColl, filter, 0)
+	if err != nil {
+		return nil, errors.WithMessage(err, "rep getUsages")
+	}
+
+	usages := make([]*entities.Usage, 0, len(readDocs))
+	for _, readDoc := range readDocs {
+		bsonBytes, err := bson.Marshal(readDoc)
+		if err != nil {
+			return nil, errors.Wrap(err, "rep getUsages")
+		}
+		doc := new(usageDoc)
+		if err = bson.Unmarshal(bsonBytes, doc); err != nil {
+			return nil, errors.Wrap(err, "rep getUsages")
+		}
+		usages = append(usages, &entities.Usage{
+			KeyID:    doc.KeyID,
+			Day:      doc.Day,
+			Account:  account,
+			Requests: doc.Requests,
+			Writes:   doc.Writes,
+			BulkRows: doc.BulkRows,
+			Contacts: doc.Contacts,
+		})
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Day != usages[j].Day {
+			return usages[i].Day < usages[j].Day
+		}
+		return usages[i].KeyID < usages[j].KeyID
+	})
+
+	return usages, nil
+}
@@ -1,32 +1,68 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/STreeChin/contactapi/internal/service"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/route/middleware/crpt"
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
+type contextKey string
+
+//principalKey the key of the principal in the context of the authenticated request
+const principalKey contextKey = "principal"
+
+//the principals of the api keys are cached in the process in front of redis, the invalid keys for less
+const (
+	principalTTL     = 30 * time.Second
+	invalidKeyTTL    = 5 * time.Second
+	principalLRUSize = 10000
+)
+
 //Auth interface
 type Auth interface {
 	Middleware(next http.Handler) http.Handler
+	WatchRevocations()
+}
+
+//PrincipalCache the principals of the api keys shared by the instances, by the digests of the keys
+type PrincipalCache interface {
+	GetPrincipal(digest string) (*entities.Principal, error)
+	SetPrincipal(digest string, principal *entities.Principal, ttl time.Duration) error
+	SubscribePrincipals(onRevoke func(keyID string))
 }
 
 type auth struct {
-	log *logrus.Logger
-	rep service.Repository
+	log        *logrus.Logger
+	rep        service.Repository
+	principals PrincipalCache
+	local      *principalLRU
 }
 
 //NewAuth new auth
-func NewAuth(log *logrus.Logger, rep service.Repository) *auth {
-	return &auth{log, rep}
+func NewAuth(log *logrus.Logger, rep service.Repository, principals PrincipalCache) *auth {
+	return &auth{log, rep, principals, newPrincipalLRU(principalLRUSize)}
+}
+
+//WatchRevocations drop the principals of the api keys revoked by any instance from the process, it never returns
+func (a *auth) WatchRevocations() {
+	a.principals.SubscribePrincipals(func(keyID string) {
+		now := time.Now()
+		a.local.revoke(keyID, now.Add(principalTTL), now)
+	})
 }
 
 func (a *auth) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		//Endpoints that don't require authentication
-		needAuthPaths := []string{"/v1/user/login"}
+		needAuthPaths := []string{"/v1/user/login", "/health"}
 		for _, value := range needAuthPaths {
 			if value == r.URL.Path {
 				next.ServeHTTP(w, r)
@@ -44,7 +80,7 @@ func (a *auth) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
-		_, err := a.rep.GetContactIDByAPIKey(apiKey)
+		principal, err := a.principal(apiKey)
 		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
 			err = json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized", "message": "Provided autopilotapikey not valid."})
@@ -54,8 +90,100 @@ func (a *auth) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
-		//ctx := context.WithValue(r.Context(), "contactid", contactId)
-		//r = r.WithContext(ctx)
+		r = r.WithContext(WithPrincipal(r.Context(), principal))
+		next.ServeHTTP(w, r)
+	})
+}
+
+//principal the principal of the api key: from the process, else from redis, else from the repository, caching it on the way back
+func (a *auth) principal(apiKey string) (*entities.Principal, error) {
+	now := time.Now()
+	digest := crpt.APIKeyDigest(apiKey)
+	if principal, ok := a.local.get(digest, now); ok {
+		if principal == nil {
+			return nil, errors.WithStack(entities.ErrInvalidAPIKey)
+		}
+		return principal, nil
+	}
+
+	principal, err := a.principals.GetPrincipal(digest)
+	switch errors.Cause(err) {
+	case nil:
+		a.local.add(digest, principal, now.Add(principalTTLOf(principal, now)), now)
+		return principal, nil
+	case entities.ErrInvalidAPIKey:
+		a.local.add(digest, nil, now.Add(invalidKeyTTL), now)
+		return nil, err
+	case redis.ErrNil:
+	default:
+		//the repository still authenticates without redis
+		warnCache(a.log, "auth get principal", err)
+	}
+
+	principal, err = a.rep.GetPrincipalByAPIKey(apiKey)
+	if err != nil {
+		if errors.Cause(err) == entities.ErrInvalidAPIKey {
+			a.cachePrincipal(digest, nil, invalidKeyTTL, now)
+		}
+		return nil, err
+	}
+	a.cachePrincipal(digest, principal, principalTTLOf(principal, now), now)
+	return principal, nil
+}
+
+func (a *auth) cachePrincipal(digest string, principal *entities.Principal, ttl time.Duration, now time.Time) {
+	if ttl <= 0 {
+		return
+	}
+	if err := a.principals.SetPrincipal(digest, principal, ttl); err != nil {
+		warnCache(a.log, "auth set principal", err)
+	}
+	a.local.add(digest, principal, now.Add(ttl), now)
+}
+
+//principalTTLOf the principal is cached no longer than its key is valid
+func principalTTLOf(principal *entities.Principal, now time.Time) time.Duration {
+	if ttl := principal.ExpiresAt.Sub(now); !principal.ExpiresAt.IsZero() && ttl < principalTTL {
+		return ttl
+	}
+	return principalTTL
+}
+
+//RequireScope the request goes on to next only if its api key has the scope, 403 if it has not;
+//an empty scope needs none, the requests the middleware does not authenticate have no scopes
+func RequireScope(next http.Handler, scope string) http.Handler {
+	if scope == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := PrincipalFrom(r.Context())
+		if principal == nil || !principal.HasScope(scope) {
+			w.WriteHeader(http.StatusForbidden)
+			err := json.NewEncoder(w).Encode(map[string]string{"error": "Forbidden", "message": "Provided autopilotapikey lacks the scope " + scope + "."})
+			if err != nil {
+				logrus.Error("RequireScope: json encode error")
+			}
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+//PrincipalFrom the principal of the api key of the request, nil if the request is not authenticated
+func PrincipalFrom(ctx context.Context) *entities.Principal {
+	principal, _ := ctx.Value(principalKey).(*entities.Principal)
+	return principal
+}
+
+//WithPrincipal the context of a request authenticated as the principal
+func WithPrincipal(ctx context.Context, principal *entities.Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+//AccountID the account owns the api key of the request, empty if the request is not authenticated
+func AccountID(ctx context.Context) string {
+	if principal := PrincipalFrom(ctx); principal != nil {
+		return principal.Account
+	}
+	return ""
+}
@@ -0,0 +1,271 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/STreeChin/contactapi/internal/service"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/pkg/route/middleware"
+	"github.com/STreeChin/contactapi/pkg/route/middleware/crpt"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestAuth the principal of the api key is in the context, the scope of the route is checked against it
+func TestAuth(t *testing.T) {
+	Convey("TestAuth", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockRep := mocks.NewMockRepository(ctl)
+		mockPrincipals := mocks.NewMockPrincipalCache(ctl)
+		mockPrincipals.EXPECT().GetPrincipal(gomock.Any()).Return(nil, redis.ErrNil).AnyTimes()
+		mockPrincipals.EXPECT().SetPrincipal(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		principal := &entities.Principal{
+			Account: "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23",
+			KeyID:   "ak_0123456789abcdef",
+			Scopes:  []string{entities.ScopeContactsRead},
+		}
+
+		var seen *entities.Principal
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = middleware.PrincipalFrom(r.Context())
+		})
+		serve := func(scope, apiKey string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/v1/contacts", nil)
+			if apiKey != "" {
+				req.Header.Set("autopilotapikey", apiKey)
+			}
+			w := httptest.NewRecorder()
+			middleware.NewAuth(logger, mockRep, mockPrincipals).Middleware(middleware.RequireScope(handler, scope)).ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("Normal Case1: the key has the scope", func() {
+			mockRep.EXPECT().GetPrincipalByAPIKey("ak_0123456789abcdef.secret").Return(principal, nil)
+
+			w := serve(entities.ScopeContactsRead, "ak_0123456789abcdef.secret")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(seen, ShouldEqual, principal)
+			So(middleware.AccountID(middleware.WithPrincipal(context.Background(), principal)), ShouldEqual, principal.Account)
+		})
+
+		Convey("Normal Case2: admin grants every scope", func() {
+			admin := &entities.Principal{Account: principal.Account, Scopes: []string{entities.ScopeAdmin}}
+			mockRep.EXPECT().GetPrincipalByAPIKey("ak_0123456789abcdef.secret").Return(admin, nil)
+
+			w := serve(entities.ScopeContactsDelete, "ak_0123456789abcdef.secret")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(seen, ShouldEqual, admin)
+		})
+
+		Convey("AbNormal Case1: 403, the key lacks the scope", func() {
+			mockRep.EXPECT().GetPrincipalByAPIKey("ak_0123456789abcdef.secret").Return(principal, nil)
+
+			w := serve(entities.ScopeContactsWrite, "ak_0123456789abcdef.secret")
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+			So(w.Body.String(), ShouldContainSubstring, "lacks the scope contacts:write")
+			So(seen, ShouldBeNil)
+		})
+
+		Convey("AbNormal Case2: 400 without a key, 401 with an invalid one", func() {
+			So(serve(entities.ScopeContactsRead, "").Code, ShouldEqual, http.StatusBadRequest)
+
+			mockRep.EXPECT().GetPrincipalByAPIKey("invalidKey").Return(nil, entities.ErrInvalidAPIKey)
+			So(serve(entities.ScopeContactsRead, "invalidKey").Code, ShouldEqual, http.StatusUnauthorized)
+			So(seen, ShouldBeNil)
+		})
+	})
+}
+
+// TestPrincipalCache the principals are read from the process, then from redis, then from the repository
+func TestPrincipalCache(t *testing.T) {
+	Convey("TestPrincipalCache", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockRep := mocks.NewMockRepository(ctl)
+		mockPrincipals := mocks.NewMockPrincipalCache(ctl)
+		auth := middleware.NewAuth(logger, mockRep, mockPrincipals)
+		apiKey := "ak_0123456789abcdef.secret"
+		digest := crpt.APIKeyDigest(apiKey)
+		principal := &entities.Principal{
+			Account:   "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23",
+			KeyID:     "ak_0123456789abcdef",
+			Scopes:    []string{entities.ScopeContactsRead},
+			ExpiresAt: time.Now().Add(time.Hour),
+		}
+
+		handler := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		serve := func(apiKey string) int {
+			req := httptest.NewRequest("GET", "/v1/contacts", nil)
+			req.Header.Set("autopilotapikey", apiKey)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		Convey("Normal Case1: the repository once, then the process", func() {
+			gomock.InOrder(
+				mockPrincipals.EXPECT().GetPrincipal(digest).Return(nil, redis.ErrNil),
+				mockRep.EXPECT().GetPrincipalByAPIKey(apiKey).Return(principal, nil),
+				mockPrincipals.EXPECT().SetPrincipal(digest, principal, gomock.Any()).DoAndReturn(func(digest string, principal *entities.Principal, ttl time.Duration) error {
+					So(ttl, ShouldEqual, 30*time.Second)
+					return nil
+				}),
+			)
+			for i := 0; i < 3; i++ {
+				So(serve(apiKey), ShouldEqual, http.StatusOK)
+			}
+		})
+
+		Convey("Normal Case2: redis spares the repository, the repository goes on without redis", func() {
+			mockPrincipals.EXPECT().GetPrincipal(digest).Return(principal, nil)
+			So(serve(apiKey), ShouldEqual, http.StatusOK)
+
+			other := "ak_fedcba9876543210.secret"
+			mockPrincipals.EXPECT().GetPrincipal(crpt.APIKeyDigest(other)).Return(nil, errors.New("redis down"))
+			mockRep.EXPECT().GetPrincipalByAPIKey(other).Return(principal, nil)
+			mockPrincipals.EXPECT().SetPrincipal(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("redis down"))
+			So(serve(other), ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Normal Case3: an invalid key is cached too, a repository failure is not", func() {
+			gomock.InOrder(
+				mockPrincipals.EXPECT().GetPrincipal(digest).Return(nil, redis.ErrNil),
+				mockRep.EXPECT().GetPrincipalByAPIKey(apiKey).Return(nil, errors.WithStack(entities.ErrInvalidAPIKey)),
+				mockPrincipals.EXPECT().SetPrincipal(digest, nil, 5*time.Second).Return(nil),
+			)
+			So(serve(apiKey), ShouldEqual, http.StatusUnauthorized)
+			So(serve(apiKey), ShouldEqual, http.StatusUnauthorized)
+
+			other := "ak_fedcba9876543210.secret"
+			mockPrincipals.EXPECT().GetPrincipal(crpt.APIKeyDigest(other)).Return(nil, redis.ErrNil).Times(2)
+			mockRep.EXPECT().GetPrincipalByAPIKey(other).Return(nil, errors.New("mongo down")).Times(2)
+			So(serve(other), ShouldEqual, http.StatusUnauthorized)
+			So(serve(other), ShouldEqual, http.StatusUnauthorized)
+		})
+
+		Convey("Normal Case4: a revocation by any instance drops the principal at once", func() {
+			var onRevoke func(keyID string)
+			mockPrincipals.EXPECT().SubscribePrincipals(gomock.Any()).Do(func(f func(keyID string)) { onRevoke = f })
+			auth.WatchRevocations()
+
+			mockPrincipals.EXPECT().GetPrincipal(digest).Return(principal, nil)
+			So(serve(apiKey), ShouldEqual, http.StatusOK)
+			onRevoke(principal.KeyID)
+
+			gomock.InOrder(
+				mockPrincipals.EXPECT().GetPrincipal(digest).Return(nil, redis.ErrNil),
+				mockRep.EXPECT().GetPrincipalByAPIKey(apiKey).Return(nil, errors.WithStack(entities.ErrInvalidAPIKey)),
+				mockPrincipals.EXPECT().SetPrincipal(digest, nil, gomock.Any()).Return(nil),
+			)
+			So(serve(apiKey), ShouldEqual, http.StatusUnauthorized)
+		})
+
+		Convey("Normal Case5: a principal read before its revocation is not cached again", func() {
+			var onRevoke func(keyID string)
+			mockPrincipals.EXPECT().SubscribePrincipals(gomock.Any()).Do(func(f func(keyID string)) { onRevoke = f })
+			auth.WatchRevocations()
+			onRevoke(principal.KeyID)
+
+			mockPrincipals.EXPECT().GetPrincipal(digest).Return(principal, nil).Times(2)
+			So(serve(apiKey), ShouldEqual, http.StatusOK)
+			So(serve(apiKey), ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Normal Case6: a principal is cached no longer than its key is valid", func() {
+			expiring := *principal
+			expiring.ExpiresAt = time.Now().Add(10 * time.Second)
+			mockPrincipals.EXPECT().GetPrincipal(digest).Return(nil, redis.ErrNil)
+			mockRep.EXPECT().GetPrincipalByAPIKey(apiKey).Return(&expiring, nil)
+			mockPrincipals.EXPECT().SetPrincipal(digest, &expiring, gomock.Any()).DoAndReturn(func(digest string, principal *entities.Principal, ttl time.Duration) error {
+				So(ttl, ShouldBeBetween, 9*time.Second, 10*time.Second)
+				return nil
+			})
+			So(serve(apiKey), ShouldEqual, http.StatusOK)
+		})
+	})
+}
+
+// mongoRoundTrip a FindOne of an api key on a mongo of the same network
+const mongoRoundTrip = 200 * time.Microsecond
+
+// benchRepository authenticates like the repository: the hash of the secret, after a round trip to mongo
+type benchRepository struct {
+	service.Repository
+	salt, hash []byte
+	principal  *entities.Principal
+}
+
+func (r *benchRepository) GetPrincipalByAPIKey(apiKey string) (*entities.Principal, error) {
+	_, secret, _ := crpt.SplitAPIKey(apiKey)
+	time.Sleep(mongoRoundTrip)
+	if !crpt.CheckAPIKey(secret, r.salt, r.hash) {
+		return nil, entities.ErrInvalidAPIKey
+	}
+	return r.principal, nil
+}
+
+// benchPrincipals a redis that caches nothing, the process serves every hit
+type benchPrincipals struct{}
+
+func (benchPrincipals) GetPrincipal(string) (*entities.Principal, error) { return nil, redis.ErrNil }
+func (benchPrincipals) SetPrincipal(string, *entities.Principal, time.Duration) error {
+	return nil
+}
+func (benchPrincipals) SubscribePrincipals(func(string)) {}
+
+// BenchmarkAuth the overhead of the authentication per request, by the repository each time and by the cache
+func BenchmarkAuth(b *testing.B) {
+	keyID, apiKey, _ := crpt.NewAPIKey(entities.APIKeyIDPrefix)
+	_, secret, _ := crpt.SplitAPIKey(apiKey)
+	salt, hash, _ := crpt.HashAPIKey(secret)
+	rep := &benchRepository{salt: salt, hash: hash, principal: &entities.Principal{
+		Account:   "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23",
+		KeyID:     keyID,
+		Scopes:    []string{entities.ScopeAdmin},
+		ExpiresAt: time.Now().Add(time.Hour),
+	}}
+	logger := logrus.New()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	bench := func(b *testing.B, handler http.Handler) {
+		req := httptest.NewRequest("GET", "/v1/contacts", nil)
+		req.Header.Set("autopilotapikey", apiKey)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				b.Fatalf("status %d", w.Code)
+			}
+		}
+	}
+
+	b.Run("repository", func(b *testing.B) {
+		//the middleware before the cache
+		bench(b, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := rep.GetPrincipalByAPIKey(r.Header.Get("autopilotapikey"))
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(middleware.WithPrincipal(r.Context(), principal)))
+		}))
+	})
+	b.Run("cached", func(b *testing.B) {
+		bench(b, middleware.NewAuth(logger, rep, benchPrincipals{}).Middleware(next))
+	})
+}
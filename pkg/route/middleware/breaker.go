@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+//Breaker the circuit breaker of redis shared with the service: while it is open the middlewares skip redis
+type Breaker interface {
+	//Allow entities.ErrCacheUnavailable if the call must not go to redis, else the generation of the state it is allowed in
+	Allow() (uint64, error)
+	//Done the result of the call allowed in the generation
+	Done(generation uint64, err error)
+}
+
+type principalsBreaker struct {
+	PrincipalCache
+	breaker Breaker
+}
+
+//GuardPrincipals the principals of the api keys behind the breaker, the subscription to the revocations is not
+func GuardPrincipals(principals PrincipalCache, breaker Breaker) PrincipalCache {
+	return &principalsBreaker{principals, breaker}
+}
+
+func (p *principalsBreaker) GetPrincipal(digest string) (*entities.Principal, error) {
+	generation, err := p.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+	principal, err := p.PrincipalCache.GetPrincipal(digest)
+	p.breaker.Done(generation, err)
+	return principal, err
+}
+
+func (p *principalsBreaker) SetPrincipal(digest string, principal *entities.Principal, ttl time.Duration) error {
+	generation, err := p.breaker.Allow()
+	if err != nil {
+		return err
+	}
+	err = p.PrincipalCache.SetPrincipal(digest, principal, ttl)
+	p.breaker.Done(generation, err)
+	return err
+}
+
+type bucketsBreaker struct {
+	buckets TokenBuckets
+	breaker Breaker
+}
+
+//GuardBuckets the token buckets behind the breaker
+func GuardBuckets(buckets TokenBuckets, breaker Breaker) TokenBuckets {
+	return &bucketsBreaker{buckets, breaker}
+}
+
+func (b *bucketsBreaker) Take(key string, limit int, period time.Duration) (*entities.RateLimit, error) {
+	generation, err := b.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+	rateLimit, err := b.buckets.Take(key, limit, period)
+	b.breaker.Done(generation, err)
+	return rateLimit, err
+}
+
+type usagesBreaker struct {
+	usages  UsageCounters
+	breaker Breaker
+}
+
+//GuardUsages the usage counters behind the breaker
+func GuardUsages(usages UsageCounters, breaker Breaker) UsageCounters {
+	return &usagesBreaker{usages, breaker}
+}
+
+func (u *usagesBreaker) IncrUsage(day string, usage *entities.Usage) error {
+	generation, err := u.breaker.Allow()
+	if err != nil {
+		return err
+	}
+	err = u.usages.IncrUsage(day, usage)
+	u.breaker.Done(generation, err)
+	return err
+}
+
+func (u *usagesBreaker) GetUsage(day, account, keyID string) (*entities.Usage, error) {
+	generation, err := u.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+	usage, err := u.usages.GetUsage(day, account, keyID)
+	u.breaker.Done(generation, err)
+	return usage, err
+}
+
+//warnCache log the error of redis, not the calls refused by the open breaker: it logged redis is down
+func warnCache(log *logrus.Logger, msg string, err error) {
+	if errors.Cause(err) != entities.ErrCacheUnavailable {
+		log.Warnf(msg+": %+v", err)
+	}
+}
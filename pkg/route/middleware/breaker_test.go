@@ -0,0 +1,67 @@
+package middleware_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/route/middleware"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestGuard the principals, the buckets and the usages skip redis while the breaker is open, their results go to it otherwise
+func TestGuard(t *testing.T) {
+	Convey("TestGuard", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockBreaker := mocks.NewMockBreaker(ctl)
+		mockPrincipals := mocks.NewMockPrincipalCache(ctl)
+		mockBuckets := mocks.NewMockTokenBuckets(ctl)
+		mockUsages := mocks.NewMockUsageCounters(ctl)
+		principals := middleware.GuardPrincipals(mockPrincipals, mockBreaker)
+		buckets := middleware.GuardBuckets(mockBuckets, mockBreaker)
+		usages := middleware.GuardUsages(mockUsages, mockBreaker)
+		usage := &entities.Usage{KeyID: "ak_0123456789abcdef", Requests: 1}
+
+		Convey("Normal Case1: closed, the calls go to redis, their results to the generation they were allowed in", func() {
+			errDown := errors.New("redis down")
+			rateLimit := &entities.RateLimit{Allowed: true, Limit: 600, Remaining: 599}
+			gomock.InOrder(
+				mockBreaker.EXPECT().Allow().Return(uint64(3), nil),
+				mockPrincipals.EXPECT().GetPrincipal("digest").Return(nil, errDown),
+				mockBreaker.EXPECT().Done(uint64(3), errDown),
+				mockBreaker.EXPECT().Allow().Return(uint64(4), nil),
+				mockBuckets.EXPECT().Take("ak_0123456789abcdef:read", 600, time.Minute).Return(rateLimit, nil),
+				mockBreaker.EXPECT().Done(uint64(4), nil),
+				mockBreaker.EXPECT().Allow().Return(uint64(4), nil),
+				mockUsages.EXPECT().IncrUsage("2020-05-01", usage).Return(nil),
+				mockBreaker.EXPECT().Done(uint64(4), nil),
+			)
+
+			_, err := principals.GetPrincipal("digest")
+			So(err, ShouldEqual, errDown)
+			got, err := buckets.Take("ak_0123456789abcdef:read", 600, time.Minute)
+			So(err, ShouldEqual, nil)
+			So(got, ShouldEqual, rateLimit)
+			So(usages.IncrUsage("2020-05-01", usage), ShouldEqual, nil)
+		})
+
+		Convey("Normal Case2: open, redis is not called", func() {
+			mockBreaker.EXPECT().Allow().Return(uint64(0), errors.WithStack(entities.ErrCacheUnavailable)).Times(5)
+
+			_, err := principals.GetPrincipal("digest")
+			So(errors.Cause(err), ShouldEqual, entities.ErrCacheUnavailable)
+			err = principals.SetPrincipal("digest", &entities.Principal{}, time.Minute)
+			So(errors.Cause(err), ShouldEqual, entities.ErrCacheUnavailable)
+			_, err = buckets.Take("ak_0123456789abcdef:read", 600, time.Minute)
+			So(errors.Cause(err), ShouldEqual, entities.ErrCacheUnavailable)
+			err = usages.IncrUsage("2020-05-01", usage)
+			So(errors.Cause(err), ShouldEqual, entities.ErrCacheUnavailable)
+			_, err = usages.GetUsage("2020-05-01", "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23", "ak_0123456789abcdef")
+			So(errors.Cause(err), ShouldEqual, entities.ErrCacheUnavailable)
+		})
+	})
+}
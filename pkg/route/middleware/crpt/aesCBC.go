@@ -12,7 +12,7 @@ import (
 type AesEncryptCBC struct {
 }
 
-//AesEncrypt encrypt
+//AesEncrypt encrypt, deterministic: the legacy values and the api keys looked up by their ciphertext
 func AesEncrypt(origData []byte) ([]byte, error) {
 	aesEnc := AesEncryptCBC{}
 	key := []byte("1234567812345678")
@@ -31,7 +31,7 @@ func AesEncrypt(origData []byte) ([]byte, error) {
 	return crypt, nil
 }
 
-//AesDecrypt decrypt
+//AesDecrypt decrypt, ErrDecrypt if the data is not a whole number of blocks or its padding is invalid
 func AesDecrypt(cryptKey []byte) ([]byte, error) {
 	aesEnc := AesEncryptCBC{}
 	key := []byte("1234567812345678")
@@ -41,12 +41,15 @@ func AesDecrypt(cryptKey []byte) ([]byte, error) {
 		return nil, errors.Wrap(err, "AesDecrypt")
 	}
 	blockSize := block.BlockSize()
+	if len(cryptKey) == 0 || len(cryptKey)%blockSize != 0 {
+		return nil, errors.Wrap(ErrDecrypt, "AesDecrypt")
+	}
 	blockMode := cipher.NewCBCDecrypter(block, key[:blockSize])
 	origData := make([]byte, len(cryptKey))
 	blockMode.CryptBlocks(origData, cryptKey)
-	origData = aesEnc.pkcs7UnPadding(origData)
+	origData, err = aesEnc.pkcs7UnPadding(origData, blockSize)
 
-	return origData, nil
+	return origData, errors.Wrap(err, "AesDecrypt")
 }
 
 func (a *AesEncryptCBC) pkcs7Padding(cipherText []byte, blockSize int) []byte {
@@ -55,8 +58,37 @@ func (a *AesEncryptCBC) pkcs7Padding(cipherText []byte, blockSize int) []byte {
 	return append(cipherText, padText...)
 }
 
-func (a *AesEncryptCBC) pkcs7UnPadding(plantText []byte) []byte {
+func (a *AesEncryptCBC) pkcs7UnPadding(plantText []byte, blockSize int) ([]byte, error) {
 	length := len(plantText)
+	if length == 0 {
+		return nil, ErrDecrypt
+	}
 	unPadding := int(plantText[length-1])
-	return plantText[:(length - unPadding)]
+	if unPadding == 0 || unPadding > blockSize || unPadding > length {
+		return nil, ErrDecrypt
+	}
+	for _, b := range plantText[length-unPadding:] {
+		if int(b) != unPadding {
+			return nil, ErrDecrypt
+		}
+	}
+	return plantText[:(length - unPadding)], nil
+}
+
+//LegacyCBC the Cipher of the values stored before AES-GCM, only to read them
+type LegacyCBC struct{}
+
+//Encrypt encrypt
+func (LegacyCBC) Encrypt(plain []byte) ([]byte, error) {
+	return AesEncrypt(plain)
+}
+
+//Decrypt decrypt whatever the first byte: the legacy data may start like the data of AESGCM, put it after AESGCM in a Fallback
+func (LegacyCBC) Decrypt(data []byte) ([]byte, error) {
+	return AesDecrypt(data)
+}
+
+//Current the legacy data is never encrypted by the current key
+func (LegacyCBC) Current([]byte) bool {
+	return false
 }
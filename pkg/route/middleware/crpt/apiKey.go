@@ -0,0 +1,72 @@
+package crpt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	//apiKeyIDSize the random bytes of the key id, the lookup prefix of the api key
+	apiKeyIDSize = 8
+	//apiKeySecretSize the random bytes of the secret of the api key
+	apiKeySecretSize = 32
+	//apiKeySaltSize the random bytes of the salt of the hash
+	apiKeySaltSize = 16
+)
+
+//apiKeySeparator between the key id and the secret
+const apiKeySeparator = "."
+
+//NewAPIKey a new api key: the key id, the lookup prefix, then the secret; the prefix tells the kind of the key id
+func NewAPIKey(prefix string) (keyID, key string, err error) {
+	random := make([]byte, apiKeyIDSize+apiKeySecretSize)
+	if _, err := io.ReadFull(rand.Reader, random); err != nil {
+		return "", "", errors.Wrap(err, "NewAPIKey")
+	}
+	keyID = prefix + hex.EncodeToString(random[:apiKeyIDSize])
+
+	return keyID, keyID + apiKeySeparator + base64.RawURLEncoding.EncodeToString(random[apiKeyIDSize:]), nil
+}
+
+//SplitAPIKey the key id and the secret of the api key, false if it has no key id like the keys issued before them
+func SplitAPIKey(key string) (keyID, secret string, ok bool) {
+	i := strings.Index(key, apiKeySeparator)
+	if i <= 0 || i == len(key)-1 {
+		return "", "", false
+	}
+	return key[:i], key[i+1:], true
+}
+
+//HashAPIKey a new random salt and the salted sha-256 of the secret, the secrets are random so a fast hash is enough
+func HashAPIKey(secret string) (salt, hash []byte, err error) {
+	salt = make([]byte, apiKeySaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, nil, errors.Wrap(err, "HashAPIKey")
+	}
+	return salt, hashAPIKey(salt, secret), nil
+}
+
+//CheckAPIKey the secret has the salted hash, in constant time
+func CheckAPIKey(secret string, salt, hash []byte) bool {
+	return subtle.ConstantTimeCompare(hashAPIKey(salt, secret), hash) == 1
+}
+
+func hashAPIKey(salt []byte, secret string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(secret))
+	return h.Sum(nil)
+}
+
+//APIKeyDigest the sha-256 of the whole api key, the caches know the keys by it and never store them
+func APIKeyDigest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
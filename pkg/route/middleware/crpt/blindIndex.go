@@ -0,0 +1,21 @@
+package crpt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+//BlindIndexKeySize the minimum size of the key of the blind indexes
+const BlindIndexKeySize = 32
+
+//BlindIndex the keyed hmac-sha256 of the value of the field, the same value of the same field always has the same index,
+//the field keeps the indexes of different fields apart
+func BlindIndex(key []byte, field, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,179 @@
+package crpt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+//ErrDecrypt the data is corrupt or was encrypted by another key
+var ErrDecrypt = errors.New("the data can not be decrypted")
+
+//Cipher encrypt and decrypt the stored values
+type Cipher interface {
+	Encrypt(plain []byte) ([]byte, error)
+	//Decrypt ErrDecrypt if the data can not be decrypted, never panics
+	Decrypt(data []byte) ([]byte, error)
+	//Current the data is encrypted by the current key, false if it must be encrypted again after a rotation
+	Current(data []byte) bool
+}
+
+//DataKeySize the size of the AES-256 keys
+const DataKeySize = 32
+
+//maxKeyIDSize the key id is written after its size in one byte
+const maxKeyIDSize = 255
+
+const (
+	//gcmVersion the data encrypted before the key ids: version | nonce | sealed
+	gcmVersion byte = 1
+	//gcmKeyIDVersion version | size of the key id | key id | nonce | sealed, the key id is authenticated too
+	gcmKeyIDVersion byte = 2
+)
+
+//AESGCM AES-256-GCM with a random nonce per value, the same value encrypts differently every time,
+//the current key of the keyring encrypts and the key id in the data picks the key decrypts
+type AESGCM struct {
+	current string
+	aeads   map[string]cipher.AEAD
+	//ids the key ids in the order of the keyring
+	ids []string
+}
+
+//NewAESGCM the keys of the provider must be 32 bytes
+func NewAESGCM(provider KeyProvider) (*AESGCM, error) {
+	keyring, err := provider.Keyring()
+	if err != nil {
+		return nil, errors.WithMessage(err, "NewAESGCM")
+	}
+	if len(keyring) == 0 {
+		return nil, errors.New("NewAESGCM: no key")
+	}
+
+	c := &AESGCM{current: keyring.Current().ID, aeads: make(map[string]cipher.AEAD, len(keyring))}
+	for _, key := range keyring {
+		if len(key.ID) == 0 || len(key.ID) > maxKeyIDSize {
+			return nil, errors.Errorf("NewAESGCM: the key id %q must be 1 to %d bytes", key.ID, maxKeyIDSize)
+		}
+		if len(key.Secret) != DataKeySize {
+			return nil, errors.Errorf("NewAESGCM: the key %q is %d bytes, it must be %d", key.ID, len(key.Secret), DataKeySize)
+		}
+		block, err := aes.NewCipher(key.Secret)
+		if err != nil {
+			return nil, errors.Wrap(err, "NewAESGCM")
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errors.Wrap(err, "NewAESGCM")
+		}
+		c.aeads[key.ID] = aead
+		c.ids = append(c.ids, key.ID)
+	}
+
+	return c, nil
+}
+
+//CurrentKeyID the id of the key encrypts
+func (c *AESGCM) CurrentKeyID() string {
+	return c.current
+}
+
+//Encrypt encrypt by the current key
+func (c *AESGCM) Encrypt(plain []byte) ([]byte, error) {
+	aead := c.aeads[c.current]
+	header := 2 + len(c.current)
+	nonceSize := aead.NonceSize()
+	data := make([]byte, header+nonceSize, header+nonceSize+len(plain)+aead.Overhead())
+	data[0] = gcmKeyIDVersion
+	data[1] = byte(len(c.current))
+	copy(data[2:], c.current)
+	nonce := data[header:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrap(err, "AESGCM encrypt")
+	}
+
+	return aead.Seal(data, nonce, plain, data[:header]), nil
+}
+
+//Decrypt decrypt by the key of the key id, the data without one by any key
+func (c *AESGCM) Decrypt(data []byte) ([]byte, error) {
+	if len(data) > 0 && data[0] == gcmVersion {
+		for _, id := range c.ids {
+			if plain, err := c.open(c.aeads[id], data, 1, nil); err == nil {
+				return plain, nil
+			}
+		}
+		return nil, errors.Wrap(ErrDecrypt, "AESGCM decrypt")
+	}
+
+	id, ok := KeyID(data)
+	if !ok {
+		return nil, errors.Wrap(ErrDecrypt, "AESGCM decrypt")
+	}
+	aead, ok := c.aeads[id]
+	if !ok {
+		return nil, errors.Wrapf(ErrDecrypt, "AESGCM decrypt: unknown key %q", id)
+	}
+	header := 2 + len(id)
+	plain, err := c.open(aead, data, header, data[:header])
+	return plain, errors.Wrap(err, "AESGCM decrypt")
+}
+
+func (c *AESGCM) open(aead cipher.AEAD, data []byte, header int, additional []byte) ([]byte, error) {
+	nonceSize := aead.NonceSize()
+	if len(data) < header+nonceSize+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	plain, err := aead.Open(nil, data[header:header+nonceSize], data[header+nonceSize:], additional)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+//Current current
+func (c *AESGCM) Current(data []byte) bool {
+	id, ok := KeyID(data)
+	return ok && id == c.current
+}
+
+//KeyID the id of the key encrypted the data, false if the data has none
+func KeyID(data []byte) (string, bool) {
+	if len(data) < 2 || data[0] != gcmKeyIDVersion {
+		return "", false
+	}
+	size := int(data[1])
+	if size == 0 || len(data) < 2+size {
+		return "", false
+	}
+	return string(data[2 : 2+size]), true
+}
+
+//Fallback encrypt by the first cipher, decrypt by the first one can: the values stored by the older ciphers stay readable,
+//the next cipher is tried only when the previous ones fail, the data authenticated by AESGCM never reaches the legacy one
+type Fallback []Cipher
+
+//Encrypt encrypt
+func (f Fallback) Encrypt(plain []byte) ([]byte, error) {
+	return f[0].Encrypt(plain)
+}
+
+//Decrypt decrypt
+func (f Fallback) Decrypt(data []byte) ([]byte, error) {
+	var err error
+	for _, c := range f {
+		var plain []byte
+		if plain, err = c.Decrypt(data); err == nil {
+			return plain, nil
+		}
+	}
+	return nil, err
+}
+
+//Current current
+func (f Fallback) Current(data []byte) bool {
+	return f[0].Current(data)
+}
@@ -0,0 +1,380 @@
+package crpt_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/STreeChin/contactapi/pkg/route/middleware/crpt"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+var testKeyring = crpt.MemoryKeyProvider{{ID: "2020-05", Secret: testKey}}
+
+// TestAESGCM
+func TestAESGCM(t *testing.T) {
+	Convey("TestAESGCM", t, func() {
+		c, err := crpt.NewAESGCM(testKeyring)
+		So(err, ShouldEqual, nil)
+
+		Convey("Normal Case1: round trip, the same value encrypts differently every time", func() {
+			first, err := c.Encrypt([]byte("StGr@gmail.com"))
+			So(err, ShouldEqual, nil)
+			second, err := c.Encrypt([]byte("StGr@gmail.com"))
+			So(err, ShouldEqual, nil)
+			So(bytes.Equal(first, second), ShouldBeFalse)
+
+			plain, err := c.Decrypt(first)
+			So(err, ShouldEqual, nil)
+			So(string(plain), ShouldEqual, "StGr@gmail.com")
+			plain, err = c.Decrypt(second)
+			So(err, ShouldEqual, nil)
+			So(string(plain), ShouldEqual, "StGr@gmail.com")
+		})
+
+		Convey("Normal Case2: the empty value", func() {
+			data, err := c.Encrypt(nil)
+			So(err, ShouldEqual, nil)
+			plain, err := c.Decrypt(data)
+			So(err, ShouldEqual, nil)
+			So(len(plain), ShouldEqual, 0)
+		})
+
+		Convey("AbNormal Case1: tampered, truncated, empty or foreign data is an error", func() {
+			data, err := c.Encrypt([]byte("StGr@gmail.com"))
+			So(err, ShouldEqual, nil)
+			tampered := append([]byte{}, data...)
+			tampered[len(tampered)-1] ^= 1
+
+			other, err := crpt.NewAESGCM(crpt.MemoryKeyProvider{{ID: "2020-05", Secret: bytes.Repeat([]byte{7}, crpt.DataKeySize)}})
+			So(err, ShouldEqual, nil)
+			foreign, err := other.Encrypt([]byte("StGr@gmail.com"))
+			So(err, ShouldEqual, nil)
+
+			for _, bad := range [][]byte{nil, {}, {1}, tampered, data[:len(data)-1], foreign} {
+				plain, err := c.Decrypt(bad)
+				So(plain, ShouldEqual, nil)
+				So(errors.Cause(err), ShouldEqual, crpt.ErrDecrypt)
+			}
+		})
+
+		Convey("AbNormal Case2: the key must be 32 bytes", func() {
+			_, err := crpt.NewAESGCM(crpt.MemoryKeyProvider{{ID: "2020-05", Secret: []byte("1234567812345678")}})
+			So(err, ShouldNotEqual, nil)
+		})
+	})
+}
+
+// TestKeyRotation
+func TestKeyRotation(t *testing.T) {
+	Convey("TestKeyRotation", t, func() {
+		oldKey := crpt.Key{ID: "2020-05", Secret: testKey}
+		newKey := crpt.Key{ID: "2020-06", Secret: bytes.Repeat([]byte{7}, crpt.DataKeySize)}
+		before, err := crpt.NewAESGCM(crpt.MemoryKeyProvider{oldKey})
+		So(err, ShouldEqual, nil)
+		after, err := crpt.NewAESGCM(crpt.MemoryKeyProvider{oldKey, newKey})
+		So(err, ShouldEqual, nil)
+		So(after.CurrentKeyID(), ShouldEqual, "2020-06")
+
+		Convey("Normal Case1: the key id picks the key, the current key encrypts", func() {
+			old, err := before.Encrypt([]byte("StGr@gmail.com"))
+			So(err, ShouldEqual, nil)
+			id, ok := crpt.KeyID(old)
+			So(ok, ShouldBeTrue)
+			So(id, ShouldEqual, "2020-05")
+			So(before.Current(old), ShouldBeTrue)
+			So(after.Current(old), ShouldBeFalse)
+
+			plain, err := after.Decrypt(old)
+			So(err, ShouldEqual, nil)
+			So(string(plain), ShouldEqual, "StGr@gmail.com")
+
+			current, err := after.Encrypt(plain)
+			So(err, ShouldEqual, nil)
+			id, _ = crpt.KeyID(current)
+			So(id, ShouldEqual, "2020-06")
+			So(after.Current(current), ShouldBeTrue)
+		})
+
+		Convey("AbNormal Case1: the key was removed from the keyring", func() {
+			current, err := after.Encrypt([]byte("StGr@gmail.com"))
+			So(err, ShouldEqual, nil)
+			_, err = before.Decrypt(current)
+			So(errors.Cause(err), ShouldEqual, crpt.ErrDecrypt)
+		})
+
+		Convey("AbNormal Case2: the key id is authenticated, it can not be swapped", func() {
+			old, err := before.Encrypt([]byte("StGr@gmail.com"))
+			So(err, ShouldEqual, nil)
+			//same size, both keys in the keyring: only the authentication tells
+			swapped := append([]byte{}, old...)
+			copy(swapped[2:], "2020-06")
+			_, err = after.Decrypt(swapped)
+			So(errors.Cause(err), ShouldEqual, crpt.ErrDecrypt)
+		})
+	})
+}
+
+// TestParseKeyring
+func TestParseKeyring(t *testing.T) {
+	Convey("TestParseKeyring", t, func() {
+		encoded := base64.StdEncoding.EncodeToString(testKey)
+
+		Convey("Normal Case1: id:base64 entries, the last one is current", func() {
+			keyring, err := crpt.ParseKeyring("2020-05:" + encoded + "\n 2020-06:" + encoded + ",\n")
+			So(err, ShouldEqual, nil)
+			So(len(keyring), ShouldEqual, 2)
+			So(keyring.Current().ID, ShouldEqual, "2020-06")
+			key, ok := keyring.Get("2020-05")
+			So(ok, ShouldBeTrue)
+			So(key.Secret, ShouldResemble, testKey)
+		})
+
+		Convey("Normal Case2: a single key without an id is the key 0", func() {
+			keyring, err := crpt.ParseKeyring(encoded + "\n")
+			So(err, ShouldEqual, nil)
+			So(keyring.Current(), ShouldResemble, crpt.Key{ID: "0", Secret: testKey})
+		})
+
+		Convey("AbNormal Case1: empty, an empty id, an id used twice, invalid base64", func() {
+			for _, text := range []string{"", " \n", ":" + encoded, "a:" + encoded + " a:" + encoded, "a:not base64!"} {
+				_, err := crpt.ParseKeyring(text)
+				So(err, ShouldNotEqual, nil)
+			}
+		})
+	})
+}
+
+// TestLegacyCBC
+func TestLegacyCBC(t *testing.T) {
+	Convey("TestLegacyCBC", t, func() {
+		Convey("Normal Case1: round trip", func() {
+			data, err := crpt.AesEncrypt([]byte("StGr@gmail.com"))
+			So(err, ShouldEqual, nil)
+			plain, err := crpt.AesDecrypt(data)
+			So(err, ShouldEqual, nil)
+			So(string(plain), ShouldEqual, "StGr@gmail.com")
+		})
+
+		Convey("AbNormal Case1: empty, partial blocks or invalid padding are errors, not panics", func() {
+			invalidPadding, err := crpt.AesEncrypt([]byte("0123456789abcdef"))
+			So(err, ShouldEqual, nil)
+			//the last block is all padding, drop it
+			invalidPadding = invalidPadding[:16]
+
+			for _, bad := range [][]byte{nil, {}, {1, 2, 3}, make([]byte, 17), invalidPadding} {
+				var plain []byte
+				So(func() { plain, err = crpt.AesDecrypt(bad) }, ShouldNotPanic)
+				So(plain, ShouldEqual, nil)
+				So(errors.Cause(err), ShouldEqual, crpt.ErrDecrypt)
+			}
+		})
+	})
+}
+
+// TestFallback
+func TestFallback(t *testing.T) {
+	Convey("TestFallback", t, func() {
+		gcm, err := crpt.NewAESGCM(testKeyring)
+		So(err, ShouldEqual, nil)
+		c := crpt.Fallback{gcm, crpt.LegacyCBC{}}
+
+		Convey("Normal Case1: encrypt by the first cipher, decrypt the legacy data", func() {
+			data, err := c.Encrypt([]byte("StGr@gmail.com"))
+			So(err, ShouldEqual, nil)
+			plain, err := gcm.Decrypt(data)
+			So(err, ShouldEqual, nil)
+			So(string(plain), ShouldEqual, "StGr@gmail.com")
+
+			legacy, err := crpt.AesEncrypt([]byte("StGr@gmail.com"))
+			So(err, ShouldEqual, nil)
+			plain, err = c.Decrypt(legacy)
+			So(err, ShouldEqual, nil)
+			So(string(plain), ShouldEqual, "StGr@gmail.com")
+		})
+
+		Convey("AbNormal Case1: no cipher can decrypt", func() {
+			_, err := c.Decrypt([]byte{1, 2, 3})
+			So(errors.Cause(err), ShouldEqual, crpt.ErrDecrypt)
+		})
+
+		Convey("Normal Case2: the legacy data starting like the data of GCM is still decrypted by the legacy cipher", func() {
+			//about one in 128 of the legacy values starts with the version byte of GCM
+			var plain string
+			var legacy []byte
+			found := false
+			for i := 0; i < 1<<16 && !found; i++ {
+				plain = fmt.Sprintf("user%d@gmail.com", i)
+				legacy, err = crpt.AesEncrypt([]byte(plain))
+				So(err, ShouldEqual, nil)
+				found = legacy[0] == 1 || legacy[0] == 2
+			}
+			So(found, ShouldBeTrue)
+
+			decrypted, err := c.Decrypt(legacy)
+			So(err, ShouldEqual, nil)
+			So(string(decrypted), ShouldEqual, plain)
+		})
+
+		Convey("AbNormal Case2: the data of GCM failing its tag goes to the legacy cipher, which rejects it", func() {
+			data, err := c.Encrypt([]byte("StGr@gmail.com"))
+			So(err, ShouldEqual, nil)
+			tampered := append([]byte{}, data...)
+			tampered[len(tampered)-1] ^= 1
+			_, err = gcm.Decrypt(tampered)
+			So(errors.Cause(err), ShouldEqual, crpt.ErrDecrypt)
+			//not a whole number of blocks of CBC
+			So(len(tampered)%16, ShouldNotEqual, 0)
+
+			plain, err := c.Decrypt(tampered)
+			So(plain, ShouldEqual, nil)
+			So(errors.Cause(err), ShouldEqual, crpt.ErrDecrypt)
+		})
+	})
+}
+
+// TestKeyProvider
+func TestKeyProvider(t *testing.T) {
+	Convey("TestKeyProvider", t, func() {
+		encoded := base64.StdEncoding.EncodeToString(testKey)
+
+		Convey("Normal Case1: a file, like a docker secret, with a trailing newline", func() {
+			dir, err := ioutil.TempDir("", "crpt")
+			So(err, ShouldEqual, nil)
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "data-key")
+			So(ioutil.WriteFile(path, []byte(encoded+"\n"), 0600), ShouldEqual, nil)
+
+			provider, err := crpt.NewKeyProvider("file:" + path)
+			So(err, ShouldEqual, nil)
+			keyring, err := provider.Keyring()
+			So(err, ShouldEqual, nil)
+			So(keyring.Current().Secret, ShouldResemble, testKey)
+		})
+
+		Convey("Normal Case2: an environment variable", func() {
+			So(os.Setenv("CRPT_TEST_DATAKEY", encoded), ShouldEqual, nil)
+			defer os.Unsetenv("CRPT_TEST_DATAKEY")
+
+			provider, err := crpt.NewKeyProvider("env:CRPT_TEST_DATAKEY")
+			So(err, ShouldEqual, nil)
+			keyring, err := provider.Keyring()
+			So(err, ShouldEqual, nil)
+			So(keyring.Current().Secret, ShouldResemble, testKey)
+		})
+
+		Convey("AbNormal Case1: unknown source, missing file or variable, invalid base64", func() {
+			_, err := crpt.NewKeyProvider("vault:data-key")
+			So(err, ShouldNotEqual, nil)
+
+			_, err = crpt.FileKeyProvider("/nonexistent/data-key").Keyring()
+			So(err, ShouldNotEqual, nil)
+
+			_, err = crpt.EnvKeyProvider("CRPT_TEST_UNSET").Keyring()
+			So(err, ShouldNotEqual, nil)
+
+			So(os.Setenv("CRPT_TEST_DATAKEY", "not base64!"), ShouldEqual, nil)
+			defer os.Unsetenv("CRPT_TEST_DATAKEY")
+			_, err = crpt.EnvKeyProvider("CRPT_TEST_DATAKEY").Keyring()
+			So(err, ShouldNotEqual, nil)
+		})
+	})
+}
+
+// TestLocalKMS
+func TestLocalKMS(t *testing.T) {
+	Convey("TestLocalKMS", t, func() {
+		master, err := crpt.NewAESGCM(testKeyring)
+		So(err, ShouldEqual, nil)
+		kms := crpt.LocalKMS{Master: master}
+
+		Convey("Normal Case1: the wrapped data key unwraps to the plain one, its values carry the data key id", func() {
+			plain, wrapped, err := kms.GenerateDataKey()
+			So(err, ShouldEqual, nil)
+			So(len(plain), ShouldEqual, crpt.DataKeySize)
+			So(bytes.Contains(wrapped, plain), ShouldBeFalse)
+			unwrapped, err := kms.Decrypt(wrapped)
+			So(err, ShouldEqual, nil)
+			So(unwrapped, ShouldResemble, plain)
+
+			dek, err := crpt.NewDataKeyCipher(unwrapped)
+			So(err, ShouldEqual, nil)
+			data, err := dek.Encrypt([]byte("StGr@gmail.com"))
+			So(err, ShouldEqual, nil)
+			id, _ := crpt.KeyID(data)
+			So(id, ShouldEqual, crpt.DataKeyID)
+		})
+
+		Convey("AbNormal Case1: once the data key is destroyed, its values can not be decrypted by the master key", func() {
+			plain, _, err := kms.GenerateDataKey()
+			So(err, ShouldEqual, nil)
+			dek, err := crpt.NewDataKeyCipher(plain)
+			So(err, ShouldEqual, nil)
+			data, err := dek.Encrypt([]byte("StGr@gmail.com"))
+			So(err, ShouldEqual, nil)
+
+			_, err = crpt.Fallback{master, crpt.LegacyCBC{}}.Decrypt(data)
+			So(errors.Cause(err), ShouldEqual, crpt.ErrDecrypt)
+		})
+
+		Convey("AbNormal Case2: a value of the master key is not a data key", func() {
+			wrapped, err := master.Encrypt([]byte("StGr@gmail.com"))
+			So(err, ShouldEqual, nil)
+			_, err = kms.Decrypt(wrapped)
+			So(errors.Cause(err), ShouldEqual, crpt.ErrDecrypt)
+		})
+	})
+}
+
+// TestAPIKey
+func TestAPIKey(t *testing.T) {
+	Convey("TestAPIKey", t, func() {
+		Convey("Normal Case1: the key id is the prefix of the key, the salted hash checks the secret", func() {
+			keyID, key, err := crpt.NewAPIKey("ak_")
+			So(err, ShouldEqual, nil)
+			So(strings.HasPrefix(keyID, "ak_"), ShouldBeTrue)
+			id, secret, ok := crpt.SplitAPIKey(key)
+			So(ok, ShouldBeTrue)
+			So(id, ShouldEqual, keyID)
+
+			salt, hash, err := crpt.HashAPIKey(secret)
+			So(err, ShouldEqual, nil)
+			So(crpt.CheckAPIKey(secret, salt, hash), ShouldBeTrue)
+			So(crpt.CheckAPIKey(secret+"x", salt, hash), ShouldBeFalse)
+
+			//the same secret hashes differently by another salt
+			otherSalt, otherHash, err := crpt.HashAPIKey(secret)
+			So(err, ShouldEqual, nil)
+			So(bytes.Equal(otherSalt, salt), ShouldBeFalse)
+			So(bytes.Equal(otherHash, hash), ShouldBeFalse)
+		})
+
+		Convey("Normal Case2: the keys issued before the key ids have none", func() {
+			for _, key := range []string{"65263027fab7d440ba4c5f3b834fb800", ".secret", "ak_1."} {
+				_, _, ok := crpt.SplitAPIKey(key)
+				So(ok, ShouldBeFalse)
+			}
+		})
+	})
+}
+
+// TestBlindIndex
+func TestBlindIndex(t *testing.T) {
+	Convey("TestBlindIndex", t, func() {
+		Convey("Normal Case1: deterministic per key, field and value", func() {
+			index := crpt.BlindIndex(testKey, "email", "StGr@gmail.com")
+			So(crpt.BlindIndex(testKey, "email", "StGr@gmail.com"), ShouldEqual, index)
+			So(crpt.BlindIndex(testKey, "email", "stgr@gmail.com"), ShouldNotEqual, index)
+			So(crpt.BlindIndex(testKey, "contactid", "StGr@gmail.com"), ShouldNotEqual, index)
+			So(crpt.BlindIndex(bytes.Repeat([]byte{7}, 32), "email", "StGr@gmail.com"), ShouldNotEqual, index)
+		})
+	})
+}
@@ -0,0 +1,118 @@
+package crpt
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//Key one key of the keyring, ID is written before the data it encrypts
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+//Keyring the keys, the last one is the current key: rotate by appending a new key, keep the old ones until nothing uses them
+type Keyring []Key
+
+//Current the key encrypts
+func (k Keyring) Current() Key {
+	return k[len(k)-1]
+}
+
+//Get the key of the id
+func (k Keyring) Get(id string) (Key, bool) {
+	for _, key := range k {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+//defaultKeyID the id of a key written without one, like the single key before the rotation
+const defaultKeyID = "0"
+
+//ParseKeyring parse the entries separated by spaces, newlines or commas, each is id:base64 or the base64 of the key 0
+func ParseKeyring(text string) (Keyring, error) {
+	entries := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	if len(entries) == 0 {
+		return nil, errors.New("ParseKeyring: no key")
+	}
+
+	keyring := make(Keyring, 0, len(entries))
+	for _, entry := range entries {
+		id, encoded := defaultKeyID, entry
+		if i := strings.IndexByte(entry, ':'); i >= 0 {
+			id, encoded = entry[:i], entry[i+1:]
+		}
+		if id == "" || len(id) > maxKeyIDSize {
+			return nil, errors.Errorf("ParseKeyring: the key id %q must be 1 to %d bytes", id, maxKeyIDSize)
+		}
+		if _, ok := keyring.Get(id); ok {
+			return nil, errors.Errorf("ParseKeyring: the key id %q is used twice", id)
+		}
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.Wrapf(err, "ParseKeyring: key %q", id)
+		}
+		keyring = append(keyring, Key{ID: id, Secret: secret})
+	}
+
+	return keyring, nil
+}
+
+//KeyProvider provide the keyring of a Cipher
+type KeyProvider interface {
+	Keyring() (Keyring, error)
+}
+
+//NewKeyProvider the provider of the source: file:<path> for a file or a docker secret, env:<name> for an environment variable
+func NewKeyProvider(source string) (KeyProvider, error) {
+	switch {
+	case strings.HasPrefix(source, "file:"):
+		return FileKeyProvider(strings.TrimPrefix(source, "file:")), nil
+	case strings.HasPrefix(source, "env:"):
+		return EnvKeyProvider(strings.TrimPrefix(source, "env:")), nil
+	}
+	return nil, errors.Errorf("NewKeyProvider: unknown key source %q", source)
+}
+
+//FileKeyProvider the path of a file holds the keyring, like a docker secret in /run/secrets
+type FileKeyProvider string
+
+//Keyring keyring
+func (p FileKeyProvider) Keyring() (Keyring, error) {
+	buf, err := ioutil.ReadFile(string(p))
+	if err != nil {
+		return nil, errors.Wrap(err, "FileKeyProvider")
+	}
+	keyring, err := ParseKeyring(string(buf))
+	return keyring, errors.WithMessage(err, "FileKeyProvider")
+}
+
+//EnvKeyProvider the name of an environment variable holds the keyring
+type EnvKeyProvider string
+
+//Keyring keyring
+func (p EnvKeyProvider) Keyring() (Keyring, error) {
+	value, ok := os.LookupEnv(string(p))
+	if !ok {
+		return nil, errors.Errorf("EnvKeyProvider: %s is not set", string(p))
+	}
+	keyring, err := ParseKeyring(value)
+	return keyring, errors.WithMessage(err, "EnvKeyProvider")
+}
+
+//MemoryKeyProvider the keyring itself, for the tests
+type MemoryKeyProvider Keyring
+
+//Keyring keyring
+func (p MemoryKeyProvider) Keyring() (Keyring, error) {
+	return Keyring(p), nil
+}
@@ -0,0 +1,55 @@
+package crpt
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+//DataKeyID the key id in the values encrypted by a data key of a KMS, not the id of a key of the keyring
+const DataKeyID = "dek"
+
+//KMS the key management service wraps the data keys by its master key, the master key never leaves it
+type KMS interface {
+	//GenerateDataKey a new data key, plain to encrypt and wrapped to store
+	GenerateDataKey() (plain, wrapped []byte, err error)
+	//Decrypt unwrap the stored data key, ErrDecrypt if the master key can not
+	Decrypt(wrapped []byte) ([]byte, error)
+}
+
+//LocalKMS the stand-in of a KMS in the process, the master key is the cipher of the keyring:
+//rotating the keyring rotates the master key, the wrapped data keys are encrypted again like any value
+type LocalKMS struct {
+	Master Cipher
+}
+
+//GenerateDataKey generate data key
+func (k LocalKMS) GenerateDataKey() ([]byte, []byte, error) {
+	plain := make([]byte, DataKeySize)
+	if _, err := io.ReadFull(rand.Reader, plain); err != nil {
+		return nil, nil, errors.Wrap(err, "LocalKMS generateDataKey")
+	}
+	wrapped, err := k.Master.Encrypt(plain)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "LocalKMS generateDataKey")
+	}
+	return plain, wrapped, nil
+}
+
+//Decrypt decrypt
+func (k LocalKMS) Decrypt(wrapped []byte) ([]byte, error) {
+	plain, err := k.Master.Decrypt(wrapped)
+	if err != nil {
+		return nil, errors.WithMessage(err, "LocalKMS decrypt")
+	}
+	if len(plain) != DataKeySize {
+		return nil, errors.Wrap(ErrDecrypt, "LocalKMS decrypt: not a data key")
+	}
+	return plain, nil
+}
+
+//NewDataKeyCipher the cipher of the plain data key, its values are told from the ones of the keyring by DataKeyID
+func NewDataKeyCipher(plain []byte) (*AESGCM, error) {
+	return NewAESGCM(MemoryKeyProvider{{ID: DataKeyID, Secret: plain}})
+}
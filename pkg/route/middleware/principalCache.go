@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+)
+
+//principalLRU the principals of the api keys in the process, by the digests of the keys, the least recently used go first;
+//a nil principal is an invalid key
+type principalLRU struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+	//revoked the key ids revoked lately, until when their principals read before are not cached again
+	revoked map[string]time.Time
+}
+
+type principalEntry struct {
+	digest    string
+	principal *entities.Principal
+	expires   time.Time
+}
+
+func newPrincipalLRU(size int) *principalLRU {
+	return &principalLRU{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+		revoked: make(map[string]time.Time),
+	}
+}
+
+//get the principal of the digest, false if it is not cached or expired
+func (l *principalLRU) get(digest string, now time.Time) (*entities.Principal, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	element, ok := l.entries[digest]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*principalEntry)
+	if !now.Before(entry.expires) {
+		l.remove(element)
+		return nil, false
+	}
+	l.order.MoveToFront(element)
+	return entry.principal, true
+}
+
+//add the principal of the digest until expires, unless its key id was revoked since
+func (l *principalLRU) add(digest string, principal *entities.Principal, expires, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if principal != nil {
+		if until, ok := l.revoked[principal.KeyID]; ok && now.Before(until) {
+			return
+		}
+	}
+	if element, ok := l.entries[digest]; ok {
+		l.remove(element)
+	}
+	l.entries[digest] = l.order.PushFront(&principalEntry{digest, principal, expires})
+	for l.order.Len() > l.size {
+		l.remove(l.order.Back())
+	}
+}
+
+//revoke drop the principals of the key id and keep them out until until, all of them for an empty key id
+func (l *principalLRU) revoke(keyID string, until, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if keyID == "" {
+		l.order.Init()
+		l.entries = make(map[string]*list.Element, l.size)
+		return
+	}
+	for id, t := range l.revoked {
+		if !now.Before(t) {
+			delete(l.revoked, id)
+		}
+	}
+	l.revoked[keyID] = until
+	for element := l.order.Front(); element != nil; {
+		next := element.Next()
+		if p := element.Value.(*principalEntry).principal; p != nil && p.KeyID == keyID {
+			l.remove(element)
+		}
+		element = next
+	}
+}
+
+func (l *principalLRU) remove(element *list.Element) {
+	l.order.Remove(element)
+	delete(l.entries, element.Value.(*principalEntry).digest)
+}
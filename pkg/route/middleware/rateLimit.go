@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/config"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/sirupsen/logrus"
+)
+
+//the requests per minute of an api key in each class of routes if the config has none
+var defaultRateLimits = map[string]int{
+	entities.RateClassRead:  600,
+	entities.RateClassWrite: 300,
+	entities.RateClassBulk:  30,
+}
+
+//RateLimiter interface
+type RateLimiter interface {
+	Limit(next http.Handler, class string) http.Handler
+}
+
+//TokenBuckets the token buckets of the api keys by key id and class, each holds limit tokens and gets them back over period
+type TokenBuckets interface {
+	Take(key string, limit int, period time.Duration) (*entities.RateLimit, error)
+}
+
+type rateLimit struct {
+	log     *logrus.Logger
+	buckets TokenBuckets
+	limits  map[string]int
+}
+
+//NewRateLimit the rate limits of the config, the buckets are the shared ones or, in the local mode, the ones of the process
+func NewRateLimit(log *logrus.Logger, cfg config.Config, shared TokenBuckets) *rateLimit {
+	rlCfg := cfg.GetRateLimitConfig()
+	limits := make(map[string]int, len(defaultRateLimits))
+	for class, limit := range map[string]int{
+		entities.RateClassRead:  rlCfg.Read,
+		entities.RateClassWrite: rlCfg.Write,
+		entities.RateClassBulk:  rlCfg.Bulk,
+	} {
+		if limit <= 0 {
+			limit = defaultRateLimits[class]
+		}
+		limits[class] = limit
+	}
+
+	buckets := shared
+	if rlCfg.Mode == "local" {
+		buckets = NewLocalBuckets()
+	}
+	return &rateLimit{log, buckets, limits}
+}
+
+//Limit the request goes on to next only if the bucket of its api key in the class has a token, 429 if it has not;
+//the requests without an api key or a class are not limited, nor are they if the buckets fail
+func (l *rateLimit) Limit(next http.Handler, class string) http.Handler {
+	if class == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := PrincipalFrom(r.Context())
+		if principal == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limit := principal.RateLimits[class]
+		if limit <= 0 {
+			limit = l.limits[class]
+		}
+
+		result, err := l.buckets.Take(principal.KeyID+":"+class, limit, entities.RateLimitPeriod)
+		if err != nil {
+			warnCache(l.log, "RateLimit", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(result.Reset).Unix(), 10))
+		if !result.Allowed {
+			header.Set("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+			w.WriteHeader(http.StatusTooManyRequests)
+			err = json.NewEncoder(w).Encode(map[string]string{"error": "Too Many Requests", "message": "Rate limit of " + strconv.Itoa(result.Limit) + " " + class + " requests per minute exceeded."})
+			if err != nil {
+				l.log.Error("RateLimit: json encode error")
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+//localBucketsSweep the buckets of the process are swept of the full ones once there are more
+const localBucketsSweep = 10000
+
+type localBucket struct {
+	tokens float64
+	ts     time.Time
+	full   time.Time
+}
+
+type localBuckets struct {
+	mu      sync.Mutex
+	buckets map[string]*localBucket
+}
+
+//NewLocalBuckets the token buckets in the process, for a single instance and the tests
+func NewLocalBuckets() *localBuckets {
+	return &localBuckets{buckets: make(map[string]*localBucket)}
+}
+
+//Take take a token from the bucket of the key, like the shared buckets do
+func (b *localBuckets) Take(key string, limit int, period time.Duration) (*entities.RateLimit, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if len(b.buckets) > localBucketsSweep {
+		for k, bucket := range b.buckets {
+			if !now.Before(bucket.full) {
+				delete(b.buckets, k)
+			}
+		}
+	}
+
+	//tokens per nanosecond
+	rate := float64(limit) / float64(period)
+	bucket, ok := b.buckets[key]
+	if !ok {
+		bucket = &localBucket{tokens: float64(limit), ts: now}
+		b.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(float64(limit), bucket.tokens+float64(now.Sub(bucket.ts))*rate)
+	bucket.ts = now
+
+	result := &entities.RateLimit{Limit: limit}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((1 - bucket.tokens) / rate))
+	}
+	result.Remaining = int(bucket.tokens)
+	result.Reset = time.Duration(math.Ceil((float64(limit) - bucket.tokens) / rate))
+	bucket.full = now.Add(result.Reset)
+
+	return result, nil
+}
@@ -0,0 +1,127 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/config"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/pkg/route/middleware"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestRateLimit each api key has a bucket per class of routes, its own limits or the configured ones
+func TestRateLimit(t *testing.T) {
+	Convey("TestRateLimit", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockBuckets := mocks.NewMockTokenBuckets(ctl)
+		principal := &entities.Principal{
+			Account: "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23",
+			KeyID:   "ak_0123456789abcdef",
+			Scopes:  []string{entities.ScopeAdmin},
+		}
+
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+		serve := func(limiter middleware.RateLimiter, class string, principal *entities.Principal) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/v1/contacts", nil)
+			if principal != nil {
+				req = req.WithContext(middleware.WithPrincipal(req.Context(), principal))
+			}
+			w := httptest.NewRecorder()
+			limiter.Limit(next, class).ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("Normal Case1: the local buckets, the headers, then 429 once the bucket is empty", func() {
+			mockCfg.EXPECT().GetRateLimitConfig().Return(&config.RateLimitConfig{Mode: "local", Bulk: 2})
+			limiter := middleware.NewRateLimit(logger, mockCfg, mockBuckets)
+
+			for remaining := 1; remaining >= 0; remaining-- {
+				w := serve(limiter, entities.RateClassBulk, principal)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("X-RateLimit-Limit"), ShouldEqual, "2")
+				So(w.Header().Get("X-RateLimit-Remaining"), ShouldEqual, strconv.Itoa(remaining))
+				reset, _ := strconv.ParseInt(w.Header().Get("X-RateLimit-Reset"), 10, 64)
+				So(reset, ShouldBeBetween, time.Now().Unix(), time.Now().Add(time.Minute).Unix()+1)
+				So(w.Header().Get("Retry-After"), ShouldEqual, "")
+			}
+
+			w := serve(limiter, entities.RateClassBulk, principal)
+			So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+			So(w.Header().Get("X-RateLimit-Remaining"), ShouldEqual, "0")
+			So(w.Header().Get("Retry-After"), ShouldBeIn, []string{"29", "30"})
+			So(w.Body.String(), ShouldContainSubstring, "Rate limit of 2 bulk requests per minute exceeded.")
+
+			//the other classes and the other keys have their own buckets
+			So(serve(limiter, entities.RateClassRead, principal).Header().Get("X-RateLimit-Limit"), ShouldEqual, "600")
+			other := *principal
+			other.KeyID = "ak_fedcba9876543210"
+			So(serve(limiter, entities.RateClassBulk, &other).Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Normal Case2: the limit of the key wins over the configured one", func() {
+			mockCfg.EXPECT().GetRateLimitConfig().Return(&config.RateLimitConfig{Mode: "redis", Write: 100})
+			limiter := middleware.NewRateLimit(logger, mockCfg, mockBuckets)
+
+			mockBuckets.EXPECT().Take("ak_0123456789abcdef:write", 100, time.Minute).Return(&entities.RateLimit{Allowed: true, Limit: 100, Remaining: 99}, nil)
+			So(serve(limiter, entities.RateClassWrite, principal).Header().Get("X-RateLimit-Limit"), ShouldEqual, "100")
+
+			own := *principal
+			own.RateLimits = map[string]int{entities.RateClassWrite: 5}
+			mockBuckets.EXPECT().Take("ak_0123456789abcdef:write", 5, time.Minute).Return(&entities.RateLimit{Limit: 5, RetryAfter: 1500 * time.Millisecond, Reset: time.Minute}, nil)
+			w := serve(limiter, entities.RateClassWrite, &own)
+			So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+			So(w.Header().Get("Retry-After"), ShouldEqual, "2")
+		})
+
+		Convey("Normal Case3: no limit without an api key or a class, nor if the buckets fail", func() {
+			mockCfg.EXPECT().GetRateLimitConfig().Return(&config.RateLimitConfig{})
+			limiter := middleware.NewRateLimit(logger, mockCfg, mockBuckets)
+
+			So(serve(limiter, entities.RateClassRead, nil).Header().Get("X-RateLimit-Limit"), ShouldEqual, "")
+			So(serve(limiter, "", principal).Header().Get("X-RateLimit-Limit"), ShouldEqual, "")
+
+			mockBuckets.EXPECT().Take("ak_0123456789abcdef:read", 600, time.Minute).Return(nil, errors.New("redis down"))
+			w := serve(limiter, entities.RateClassRead, principal)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("X-RateLimit-Limit"), ShouldEqual, "")
+		})
+	})
+}
+
+// TestLocalBuckets the tokens come back over the period, never more than the limit
+func TestLocalBuckets(t *testing.T) {
+	Convey("TestLocalBuckets", t, func() {
+		buckets := middleware.NewLocalBuckets()
+		period := 100 * time.Millisecond
+
+		for i := 0; i < 2; i++ {
+			result, _ := buckets.Take("key", 2, period)
+			So(result.Allowed, ShouldBeTrue)
+		}
+		result, _ := buckets.Take("key", 2, period)
+		So(result.Allowed, ShouldBeFalse)
+		So(result.RetryAfter, ShouldBeBetween, 40*time.Millisecond, 51*time.Millisecond)
+		So(result.Reset, ShouldBeBetween, 90*time.Millisecond, 101*time.Millisecond)
+
+		time.Sleep(60 * time.Millisecond)
+		result, _ = buckets.Take("key", 2, period)
+		So(result.Allowed, ShouldBeTrue)
+		So(result.Remaining, ShouldEqual, 0)
+
+		time.Sleep(2 * period)
+		result, _ = buckets.Take("key", 2, period)
+		So(result.Allowed, ShouldBeTrue)
+		So(result.Remaining, ShouldEqual, 1)
+	})
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/config"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/sirupsen/logrus"
+)
+
+//usageKey the key of the metered request in its context, the handlers add to its usage
+const usageKey contextKey = "usage"
+
+//meteredRequest the usage of the request and what the quotas of its api key left in the day when it came,
+//by counter: no entry for the counters without a quota or once the counters could not be read
+type meteredRequest struct {
+	meter  *meter
+	usage  *entities.Usage
+	now    time.Time
+	quotas map[string]int64
+	left   map[string]int64
+}
+
+//Meter interface
+type Meter interface {
+	Meter(next http.Handler, class string) http.Handler
+}
+
+//UsageCounters the counters of the usage of the api keys by day, shared by the instances
+type UsageCounters interface {
+	IncrUsage(day string, usage *entities.Usage) error
+	GetUsage(day, account, keyID string) (*entities.Usage, error)
+}
+
+type meter struct {
+	log    *logrus.Logger
+	usages UsageCounters
+	quotas map[string]int64
+}
+
+//NewMeter the quotas of the config, none for the counters it has 0
+func NewMeter(log *logrus.Logger, cfg config.Config, usages UsageCounters) *meter {
+	usageCfg := cfg.GetUsageConfig()
+	quotas := map[string]int64{
+		entities.UsageWrites:   usageCfg.Writes,
+		entities.UsageBulkRows: usageCfg.BulkRows,
+		entities.UsageContacts: usageCfg.Contacts,
+	}
+	return &meter{log, usages, quotas}
+}
+
+//Meter count the request of the api key in the day, a write is rejected with 429 once a quota of the key is exceeded
+//and the handler reserves the weight of the request by ReserveUsage;
+//the requests without an api key are not counted, the writes are not rejected if the counters can not be read
+func (m *meter) Meter(next http.Handler, class string) http.Handler {
+	write := class == entities.RateClassWrite || class == entities.RateClassBulk
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := PrincipalFrom(r.Context())
+		if principal == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now := time.Now().UTC()
+		day := now.Format(entities.UsageDayLayout)
+		usage := &entities.Usage{KeyID: principal.KeyID, Account: principal.Account, Requests: 1}
+		defer func() {
+			if err := m.usages.IncrUsage(day, usage); err != nil {
+				warnCache(m.log, "Meter", err)
+			}
+		}()
+
+		metered := &meteredRequest{meter: m, usage: usage, now: now}
+		if write {
+			metered.quotas, metered.left = m.left(day, principal)
+			for _, counter := range entities.UsageCounters {
+				if left, ok := metered.left[counter]; ok && left <= 0 {
+					m.reject(w, now, counter, metered.quotas[counter])
+					return
+				}
+			}
+			usage.Writes = 1
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), usageKey, metered)))
+	})
+}
+
+//left the quotas of the api key and what they left in the day, by counter; empty if no counter has a quota
+func (m *meter) left(day string, principal *entities.Principal) (map[string]int64, map[string]int64) {
+	quotas := make(map[string]int64, len(m.quotas))
+	for _, counter := range entities.UsageCounters {
+		quota := principal.Quotas[counter]
+		if quota <= 0 {
+			quota = m.quotas[counter]
+		}
+		if quota > 0 {
+			quotas[counter] = quota
+		}
+	}
+	left := make(map[string]int64, len(quotas))
+	if len(quotas) == 0 {
+		return quotas, left
+	}
+
+	usage, err := m.usages.GetUsage(day, principal.Account, principal.KeyID)
+	if err != nil {
+		warnCache(m.log, "Meter", err)
+		return quotas, left
+	}
+	for counter, quota := range quotas {
+		left[counter] = quota - usage.Counter(counter)
+	}
+	return quotas, left
+}
+
+//reject answer 429 until the next day for the quota of the counter
+func (m *meter) reject(w http.ResponseWriter, now time.Time, counter string, quota int64) {
+	tomorrow := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	w.Header().Set("Retry-After", strconv.Itoa(int(tomorrow.Sub(now).Seconds())+1))
+	w.WriteHeader(http.StatusTooManyRequests)
+	err := json.NewEncoder(w).Encode(map[string]string{"error": "Too Many Requests", "message": "Quota of " + strconv.FormatInt(quota, 10) + " " + counter + " per day exceeded."})
+	if err != nil {
+		m.log.Error("Meter: json encode error")
+	}
+}
+
+//ReserveUsage take the bulk rows and the new contacts the request may add at most from what the quotas of the api key left,
+//before the handler writes anything: false once it answered 429, the request is over a quota. True if it is not metered
+func ReserveUsage(w http.ResponseWriter, r *http.Request, bulkRows, contacts int64) bool {
+	metered, ok := r.Context().Value(usageKey).(*meteredRequest)
+	if !ok {
+		return true
+	}
+	weights := map[string]int64{entities.UsageBulkRows: bulkRows, entities.UsageContacts: contacts}
+	for _, counter := range entities.UsageCounters {
+		if left, ok := metered.left[counter]; ok && weights[counter] > left {
+			metered.meter.reject(w, metered.now, counter, metered.quotas[counter])
+			return false
+		}
+	}
+	for counter, weight := range weights {
+		if _, ok := metered.left[counter]; ok {
+			metered.left[counter] -= weight
+		}
+	}
+	return true
+}
+
+//AddUsage count the bulk rows and the new contacts of the request, nothing if it is not metered
+func AddUsage(ctx context.Context, bulkRows, contacts int64) {
+	if metered, ok := ctx.Value(usageKey).(*meteredRequest); ok {
+		metered.usage.BulkRows += bulkRows
+		metered.usage.Contacts += contacts
+	}
+}
@@ -0,0 +1,123 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/config"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/pkg/route/middleware"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestMeter each request of an api key is counted in its day, the writes are rejected once a quota is exceeded
+func TestMeter(t *testing.T) {
+	Convey("TestMeter", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockUsages := mocks.NewMockUsageCounters(ctl)
+		principal := &entities.Principal{
+			Account: "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23",
+			KeyID:   "ak_0123456789abcdef",
+			Scopes:  []string{entities.ScopeAdmin},
+		}
+		day := time.Now().UTC().Format(entities.UsageDayLayout)
+
+		served := false
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served = true
+			middleware.AddUsage(r.Context(), 3, 2)
+		})
+		serve := func(meter middleware.Meter, class string, principal *entities.Principal) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("POST", "/v1/contacts", nil)
+			if principal != nil {
+				req = req.WithContext(middleware.WithPrincipal(req.Context(), principal))
+			}
+			w := httptest.NewRecorder()
+			meter.Meter(next, class).ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("Normal Case1: no quota, the request, the write and what the handler adds are counted", func() {
+			mockCfg.EXPECT().GetUsageConfig().Return(&config.UsageConfig{})
+			meter := middleware.NewMeter(logger, mockCfg, mockUsages)
+
+			mockUsages.EXPECT().IncrUsage(day, &entities.Usage{KeyID: principal.KeyID, Account: principal.Account, Requests: 1, Writes: 1, BulkRows: 3, Contacts: 2}).Return(nil)
+			So(serve(meter, entities.RateClassBulk, principal).Code, ShouldEqual, http.StatusOK)
+
+			mockUsages.EXPECT().IncrUsage(day, &entities.Usage{KeyID: principal.KeyID, Account: principal.Account, Requests: 1, BulkRows: 3, Contacts: 2}).Return(errors.New("redis down"))
+			So(serve(meter, entities.RateClassRead, principal).Code, ShouldEqual, http.StatusOK)
+
+			//the requests without an api key are not counted
+			So(serve(meter, entities.RateClassWrite, nil).Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Normal Case2: a write is rejected once a quota is reached, a read is not", func() {
+			mockCfg.EXPECT().GetUsageConfig().Return(&config.UsageConfig{Writes: 100, Contacts: 50})
+			meter := middleware.NewMeter(logger, mockCfg, mockUsages)
+
+			mockUsages.EXPECT().GetUsage(day, principal.Account, principal.KeyID).Return(&entities.Usage{Writes: 10, Contacts: 50}, nil)
+			mockUsages.EXPECT().IncrUsage(day, &entities.Usage{KeyID: principal.KeyID, Account: principal.Account, Requests: 1}).Return(nil)
+			w := serve(meter, entities.RateClassWrite, principal)
+			So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+			So(w.Body.String(), ShouldContainSubstring, "Quota of 50 contacts per day exceeded.")
+			So(w.Header().Get("Retry-After"), ShouldNotEqual, "")
+			So(served, ShouldBeFalse)
+
+			mockUsages.EXPECT().IncrUsage(day, gomock.Any()).Return(nil)
+			So(serve(meter, entities.RateClassRead, principal).Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Normal Case3: the quota of the key wins over the configured one, a failure to read the counters lets the write go", func() {
+			mockCfg.EXPECT().GetUsageConfig().Return(&config.UsageConfig{BulkRows: 10})
+			meter := middleware.NewMeter(logger, mockCfg, mockUsages)
+			own := *principal
+			own.Quotas = map[string]int64{entities.UsageBulkRows: 1000}
+
+			mockUsages.EXPECT().GetUsage(day, principal.Account, principal.KeyID).Return(&entities.Usage{BulkRows: 500}, nil)
+			mockUsages.EXPECT().IncrUsage(day, gomock.Any()).Return(nil).Times(2)
+			So(serve(meter, entities.RateClassBulk, &own).Code, ShouldEqual, http.StatusOK)
+
+			mockUsages.EXPECT().GetUsage(day, principal.Account, principal.KeyID).Return(nil, errors.New("redis down"))
+			So(serve(meter, entities.RateClassBulk, principal).Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Normal Case4: the handler reserves the whole weight of a bulk request before it writes", func() {
+			mockCfg.EXPECT().GetUsageConfig().Return(&config.UsageConfig{BulkRows: 100})
+			meter := middleware.NewMeter(logger, mockCfg, mockUsages)
+			reserve := func(rows int64) *httptest.ResponseRecorder {
+				served = false
+				req := httptest.NewRequest("POST", "/v1/contacts/bulk", nil)
+				req = req.WithContext(middleware.WithPrincipal(req.Context(), principal))
+				w := httptest.NewRecorder()
+				meter.Meter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if middleware.ReserveUsage(w, r, rows, rows) {
+						served = true
+						middleware.AddUsage(r.Context(), rows, 0)
+					}
+				}), entities.RateClassBulk).ServeHTTP(w, req)
+				return w
+			}
+
+			mockUsages.EXPECT().GetUsage(day, principal.Account, principal.KeyID).Return(&entities.Usage{BulkRows: 90}, nil)
+			mockUsages.EXPECT().IncrUsage(day, &entities.Usage{KeyID: principal.KeyID, Account: principal.Account, Requests: 1, Writes: 1}).Return(nil)
+			w := reserve(11)
+			So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+			So(w.Body.String(), ShouldContainSubstring, "Quota of 100 bulk_rows per day exceeded.")
+			So(served, ShouldBeFalse)
+
+			mockUsages.EXPECT().GetUsage(day, principal.Account, principal.KeyID).Return(&entities.Usage{BulkRows: 90}, nil)
+			mockUsages.EXPECT().IncrUsage(day, &entities.Usage{KeyID: principal.KeyID, Account: principal.Account, Requests: 1, Writes: 1, BulkRows: 10}).Return(nil)
+			So(reserve(10).Code, ShouldEqual, http.StatusOK)
+			So(served, ShouldBeTrue)
+		})
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/route/middleware"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
@@ -13,6 +15,34 @@ import (
 type ContactController interface {
 	GetOneContactCtrl(w http.ResponseWriter, r *http.Request)
 	AddOrUpdateContactCtrl(w http.ResponseWriter, r *http.Request)
+	GetAllContactsCtrl(w http.ResponseWriter, r *http.Request)
+	GetAllContactsBookmarkCtrl(w http.ResponseWriter, r *http.Request)
+	AddBulkContactsCtrl(w http.ResponseWriter, r *http.Request)
+	DeleteContactCtrl(w http.ResponseWriter, r *http.Request)
+	PatchContactCtrl(w http.ResponseWriter, r *http.Request)
+	UnsubscribeContactCtrl(w http.ResponseWriter, r *http.Request)
+	ResubscribeContactCtrl(w http.ResponseWriter, r *http.Request)
+	GetCustomFieldsCtrl(w http.ResponseWriter, r *http.Request)
+	GetListsCtrl(w http.ResponseWriter, r *http.Request)
+	AddListCtrl(w http.ResponseWriter, r *http.Request)
+	GetListContactsCtrl(w http.ResponseWriter, r *http.Request)
+	GetListContactsBookmarkCtrl(w http.ResponseWriter, r *http.Request)
+	AddContactToListCtrl(w http.ResponseWriter, r *http.Request)
+	RemoveContactFromListCtrl(w http.ResponseWriter, r *http.Request)
+	CheckContactInListCtrl(w http.ResponseWriter, r *http.Request)
+	GetSegmentsCtrl(w http.ResponseWriter, r *http.Request)
+	AddSegmentCtrl(w http.ResponseWriter, r *http.Request)
+	CountSegmentContactsCtrl(w http.ResponseWriter, r *http.Request)
+	GetSegmentContactsCtrl(w http.ResponseWriter, r *http.Request)
+	GetSegmentContactsBookmarkCtrl(w http.ResponseWriter, r *http.Request)
+	GetReencryptionCtrl(w http.ResponseWriter, r *http.Request)
+	EraseContactCtrl(w http.ResponseWriter, r *http.Request)
+	GetAPIKeysCtrl(w http.ResponseWriter, r *http.Request)
+	CreateAPIKeyCtrl(w http.ResponseWriter, r *http.Request)
+	RotateAPIKeyCtrl(w http.ResponseWriter, r *http.Request)
+	RevokeAPIKeyCtrl(w http.ResponseWriter, r *http.Request)
+	GetUsageCtrl(w http.ResponseWriter, r *http.Request)
+	HealthCtrl(w http.ResponseWriter, r *http.Request)
 }
 
 type routeFrame struct {
@@ -20,13 +50,18 @@ type routeFrame struct {
 	Method      string
 	Pattern     string
 	Queries     []string
+	Scope       string
+	RateClass   string
 	HandlerFunc http.HandlerFunc
 }
 
 type routeLst []routeFrame
 
-//NewRouter register the routeFrame and handler
-func NewRouter(cc ContactController) *mux.Router {
+//healthPath the health of the instance, for the load balancers: no api key, no scope, no rate limit
+const healthPath = "/health"
+
+//NewRouter register the routeFrame and handler, the requests of each route are limited by its class then metered
+func NewRouter(cc ContactController, limiter middleware.RateLimiter, meter middleware.Meter) *mux.Router {
 	var routes = routeLst{
 		routeFrame{
 			"GetOneContactCtrl",
@@ -34,6 +69,8 @@ func NewRouter(cc ContactController) *mux.Router {
 			//"GET", 127.0.0.1:8080/v1/contact/contact_id_or_email
 			"/v1/contact/{contact_id_or_email}",
 			[]string{},
+			entities.ScopeContactsRead,
+			entities.RateClassRead,
 			cc.GetOneContactCtrl,
 		},
 		routeFrame{
@@ -42,39 +79,69 @@ func NewRouter(cc ContactController) *mux.Router {
 			//127.0.0.1:8080/v1/contact
 			"/v1/contact",
 			[]string{},
+			entities.ScopeContactsWrite,
+			entities.RateClassWrite,
 			cc.AddOrUpdateContactCtrl,
 		},
-		/*routeFrame{
+		routeFrame{
 			"GetAllContactsCtrl",
 			strings.ToUpper("Get"),
 			//"GET", 127.0.0.1:8080/v1/contacts
 			"/v1/contacts",
 			[]string{},
-			controller.GetAllContactsCtrl,
+			entities.ScopeContactsRead,
+			entities.RateClassRead,
+			cc.GetAllContactsCtrl,
+		},
+		routeFrame{
+			"GetCustomFieldsCtrl",
+			strings.ToUpper("Get"),
+			//"GET", 127.0.0.1:8080/v1/contacts/custom_fields, before the bookmark route
+			"/v1/contacts/custom_fields",
+			[]string{},
+			entities.ScopeContactsRead,
+			entities.RateClassRead,
+			cc.GetCustomFieldsCtrl,
 		},
 		routeFrame{
 			"GetAllContactsBookmarkCtrl",
 			strings.ToUpper("Get"),
 			//"GET", 127.0.0.1:8080/v1/contacts/bookmark
-			"/v1/contacts/bookmark",
+			"/v1/contacts/{bookmark}",
 			[]string{},
-			controller.GetAllContactsBookmarkCtrl,
+			entities.ScopeContactsRead,
+			entities.RateClassRead,
+			cc.GetAllContactsBookmarkCtrl,
 		},
 		routeFrame{
 			"AddBulkContactsCtrl",
 			strings.ToUpper("Post"),
-			//127.0.0.1:8080/v1/contact
+			//127.0.0.1:8080/v1/contacts
 			"/v1/contacts",
 			[]string{},
-			controller.AddBulkContactsCtrl,
+			entities.ScopeContactsWrite,
+			entities.RateClassBulk,
+			cc.AddBulkContactsCtrl,
 		},
 		routeFrame{
 			"DeleteContactCtrl",
 			strings.ToUpper("Delete"),
-			//127.0.0.1:8080/v1/contact
+			//127.0.0.1:8080/v1/contact/contact_id_or_email
+			"/v1/contact/{contact_id_or_email}",
+			[]string{},
+			entities.ScopeContactsDelete,
+			entities.RateClassWrite,
+			cc.DeleteContactCtrl,
+		},
+		routeFrame{
+			"PatchContactCtrl",
+			strings.ToUpper("Patch"),
+			//127.0.0.1:8080/v1/contact/contact_id_or_email
 			"/v1/contact/{contact_id_or_email}",
 			[]string{},
-			controller.DeleteContactCtrl,
+			entities.ScopeContactsWrite,
+			entities.RateClassWrite,
+			cc.PatchContactCtrl,
 		},
 		routeFrame{
 			"UnsubscribeContactCtrl",
@@ -82,8 +149,210 @@ func NewRouter(cc ContactController) *mux.Router {
 			//127.0.0.1:8080/v1/contact/contact_id_or_email/unsubscribe
 			"/v1/contact/{contact_id_or_email}/unsubscribe",
 			[]string{},
-			controller.UnsubscribeContactCtrl,
-		},*/
+			entities.ScopeContactsWrite,
+			entities.RateClassWrite,
+			cc.UnsubscribeContactCtrl,
+		},
+		routeFrame{
+			"ResubscribeContactCtrl",
+			strings.ToUpper("Post"),
+			//127.0.0.1:8080/v1/contact/contact_id_or_email/resubscribe
+			"/v1/contact/{contact_id_or_email}/resubscribe",
+			[]string{},
+			entities.ScopeContactsWrite,
+			entities.RateClassWrite,
+			cc.ResubscribeContactCtrl,
+		},
+		routeFrame{
+			"EraseContactCtrl",
+			strings.ToUpper("Post"),
+			//127.0.0.1:8080/v1/contact/contact_id_or_email/erase
+			"/v1/contact/{contact_id_or_email}/erase",
+			[]string{},
+			entities.ScopeContactsDelete,
+			entities.RateClassWrite,
+			cc.EraseContactCtrl,
+		},
+		routeFrame{
+			"GetListsCtrl",
+			strings.ToUpper("Get"),
+			//"GET", 127.0.0.1:8080/v1/lists
+			"/v1/lists",
+			[]string{},
+			entities.ScopeListsRead,
+			entities.RateClassRead,
+			cc.GetListsCtrl,
+		},
+		routeFrame{
+			"AddListCtrl",
+			strings.ToUpper("Post"),
+			//127.0.0.1:8080/v1/lists
+			"/v1/lists",
+			[]string{},
+			entities.ScopeListsWrite,
+			entities.RateClassWrite,
+			cc.AddListCtrl,
+		},
+		routeFrame{
+			"GetListContactsCtrl",
+			strings.ToUpper("Get"),
+			//"GET", 127.0.0.1:8080/v1/list/list_id/contacts
+			"/v1/list/{list_id}/contacts",
+			[]string{},
+			entities.ScopeListsRead,
+			entities.RateClassRead,
+			cc.GetListContactsCtrl,
+		},
+		routeFrame{
+			"GetListContactsBookmarkCtrl",
+			strings.ToUpper("Get"),
+			//"GET", 127.0.0.1:8080/v1/list/list_id/contacts/bookmark
+			"/v1/list/{list_id}/contacts/{bookmark}",
+			[]string{},
+			entities.ScopeListsRead,
+			entities.RateClassRead,
+			cc.GetListContactsBookmarkCtrl,
+		},
+		routeFrame{
+			"AddContactToListCtrl",
+			strings.ToUpper("Post"),
+			//127.0.0.1:8080/v1/list/list_id/contact/contact_id_or_email
+			"/v1/list/{list_id}/contact/{contact_id_or_email}",
+			[]string{},
+			entities.ScopeListsWrite,
+			entities.RateClassWrite,
+			cc.AddContactToListCtrl,
+		},
+		routeFrame{
+			"RemoveContactFromListCtrl",
+			strings.ToUpper("Delete"),
+			//127.0.0.1:8080/v1/list/list_id/contact/contact_id_or_email
+			"/v1/list/{list_id}/contact/{contact_id_or_email}",
+			[]string{},
+			entities.ScopeListsWrite,
+			entities.RateClassWrite,
+			cc.RemoveContactFromListCtrl,
+		},
+		routeFrame{
+			"CheckContactInListCtrl",
+			strings.ToUpper("Get"),
+			//"GET", 127.0.0.1:8080/v1/list/list_id/contact/contact_id_or_email
+			"/v1/list/{list_id}/contact/{contact_id_or_email}",
+			[]string{},
+			entities.ScopeListsRead,
+			entities.RateClassRead,
+			cc.CheckContactInListCtrl,
+		},
+		routeFrame{
+			"GetSegmentsCtrl",
+			strings.ToUpper("Get"),
+			//"GET", 127.0.0.1:8080/v1/segments
+			"/v1/segments",
+			[]string{},
+			entities.ScopeSegmentsRead,
+			entities.RateClassRead,
+			cc.GetSegmentsCtrl,
+		},
+		routeFrame{
+			"AddSegmentCtrl",
+			strings.ToUpper("Post"),
+			//127.0.0.1:8080/v1/segments
+			"/v1/segments",
+			[]string{},
+			entities.ScopeSegmentsWrite,
+			entities.RateClassWrite,
+			cc.AddSegmentCtrl,
+		},
+		routeFrame{
+			"CountSegmentContactsCtrl",
+			strings.ToUpper("Get"),
+			//"GET", 127.0.0.1:8080/v1/segment/segment_id/count
+			"/v1/segment/{segment_id}/count",
+			[]string{},
+			entities.ScopeSegmentsRead,
+			entities.RateClassRead,
+			cc.CountSegmentContactsCtrl,
+		},
+		routeFrame{
+			"GetSegmentContactsCtrl",
+			strings.ToUpper("Get"),
+			//"GET", 127.0.0.1:8080/v1/segment/segment_id/contacts
+			"/v1/segment/{segment_id}/contacts",
+			[]string{},
+			entities.ScopeSegmentsRead,
+			entities.RateClassRead,
+			cc.GetSegmentContactsCtrl,
+		},
+		routeFrame{
+			"GetSegmentContactsBookmarkCtrl",
+			strings.ToUpper("Get"),
+			//"GET", 127.0.0.1:8080/v1/segment/segment_id/contacts/bookmark
+			"/v1/segment/{segment_id}/contacts/{bookmark}",
+			[]string{},
+			entities.ScopeSegmentsRead,
+			entities.RateClassRead,
+			cc.GetSegmentContactsBookmarkCtrl,
+		},
+		routeFrame{
+			"GetReencryptionCtrl",
+			strings.ToUpper("Get"),
+			//"GET", 127.0.0.1:8080/v1/admin/reencryption
+			"/v1/admin/reencryption",
+			[]string{},
+			entities.ScopeAdmin,
+			entities.RateClassRead,
+			cc.GetReencryptionCtrl,
+		},
+		routeFrame{
+			"GetAPIKeysCtrl",
+			strings.ToUpper("Get"),
+			//"GET", 127.0.0.1:8080/v1/admin/apikeys
+			"/v1/admin/apikeys",
+			[]string{},
+			entities.ScopeAdmin,
+			entities.RateClassRead,
+			cc.GetAPIKeysCtrl,
+		},
+		routeFrame{
+			"CreateAPIKeyCtrl",
+			strings.ToUpper("Post"),
+			//127.0.0.1:8080/v1/admin/apikeys
+			"/v1/admin/apikeys",
+			[]string{},
+			entities.ScopeAdmin,
+			entities.RateClassWrite,
+			cc.CreateAPIKeyCtrl,
+		},
+		routeFrame{
+			"RotateAPIKeyCtrl",
+			strings.ToUpper("Post"),
+			//127.0.0.1:8080/v1/admin/apikey/key_id/rotate
+			"/v1/admin/apikey/{key_id}/rotate",
+			[]string{},
+			entities.ScopeAdmin,
+			entities.RateClassWrite,
+			cc.RotateAPIKeyCtrl,
+		},
+		routeFrame{
+			"RevokeAPIKeyCtrl",
+			strings.ToUpper("Delete"),
+			//127.0.0.1:8080/v1/admin/apikey/key_id
+			"/v1/admin/apikey/{key_id}",
+			[]string{},
+			entities.ScopeAdmin,
+			entities.RateClassWrite,
+			cc.RevokeAPIKeyCtrl,
+		},
+		routeFrame{
+			"GetUsageCtrl",
+			strings.ToUpper("Get"),
+			//"GET", 127.0.0.1:8080/v1/admin/usage?from=2020-05-01&to=2020-05-31&key=key_id
+			"/v1/admin/usage",
+			[]string{},
+			entities.ScopeAdmin,
+			entities.RateClassRead,
+			cc.GetUsageCtrl,
+		},
 	}
 
 	router := mux.NewRouter().StrictSlash(true)
@@ -91,6 +360,9 @@ func NewRouter(cc ContactController) *mux.Router {
 		var handler http.Handler
 
 		handler = route.HandlerFunc
+		handler = middleware.RequireScope(handler, route.Scope)
+		handler = meter.Meter(handler, route.RateClass)
+		handler = limiter.Limit(handler, route.RateClass)
 		handler = logger(handler, route.Name)
 		router.
 			Methods(route.Method).
@@ -99,6 +371,7 @@ func NewRouter(cc ContactController) *mux.Router {
 			Name(route.Name).
 			Handler(handler)
 	}
+	router.Methods(http.MethodGet).Path(healthPath).Name("HealthCtrl").Handler(logger(http.HandlerFunc(cc.HealthCtrl), "HealthCtrl"))
 	return router
 }
 
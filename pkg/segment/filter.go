@@ -0,0 +1,438 @@
+package segment
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/customfield"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+//Filter a parsed expression: the query mongo can run and the part left to match in the process
+type Filter struct {
+	query    bson.M
+	residual node
+}
+
+//Parse parse the expression, customTypes are the types of the custom fields registered in the account, name->type,
+//encrypted are the json names of the fields and the custom.Name of the custom fields stored encrypted, besides entities.EncryptedLookupFields,
+//custom.* for every custom field
+func Parse(expr string, customTypes map[string]string, encrypted ...string) (*Filter, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	if tokens[0].kind == tokenEOF {
+		return nil, &Error{0, "empty expression"}
+	}
+
+	resolver := fieldResolver{customTypes: customTypes, encrypted: make(map[string]bool)}
+	for _, name := range append(append([]string{}, entities.EncryptedLookupFields...), encrypted...) {
+		resolver.encrypted[name] = true
+	}
+	p := &parser{tokens: tokens, fields: resolver}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &Error{t.pos, fmt.Sprintf("unexpected %q", t.text)}
+	}
+
+	query, residual := root.split()
+	return &Filter{query: query, residual: residual}, nil
+}
+
+//Query the mongo query of the contacts may match, all of them if nothing can be pushed down
+func (f *Filter) Query() bson.M {
+	if f.query == nil {
+		return bson.M{}
+	}
+	return f.query
+}
+
+//Exact the contacts of the query are exactly the members, nothing is left to match in the process
+func (f *Filter) Exact() bool {
+	return f.residual == nil
+}
+
+//Match the contact of the query is a member, by the part mongo can not evaluate, like the encrypted fields
+func (f *Filter) Match(contact *entities.Contact) bool {
+	return f.residual == nil || f.residual.match(contact)
+}
+
+type node interface {
+	//split the query of a superset of the matches, nil if there is none, and the part left to match, nil if none
+	split() (bson.M, node)
+	match(contact *entities.Contact) bool
+}
+
+type andNode []node
+
+func (n andNode) split() (bson.M, node) {
+	var queries []interface{}
+	var rest andNode
+	for _, child := range n {
+		query, residual := child.split()
+		if query != nil {
+			queries = append(queries, query)
+		}
+		if residual != nil {
+			rest = append(rest, residual)
+		}
+	}
+
+	var query bson.M
+	switch len(queries) {
+	case 0:
+	case 1:
+		query = queries[0].(bson.M)
+	default:
+		query = bson.M{"$and": queries}
+	}
+	switch len(rest) {
+	case 0:
+		return query, nil
+	case 1:
+		return query, rest[0]
+	default:
+		return query, rest
+	}
+}
+
+func (n andNode) match(contact *entities.Contact) bool {
+	for _, child := range n {
+		if !child.match(contact) {
+			return false
+		}
+	}
+	return true
+}
+
+type orNode []node
+
+//split the or of the queries of the children is a superset if every child has one, the whole or is left to match if any child has a residual
+func (n orNode) split() (bson.M, node) {
+	queries := make([]interface{}, 0, len(n))
+	exact := true
+	for _, child := range n {
+		query, residual := child.split()
+		if query == nil {
+			return nil, n
+		}
+		queries = append(queries, query)
+		exact = exact && residual == nil
+	}
+
+	if exact {
+		return bson.M{"$or": queries}, nil
+	}
+	return bson.M{"$or": queries}, n
+}
+
+func (n orNode) match(contact *entities.Contact) bool {
+	for _, child := range n {
+		if child.match(contact) {
+			return true
+		}
+	}
+	return false
+}
+
+type notNode struct {
+	inner node
+}
+
+func (n notNode) split() (bson.M, node) {
+	query, residual := n.inner.split()
+	if query == nil || residual != nil {
+		return nil, n
+	}
+	return bson.M{"$nor": []interface{}{query}}, nil
+}
+
+func (n notNode) match(contact *entities.Contact) bool {
+	return !n.inner.match(contact)
+}
+
+type condNode struct {
+	field *field
+	op    string
+	value interface{}
+}
+
+//the mongo operators of the comparisons
+var mongoOperators = map[string]string{"!=": "$ne", ">": "$gt", ">=": "$gte", "<": "$lt", "<=": "$lte"}
+
+func (n *condNode) split() (bson.M, node) {
+	if !n.field.pushable() {
+		return nil, n
+	}
+
+	if n.op == "=" {
+		return bson.M{n.field.key: n.value}, nil
+	}
+	return bson.M{n.field.key: bson.M{mongoOperators[n.op]: n.value}}, nil
+}
+
+//match like mongo: a missing field or a value of another type only matches !=, an array matches if any item does
+func (n *condNode) match(contact *entities.Contact) bool {
+	value, ok := n.field.get(contact)
+	if !ok {
+		return n.op == "!="
+	}
+
+	if items, ok := arrayItems(value); ok {
+		contains := false
+		for _, item := range items {
+			if c, ok := compare(item, n.value); ok && c == 0 {
+				contains = true
+				break
+			}
+		}
+		return contains == (n.op == "=")
+	}
+
+	c, ok := compare(value, n.value)
+	if !ok {
+		return n.op == "!="
+	}
+	switch n.op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	default:
+		return c <= 0
+	}
+}
+
+func arrayItems(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case []string:
+		items := make([]interface{}, len(v))
+		for i := range v {
+			items[i] = v[i]
+		}
+		return items, true
+	}
+	return nil, false
+}
+
+//compare -1, 0 or 1, false if the values can not be compared
+func compare(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			if a == b {
+				return 0, true
+			}
+			return 1, true
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			switch {
+			case a.Before(b):
+				return -1, true
+			case a.After(b):
+				return 1, true
+			}
+			return 0, true
+		}
+	default:
+		x, okA := toFloat(a)
+		y, okB := toFloat(b)
+		if okA && okB {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	return 0, false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindBool
+	kindTime
+	kindList
+	kindCustom
+)
+
+//timeLayouts the layouts of the values of the time fields
+var timeLayouts = []string{time.RFC3339Nano, customfield.DateLayout, "2006-01-02"}
+
+type field struct {
+	kind fieldKind
+	//key the key in mongo
+	key string
+	//index the index in entities.Contact, for the fields except the custom ones
+	index int
+	//custom the name of the custom field and its type
+	custom     string
+	customType string
+	encrypted  bool
+}
+
+//pushable mongo can compare the field
+func (f *field) pushable() bool {
+	if f.encrypted {
+		return false
+	}
+	if f.kind == kindCustom {
+		//a dot in the name would be a path in mongo
+		return !strings.Contains(f.custom, ".") && !strings.HasPrefix(f.custom, "$")
+	}
+	return true
+}
+
+//allows the operator can compare the field
+func (f *field) allows(op string) bool {
+	equality := op == "=" || op == "!="
+	switch f.kind {
+	case kindBool, kindList:
+		return equality
+	case kindCustom:
+		switch f.customType {
+		case customfield.TypeBoolean, customfield.TypeArray:
+			return equality
+		case customfield.TypeCurrency:
+			return false
+		}
+	}
+	return true
+}
+
+//value the value of the token in the go type of the field
+func (f *field) value(t token) (interface{}, error) {
+	switch f.kind {
+	case kindBool:
+		b, err := strconv.ParseBool(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("expected true or false")
+		}
+		return b, nil
+	case kindTime:
+		for _, layout := range timeLayouts {
+			if tm, err := time.ParseInLocation(layout, t.text, time.UTC); err == nil {
+				return tm.UTC(), nil
+			}
+		}
+		return nil, fmt.Errorf("expected a time like 2006-01-02 or RFC 3339")
+	case kindCustom:
+		if f.customType == customfield.TypeArray {
+			//compared with the items, json numbers are float64
+			if t.kind == tokenNumber {
+				return strconv.ParseFloat(t.text, 64)
+			}
+			if t.kind == tokenIdent {
+				if b, err := strconv.ParseBool(t.text); err == nil {
+					return b, nil
+				}
+			}
+			return t.text, nil
+		}
+		v, err := customfield.DecodeValue(f.customType, t.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value: %s", f.customType, err.Error())
+		}
+		return v, nil
+	default:
+		return t.text, nil
+	}
+}
+
+//get the value of the field of the contact, false if the contact has no such custom field
+func (f *field) get(contact *entities.Contact) (interface{}, bool) {
+	if f.kind == kindCustom {
+		v, ok := contact.Custom[f.custom]
+		return v, ok && v != nil
+	}
+	return reflect.ValueOf(contact).Elem().Field(f.index).Interface(), true
+}
+
+type fieldResolver struct {
+	customTypes map[string]string
+	//encrypted the fields stored encrypted, mongo can not compare them
+	encrypted map[string]bool
+}
+
+var contactType = reflect.TypeOf(entities.Contact{})
+
+//resolve the field of the name, the json name of entities.Contact case insensitive, or custom.Name
+func (r fieldResolver) resolve(name string) (*field, error) {
+	if strings.HasPrefix(name, CustomPrefix) {
+		custom := strings.TrimPrefix(name, CustomPrefix)
+		customType, ok := r.customTypes[custom]
+		if !ok {
+			return nil, fmt.Errorf("unknown custom field %q", custom)
+		}
+		return &field{kind: kindCustom, key: "custom." + custom, custom: custom, customType: customType, encrypted: r.encrypted[name] || r.encrypted[CustomPrefix+entities.AllCustomFields]}, nil
+	}
+
+	for i := 0; i < contactType.NumField(); i++ {
+		sf := contactType.Field(i)
+		jsonName := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if jsonName == "" || jsonName == "-" || !strings.EqualFold(jsonName, name) {
+			continue
+		}
+
+		f := &field{index: i, encrypted: r.encrypted[jsonName]}
+		f.key = strings.Split(sf.Tag.Get("bson"), ",")[0]
+		if f.key == "" {
+			f.key = strings.ToLower(sf.Name)
+		}
+		switch sf.Type {
+		case reflect.TypeOf(""):
+			f.kind = kindString
+		case reflect.TypeOf(false):
+			f.kind = kindBool
+		case reflect.TypeOf(time.Time{}):
+			f.kind = kindTime
+		case reflect.TypeOf([]string{}):
+			f.kind = kindList
+		default:
+			return nil, fmt.Errorf("field %q can not be compared", name)
+		}
+		return f, nil
+	}
+
+	return nil, fmt.Errorf("unknown field %q", name)
+}
@@ -0,0 +1,254 @@
+/*
+Package segment implements the filter expressions of the smart segments.
+
+An expression compares the fields of the contacts to values, joined by AND, OR, NOT and parentheses:
+
+	Status=Lead AND MailingCountry=US AND custom.Score>50
+	(lists=contactlist_9EAF39E4 OR unsubscribed=true) AND NOT custom."Test Field"="x"
+
+The fields are the json names of entities.Contact and the custom fields as custom.Name,
+the values are bare words, numbers or double-quoted strings.
+*/
+package segment
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//Error the expression can not be parsed, Pos is the byte offset in the expression
+type Error struct {
+	Pos     int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Pos)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOp
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+//the comparison operators, the longer ones first
+var operators = []string{"!=", ">=", "<=", "=", ">", "<"}
+
+//CustomPrefix the prefix of the custom fields, custom.Name or custom."Name with spaces"
+const CustomPrefix = "custom."
+
+func lex(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenRParen, ")", i})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(expr) && expr[end] != '"' {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, &Error{i, "unterminated string"}
+			}
+			text, err := strconv.Unquote(expr[i : end+1])
+			if err != nil {
+				return nil, &Error{i, "invalid string"}
+			}
+			tokens = append(tokens, token{tokenString, text, i})
+			i = end + 1
+		case strings.ContainsRune("!=<>", rune(c)):
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(expr[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, &Error{i, "invalid operator"}
+			}
+			tokens = append(tokens, token{tokenOp, op, i})
+			i += len(op)
+		case isWordByte(c):
+			end := i
+			for end < len(expr) && isWordByte(expr[end]) {
+				end++
+			}
+			kind := tokenIdent
+			if c == '-' || c >= '0' && c <= '9' {
+				if _, err := strconv.ParseFloat(expr[i:end], 64); err == nil {
+					kind = tokenNumber
+				}
+			}
+			tokens = append(tokens, token{kind, expr[i:end], i})
+			i = end
+		default:
+			return nil, &Error{i, fmt.Sprintf("unexpected %q", c)}
+		}
+	}
+
+	return append(tokens, token{tokenEOF, "", len(expr)}), nil
+}
+
+//isWordByte the bytes of the bare words: the field names and the values without quotes
+func isWordByte(c byte) bool {
+	return c == '_' || c == '.' || c == '-' || c == '+' || c == '@' || c == ':' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	fields fieldResolver
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+//keyword the current token is the keyword, case insensitive
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && strings.EqualFold(t.text, word)
+}
+
+//parseOr or := and ("OR" and)*
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []node{left}
+	for p.keyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+	if len(nodes) == 1 {
+		return left, nil
+	}
+
+	return orNode(nodes), nil
+}
+
+//parseAnd and := unary ("AND" unary)*
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []node{left}
+	for p.keyword("AND") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+	if len(nodes) == 1 {
+		return left, nil
+	}
+
+	return andNode(nodes), nil
+}
+
+//parseUnary unary := "NOT" unary | "(" or ")" | condition
+func (p *parser) parseUnary() (node, error) {
+	if p.keyword("NOT") {
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+
+	if p.peek().kind == tokenLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokenRParen {
+			return nil, &Error{t.pos, "expected )"}
+		}
+		return inner, nil
+	}
+
+	return p.parseCondition()
+}
+
+//parseCondition condition := field operator value
+func (p *parser) parseCondition() (node, error) {
+	t := p.next()
+	if t.kind != tokenIdent {
+		return nil, &Error{t.pos, "expected a field"}
+	}
+	name := t.text
+	//custom."Name with spaces"
+	if name == CustomPrefix && p.peek().kind == tokenString {
+		name += p.next().text
+	}
+	f, err := p.fields.resolve(name)
+	if err != nil {
+		return nil, &Error{t.pos, err.Error()}
+	}
+
+	opToken := p.next()
+	if opToken.kind != tokenOp {
+		return nil, &Error{opToken.pos, "expected an operator"}
+	}
+	if !f.allows(opToken.text) {
+		return nil, &Error{opToken.pos, fmt.Sprintf("%s can not be compared with %s", name, opToken.text)}
+	}
+
+	valueToken := p.next()
+	if valueToken.kind != tokenIdent && valueToken.kind != tokenString && valueToken.kind != tokenNumber {
+		return nil, &Error{valueToken.pos, "expected a value"}
+	}
+	value, err := f.value(valueToken)
+	if err != nil {
+		return nil, &Error{valueToken.pos, err.Error()}
+	}
+
+	return &condNode{field: f, op: opToken.text, value: value}, nil
+}
@@ -0,0 +1,159 @@
+package segment_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/segment"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var customTypes = map[string]string{
+	"Score":      "integer",
+	"Test Field": "string",
+	"Tags":       "array",
+	"Joined":     "datetime",
+	"Price":      "currency",
+	"Member":     "boolean",
+}
+
+var contact = entities.Contact{
+	ContactID:      "person_AP2-9cbf7ac0-eec5-11e4-87bc-6df09cc44d23",
+	Email:          "StGr@gmail.com",
+	Status:         "Lead",
+	MailingCountry: "US",
+	Lists:          []string{"contactlist_9EAF39E4-9AEC-4134-964A"},
+	CreatTime:      time.Date(2015, 4, 29, 23, 15, 25, 0, time.UTC),
+	Custom: map[string]interface{}{
+		"Score":  64,
+		"Tags":   []interface{}{"vip", 1.0},
+		"Joined": time.Date(2020, 5, 1, 8, 30, 0, 0, time.UTC),
+	},
+}
+
+// TestParse
+func TestParse(t *testing.T) {
+	Convey("TestParse", t, func() {
+		Convey("Normal Case1: the whole expression is pushed down to mongo", func() {
+			filter, err := segment.Parse(`Status=Lead AND MailingCountry=US AND custom.Score>50`, customTypes)
+			So(err, ShouldEqual, nil)
+			So(filter.Exact(), ShouldBeTrue)
+			So(filter.Query(), ShouldResemble, bson.M{"$and": []interface{}{
+				bson.M{"status": "Lead"},
+				bson.M{"mailingcountry": "US"},
+				bson.M{"custom.Score": bson.M{"$gt": 50}},
+			}})
+			So(filter.Match(&contact), ShouldBeTrue)
+		})
+
+		Convey("Normal Case2: or, not, parentheses and the typed values", func() {
+			filter, err := segment.Parse(`(lists = contactlist_9EAF39E4-9AEC-4134-964A OR unsubscribed = true) and not custom."Test Field" = "a b" and created_at >= 2015-01-01`, customTypes)
+			So(err, ShouldEqual, nil)
+			So(filter.Exact(), ShouldBeTrue)
+			So(filter.Query(), ShouldResemble, bson.M{"$and": []interface{}{
+				bson.M{"$or": []interface{}{bson.M{"lists": "contactlist_9EAF39E4-9AEC-4134-964A"}, bson.M{"unsubscribed": true}}},
+				bson.M{"$nor": []interface{}{bson.M{"custom.Test Field": "a b"}}},
+				bson.M{"created_at": bson.M{"$gte": time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)}},
+			}})
+		})
+
+		Convey("Normal Case3: the encrypted fields are matched in the process", func() {
+			filter, err := segment.Parse(`Status=Lead AND Email=StGr@gmail.com`, customTypes)
+			So(err, ShouldEqual, nil)
+			So(filter.Exact(), ShouldBeFalse)
+			So(filter.Query(), ShouldResemble, bson.M{"status": "Lead"})
+			So(filter.Match(&contact), ShouldBeTrue)
+
+			other := contact
+			other.Email = "other@gmail.com"
+			So(filter.Match(&other), ShouldBeFalse)
+		})
+
+		Convey("Normal Case4: an or with an encrypted field keeps a superset query and matches the whole or", func() {
+			filter, err := segment.Parse(`Status=Customer OR (contact_id!=x AND custom.Score<=64)`, customTypes)
+			So(err, ShouldEqual, nil)
+			So(filter.Exact(), ShouldBeFalse)
+			So(filter.Query(), ShouldResemble, bson.M{"$or": []interface{}{bson.M{"status": "Customer"}, bson.M{"custom.Score": bson.M{"$lte": 64}}}})
+			So(filter.Match(&contact), ShouldBeTrue)
+		})
+
+		Convey("Normal Case5: nothing is pushed down if a branch of the or can not be", func() {
+			filter, err := segment.Parse(`Status=Customer OR NOT Email=StGr@gmail.com`, customTypes)
+			So(err, ShouldEqual, nil)
+			So(filter.Query(), ShouldResemble, bson.M{})
+			So(filter.Match(&contact), ShouldBeFalse)
+		})
+
+		Convey("Normal Case6: the fields of the encryption policy are matched in the process too", func() {
+			filter, err := segment.Parse(`MailingCountry=US AND custom.Score>50`, customTypes, "MailingCountry", "custom.Score")
+			So(err, ShouldEqual, nil)
+			So(filter.Exact(), ShouldBeFalse)
+			So(filter.Query(), ShouldResemble, bson.M{})
+			So(filter.Match(&contact), ShouldBeTrue)
+		})
+
+		Convey("Normal Case7: custom.* encrypts every custom field", func() {
+			filter, err := segment.Parse(`Status=Lead AND custom.Score>50`, customTypes, "custom.*")
+			So(err, ShouldEqual, nil)
+			So(filter.Exact(), ShouldBeFalse)
+			So(filter.Query(), ShouldResemble, bson.M{"status": "Lead"})
+			So(filter.Match(&contact), ShouldBeTrue)
+		})
+
+		Convey("AbNormal Case: the errors tell the position", func() {
+			cases := map[string]int{
+				``:                             0,
+				`Status=`:                      7,
+				`Status Lead`:                  7,
+				`Nope=1`:                       0,
+				`custom.Nope=1`:                0,
+				`custom.Score=abc`:             13,
+				`custom.Price=1`:               12,
+				`unsubscribed>true`:            12,
+				`(Status=Lead`:                 12,
+				`Status=Lead)`:                 11,
+				`Status="Lead`:                 7,
+				`Status=Lead AND`:              15,
+				`created_at<yesterday`:         11,
+				`Status=Lead OR custom.Tags>1`: 26,
+			}
+			for expr, pos := range cases {
+				_, err := segment.Parse(expr, customTypes)
+				exprErr, ok := err.(*segment.Error)
+				So(ok, ShouldBeTrue)
+				So(exprErr.Pos, ShouldEqual, pos)
+			}
+		})
+	})
+}
+
+// TestMatch the in-process matching agrees with the mongo semantics, an or with an encrypted field is matched as a whole
+func TestMatch(t *testing.T) {
+	Convey("TestMatch", t, func() {
+		cases := map[string]bool{
+			`custom.Score>=64`:                           true,
+			`custom.Score>64`:                            false,
+			`custom.Score!=1`:                            true,
+			`custom.Tags=vip`:                            true,
+			`custom.Tags=1`:                              true,
+			`custom.Tags!=vip`:                           false,
+			`custom.Member!=true`:                        true,
+			`custom.Member=false`:                        false,
+			`custom."Test Field"<"z"`:                    false,
+			`custom.Joined>"2020-05-01T10:00:00+02:00"`:  true,
+			`lists=contactlist_9EAF39E4-9AEC-4134-964A`:  true,
+			`lists!=contactlist_9EAF39E4-9AEC-4134-964A`: false,
+			`created_at<"2015-04-30 00:00:00"`:           true,
+			`(Email>StGr AND Status=Lead)`:               true,
+			`NOT (Status=Lead AND MailingCountry=US)`:    false,
+		}
+		for expr, want := range cases {
+			filter, err := segment.Parse(`Email=nobody@gmail.com OR `+expr, customTypes)
+			So(err, ShouldEqual, nil)
+			So(filter.Exact(), ShouldBeFalse)
+			So(filter.Match(&contact), ShouldEqual, want)
+		}
+	})
+}
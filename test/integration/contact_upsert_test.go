@@ -0,0 +1,142 @@
+// +build integration
+
+package integration_test
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/STreeChin/contactapi/pkg/config"
+	"github.com/STreeChin/contactapi/pkg/entities"
+	"github.com/STreeChin/contactapi/pkg/log"
+	"github.com/STreeChin/contactapi/pkg/repository"
+	"github.com/STreeChin/contactapi/pkg/route/middleware/crpt"
+	"github.com/STreeChin/contactapi/test/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//run against a live mongo: CONTACTAPI_MONGO_URL=mongodb://localhost:27017 go test -tags integration ./test/integration
+func mongoURL() string {
+	if url := os.Getenv("CONTACTAPI_MONGO_URL"); url != "" {
+		return url
+	}
+	return "mongodb://localhost:27017"
+}
+
+//TestUpsertOneContactConcurrent
+func TestUpsertOneContactConcurrent(t *testing.T) {
+	Convey("TestUpsertOneContactConcurrent", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCfg.EXPECT().GetDBConfig().Return(&config.DatabaseConfig{URL: mongoURL()}).AnyTimes()
+		indexKey := []byte("integration-blind-index-key-0123456789")
+		So(os.Setenv("CONTACTAPI_TEST_DATAKEY", base64.StdEncoding.EncodeToString([]byte("integration-data-key-0123456789a"))), ShouldEqual, nil)
+		cryptoCfg := &config.CryptoConfig{IndexKey: base64.StdEncoding.EncodeToString(indexKey), DataKey: "env:CONTACTAPI_TEST_DATAKEY"}
+		mockCfg.EXPECT().GetCryptoConfig().Return(cryptoCfg).AnyTimes()
+		rep := repository.NewRepository(logger, mockCfg)
+
+		client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(mongoURL()))
+		So(err, ShouldEqual, nil)
+		defer func() { _ = client.Disconnect(context.TODO()) }()
+
+		account := "person_AP2-" + uuid.New().String()
+		email := "upsert-" + uuid.New().String() + "@test.com"
+		accountIndex := crpt.BlindIndex(indexKey, "account", account)
+		emailIndex := crpt.BlindIndex(indexKey, "email", email)
+		defer func() { _ = rep.DeleteOneContact(account, "email", email) }()
+
+		Convey("Normal Case1: concurrent upserts of the same email store one contact", func() {
+			const upserts = 20
+			var wg sync.WaitGroup
+			stored := make([]*entities.Contact, upserts)
+			errs := make([]error, upserts)
+			for i := 0; i < upserts; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					contact := entities.Contact{ContactID: "person_AP2-" + uuid.New().String(), Email: email}
+					stored[i], errs[i] = rep.UpsertOneContact(account, &contact)
+				}(i)
+			}
+			wg.Wait()
+
+			created := 0
+			for i := 0; i < upserts; i++ {
+				So(errs[i], ShouldEqual, nil)
+				if stored[i] == nil {
+					created++
+				}
+			}
+			So(created, ShouldEqual, 1)
+
+			count, err := client.Database("contact").Collection("contactInfo").
+				CountDocuments(context.TODO(), bson.M{"account_bidx": accountIndex, "email_bidx": emailIndex})
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 1)
+		})
+	})
+}
+
+//TestUpsertContactsDataKeys
+func TestUpsertContactsDataKeys(t *testing.T) {
+	Convey("TestUpsertContactsDataKeys", t, func() {
+		ctl := gomock.NewController(t)
+		defer ctl.Finish()
+		mockCfg := mocks.NewMockConfig(ctl)
+		logger := log.NewLogger(mockCfg)
+		mockCfg.EXPECT().GetDBConfig().Return(&config.DatabaseConfig{URL: mongoURL()}).AnyTimes()
+		indexKey := []byte("integration-blind-index-key-0123456789")
+		So(os.Setenv("CONTACTAPI_TEST_DATAKEY", base64.StdEncoding.EncodeToString([]byte("integration-data-key-0123456789a"))), ShouldEqual, nil)
+		cryptoCfg := &config.CryptoConfig{IndexKey: base64.StdEncoding.EncodeToString(indexKey), DataKey: "env:CONTACTAPI_TEST_DATAKEY"}
+		mockCfg.EXPECT().GetCryptoConfig().Return(cryptoCfg).AnyTimes()
+		rep := repository.NewRepository(logger, mockCfg)
+
+		client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(mongoURL()))
+		So(err, ShouldEqual, nil)
+		defer func() { _ = client.Disconnect(context.TODO()) }()
+
+		account := "person_AP2-" + uuid.New().String()
+		accountIndex := crpt.BlindIndex(indexKey, "account", account)
+		contacts := make([]*entities.Contact, 0, 3)
+		for i := 0; i < 3; i++ {
+			contact := &entities.Contact{ContactID: "person_AP2-" + uuid.New().String(), Email: "bulk-" + uuid.New().String() + "@test.com", FirstName: "Bulk"}
+			contacts = append(contacts, contact)
+			defer func() { _ = rep.DeleteOneContact(account, "email", contact.Email) }()
+		}
+		defer func() {
+			_, _ = client.Database("keystore").Collection("dataKey").DeleteMany(context.TODO(), bson.M{"account_bidx": accountIndex})
+		}()
+
+		Convey("Normal Case1: the contacts of a batch get one data key each, twice upserted", func() {
+			_, err := rep.UpsertOneContact(account, contacts[0])
+			So(err, ShouldEqual, nil)
+			for i := 0; i < 2; i++ {
+				results, err := rep.UpsertContacts(account, contacts)
+				So(err, ShouldEqual, nil)
+				So(len(results), ShouldEqual, len(contacts))
+			}
+
+			for _, contact := range contacts {
+				emailIndex := crpt.BlindIndex(indexKey, "email", contact.Email)
+				count, err := client.Database("keystore").Collection("dataKey").
+					CountDocuments(context.TODO(), bson.M{"account_bidx": accountIndex, "email_bidx": emailIndex})
+				So(err, ShouldEqual, nil)
+				So(count, ShouldEqual, 1)
+
+				stored, err := rep.GetOneContact(account, "email", contact.Email)
+				So(err, ShouldEqual, nil)
+				So(stored.FirstName, ShouldEqual, "Bulk")
+			}
+		})
+	})
+}
@@ -0,0 +1,126 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./pkg/route/middleware/authApiKey.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	entities "github.com/STreeChin/contactapi/pkg/entities"
+	gomock "github.com/golang/mock/gomock"
+	http "net/http"
+	reflect "reflect"
+	time "time"
+)
+
+// MockAuth is a mock of Auth interface
+type MockAuth struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuthMockRecorder
+}
+
+// MockAuthMockRecorder is the mock recorder for MockAuth
+type MockAuthMockRecorder struct {
+	mock *MockAuth
+}
+
+// NewMockAuth creates a new mock instance
+func NewMockAuth(ctrl *gomock.Controller) *MockAuth {
+	mock := &MockAuth{ctrl: ctrl}
+	mock.recorder = &MockAuthMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAuth) EXPECT() *MockAuthMockRecorder {
+	return m.recorder
+}
+
+// Middleware mocks base method
+func (m *MockAuth) Middleware(next http.Handler) http.Handler {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Middleware", next)
+	ret0, _ := ret[0].(http.Handler)
+	return ret0
+}
+
+// Middleware indicates an expected call of Middleware
+func (mr *MockAuthMockRecorder) Middleware(next interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Middleware", reflect.TypeOf((*MockAuth)(nil).Middleware), next)
+}
+
+// WatchRevocations mocks base method
+func (m *MockAuth) WatchRevocations() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "WatchRevocations")
+}
+
+// WatchRevocations indicates an expected call of WatchRevocations
+func (mr *MockAuthMockRecorder) WatchRevocations() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchRevocations", reflect.TypeOf((*MockAuth)(nil).WatchRevocations))
+}
+
+// MockPrincipalCache is a mock of PrincipalCache interface
+type MockPrincipalCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockPrincipalCacheMockRecorder
+}
+
+// MockPrincipalCacheMockRecorder is the mock recorder for MockPrincipalCache
+type MockPrincipalCacheMockRecorder struct {
+	mock *MockPrincipalCache
+}
+
+// NewMockPrincipalCache creates a new mock instance
+func NewMockPrincipalCache(ctrl *gomock.Controller) *MockPrincipalCache {
+	mock := &MockPrincipalCache{ctrl: ctrl}
+	mock.recorder = &MockPrincipalCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockPrincipalCache) EXPECT() *MockPrincipalCacheMockRecorder {
+	return m.recorder
+}
+
+// GetPrincipal mocks base method
+func (m *MockPrincipalCache) GetPrincipal(digest string) (*entities.Principal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPrincipal", digest)
+	ret0, _ := ret[0].(*entities.Principal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPrincipal indicates an expected call of GetPrincipal
+func (mr *MockPrincipalCacheMockRecorder) GetPrincipal(digest interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPrincipal", reflect.TypeOf((*MockPrincipalCache)(nil).GetPrincipal), digest)
+}
+
+// SetPrincipal mocks base method
+func (m *MockPrincipalCache) SetPrincipal(digest string, principal *entities.Principal, ttl time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPrincipal", digest, principal, ttl)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPrincipal indicates an expected call of SetPrincipal
+func (mr *MockPrincipalCacheMockRecorder) SetPrincipal(digest, principal, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPrincipal", reflect.TypeOf((*MockPrincipalCache)(nil).SetPrincipal), digest, principal, ttl)
+}
+
+// SubscribePrincipals mocks base method
+func (m *MockPrincipalCache) SubscribePrincipals(onRevoke func(string)) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SubscribePrincipals", onRevoke)
+}
+
+// SubscribePrincipals indicates an expected call of SubscribePrincipals
+func (mr *MockPrincipalCacheMockRecorder) SubscribePrincipals(onRevoke interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribePrincipals", reflect.TypeOf((*MockPrincipalCache)(nil).SubscribePrincipals), onRevoke)
+}
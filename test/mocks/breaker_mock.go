@@ -0,0 +1,60 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./pkg/route/middleware/breaker.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockBreaker is a mock of Breaker interface
+type MockBreaker struct {
+	ctrl     *gomock.Controller
+	recorder *MockBreakerMockRecorder
+}
+
+// MockBreakerMockRecorder is the mock recorder for MockBreaker
+type MockBreakerMockRecorder struct {
+	mock *MockBreaker
+}
+
+// NewMockBreaker creates a new mock instance
+func NewMockBreaker(ctrl *gomock.Controller) *MockBreaker {
+	mock := &MockBreaker{ctrl: ctrl}
+	mock.recorder = &MockBreakerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockBreaker) EXPECT() *MockBreakerMockRecorder {
+	return m.recorder
+}
+
+// Allow mocks base method
+func (m *MockBreaker) Allow() (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Allow")
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Allow indicates an expected call of Allow
+func (mr *MockBreakerMockRecorder) Allow() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Allow", reflect.TypeOf((*MockBreaker)(nil).Allow))
+}
+
+// Done mocks base method
+func (m *MockBreaker) Done(generation uint64, err error) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Done", generation, err)
+}
+
+// Done indicates an expected call of Done
+func (mr *MockBreakerMockRecorder) Done(generation, err interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Done", reflect.TypeOf((*MockBreaker)(nil).Done), generation, err)
+}
@@ -60,3 +60,59 @@ func (mr *MockConfigMockRecorder) GetDBConfig() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDBConfig", reflect.TypeOf((*MockConfig)(nil).GetDBConfig))
 }
+
+// GetContactConfig mocks base method
+func (m *MockConfig) GetContactConfig() *config.ContactConfig {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContactConfig")
+	ret0, _ := ret[0].(*config.ContactConfig)
+	return ret0
+}
+
+// GetContactConfig indicates an expected call of GetContactConfig
+func (mr *MockConfigMockRecorder) GetContactConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContactConfig", reflect.TypeOf((*MockConfig)(nil).GetContactConfig))
+}
+
+// GetCryptoConfig mocks base method
+func (m *MockConfig) GetCryptoConfig() *config.CryptoConfig {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCryptoConfig")
+	ret0, _ := ret[0].(*config.CryptoConfig)
+	return ret0
+}
+
+// GetCryptoConfig indicates an expected call of GetCryptoConfig
+func (mr *MockConfigMockRecorder) GetCryptoConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCryptoConfig", reflect.TypeOf((*MockConfig)(nil).GetCryptoConfig))
+}
+
+// GetRateLimitConfig mocks base method
+func (m *MockConfig) GetRateLimitConfig() *config.RateLimitConfig {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRateLimitConfig")
+	ret0, _ := ret[0].(*config.RateLimitConfig)
+	return ret0
+}
+
+// GetRateLimitConfig indicates an expected call of GetRateLimitConfig
+func (mr *MockConfigMockRecorder) GetRateLimitConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRateLimitConfig", reflect.TypeOf((*MockConfig)(nil).GetRateLimitConfig))
+}
+
+// GetUsageConfig mocks base method
+func (m *MockConfig) GetUsageConfig() *config.UsageConfig {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsageConfig")
+	ret0, _ := ret[0].(*config.UsageConfig)
+	return ret0
+}
+
+// GetUsageConfig indicates an expected call of GetUsageConfig
+func (mr *MockConfigMockRecorder) GetUsageConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsageConfig", reflect.TypeOf((*MockConfig)(nil).GetUsageConfig))
+}
@@ -8,6 +8,7 @@ import (
 	entities "github.com/STreeChin/contactapi/pkg/entities"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
+	time "time"
 )
 
 // MockContactService is a mock of ContactService interface
@@ -34,31 +35,413 @@ func (m *MockContactService) EXPECT() *MockContactServiceMockRecorder {
 }
 
 // AddOrUpdateContact mocks base method
-func (m *MockContactService) AddOrUpdateContact(contact *entities.Contact) (string, error) {
+func (m *MockContactService) AddOrUpdateContact(account string, contact *entities.Contact) (string, bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddOrUpdateContact", contact)
+	ret := m.ctrl.Call(m, "AddOrUpdateContact", account, contact)
 	ret0, _ := ret[0].(string)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // AddOrUpdateContact indicates an expected call of AddOrUpdateContact
-func (mr *MockContactServiceMockRecorder) AddOrUpdateContact(contact interface{}) *gomock.Call {
+func (mr *MockContactServiceMockRecorder) AddOrUpdateContact(account, contact interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrUpdateContact", reflect.TypeOf((*MockContactService)(nil).AddOrUpdateContact), contact)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrUpdateContact", reflect.TypeOf((*MockContactService)(nil).AddOrUpdateContact), account, contact)
 }
 
 // GetOneContact mocks base method
-func (m *MockContactService) GetOneContact(key, value string) (*entities.Contact, error) {
+func (m *MockContactService) GetOneContact(account, key, value string) (*entities.Contact, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOneContact", key, value)
+	ret := m.ctrl.Call(m, "GetOneContact", account, key, value)
 	ret0, _ := ret[0].(*entities.Contact)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOneContact indicates an expected call of GetOneContact
-func (mr *MockContactServiceMockRecorder) GetOneContact(key, value interface{}) *gomock.Call {
+func (mr *MockContactServiceMockRecorder) GetOneContact(account, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOneContact", reflect.TypeOf((*MockContactService)(nil).GetOneContact), account, key, value)
+}
+
+// GetAllContacts mocks base method
+func (m *MockContactService) GetAllContacts(account, bookmark string) (*entities.ContactPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllContacts", account, bookmark)
+	ret0, _ := ret[0].(*entities.ContactPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllContacts indicates an expected call of GetAllContacts
+func (mr *MockContactServiceMockRecorder) GetAllContacts(account, bookmark interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllContacts", reflect.TypeOf((*MockContactService)(nil).GetAllContacts), account, bookmark)
+}
+
+// CheckBulkLimit mocks base method
+func (m *MockContactService) CheckBulkLimit(count int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckBulkLimit", count)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckBulkLimit indicates an expected call of CheckBulkLimit
+func (mr *MockContactServiceMockRecorder) CheckBulkLimit(count interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckBulkLimit", reflect.TypeOf((*MockContactService)(nil).CheckBulkLimit), count)
+}
+
+// AddBulkContacts mocks base method
+func (m *MockContactService) AddBulkContacts(account string, contacts []*entities.Contact) ([]entities.BulkContactResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddBulkContacts", account, contacts)
+	ret0, _ := ret[0].([]entities.BulkContactResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddBulkContacts indicates an expected call of AddBulkContacts
+func (mr *MockContactServiceMockRecorder) AddBulkContacts(account, contacts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBulkContacts", reflect.TypeOf((*MockContactService)(nil).AddBulkContacts), account, contacts)
+}
+
+// DeleteContact mocks base method
+func (m *MockContactService) DeleteContact(account, key, value string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteContact", account, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteContact indicates an expected call of DeleteContact
+func (mr *MockContactServiceMockRecorder) DeleteContact(account, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteContact", reflect.TypeOf((*MockContactService)(nil).DeleteContact), account, key, value)
+}
+
+// PatchContact mocks base method
+func (m *MockContactService) PatchContact(account, key, value string, patch map[string]interface{}, customTypes map[string]string) (*entities.Contact, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchContact", account, key, value, patch, customTypes)
+	ret0, _ := ret[0].(*entities.Contact)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PatchContact indicates an expected call of PatchContact
+func (mr *MockContactServiceMockRecorder) PatchContact(account, key, value, patch, customTypes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchContact", reflect.TypeOf((*MockContactService)(nil).PatchContact), account, key, value, patch, customTypes)
+}
+
+// UnsubscribeContact mocks base method
+func (m *MockContactService) UnsubscribeContact(account, key, value string, unsub *entities.Unsubscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnsubscribeContact", account, key, value, unsub)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnsubscribeContact indicates an expected call of UnsubscribeContact
+func (mr *MockContactServiceMockRecorder) UnsubscribeContact(account, key, value, unsub interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnsubscribeContact", reflect.TypeOf((*MockContactService)(nil).UnsubscribeContact), account, key, value, unsub)
+}
+
+// ResubscribeContact mocks base method
+func (m *MockContactService) ResubscribeContact(account, key, value string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResubscribeContact", account, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResubscribeContact indicates an expected call of ResubscribeContact
+func (mr *MockContactServiceMockRecorder) ResubscribeContact(account, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResubscribeContact", reflect.TypeOf((*MockContactService)(nil).ResubscribeContact), account, key, value)
+}
+
+// GetCustomFields mocks base method
+func (m *MockContactService) GetCustomFields(account string) ([]*entities.CustomField, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCustomFields", account)
+	ret0, _ := ret[0].([]*entities.CustomField)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCustomFields indicates an expected call of GetCustomFields
+func (mr *MockContactServiceMockRecorder) GetCustomFields(account interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCustomFields", reflect.TypeOf((*MockContactService)(nil).GetCustomFields), account)
+}
+
+// GetLists mocks base method
+func (m *MockContactService) GetLists(account string) ([]*entities.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLists", account)
+	ret0, _ := ret[0].([]*entities.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLists indicates an expected call of GetLists
+func (mr *MockContactServiceMockRecorder) GetLists(account interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLists", reflect.TypeOf((*MockContactService)(nil).GetLists), account)
+}
+
+// AddList mocks base method
+func (m *MockContactService) AddList(account, title string) (*entities.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddList", account, title)
+	ret0, _ := ret[0].(*entities.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddList indicates an expected call of AddList
+func (mr *MockContactServiceMockRecorder) AddList(account, title interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddList", reflect.TypeOf((*MockContactService)(nil).AddList), account, title)
+}
+
+// GetListContacts mocks base method
+func (m *MockContactService) GetListContacts(account, listID, bookmark string) (*entities.ContactPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetListContacts", account, listID, bookmark)
+	ret0, _ := ret[0].(*entities.ContactPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetListContacts indicates an expected call of GetListContacts
+func (mr *MockContactServiceMockRecorder) GetListContacts(account, listID, bookmark interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetListContacts", reflect.TypeOf((*MockContactService)(nil).GetListContacts), account, listID, bookmark)
+}
+
+// AddContactToList mocks base method
+func (m *MockContactService) AddContactToList(account, listID, key, value string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddContactToList", account, listID, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddContactToList indicates an expected call of AddContactToList
+func (mr *MockContactServiceMockRecorder) AddContactToList(account, listID, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddContactToList", reflect.TypeOf((*MockContactService)(nil).AddContactToList), account, listID, key, value)
+}
+
+// RemoveContactFromList mocks base method
+func (m *MockContactService) RemoveContactFromList(account, listID, key, value string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveContactFromList", account, listID, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveContactFromList indicates an expected call of RemoveContactFromList
+func (mr *MockContactServiceMockRecorder) RemoveContactFromList(account, listID, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveContactFromList", reflect.TypeOf((*MockContactService)(nil).RemoveContactFromList), account, listID, key, value)
+}
+
+// CheckContactInList mocks base method
+func (m *MockContactService) CheckContactInList(account, listID, key, value string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckContactInList", account, listID, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckContactInList indicates an expected call of CheckContactInList
+func (mr *MockContactServiceMockRecorder) CheckContactInList(account, listID, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckContactInList", reflect.TypeOf((*MockContactService)(nil).CheckContactInList), account, listID, key, value)
+}
+
+// GetSegments mocks base method
+func (m *MockContactService) GetSegments(account string) ([]*entities.Segment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSegments", account)
+	ret0, _ := ret[0].([]*entities.Segment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSegments indicates an expected call of GetSegments
+func (mr *MockContactServiceMockRecorder) GetSegments(account interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSegments", reflect.TypeOf((*MockContactService)(nil).GetSegments), account)
+}
+
+// AddSegment mocks base method
+func (m *MockContactService) AddSegment(account, name, expression string) (*entities.Segment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddSegment", account, name, expression)
+	ret0, _ := ret[0].(*entities.Segment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddSegment indicates an expected call of AddSegment
+func (mr *MockContactServiceMockRecorder) AddSegment(account, name, expression interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSegment", reflect.TypeOf((*MockContactService)(nil).AddSegment), account, name, expression)
+}
+
+// CountSegmentContacts mocks base method
+func (m *MockContactService) CountSegmentContacts(account, segmentID string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountSegmentContacts", account, segmentID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountSegmentContacts indicates an expected call of CountSegmentContacts
+func (mr *MockContactServiceMockRecorder) CountSegmentContacts(account, segmentID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountSegmentContacts", reflect.TypeOf((*MockContactService)(nil).CountSegmentContacts), account, segmentID)
+}
+
+// GetSegmentContacts mocks base method
+func (m *MockContactService) GetSegmentContacts(account, segmentID, bookmark string) (*entities.ContactPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSegmentContacts", account, segmentID, bookmark)
+	ret0, _ := ret[0].(*entities.ContactPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSegmentContacts indicates an expected call of GetSegmentContacts
+func (mr *MockContactServiceMockRecorder) GetSegmentContacts(account, segmentID, bookmark interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSegmentContacts", reflect.TypeOf((*MockContactService)(nil).GetSegmentContacts), account, segmentID, bookmark)
+}
+
+// GetReencryption mocks base method
+func (m *MockContactService) GetReencryption() (*entities.Reencryption, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReencryption")
+	ret0, _ := ret[0].(*entities.Reencryption)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReencryption indicates an expected call of GetReencryption
+func (mr *MockContactServiceMockRecorder) GetReencryption() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReencryption", reflect.TypeOf((*MockContactService)(nil).GetReencryption))
+}
+
+// EraseContact mocks base method
+func (m *MockContactService) EraseContact(account, key, value string) (*entities.Erasure, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EraseContact", account, key, value)
+	ret0, _ := ret[0].(*entities.Erasure)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EraseContact indicates an expected call of EraseContact
+func (mr *MockContactServiceMockRecorder) EraseContact(account, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EraseContact", reflect.TypeOf((*MockContactService)(nil).EraseContact), account, key, value)
+}
+
+// GetAPIKeys mocks base method
+func (m *MockContactService) GetAPIKeys(account string) ([]*entities.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", account)
+	ret0, _ := ret[0].([]*entities.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys
+func (mr *MockContactServiceMockRecorder) GetAPIKeys(account interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockContactService)(nil).GetAPIKeys), account)
+}
+
+// CreateAPIKey mocks base method
+func (m *MockContactService) CreateAPIKey(account, name string, scopes []string, rateLimits map[string]int, quotas map[string]int64, expiresAt time.Time) (*entities.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", account, name, scopes, rateLimits, quotas, expiresAt)
+	ret0, _ := ret[0].(*entities.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey
+func (mr *MockContactServiceMockRecorder) CreateAPIKey(account, name, scopes, rateLimits, quotas, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockContactService)(nil).CreateAPIKey), account, name, scopes, rateLimits, quotas, expiresAt)
+}
+
+// RotateAPIKey mocks base method
+func (m *MockContactService) RotateAPIKey(account, keyID string) (*entities.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateAPIKey", account, keyID)
+	ret0, _ := ret[0].(*entities.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RotateAPIKey indicates an expected call of RotateAPIKey
+func (mr *MockContactServiceMockRecorder) RotateAPIKey(account, keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateAPIKey", reflect.TypeOf((*MockContactService)(nil).RotateAPIKey), account, keyID)
+}
+
+// RevokeAPIKey mocks base method
+func (m *MockContactService) RevokeAPIKey(account, keyID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", account, keyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey
+func (mr *MockContactServiceMockRecorder) RevokeAPIKey(account, keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockContactService)(nil).RevokeAPIKey), account, keyID)
+}
+
+// GetUsageReport mocks base method
+func (m *MockContactService) GetUsageReport(account, keyID string, from, to time.Time) (*entities.UsageReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsageReport", account, keyID, from, to)
+	ret0, _ := ret[0].(*entities.UsageReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsageReport indicates an expected call of GetUsageReport
+func (mr *MockContactServiceMockRecorder) GetUsageReport(account, keyID, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsageReport", reflect.TypeOf((*MockContactService)(nil).GetUsageReport), account, keyID, from, to)
+}
+
+// GetHealth mocks base method
+func (m *MockContactService) GetHealth() *entities.Health {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHealth")
+	ret0, _ := ret[0].(*entities.Health)
+	return ret0
+}
+
+// GetHealth indicates an expected call of GetHealth
+func (mr *MockContactServiceMockRecorder) GetHealth() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOneContact", reflect.TypeOf((*MockContactService)(nil).GetOneContact), key, value)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHealth", reflect.TypeOf((*MockContactService)(nil).GetHealth))
 }
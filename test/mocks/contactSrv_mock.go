@@ -6,8 +6,10 @@ package mocks
 
 import (
 	entities "github.com/STreeChin/contactapi/pkg/entities"
+	segment "github.com/STreeChin/contactapi/pkg/segment"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
+	time "time"
 )
 
 // MockRepository is a mock of Repository interface
@@ -34,61 +36,517 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 }
 
 // GetOneContact mocks base method
-func (m *MockRepository) GetOneContact(key, value string) (*entities.Contact, error) {
+func (m *MockRepository) GetOneContact(account, key, value string) (*entities.Contact, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOneContact", key, value)
+	ret := m.ctrl.Call(m, "GetOneContact", account, key, value)
 	ret0, _ := ret[0].(*entities.Contact)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOneContact indicates an expected call of GetOneContact
-func (mr *MockRepositoryMockRecorder) GetOneContact(key, value interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetOneContact(account, key, value interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOneContact", reflect.TypeOf((*MockRepository)(nil).GetOneContact), key, value)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOneContact", reflect.TypeOf((*MockRepository)(nil).GetOneContact), account, key, value)
 }
 
-// InsertOneContact mocks base method
-func (m *MockRepository) InsertOneContact(contact *entities.Contact) error {
+// UpdateOneContact mocks base method
+func (m *MockRepository) UpdateOneContact(account string, contact *entities.Contact) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "InsertOneContact", contact)
+	ret := m.ctrl.Call(m, "UpdateOneContact", account, contact)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// InsertOneContact indicates an expected call of InsertOneContact
-func (mr *MockRepositoryMockRecorder) InsertOneContact(contact interface{}) *gomock.Call {
+// UpdateOneContact indicates an expected call of UpdateOneContact
+func (mr *MockRepositoryMockRecorder) UpdateOneContact(account, contact interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertOneContact", reflect.TypeOf((*MockRepository)(nil).InsertOneContact), contact)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOneContact", reflect.TypeOf((*MockRepository)(nil).UpdateOneContact), account, contact)
 }
 
-// UpdateOneContact mocks base method
-func (m *MockRepository) UpdateOneContact(contact *entities.Contact) error {
+// UpsertOneContact mocks base method
+func (m *MockRepository) UpsertOneContact(account string, contact *entities.Contact) (*entities.Contact, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateOneContact", contact)
+	ret := m.ctrl.Call(m, "UpsertOneContact", account, contact)
+	ret0, _ := ret[0].(*entities.Contact)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertOneContact indicates an expected call of UpsertOneContact
+func (mr *MockRepositoryMockRecorder) UpsertOneContact(account, contact interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertOneContact", reflect.TypeOf((*MockRepository)(nil).UpsertOneContact), account, contact)
+}
+
+// DeleteOneContact mocks base method
+func (m *MockRepository) DeleteOneContact(account, key, value string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOneContact", account, key, value)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateOneContact indicates an expected call of UpdateOneContact
-func (mr *MockRepositoryMockRecorder) UpdateOneContact(contact interface{}) *gomock.Call {
+// DeleteOneContact indicates an expected call of DeleteOneContact
+func (mr *MockRepositoryMockRecorder) DeleteOneContact(account, key, value interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOneContact", reflect.TypeOf((*MockRepository)(nil).UpdateOneContact), contact)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOneContact", reflect.TypeOf((*MockRepository)(nil).DeleteOneContact), account, key, value)
 }
 
-// GetContactIDByAPIKey mocks base method
-func (m *MockRepository) GetContactIDByAPIKey(apiKey string) (string, error) {
+// GetPrincipalByAPIKey mocks base method
+func (m *MockRepository) GetPrincipalByAPIKey(apiKey string) (*entities.Principal, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetContactIDByAPIKey", apiKey)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "GetPrincipalByAPIKey", apiKey)
+	ret0, _ := ret[0].(*entities.Principal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPrincipalByAPIKey indicates an expected call of GetPrincipalByAPIKey
+func (mr *MockRepositoryMockRecorder) GetPrincipalByAPIKey(apiKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPrincipalByAPIKey", reflect.TypeOf((*MockRepository)(nil).GetPrincipalByAPIKey), apiKey)
+}
+
+// GetContacts mocks base method
+func (m *MockRepository) GetContacts(account, bookmark string, limit int64) ([]*entities.Contact, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContacts", account, bookmark, limit)
+	ret0, _ := ret[0].([]*entities.Contact)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetContacts indicates an expected call of GetContacts
+func (mr *MockRepositoryMockRecorder) GetContacts(account, bookmark, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContacts", reflect.TypeOf((*MockRepository)(nil).GetContacts), account, bookmark, limit)
+}
+
+// CountContacts mocks base method
+func (m *MockRepository) CountContacts(account string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountContacts", account)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountContacts indicates an expected call of CountContacts
+func (mr *MockRepositoryMockRecorder) CountContacts(account interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountContacts", reflect.TypeOf((*MockRepository)(nil).CountContacts), account)
+}
+
+// SetUnsubscription mocks base method
+func (m *MockRepository) SetUnsubscription(account, email string, unsub *entities.Unsubscription, updateTime time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUnsubscription", account, email, unsub, updateTime)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUnsubscription indicates an expected call of SetUnsubscription
+func (mr *MockRepositoryMockRecorder) SetUnsubscription(account, email, unsub, updateTime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUnsubscription", reflect.TypeOf((*MockRepository)(nil).SetUnsubscription), account, email, unsub, updateTime)
+}
+
+// AddSuppression mocks base method
+func (m *MockRepository) AddSuppression(account, email string, unsub *entities.Unsubscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddSuppression", account, email, unsub)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddSuppression indicates an expected call of AddSuppression
+func (mr *MockRepositoryMockRecorder) AddSuppression(account, email, unsub interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSuppression", reflect.TypeOf((*MockRepository)(nil).AddSuppression), account, email, unsub)
+}
+
+// DelSuppression mocks base method
+func (m *MockRepository) DelSuppression(account, email string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DelSuppression", account, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DelSuppression indicates an expected call of DelSuppression
+func (mr *MockRepositoryMockRecorder) DelSuppression(account, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelSuppression", reflect.TypeOf((*MockRepository)(nil).DelSuppression), account, email)
+}
+
+// GetSuppressions mocks base method
+func (m *MockRepository) GetSuppressions(account string, emails []string) (map[string]*entities.Unsubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSuppressions", account, emails)
+	ret0, _ := ret[0].(map[string]*entities.Unsubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSuppressions indicates an expected call of GetSuppressions
+func (mr *MockRepositoryMockRecorder) GetSuppressions(account, emails interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSuppressions", reflect.TypeOf((*MockRepository)(nil).GetSuppressions), account, emails)
+}
+
+// UpsertContacts mocks base method
+func (m *MockRepository) UpsertContacts(account string, contacts []*entities.Contact) ([]entities.BulkContactResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertContacts", account, contacts)
+	ret0, _ := ret[0].([]entities.BulkContactResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertContacts indicates an expected call of UpsertContacts
+func (mr *MockRepositoryMockRecorder) UpsertContacts(account, contacts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertContacts", reflect.TypeOf((*MockRepository)(nil).UpsertContacts), account, contacts)
+}
+
+// GetCustomFields mocks base method
+func (m *MockRepository) GetCustomFields(account string) ([]*entities.CustomField, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCustomFields", account)
+	ret0, _ := ret[0].([]*entities.CustomField)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCustomFields indicates an expected call of GetCustomFields
+func (mr *MockRepositoryMockRecorder) GetCustomFields(account interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCustomFields", reflect.TypeOf((*MockRepository)(nil).GetCustomFields), account)
+}
+
+// RegisterCustomField mocks base method
+func (m *MockRepository) RegisterCustomField(account string, field *entities.CustomField) (*entities.CustomField, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterCustomField", account, field)
+	ret0, _ := ret[0].(*entities.CustomField)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegisterCustomField indicates an expected call of RegisterCustomField
+func (mr *MockRepositoryMockRecorder) RegisterCustomField(account, field interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterCustomField", reflect.TypeOf((*MockRepository)(nil).RegisterCustomField), account, field)
+}
+
+// InsertList mocks base method
+func (m *MockRepository) InsertList(account string, list *entities.List) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertList", account, list)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertList indicates an expected call of InsertList
+func (mr *MockRepositoryMockRecorder) InsertList(account, list interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertList", reflect.TypeOf((*MockRepository)(nil).InsertList), account, list)
+}
+
+// GetLists mocks base method
+func (m *MockRepository) GetLists(account string) ([]*entities.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLists", account)
+	ret0, _ := ret[0].([]*entities.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLists indicates an expected call of GetLists
+func (mr *MockRepositoryMockRecorder) GetLists(account interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLists", reflect.TypeOf((*MockRepository)(nil).GetLists), account)
+}
+
+// GetList mocks base method
+func (m *MockRepository) GetList(account, listID string) (*entities.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetList", account, listID)
+	ret0, _ := ret[0].(*entities.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetList indicates an expected call of GetList
+func (mr *MockRepositoryMockRecorder) GetList(account, listID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetList", reflect.TypeOf((*MockRepository)(nil).GetList), account, listID)
+}
+
+// AddToList mocks base method
+func (m *MockRepository) AddToList(account, email, listID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddToList", account, email, listID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddToList indicates an expected call of AddToList
+func (mr *MockRepositoryMockRecorder) AddToList(account, email, listID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddToList", reflect.TypeOf((*MockRepository)(nil).AddToList), account, email, listID)
+}
+
+// RemoveFromList mocks base method
+func (m *MockRepository) RemoveFromList(account, email, listID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveFromList", account, email, listID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveFromList indicates an expected call of RemoveFromList
+func (mr *MockRepositoryMockRecorder) RemoveFromList(account, email, listID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveFromList", reflect.TypeOf((*MockRepository)(nil).RemoveFromList), account, email, listID)
+}
+
+// GetListContacts mocks base method
+func (m *MockRepository) GetListContacts(account, listID, bookmark string, limit int64) ([]*entities.Contact, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetListContacts", account, listID, bookmark, limit)
+	ret0, _ := ret[0].([]*entities.Contact)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetListContacts indicates an expected call of GetListContacts
+func (mr *MockRepositoryMockRecorder) GetListContacts(account, listID, bookmark, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetListContacts", reflect.TypeOf((*MockRepository)(nil).GetListContacts), account, listID, bookmark, limit)
+}
+
+// CountListContacts mocks base method
+func (m *MockRepository) CountListContacts(account, listID string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountListContacts", account, listID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountListContacts indicates an expected call of CountListContacts
+func (mr *MockRepositoryMockRecorder) CountListContacts(account, listID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountListContacts", reflect.TypeOf((*MockRepository)(nil).CountListContacts), account, listID)
+}
+
+// InsertSegment mocks base method
+func (m *MockRepository) InsertSegment(account string, seg *entities.Segment) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertSegment", account, seg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertSegment indicates an expected call of InsertSegment
+func (mr *MockRepositoryMockRecorder) InsertSegment(account, seg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertSegment", reflect.TypeOf((*MockRepository)(nil).InsertSegment), account, seg)
+}
+
+// GetSegments mocks base method
+func (m *MockRepository) GetSegments(account string) ([]*entities.Segment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSegments", account)
+	ret0, _ := ret[0].([]*entities.Segment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSegments indicates an expected call of GetSegments
+func (mr *MockRepositoryMockRecorder) GetSegments(account interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSegments", reflect.TypeOf((*MockRepository)(nil).GetSegments), account)
+}
+
+// GetSegment mocks base method
+func (m *MockRepository) GetSegment(account, segmentID string) (*entities.Segment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSegment", account, segmentID)
+	ret0, _ := ret[0].(*entities.Segment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSegment indicates an expected call of GetSegment
+func (mr *MockRepositoryMockRecorder) GetSegment(account, segmentID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSegment", reflect.TypeOf((*MockRepository)(nil).GetSegment), account, segmentID)
+}
+
+// GetSegmentContacts mocks base method
+func (m *MockRepository) GetSegmentContacts(account string, filter *segment.Filter, bookmark string, limit int64) ([]*entities.Contact, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSegmentContacts", account, filter, bookmark, limit)
+	ret0, _ := ret[0].([]*entities.Contact)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetSegmentContacts indicates an expected call of GetSegmentContacts
+func (mr *MockRepositoryMockRecorder) GetSegmentContacts(account, filter, bookmark, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSegmentContacts", reflect.TypeOf((*MockRepository)(nil).GetSegmentContacts), account, filter, bookmark, limit)
+}
+
+// CountSegmentContacts mocks base method
+func (m *MockRepository) CountSegmentContacts(account string, filter *segment.Filter) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountSegmentContacts", account, filter)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountSegmentContacts indicates an expected call of CountSegmentContacts
+func (mr *MockRepositoryMockRecorder) CountSegmentContacts(account, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountSegmentContacts", reflect.TypeOf((*MockRepository)(nil).CountSegmentContacts), account, filter)
+}
+
+// GetReencryption mocks base method
+func (m *MockRepository) GetReencryption() (*entities.Reencryption, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReencryption")
+	ret0, _ := ret[0].(*entities.Reencryption)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReencryption indicates an expected call of GetReencryption
+func (mr *MockRepositoryMockRecorder) GetReencryption() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReencryption", reflect.TypeOf((*MockRepository)(nil).GetReencryption))
+}
+
+// EraseContact mocks base method
+func (m *MockRepository) EraseContact(account string, contact *entities.Contact, erasure *entities.Erasure) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EraseContact", account, contact, erasure)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EraseContact indicates an expected call of EraseContact
+func (mr *MockRepositoryMockRecorder) EraseContact(account, contact, erasure interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EraseContact", reflect.TypeOf((*MockRepository)(nil).EraseContact), account, contact, erasure)
+}
+
+// InsertAPIKey mocks base method
+func (m *MockRepository) InsertAPIKey(account string, apiKey *entities.APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertAPIKey", account, apiKey)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertAPIKey indicates an expected call of InsertAPIKey
+func (mr *MockRepositoryMockRecorder) InsertAPIKey(account, apiKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertAPIKey", reflect.TypeOf((*MockRepository)(nil).InsertAPIKey), account, apiKey)
+}
+
+// GetAPIKeys mocks base method
+func (m *MockRepository) GetAPIKeys(account string) ([]*entities.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", account)
+	ret0, _ := ret[0].([]*entities.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys
+func (mr *MockRepositoryMockRecorder) GetAPIKeys(account interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockRepository)(nil).GetAPIKeys), account)
+}
+
+// GetAPIKey mocks base method
+func (m *MockRepository) GetAPIKey(account, keyID string) (*entities.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKey", account, keyID)
+	ret0, _ := ret[0].(*entities.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKey indicates an expected call of GetAPIKey
+func (mr *MockRepositoryMockRecorder) GetAPIKey(account, keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKey", reflect.TypeOf((*MockRepository)(nil).GetAPIKey), account, keyID)
+}
+
+// RevokeAPIKey mocks base method
+func (m *MockRepository) RevokeAPIKey(account, keyID string, revokeTime time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", account, keyID, revokeTime)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey
+func (mr *MockRepositoryMockRecorder) RevokeAPIKey(account, keyID, revokeTime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockRepository)(nil).RevokeAPIKey), account, keyID, revokeTime)
+}
+
+// ExpireAPIKey mocks base method
+func (m *MockRepository) ExpireAPIKey(account, keyID string, expireTime time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireAPIKey", account, keyID, expireTime)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExpireAPIKey indicates an expected call of ExpireAPIKey
+func (mr *MockRepositoryMockRecorder) ExpireAPIKey(account, keyID, expireTime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireAPIKey", reflect.TypeOf((*MockRepository)(nil).ExpireAPIKey), account, keyID, expireTime)
+}
+
+// UpsertUsages mocks base method
+func (m *MockRepository) UpsertUsages(usages []*entities.Usage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertUsages", usages)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpsertUsages indicates an expected call of UpsertUsages
+func (mr *MockRepositoryMockRecorder) UpsertUsages(usages interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertUsages", reflect.TypeOf((*MockRepository)(nil).UpsertUsages), usages)
+}
+
+// GetUsages mocks base method
+func (m *MockRepository) GetUsages(account, keyID, from, to string) ([]*entities.Usage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsages", account, keyID, from, to)
+	ret0, _ := ret[0].([]*entities.Usage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetContactIDByAPIKey indicates an expected call of GetContactIDByAPIKey
-func (mr *MockRepositoryMockRecorder) GetContactIDByAPIKey(apiKey interface{}) *gomock.Call {
+// GetUsages indicates an expected call of GetUsages
+func (mr *MockRepositoryMockRecorder) GetUsages(account, keyID, from, to interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContactIDByAPIKey", reflect.TypeOf((*MockRepository)(nil).GetContactIDByAPIKey), apiKey)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsages", reflect.TypeOf((*MockRepository)(nil).GetUsages), account, keyID, from, to)
 }
 
 // MockCache is a mock of Cache interface
@@ -115,73 +573,121 @@ func (m *MockCache) EXPECT() *MockCacheMockRecorder {
 }
 
 // GetEmailByContactID mocks base method
-func (m *MockCache) GetEmailByContactID(key string) (string, error) {
+func (m *MockCache) GetEmailByContactID(account, key string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetEmailByContactID", key)
+	ret := m.ctrl.Call(m, "GetEmailByContactID", account, key)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetEmailByContactID indicates an expected call of GetEmailByContactID
-func (mr *MockCacheMockRecorder) GetEmailByContactID(key interface{}) *gomock.Call {
+func (mr *MockCacheMockRecorder) GetEmailByContactID(account, key interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmailByContactID", reflect.TypeOf((*MockCache)(nil).GetEmailByContactID), key)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmailByContactID", reflect.TypeOf((*MockCache)(nil).GetEmailByContactID), account, key)
 }
 
 // SetEmailByContactID mocks base method
-func (m *MockCache) SetEmailByContactID(key, value string) error {
+func (m *MockCache) SetEmailByContactID(account, key, value string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetEmailByContactID", key, value)
+	ret := m.ctrl.Call(m, "SetEmailByContactID", account, key, value)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SetEmailByContactID indicates an expected call of SetEmailByContactID
-func (mr *MockCacheMockRecorder) SetEmailByContactID(key, value interface{}) *gomock.Call {
+func (mr *MockCacheMockRecorder) SetEmailByContactID(account, key, value interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetEmailByContactID", reflect.TypeOf((*MockCache)(nil).SetEmailByContactID), key, value)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetEmailByContactID", reflect.TypeOf((*MockCache)(nil).SetEmailByContactID), account, key, value)
 }
 
 // GetOneContact mocks base method
-func (m *MockCache) GetOneContact(value string) (*entities.Contact, error) {
+func (m *MockCache) GetOneContact(account, value string) (*entities.Contact, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOneContact", value)
+	ret := m.ctrl.Call(m, "GetOneContact", account, value)
 	ret0, _ := ret[0].(*entities.Contact)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOneContact indicates an expected call of GetOneContact
-func (mr *MockCacheMockRecorder) GetOneContact(value interface{}) *gomock.Call {
+func (mr *MockCacheMockRecorder) GetOneContact(account, value interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOneContact", reflect.TypeOf((*MockCache)(nil).GetOneContact), value)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOneContact", reflect.TypeOf((*MockCache)(nil).GetOneContact), account, value)
 }
 
 // SetOneContact mocks base method
-func (m *MockCache) SetOneContact(value string, contact *entities.Contact) error {
+func (m *MockCache) SetOneContact(account, value string, contact *entities.Contact) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetOneContact", value, contact)
+	ret := m.ctrl.Call(m, "SetOneContact", account, value, contact)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SetOneContact indicates an expected call of SetOneContact
-func (mr *MockCacheMockRecorder) SetOneContact(value, contact interface{}) *gomock.Call {
+func (mr *MockCacheMockRecorder) SetOneContact(account, value, contact interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetOneContact", reflect.TypeOf((*MockCache)(nil).SetOneContact), account, value, contact)
+}
+
+// SetNotFound mocks base method
+func (m *MockCache) SetNotFound(account, value string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetNotFound", account, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetNotFound indicates an expected call of SetNotFound
+func (mr *MockCacheMockRecorder) SetNotFound(account, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetNotFound", reflect.TypeOf((*MockCache)(nil).SetNotFound), account, value)
+}
+
+// DelContacts mocks base method
+func (m *MockCache) DelContacts(account string, values ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{account}
+	for _, a := range values {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DelContacts", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DelContacts indicates an expected call of DelContacts
+func (mr *MockCacheMockRecorder) DelContacts(account interface{}, values ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetOneContact", reflect.TypeOf((*MockCache)(nil).SetOneContact), value, contact)
+	varargs := append([]interface{}{account}, values...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelContacts", reflect.TypeOf((*MockCache)(nil).DelContacts), varargs...)
 }
 
-// DelOneContact mocks base method
-func (m *MockCache) DelOneContact(value string) error {
+// DelPrincipals mocks base method
+func (m *MockCache) DelPrincipals(keyID string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DelOneContact", value)
+	ret := m.ctrl.Call(m, "DelPrincipals", keyID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// DelOneContact indicates an expected call of DelOneContact
-func (mr *MockCacheMockRecorder) DelOneContact(value interface{}) *gomock.Call {
+// DelPrincipals indicates an expected call of DelPrincipals
+func (mr *MockCacheMockRecorder) DelPrincipals(keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelPrincipals", reflect.TypeOf((*MockCache)(nil).DelPrincipals), keyID)
+}
+
+// GetUsages mocks base method
+func (m *MockCache) GetUsages(day string) ([]*entities.Usage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsages", day)
+	ret0, _ := ret[0].([]*entities.Usage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsages indicates an expected call of GetUsages
+func (mr *MockCacheMockRecorder) GetUsages(day interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelOneContact", reflect.TypeOf((*MockCache)(nil).DelOneContact), value)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsages", reflect.TypeOf((*MockCache)(nil).GetUsages), day)
 }
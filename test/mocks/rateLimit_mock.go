@@ -0,0 +1,88 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./pkg/route/middleware/rateLimit.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	entities "github.com/STreeChin/contactapi/pkg/entities"
+	gomock "github.com/golang/mock/gomock"
+	http "net/http"
+	reflect "reflect"
+	time "time"
+)
+
+// MockRateLimiter is a mock of RateLimiter interface
+type MockRateLimiter struct {
+	ctrl     *gomock.Controller
+	recorder *MockRateLimiterMockRecorder
+}
+
+// MockRateLimiterMockRecorder is the mock recorder for MockRateLimiter
+type MockRateLimiterMockRecorder struct {
+	mock *MockRateLimiter
+}
+
+// NewMockRateLimiter creates a new mock instance
+func NewMockRateLimiter(ctrl *gomock.Controller) *MockRateLimiter {
+	mock := &MockRateLimiter{ctrl: ctrl}
+	mock.recorder = &MockRateLimiterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockRateLimiter) EXPECT() *MockRateLimiterMockRecorder {
+	return m.recorder
+}
+
+// Limit mocks base method
+func (m *MockRateLimiter) Limit(next http.Handler, class string) http.Handler {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Limit", next, class)
+	ret0, _ := ret[0].(http.Handler)
+	return ret0
+}
+
+// Limit indicates an expected call of Limit
+func (mr *MockRateLimiterMockRecorder) Limit(next, class interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Limit", reflect.TypeOf((*MockRateLimiter)(nil).Limit), next, class)
+}
+
+// MockTokenBuckets is a mock of TokenBuckets interface
+type MockTokenBuckets struct {
+	ctrl     *gomock.Controller
+	recorder *MockTokenBucketsMockRecorder
+}
+
+// MockTokenBucketsMockRecorder is the mock recorder for MockTokenBuckets
+type MockTokenBucketsMockRecorder struct {
+	mock *MockTokenBuckets
+}
+
+// NewMockTokenBuckets creates a new mock instance
+func NewMockTokenBuckets(ctrl *gomock.Controller) *MockTokenBuckets {
+	mock := &MockTokenBuckets{ctrl: ctrl}
+	mock.recorder = &MockTokenBucketsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockTokenBuckets) EXPECT() *MockTokenBucketsMockRecorder {
+	return m.recorder
+}
+
+// Take mocks base method
+func (m *MockTokenBuckets) Take(key string, limit int, period time.Duration) (*entities.RateLimit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Take", key, limit, period)
+	ret0, _ := ret[0].(*entities.RateLimit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Take indicates an expected call of Take
+func (mr *MockTokenBucketsMockRecorder) Take(key, limit, period interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Take", reflect.TypeOf((*MockTokenBuckets)(nil).Take), key, limit, period)
+}
@@ -5,8 +5,10 @@
 package mocks
 
 import (
+	database "github.com/STreeChin/contactapi/pkg/database"
 	gomock "github.com/golang/mock/gomock"
 	bson "go.mongodb.org/mongo-driver/bson"
+	mongo "go.mongodb.org/mongo-driver/mongo"
 	reflect "reflect"
 )
 
@@ -34,18 +36,18 @@ func (m *MockDBHandler) EXPECT() *MockDBHandlerMockRecorder {
 }
 
 // FindOne mocks base method
-func (m *MockDBHandler) FindOne(db, coll, key string, value interface{}) (bson.M, error) {
+func (m *MockDBHandler) FindOne(db, coll string, filter interface{}) (bson.M, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindOne", db, coll, key, value)
+	ret := m.ctrl.Call(m, "FindOne", db, coll, filter)
 	ret0, _ := ret[0].(bson.M)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindOne indicates an expected call of FindOne
-func (mr *MockDBHandlerMockRecorder) FindOne(db, coll, key, value interface{}) *gomock.Call {
+func (mr *MockDBHandlerMockRecorder) FindOne(db, coll, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOne", reflect.TypeOf((*MockDBHandler)(nil).FindOne), db, coll, key, value)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOne", reflect.TypeOf((*MockDBHandler)(nil).FindOne), db, coll, filter)
 }
 
 // InsertOne mocks base method
@@ -63,29 +65,171 @@ func (mr *MockDBHandlerMockRecorder) InsertOne(db, coll, value interface{}) *gom
 }
 
 // UpdateOne mocks base method
-func (m *MockDBHandler) UpdateOne(db, coll, key string, value, update interface{}) error {
+func (m *MockDBHandler) UpdateOne(db, coll string, filter, update interface{}) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateOne", db, coll, key, value, update)
+	ret := m.ctrl.Call(m, "UpdateOne", db, coll, filter, update)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateOne indicates an expected call of UpdateOne
-func (mr *MockDBHandlerMockRecorder) UpdateOne(db, coll, key, value, update interface{}) *gomock.Call {
+func (mr *MockDBHandlerMockRecorder) UpdateOne(db, coll, filter, update interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOne", reflect.TypeOf((*MockDBHandler)(nil).UpdateOne), db, coll, key, value, update)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOne", reflect.TypeOf((*MockDBHandler)(nil).UpdateOne), db, coll, filter, update)
 }
 
 // DeleteOne mocks base method
-func (m *MockDBHandler) DeleteOne(db, coll, key string, value interface{}) error {
+func (m *MockDBHandler) DeleteOne(db, coll string, filter interface{}) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteOne", db, coll, key, value)
+	ret := m.ctrl.Call(m, "DeleteOne", db, coll, filter)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteOne indicates an expected call of DeleteOne
-func (mr *MockDBHandlerMockRecorder) DeleteOne(db, coll, key, value interface{}) *gomock.Call {
+func (mr *MockDBHandlerMockRecorder) DeleteOne(db, coll, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOne", reflect.TypeOf((*MockDBHandler)(nil).DeleteOne), db, coll, key, value)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOne", reflect.TypeOf((*MockDBHandler)(nil).DeleteOne), db, coll, filter)
+}
+
+// SetFields mocks base method
+func (m *MockDBHandler) SetFields(db, coll string, filter, fields interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetFields", db, coll, filter, fields)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetFields indicates an expected call of SetFields
+func (mr *MockDBHandlerMockRecorder) SetFields(db, coll, filter, fields interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetFields", reflect.TypeOf((*MockDBHandler)(nil).SetFields), db, coll, filter, fields)
+}
+
+// Find mocks base method
+func (m *MockDBHandler) Find(db, coll string, filter interface{}, limit int64) ([]bson.M, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Find", db, coll, filter, limit)
+	ret0, _ := ret[0].([]bson.M)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Find indicates an expected call of Find
+func (mr *MockDBHandlerMockRecorder) Find(db, coll, filter, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockDBHandler)(nil).Find), db, coll, filter, limit)
+}
+
+// Count mocks base method
+func (m *MockDBHandler) Count(db, coll string, filter interface{}) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count", db, coll, filter)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Count indicates an expected call of Count
+func (mr *MockDBHandlerMockRecorder) Count(db, coll, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockDBHandler)(nil).Count), db, coll, filter)
+}
+
+// BulkWrite mocks base method
+func (m *MockDBHandler) BulkWrite(db, coll string, models []mongo.WriteModel) ([]database.BulkItemResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BulkWrite", db, coll, models)
+	ret0, _ := ret[0].([]database.BulkItemResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BulkWrite indicates an expected call of BulkWrite
+func (mr *MockDBHandlerMockRecorder) BulkWrite(db, coll, models interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BulkWrite", reflect.TypeOf((*MockDBHandler)(nil).BulkWrite), db, coll, models)
+}
+
+// FindOneAndUpsert mocks base method
+func (m *MockDBHandler) FindOneAndUpsert(db, coll string, filter, update interface{}) (bson.M, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOneAndUpsert", db, coll, filter, update)
+	ret0, _ := ret[0].(bson.M)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOneAndUpsert indicates an expected call of FindOneAndUpsert
+func (mr *MockDBHandlerMockRecorder) FindOneAndUpsert(db, coll, filter, update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOneAndUpsert", reflect.TypeOf((*MockDBHandler)(nil).FindOneAndUpsert), db, coll, filter, update)
+}
+
+// UpdateMatched mocks base method
+func (m *MockDBHandler) UpdateMatched(db, coll string, filter, update interface{}) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateMatched", db, coll, filter, update)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateMatched indicates an expected call of UpdateMatched
+func (mr *MockDBHandlerMockRecorder) UpdateMatched(db, coll, filter, update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMatched", reflect.TypeOf((*MockDBHandler)(nil).UpdateMatched), db, coll, filter, update)
+}
+
+// UpdateMany mocks base method
+func (m *MockDBHandler) UpdateMany(db, coll string, filter, update interface{}) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateMany", db, coll, filter, update)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateMany indicates an expected call of UpdateMany
+func (mr *MockDBHandlerMockRecorder) UpdateMany(db, coll, filter, update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMany", reflect.TypeOf((*MockDBHandler)(nil).UpdateMany), db, coll, filter, update)
+}
+
+// EnsureUniqueIndex mocks base method
+func (m *MockDBHandler) EnsureUniqueIndex(db, coll string, keys ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{db, coll}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "EnsureUniqueIndex", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnsureUniqueIndex indicates an expected call of EnsureUniqueIndex
+func (mr *MockDBHandlerMockRecorder) EnsureUniqueIndex(db, coll interface{}, keys ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{db, coll}, keys...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsureUniqueIndex", reflect.TypeOf((*MockDBHandler)(nil).EnsureUniqueIndex), varargs...)
+}
+
+// DropIndex mocks base method
+func (m *MockDBHandler) DropIndex(db, coll string, keys ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{db, coll}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DropIndex", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DropIndex indicates an expected call of DropIndex
+func (mr *MockDBHandlerMockRecorder) DropIndex(db, coll interface{}, keys ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{db, coll}, keys...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DropIndex", reflect.TypeOf((*MockDBHandler)(nil).DropIndex), varargs...)
 }
@@ -0,0 +1,101 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./pkg/route/middleware/usage.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	entities "github.com/STreeChin/contactapi/pkg/entities"
+	gomock "github.com/golang/mock/gomock"
+	http "net/http"
+	reflect "reflect"
+)
+
+// MockMeter is a mock of Meter interface
+type MockMeter struct {
+	ctrl     *gomock.Controller
+	recorder *MockMeterMockRecorder
+}
+
+// MockMeterMockRecorder is the mock recorder for MockMeter
+type MockMeterMockRecorder struct {
+	mock *MockMeter
+}
+
+// NewMockMeter creates a new mock instance
+func NewMockMeter(ctrl *gomock.Controller) *MockMeter {
+	mock := &MockMeter{ctrl: ctrl}
+	mock.recorder = &MockMeterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockMeter) EXPECT() *MockMeterMockRecorder {
+	return m.recorder
+}
+
+// Meter mocks base method
+func (m *MockMeter) Meter(next http.Handler, class string) http.Handler {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Meter", next, class)
+	ret0, _ := ret[0].(http.Handler)
+	return ret0
+}
+
+// Meter indicates an expected call of Meter
+func (mr *MockMeterMockRecorder) Meter(next, class interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Meter", reflect.TypeOf((*MockMeter)(nil).Meter), next, class)
+}
+
+// MockUsageCounters is a mock of UsageCounters interface
+type MockUsageCounters struct {
+	ctrl     *gomock.Controller
+	recorder *MockUsageCountersMockRecorder
+}
+
+// MockUsageCountersMockRecorder is the mock recorder for MockUsageCounters
+type MockUsageCountersMockRecorder struct {
+	mock *MockUsageCounters
+}
+
+// NewMockUsageCounters creates a new mock instance
+func NewMockUsageCounters(ctrl *gomock.Controller) *MockUsageCounters {
+	mock := &MockUsageCounters{ctrl: ctrl}
+	mock.recorder = &MockUsageCountersMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockUsageCounters) EXPECT() *MockUsageCountersMockRecorder {
+	return m.recorder
+}
+
+// IncrUsage mocks base method
+func (m *MockUsageCounters) IncrUsage(day string, usage *entities.Usage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrUsage", day, usage)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrUsage indicates an expected call of IncrUsage
+func (mr *MockUsageCountersMockRecorder) IncrUsage(day, usage interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrUsage", reflect.TypeOf((*MockUsageCounters)(nil).IncrUsage), day, usage)
+}
+
+// GetUsage mocks base method
+func (m *MockUsageCounters) GetUsage(day, account, keyID string) (*entities.Usage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsage", day, account, keyID)
+	ret0, _ := ret[0].(*entities.Usage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsage indicates an expected call of GetUsage
+func (mr *MockUsageCountersMockRecorder) GetUsage(day, account, keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsage", reflect.TypeOf((*MockUsageCounters)(nil).GetUsage), day, account, keyID)
+}